@@ -7,6 +7,7 @@ import (
 
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/middleware"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
 	routes "github.com/AgusMolinaCode/DCA_Api.git/internal/server"
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
 	"github.com/gin-contrib/cors"
@@ -17,12 +18,19 @@ import (
 // Instancia global del actualizador de precios
 var priceUpdater *services.PriceUpdater
 
+// Instancia global del programador de planes DCA
+var dcaScheduler *services.DCAScheduler
+
 func main() {
 	// Cargar variables de entorno
 	if err := godotenv.Load(); err != nil {
 		log.Printf("No se pudo cargar el archivo .env: %v", err)
 	}
 
+	// Formato JSON y validación de los valores decimales
+	services.ConfigureDecimalJSON()
+	middleware.RegisterDecimalValidation()
+
 	// Crear el router de Gin
 	router := gin.Default()
 
@@ -45,10 +53,14 @@ func main() {
 	}
 	defer database.DB.Close()
 
+	// Cargar precios históricos para backtesting (opcional)
+	repository.SeedHistoricalPricesFromCSV(database.DB)
+
 
 	// Iniciar el servicio de actualización de precios (snapshots cada minuto)
 	log.Println("Iniciando servicio de actualización de precios...")
 	priceUpdater = services.NewPriceUpdater(time.Minute) // El intervalo se ignora internamente
+	priceUpdater.SetRuleEvaluator(services.NewRuleEvaluator(repository.NewBolsaRepository(database.DB)))
 	priceUpdater.Start()
 	defer func() {
 		log.Println("Deteniendo servicio de actualización de precios...")
@@ -56,6 +68,14 @@ func main() {
 	}()
 	log.Println("Servicio de actualización de precios iniciado correctamente")
 
+	// Iniciar el programador de planes DCA junto al actualizador de precios
+	dcaScheduler = services.NewDCAScheduler(repository.NewDCARepository(database.DB))
+	dcaScheduler.Start()
+	defer func() {
+		log.Println("Deteniendo programador de planes DCA...")
+		dcaScheduler.Stop()
+	}()
+
 	// Hacer disponible el actualizador de precios para los handlers
 	middleware.SetPriceUpdater(priceUpdater)
 
@@ -6,13 +6,17 @@ require (
 	github.com/gin-contrib/cors v1.7.0
 	github.com/gin-gonic/gin v1.10.0
 	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
 	github.com/svix/svix-webhooks v1.68.0
 	golang.org/x/crypto v0.33.0
 )
 
-require github.com/google/uuid v1.6.0 // indirect
+require (
+	github.com/go-playground/validator/v10 v10.25.0
+	github.com/shopspring/decimal v1.4.0
+)
 
 require (
 	github.com/bytedance/sonic v1.12.8 // indirect
@@ -24,7 +28,6 @@ require (
 	github.com/go-jose/go-jose/v3 v3.0.3 // indirect
 	github.com/go-playground/locales v0.14.1 // indirect
 	github.com/go-playground/universal-translator v0.18.1 // indirect
-	github.com/go-playground/validator/v10 v10.25.0 // indirect
 	github.com/goccy/go-json v0.10.5 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.9 // indirect
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"fmt"
 	"log"
 )
 
@@ -10,8 +11,8 @@ func RunMigrations() error {
 
 	// Migración para añadir campos max_value y min_value a la tabla investment_snapshots
 	addMaxMinValueColumnsSQL := `
-	ALTER TABLE investment_snapshots ADD COLUMN max_value REAL DEFAULT 0;
-	ALTER TABLE investment_snapshots ADD COLUMN min_value REAL DEFAULT 0;
+	ALTER TABLE investment_snapshots ADD COLUMN max_value NUMERIC DEFAULT 0;
+	ALTER TABLE investment_snapshots ADD COLUMN min_value NUMERIC DEFAULT 0;
 	`
 
 	_, err := DB.Exec(addMaxMinValueColumnsSQL)
@@ -23,5 +24,123 @@ func RunMigrations() error {
 		log.Println("Columnas max_value y min_value añadidas correctamente")
 	}
 
+	// Migración para el motor de reglas: rearmado automático, enfriamiento, último disparo,
+	// dirección de la comparación y máximo observado para las reglas de caída
+	addTriggerRuleColumnsSQL := []string{
+		`ALTER TABLE trigger_rules ADD COLUMN IF NOT EXISTS rearm INTEGER DEFAULT 0`,
+		`ALTER TABLE trigger_rules ADD COLUMN IF NOT EXISTS cooldown_minutes INTEGER DEFAULT 0`,
+		`ALTER TABLE trigger_rules ADD COLUMN IF NOT EXISTS last_triggered_at TIMESTAMP`,
+		`ALTER TABLE trigger_rules ADD COLUMN IF NOT EXISTS direction TEXT DEFAULT 'above'`,
+		`ALTER TABLE trigger_rules ADD COLUMN IF NOT EXISTS peak_value NUMERIC DEFAULT 0`,
+	}
+	for _, statement := range addTriggerRuleColumnsSQL {
+		if _, err := DB.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	// Migración para registrar las comisiones de cada operación
+	addFeeColumnsSQL := []string{
+		`ALTER TABLE crypto_transactions ADD COLUMN IF NOT EXISTS fee NUMERIC DEFAULT 0`,
+		`ALTER TABLE crypto_transactions ADD COLUMN IF NOT EXISTS fee_currency TEXT DEFAULT ''`,
+		`ALTER TABLE crypto_transactions ADD COLUMN IF NOT EXISTS fee_usd NUMERIC DEFAULT 0`,
+	}
+	for _, statement := range addFeeColumnsSQL {
+		if _, err := DB.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	// Migración para enlazar las patas de los intercambios entre criptomonedas
+	addSwapColumnsSQL := []string{
+		`ALTER TABLE crypto_transactions ADD COLUMN IF NOT EXISTS swap_id TEXT`,
+		`CREATE INDEX IF NOT EXISTS idx_crypto_transactions_swap_id ON crypto_transactions(swap_id)`,
+	}
+	for _, statement := range addSwapColumnsSQL {
+		if _, err := DB.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	// Migración para guardar cantidades y valores en dólares como decimales exactos.
+	// Solo se cambian las columnas que todavía no son NUMERIC: ALTER COLUMN TYPE bloquea la tabla
+	// aunque el tipo no cambie, y las migraciones se ejecutan en cada arranque.
+	numericColumns := []struct{ table, column string }{
+		{"crypto_transactions", "amount"},
+		{"crypto_transactions", "purchase_price"},
+		{"crypto_transactions", "total"},
+		{"crypto_transactions", "usdt_received"},
+		{"crypto_transactions", "fee"},
+		{"crypto_transactions", "fee_usd"},
+		{"assets_in_bolsa", "amount"},
+		{"assets_in_bolsa", "purchase_price"},
+		{"assets_in_bolsa", "total"},
+		{"dca_plans", "amount_usd"},
+		{"dca_executions", "price"},
+		{"dca_executions", "amount"},
+		{"dca_executions", "total"},
+		{"bolsas", "goal"},
+		{"trigger_rules", "target_value"},
+		{"trigger_rules", "peak_value"},
+		{"trigger_events", "target_value"},
+		{"trigger_events", "observed_value"},
+		{"investment_snapshots", "total_value"},
+		{"investment_snapshots", "total_invested"},
+		{"investment_snapshots", "profit"},
+		{"investment_snapshots", "profit_percentage"},
+		{"investment_snapshots", "max_value"},
+		{"investment_snapshots", "min_value"},
+		{"historical_prices", "close"},
+	}
+	for _, numeric := range numericColumns {
+		var dataType string
+		err := DB.QueryRow(
+			`SELECT data_type FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2`,
+			numeric.table, numeric.column,
+		).Scan(&dataType)
+		if err != nil {
+			return fmt.Errorf("error al leer el tipo de %s.%s: %v", numeric.table, numeric.column, err)
+		}
+		if dataType == "numeric" {
+			continue
+		}
+		if _, err := DB.Exec(fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN %s TYPE NUMERIC`, numeric.table, numeric.column)); err != nil {
+			return err
+		}
+	}
+
+	// Migración para la moneda fiat en la que cada usuario ve sus valoraciones
+	addBaseCurrencyColumnSQL := `ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS base_currency TEXT NOT NULL DEFAULT 'USD'`
+	if _, err := DB.Exec(addBaseCurrencyColumnSQL); err != nil {
+		return err
+	}
+
+	// Migración para la clase de activo (crypto, stock, etf o cash) de transacciones, activos de bolsas
+	// y planes DCA
+	addAssetClassColumnsSQL := []string{
+		`ALTER TABLE crypto_transactions ADD COLUMN IF NOT EXISTS asset_class TEXT NOT NULL DEFAULT 'crypto'`,
+		`ALTER TABLE assets_in_bolsa ADD COLUMN IF NOT EXISTS asset_class TEXT NOT NULL DEFAULT 'crypto'`,
+		`ALTER TABLE dca_plans ADD COLUMN IF NOT EXISTS asset_class TEXT NOT NULL DEFAULT 'crypto'`,
+	}
+	for _, statement := range addAssetClassColumnsSQL {
+		if _, err := DB.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	// Migración para la cuenta donde se guardan los activos de cada transacción
+	// y la cuenta de destino de las transferencias
+	addAccountColumnsSQL := []string{
+		`ALTER TABLE crypto_transactions ADD COLUMN IF NOT EXISTS account_id TEXT`,
+		`ALTER TABLE crypto_transactions ADD COLUMN IF NOT EXISTS to_account_id TEXT`,
+		`CREATE INDEX IF NOT EXISTS idx_crypto_transactions_account_id ON crypto_transactions(account_id)`,
+	}
+	for _, statement := range addAccountColumnsSQL {
+		if _, err := DB.Exec(statement); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
@@ -59,14 +59,14 @@ func InitDB() error {
 		user_id TEXT NOT NULL,
 		crypto_name TEXT NOT NULL,
 		ticker TEXT NOT NULL,
-		amount REAL NOT NULL,
-		purchase_price REAL NOT NULL,
-		total REAL NOT NULL,
+		amount NUMERIC NOT NULL,
+		purchase_price NUMERIC NOT NULL,
+		total NUMERIC NOT NULL,
 		date TIMESTAMP NOT NULL,
 		note TEXT,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		type TEXT DEFAULT 'compra',
-		usdt_received REAL DEFAULT 0,
+		usdt_received NUMERIC DEFAULT 0,
 		image_url TEXT,
 		FOREIGN KEY(user_id) REFERENCES users(id)
 	);`
@@ -83,7 +83,7 @@ func InitDB() error {
 		user_id TEXT NOT NULL,
 		name TEXT NOT NULL,
 		description TEXT,
-		goal REAL,
+		goal NUMERIC,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY(user_id) REFERENCES users(id)
@@ -101,9 +101,9 @@ func InitDB() error {
 		bolsa_id TEXT NOT NULL,
 		crypto_name TEXT NOT NULL,
 		ticker TEXT NOT NULL,
-		amount REAL NOT NULL,
-		purchase_price REAL NOT NULL,
-		total REAL NOT NULL,
+		amount NUMERIC NOT NULL,
+		purchase_price NUMERIC NOT NULL,
+		total NUMERIC NOT NULL,
 		image_url TEXT,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -122,7 +122,7 @@ func InitDB() error {
 		bolsa_id TEXT NOT NULL,
 		type TEXT NOT NULL,
 		ticker TEXT,
-		target_value REAL NOT NULL,
+		target_value NUMERIC NOT NULL,
 		active INTEGER DEFAULT 1,
 		triggered INTEGER DEFAULT 0,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -135,6 +135,25 @@ func InitDB() error {
 		return err
 	}
 
+	// Crear tabla de eventos de disparo de reglas
+	createTriggerEventsTableSQL := `
+	CREATE TABLE IF NOT EXISTS trigger_events (
+		id TEXT PRIMARY KEY,
+		rule_id TEXT NOT NULL,
+		bolsa_id TEXT NOT NULL,
+		type TEXT NOT NULL,
+		ticker TEXT,
+		target_value NUMERIC NOT NULL,
+		observed_value NUMERIC NOT NULL,
+		triggered_at TIMESTAMP NOT NULL,
+		FOREIGN KEY(rule_id) REFERENCES trigger_rules(id) ON DELETE CASCADE
+	);`
+
+	_, err = DB.Exec(createTriggerEventsTableSQL)
+	if err != nil {
+		return err
+	}
+
 	// Crear tabla de etiquetas para bolsas
 	createBolsaTagsTableSQL := `
 	CREATE TABLE IF NOT EXISTS bolsa_tags (
@@ -157,10 +176,10 @@ func InitDB() error {
 		id TEXT PRIMARY KEY,
 		user_id TEXT NOT NULL,
 		date TIMESTAMP NOT NULL,
-		total_value REAL NOT NULL,
-		total_invested REAL NOT NULL,
-		profit REAL NOT NULL,
-		profit_percentage REAL NOT NULL,
+		total_value NUMERIC NOT NULL,
+		total_invested NUMERIC NOT NULL,
+		profit NUMERIC NOT NULL,
+		profit_percentage NUMERIC NOT NULL,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
 	);`
@@ -180,6 +199,161 @@ func InitDB() error {
 		return err
 	}
 
+	// Crear tabla de planes DCA
+	createDCAPlansTableSQL := `
+	CREATE TABLE IF NOT EXISTS dca_plans (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		crypto_name TEXT NOT NULL,
+		ticker TEXT NOT NULL,
+		amount_usd NUMERIC NOT NULL,
+		cadence TEXT NOT NULL,
+		cron_expr TEXT,
+		start_date TIMESTAMP NOT NULL,
+		end_date TIMESTAMP,
+		bolsa_id TEXT,
+		active INTEGER DEFAULT 1,
+		next_run_at TIMESTAMP,
+		last_run_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	_, err = DB.Exec(createDCAPlansTableSQL)
+	if err != nil {
+		return err
+	}
+
+	// Crear índice para encontrar rápidamente los planes pendientes de ejecución
+	createDCAPlansIndexSQL := `
+	CREATE INDEX IF NOT EXISTS idx_dca_plans_next_run 
+	ON dca_plans(active, next_run_at);`
+
+	_, err = DB.Exec(createDCAPlansIndexSQL)
+	if err != nil {
+		return err
+	}
+
+	// Crear tabla de ejecuciones de planes DCA
+	createDCAExecutionsTableSQL := `
+	CREATE TABLE IF NOT EXISTS dca_executions (
+		id TEXT PRIMARY KEY,
+		plan_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		scheduled_at TIMESTAMP NOT NULL,
+		executed_at TIMESTAMP NOT NULL,
+		status TEXT NOT NULL,
+		price NUMERIC DEFAULT 0,
+		amount NUMERIC DEFAULT 0,
+		total NUMERIC DEFAULT 0,
+		transaction_id TEXT,
+		error TEXT,
+		FOREIGN KEY(plan_id) REFERENCES dca_plans(id) ON DELETE CASCADE
+	);`
+
+	_, err = DB.Exec(createDCAExecutionsTableSQL)
+	if err != nil {
+		return err
+	}
+
+	// Crear tabla de precios históricos diarios (usada por el backtesting)
+	createHistoricalPricesTableSQL := `
+	CREATE TABLE IF NOT EXISTS historical_prices (
+		ticker TEXT NOT NULL,
+		date DATE NOT NULL,
+		close NUMERIC NOT NULL,
+		PRIMARY KEY(ticker, date)
+	);`
+
+	_, err = DB.Exec(createHistoricalPricesTableSQL)
+	if err != nil {
+		return err
+	}
+
+	// Crear tabla de preferencias de usuario
+	createUserSettingsTableSQL := `
+	CREATE TABLE IF NOT EXISTS user_settings (
+		user_id TEXT PRIMARY KEY,
+		cost_basis_method TEXT NOT NULL DEFAULT 'average',
+		base_currency TEXT NOT NULL DEFAULT 'USD',
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	_, err = DB.Exec(createUserSettingsTableSQL)
+	if err != nil {
+		return err
+	}
+
+	// Crear tabla de claves de idempotencia con la respuesta guardada de cada petición
+	createIdempotencyKeysTableSQL := `
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		user_id TEXT NOT NULL,
+		idempotency_key TEXT NOT NULL,
+		request_hash TEXT NOT NULL,
+		status_code INTEGER DEFAULT 0,
+		content_type TEXT,
+		response_body TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY(user_id, idempotency_key)
+	);`
+
+	_, err = DB.Exec(createIdempotencyKeysTableSQL)
+	if err != nil {
+		return err
+	}
+
+	// Crear tabla de cuentas: exchanges y billeteras donde se guardan los activos
+	createAccountsTableSQL := `
+	CREATE TABLE IF NOT EXISTS accounts (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		provider TEXT DEFAULT '',
+		note TEXT DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	_, err = DB.Exec(createAccountsTableSQL)
+	if err != nil {
+		return err
+	}
+
+	// Crear tabla de saldos informados por los exchanges y billeteras, para conciliarlos con las transacciones
+	createReportedBalancesTableSQL := `
+	CREATE TABLE IF NOT EXISTS reported_balances (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		account_id TEXT NOT NULL,
+		ticker TEXT NOT NULL,
+		amount NUMERIC NOT NULL,
+		reported_at TIMESTAMP NOT NULL,
+		source TEXT NOT NULL,
+		note TEXT DEFAULT '',
+		adjustment_id TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY(account_id) REFERENCES accounts(id) ON DELETE CASCADE
+	);`
+
+	_, err = DB.Exec(createReportedBalancesTableSQL)
+	if err != nil {
+		return err
+	}
+
+	// Crear índice para obtener rápidamente el último saldo informado de cada ticker de una cuenta
+	createReportedBalancesIndexSQL := `
+	CREATE INDEX IF NOT EXISTS idx_reported_balances_account_ticker
+	ON reported_balances(account_id, ticker, reported_at);`
+
+	_, err = DB.Exec(createReportedBalancesIndexSQL)
+	if err != nil {
+		return err
+	}
+
 	// Ejecutar migraciones para actualizar el esquema
 	err = RunMigrations()
 	return err
@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// getOwnedAccount obtiene una cuenta verificando que pertenezca al usuario.
+// Si hay un error ya escribe la respuesta y devuelve nil.
+func getOwnedAccount(c *gin.Context, accountRepo *repository.AccountRepository, userID string) *models.Account {
+	account, err := accountRepo.GetAccountByID(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cuenta no encontrada"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al obtener la cuenta: " + err.Error()})
+		return nil
+	}
+	if account.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No tienes permiso para acceder a esta cuenta"})
+		return nil
+	}
+	return account
+}
+
+// CreateAccount crea una nueva cuenta de exchange o billetera
+func CreateAccount(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var account models.Account
+	if err := c.ShouldBindJSON(&account); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accountType, err := services.NormalizeAccountType(account.Type)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	account.Name = strings.TrimSpace(account.Name)
+	if account.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El nombre de la cuenta es requerido"})
+		return
+	}
+
+	account.ID = models.GenerateUUID()
+	account.UserID = userID
+	account.Type = accountType
+	now := time.Now()
+	account.CreatedAt = now
+	account.UpdatedAt = now
+
+	accountRepo := repository.NewAccountRepository(database.DB)
+	if err := accountRepo.CreateAccount(account); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al crear la cuenta: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Cuenta creada exitosamente", "account": account})
+}
+
+// GetAccounts obtiene todas las cuentas del usuario
+func GetAccounts(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	accountRepo := repository.NewAccountRepository(database.DB)
+	accounts, err := accountRepo.GetAccountsByUserID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al obtener las cuentas: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
+
+// GetAccount obtiene una cuenta
+func GetAccount(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	account := getOwnedAccount(c, repository.NewAccountRepository(database.DB), userID)
+	if account == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"account": account})
+}
+
+// UpdateAccount actualiza el nombre, el tipo, el proveedor o la nota de una cuenta
+func UpdateAccount(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	accountRepo := repository.NewAccountRepository(database.DB)
+	account := getOwnedAccount(c, accountRepo, userID)
+	if account == nil {
+		return
+	}
+
+	var request struct {
+		Name     *string `json:"name,omitempty"`
+		Type     *string `json:"type,omitempty"`
+		Provider *string `json:"provider,omitempty"`
+		Note     *string `json:"note,omitempty"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if request.Name != nil {
+		account.Name = strings.TrimSpace(*request.Name)
+		if account.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "El nombre de la cuenta es requerido"})
+			return
+		}
+	}
+	if request.Type != nil {
+		accountType, err := services.NormalizeAccountType(*request.Type)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		account.Type = accountType
+	}
+	if request.Provider != nil {
+		account.Provider = *request.Provider
+	}
+	if request.Note != nil {
+		account.Note = *request.Note
+	}
+
+	account.UpdatedAt = time.Now()
+	if err := accountRepo.UpdateAccount(*account); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al actualizar la cuenta: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cuenta actualizada exitosamente", "account": account})
+}
+
+// DeleteAccount elimina una cuenta. No se permite si alguna transacción la usa.
+func DeleteAccount(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	accountRepo := repository.NewAccountRepository(database.DB)
+	account := getOwnedAccount(c, accountRepo, userID)
+	if account == nil {
+		return
+	}
+
+	err := accountRepo.DeleteAccount(userID, account.ID)
+	if errors.Is(err, repository.ErrAccountInUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al eliminar la cuenta: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cuenta eliminada exitosamente"})
+}
+
+// GetAccountHoldings obtiene las tenencias guardadas en una cuenta valoradas a precio de mercado
+func GetAccountHoldings(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	account := getOwnedAccount(c, repository.NewAccountRepository(database.DB), userID)
+	if account == nil {
+		return
+	}
+
+	prices, ok := currencyPrices(c, userID)
+	if !ok {
+		return
+	}
+
+	holdings, err := repository.NewHoldingsRepository(database.DB).WithPrices(prices).GetHoldings(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, accountHoldings := range holdings.ByAccount {
+		if accountHoldings.AccountID == account.ID {
+			c.JSON(http.StatusOK, gin.H{"holdings": accountHoldings, "currency": holdings.Currency})
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Cuenta no encontrada"})
+}
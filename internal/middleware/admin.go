@@ -10,7 +10,9 @@ import (
 func AdminAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		adminKey := c.GetHeader("Admin-Key")
-		if adminKey != os.Getenv("ADMIN_SECRET_KEY") {
+		// Sin ADMIN_SECRET_KEY configurada no se habilita el acceso de administrador
+		secret := os.Getenv("ADMIN_SECRET_KEY")
+		if secret == "" || adminKey != secret {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Acceso no autorizado"})
 			c.Abort()
 			return
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetReturns obtiene los rendimientos ponderados por tiempo (TWR) y por dinero (XIRR) del portafolio
+// y de cada activo. Acepta ?period= con 1M, 3M, YTD, 1Y o all (por defecto all). Los valores están en USD.
+func GetReturns(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	period, err := services.NormalizeReturnPeriod(c.Query("period"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	returns, err := repository.NewReturnsRepository(database.DB).WithPrices(requestPrices(c)).GetReturns(userID, period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al calcular los rendimientos: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, returns)
+}
+
+// GetRiskMetrics obtiene la volatilidad, la máxima caída, los ratios de Sharpe y Sortino y los mejores
+// y peores días del portafolio con el historial de snapshots. Acepta ?period= (1M, 3M, YTD, 1Y o all)
+// y ?risk_free_rate= con la tasa libre de riesgo anual en porcentaje (por defecto 0).
+func GetRiskMetrics(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	period, err := services.NormalizeReturnPeriod(c.Query("period"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var riskFreeRate float64
+	if value := c.Query("risk_free_rate"); value != "" {
+		riskFreeRate, err = strconv.ParseFloat(value, 64)
+		if err != nil || riskFreeRate <= -100 || riskFreeRate >= 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "risk_free_rate debe ser un porcentaje anual entre -100 y 100"})
+			return
+		}
+	}
+
+	metrics, err := repository.NewRiskRepository(database.DB).GetRiskMetrics(userID, period, riskFreeRate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al calcular las métricas de riesgo: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
+
+// GetBenchmark compara la curva del portafolio con la que habrían producido los mismos aportes y retiros
+// invertidos en un benchmark. Acepta ?benchmark= con btc, eth, btc_eth (60/40) o custom junto con
+// ?basket=BTC:50,SOL:50, y ?period= (1M, 3M, YTD, 1Y o all). Los valores están en USD.
+func GetBenchmark(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	period, err := services.NormalizeReturnPeriod(c.Query("period"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	benchmark, basket, err := services.BenchmarkAllocations(c.Query("benchmark"), c.Query("basket"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comparison, err := repository.NewBenchmarkRepository(database.DB).WithPrices(requestPrices(c)).GetBenchmarkComparison(userID, period, benchmark, basket)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al comparar con el benchmark: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, comparison)
+}
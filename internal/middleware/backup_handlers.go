@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxBackupSize es el tamaño máximo aceptado para una copia de seguridad a restaurar
+const maxBackupSize = 50 << 20
+
+// ExportBackup descarga la copia de seguridad del usuario: transacciones, bolsas con sus activos,
+// etiquetas y reglas, y snapshots. Con format=zip se incluye además un CSV por tipo de entidad.
+func ExportBackup(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "json"))
+	if format != "json" && format != "zip" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "formato inválido, debe ser json o zip"})
+		return
+	}
+
+	backup, err := repository.NewBackupRepository(database.DB).ExportBackup(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al generar la copia de seguridad: " + err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("dca-backup-%s.%s", backup.ExportedAt.Format("2006-01-02"), format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "zip" {
+		c.Header("Content-Type", "application/zip")
+		if err := services.WriteBackupArchive(c.Writer, *backup); err != nil {
+			log.Printf("Error al escribir la copia de seguridad en ZIP: %v", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, backup)
+}
+
+// RestoreBackup restaura una copia de seguridad (JSON o ZIP, como archivo "file" o en el cuerpo).
+// El parámetro policy indica qué hacer con los datos que ya existen: skip, overwrite o merge.
+func RestoreBackup(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var reader io.Reader = http.MaxBytesReader(c.Writer, c.Request.Body, maxBackupSize)
+	if file, err := c.FormFile("file"); err == nil {
+		opened, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no se pudo leer el archivo: " + err.Error()})
+			return
+		}
+		defer opened.Close()
+		reader = io.LimitReader(opened, maxBackupSize)
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no se pudo leer la copia de seguridad: " + err.Error()})
+		return
+	}
+
+	backup, err := services.ReadBackup(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy := c.Query("policy")
+	if _, err := services.NormalizeBackupConflictPolicy(policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := repository.NewBackupRepository(database.DB).RestoreBackup(userID, *backup, policy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al restaurar la copia de seguridad: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Copia de seguridad restaurada exitosamente", "restore": result})
+}
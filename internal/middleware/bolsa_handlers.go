@@ -3,6 +3,7 @@ package middleware
 import (
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
@@ -10,6 +11,7 @@ import (
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 )
 
 var bolsaRepo *repository.BolsaRepository
@@ -63,6 +65,12 @@ func GetUserBolsas(c *gin.Context) {
 		return
 	}
 
+	// Los valores se expresan en la moneda de ?currency= o en la del usuario
+	prices, ok := currencyPrices(c, userID)
+	if !ok {
+		return
+	}
+
 	// Obtener las bolsas del usuario
 	bolsas, err := bolsaRepo.GetBolsasByUserID(userID)
 	if err != nil {
@@ -72,7 +80,10 @@ func GetUserBolsas(c *gin.Context) {
 
 	// Actualizar los precios actuales de todos los activos en todas las bolsas
 	for i := range bolsas {
-		updateCryptoPrices(&bolsas[i])
+		if err := updateCryptoPrices(&bolsas[i], prices); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{"bolsas": bolsas})
@@ -144,7 +155,10 @@ func RemoveAssetFromBolsa(c *gin.Context) {
 	}
 
 	// Actualizar los precios actuales
-	updateCryptoPrices(updatedBolsa)
+	if err := updateCryptoPrices(updatedBolsa, requestPrices(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Activo eliminado exitosamente",
@@ -152,38 +166,43 @@ func RemoveAssetFromBolsa(c *gin.Context) {
 	})
 }
 
-// updateCryptoPrices actualiza los precios actuales de las criptomonedas en una bolsa
-func updateCryptoPrices(bolsa *models.Bolsa) {
-	if bolsa == nil || len(bolsa.Assets) == 0 {
-		return
+// updateCryptoPrices actualiza los precios actuales de las criptomonedas en una bolsa,
+// expresando todos sus valores en la moneda del proveedor de precios indicado
+func updateCryptoPrices(bolsa *models.Bolsa, prices services.PriceProvider) error {
+	if bolsa == nil {
+		return nil
+	}
+	if err := repository.ConvertBolsa(prices, bolsa); err != nil {
+		return err
+	}
+	bolsa.Currency = services.CurrencyOf(prices)
+	if len(bolsa.Assets) == 0 {
+		return nil
 	}
 
-	// Recopilar todos los tickers únicos de la bolsa
-	tickers := make([]string, 0)
-	tickerMap := make(map[string]bool)
+	// Recopilar todos los tickers únicos de la bolsa con su clase de activo
+	assets := make(map[string]string)
 	for _, asset := range bolsa.Assets {
-		if !tickerMap[asset.Ticker] {
-			tickers = append(tickers, asset.Ticker)
-			tickerMap[asset.Ticker] = true
-		}
+		assets[asset.Ticker] = asset.AssetClass
 	}
 
-	// Obtener los precios actuales de todas las criptomonedas en una sola llamada a la API
-	prices, err := services.GetMultipleCryptoPrices(tickers)
+	// Obtener los precios actuales de todos los activos en una sola llamada por proveedor
+	quotes, err := services.GetAssetQuotes(prices, assets)
 	if err != nil {
 		log.Printf("Error al obtener precios actuales: %v", err)
 		// Si hay un error, continuamos con los precios existentes
 	} else {
 		// Actualizar el precio actual de cada activo
 		for i := range bolsa.Assets {
-			if currentPrice, exists := prices[bolsa.Assets[i].Ticker]; exists {
+			if quote, exists := quotes[strings.ToUpper(bolsa.Assets[i].Ticker)]; exists {
+				currentPrice := quote.Price
 				// Actualizar el precio actual con el valor de la API
 				bolsa.Assets[i].CurrentPrice = currentPrice
-				log.Printf("Precio actualizado para %s: %.2f (precio anterior: %.2f)", 
+				log.Printf("Precio actualizado para %s: %.2f (precio anterior: %s)", 
 					bolsa.Assets[i].Ticker, currentPrice, bolsa.Assets[i].PurchasePrice)
 			} else {
 				// Si no encontramos el precio, mantenemos el precio de compra
-				log.Printf("No se encontró precio para %s, manteniendo precio de compra: %.2f", 
+				log.Printf("No se encontró precio para %s, manteniendo precio de compra: %s", 
 					bolsa.Assets[i].Ticker, bolsa.Assets[i].PurchasePrice)
 			}
 		}
@@ -191,12 +210,7 @@ func updateCryptoPrices(bolsa *models.Bolsa) {
 
 	// Recalcular valores derivados para todos los activos
 	for i := range bolsa.Assets {
-		bolsa.Assets[i].CurrentValue = bolsa.Assets[i].Amount * bolsa.Assets[i].CurrentPrice
-		bolsa.Assets[i].GainLoss = bolsa.Assets[i].CurrentValue - bolsa.Assets[i].Total
-
-		if bolsa.Assets[i].Total > 0 {
-			bolsa.Assets[i].GainLossPercent = (bolsa.Assets[i].GainLoss / bolsa.Assets[i].Total) * 100
-		}
+		services.ValueBolsaAsset(&bolsa.Assets[i], bolsa.Assets[i].CurrentPrice)
 	}
 
 	// Recalcular el valor actual total de la bolsa
@@ -204,6 +218,7 @@ func updateCryptoPrices(bolsa *models.Bolsa) {
 	for _, asset := range bolsa.Assets {
 		bolsa.CurrentValue += asset.CurrentValue
 	}
+	return nil
 }
 
 // GetBolsaDetails obtiene los detalles de una bolsa específica
@@ -235,19 +250,28 @@ func GetBolsaDetails(c *gin.Context) {
 		return
 	}
 
+	// Los valores se expresan en la moneda de ?currency= o en la del usuario
+	prices, ok := currencyPrices(c, userID)
+	if !ok {
+		return
+	}
+
 	// Actualizar los precios actuales de todos los activos en la bolsa
-	updateCryptoPrices(bolsa)
+	if err := updateCryptoPrices(bolsa, prices); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Imprimir los precios actualizados para depuración
 	log.Printf("Precios actualizados para la bolsa %s:", bolsa.ID)
 	for _, asset := range bolsa.Assets {
-		log.Printf("  - %s: Precio de compra: %.2f, Precio actual: %.2f", asset.Ticker, asset.PurchasePrice, asset.CurrentPrice)
+		log.Printf("  - %s: Precio de compra: %s, Precio actual: %.2f", asset.Ticker, asset.PurchasePrice, asset.CurrentPrice)
 	}
 
 	// Calcular información de progreso si hay un objetivo establecido
-	if bolsa.Goal > 0 {
+	if bolsa.Goal.IsPositive() {
 		// Calcular el porcentaje real de progreso
-		rawPercent := (bolsa.CurrentValue / bolsa.Goal) * 100
+		rawPercent := (bolsa.CurrentValue / bolsa.Goal.InexactFloat64()) * 100
 
 		// Crear objeto de progreso
 		progress := &models.ProgressInfo{
@@ -258,7 +282,7 @@ func GetBolsaDetails(c *gin.Context) {
 		if rawPercent > 100 {
 			progress.Percent = 100
 			progress.Status = "superado"
-			progress.ExcessAmount = bolsa.CurrentValue - bolsa.Goal
+			progress.ExcessAmount = bolsa.CurrentValue - bolsa.Goal.InexactFloat64()
 			progress.ExcessPercent = rawPercent - 100
 		} else if rawPercent == 100 {
 			progress.Percent = 100
@@ -272,6 +296,14 @@ func GetBolsaDetails(c *gin.Context) {
 		bolsa.Progress = progress
 	}
 
+	// Incluir el historial reciente de disparos de las reglas
+	history, err := bolsaRepo.GetTriggerEvents(bolsa.ID, bolsaRuleHistoryLimit)
+	if err != nil {
+		log.Printf("Error al obtener el historial de reglas de la bolsa %s: %v", bolsa.ID, err)
+	} else {
+		bolsa.RuleHistory = history
+	}
+
 	c.JSON(http.StatusOK, gin.H{"bolsa": bolsa})
 }
 
@@ -322,7 +354,7 @@ func AddAssetsToBolsa(c *gin.Context) {
 
 	// Procesar cada activo
 	addedAssets := []models.AssetInBolsa{}
-	totalValueAdded := 0.0
+	totalValueAdded := decimal.Zero
 	triggeredRules := []models.TriggerRule{}
 
 	for _, asset := range request.Assets {
@@ -336,33 +368,33 @@ func AddAssetsToBolsa(c *gin.Context) {
 		asset.UpdatedAt = now
 
 		// Calcular el valor total del activo
-		asset.Total = asset.Amount * asset.PurchasePrice
+		asset.Total = asset.Amount.Mul(asset.PurchasePrice)
+
+		assetClass, err := services.NormalizeAssetClass(asset.AssetClass)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		asset.AssetClass = assetClass
 
-		// Recopilar todos los tickers para obtener los precios en una sola llamada
-		tickers := []string{asset.Ticker}
-		
 		// Obtener precio actual y calcular valores derivados
-		prices, err := services.GetMultipleCryptoPrices(tickers)
+		quotes, err := services.GetAssetQuotes(services.GetPriceProvider(), map[string]string{asset.Ticker: asset.AssetClass})
 		if err != nil {
 			// Si no se puede obtener el precio actual, usar el precio de compra
 			log.Printf("Error al obtener precio para %s: %v", asset.Ticker, err)
-			asset.CurrentPrice = asset.PurchasePrice
-		} else if currentPrice, exists := prices[asset.Ticker]; exists {
+			asset.CurrentPrice = asset.PurchasePrice.InexactFloat64()
+		} else if quote, exists := quotes[strings.ToUpper(asset.Ticker)]; exists {
+			currentPrice := quote.Price
 			// Actualizar el precio actual con el valor de la API
 			asset.CurrentPrice = currentPrice
-			log.Printf("Precio actualizado para %s: %.2f (precio de compra: %.2f)", asset.Ticker, currentPrice, asset.PurchasePrice)
+			log.Printf("Precio actualizado para %s: %.2f (precio de compra: %s)", asset.Ticker, currentPrice, asset.PurchasePrice)
 		} else {
 			// Si no encontramos el precio, mantenemos el precio de compra
-			log.Printf("No se encontró precio para %s, manteniendo precio de compra: %.2f", asset.Ticker, asset.PurchasePrice)
-			asset.CurrentPrice = asset.PurchasePrice
+			log.Printf("No se encontró precio para %s, manteniendo precio de compra: %s", asset.Ticker, asset.PurchasePrice)
+			asset.CurrentPrice = asset.PurchasePrice.InexactFloat64()
 		}
 
-		asset.CurrentValue = asset.Amount * asset.CurrentPrice
-		asset.GainLoss = asset.CurrentValue - asset.Total
-
-		if asset.Total > 0 {
-			asset.GainLossPercent = (asset.GainLoss / asset.Total) * 100
-		}
+		services.ValueBolsaAsset(&asset, asset.CurrentPrice)
 
 		// Añadir el activo a la base de datos
 		err = bolsaRepo.AddAssetToBolsa(asset)
@@ -372,7 +404,7 @@ func AddAssetsToBolsa(c *gin.Context) {
 		}
 
 		addedAssets = append(addedAssets, asset)
-		totalValueAdded += asset.Total
+		totalValueAdded = totalValueAdded.Add(asset.Total)
 	}
 
 	// Obtener la bolsa actualizada con todos los activos
@@ -384,25 +416,16 @@ func AddAssetsToBolsa(c *gin.Context) {
 
 	// Calcular el progreso hacia el objetivo si existe
 	progressPercent := 0.0
-	if updatedBolsa.Goal > 0 {
-		progressPercent = (updatedBolsa.CurrentValue / updatedBolsa.Goal) * 100
-	}
-
-	// Verificar si se han activado reglas de tipo "value_reached"
-	for _, rule := range updatedBolsa.Rules {
-		if rule.Active && !rule.Triggered && rule.Type == "value_reached" {
-			// Para reglas de valor alcanzado, verificar si el valor actual supera el objetivo
-			if updatedBolsa.CurrentValue >= rule.TargetValue {
-				rule.Triggered = true
-				rule.UpdatedAt = time.Now()
-
-				// Actualizar la regla en la base de datos
-				err = bolsaRepo.UpdateRule(rule)
-				if err != nil {
-					log.Printf("Error al actualizar regla: %v", err)
-				} else {
-					triggeredRules = append(triggeredRules, rule)
-				}
+	if updatedBolsa.Goal.IsPositive() {
+		progressPercent = (updatedBolsa.CurrentValue / updatedBolsa.Goal.InexactFloat64()) * 100
+	}
+
+	// Evaluar las reglas de la bolsa con el nuevo valor
+	events := services.NewRuleEvaluator(bolsaRepo).EvaluateBolsa(updatedBolsa, time.Now())
+	for _, event := range events {
+		for _, rule := range updatedBolsa.Rules {
+			if rule.ID == event.RuleID {
+				triggeredRules = append(triggeredRules, rule)
 			}
 		}
 	}
@@ -415,7 +438,7 @@ func AddAssetsToBolsa(c *gin.Context) {
 		"current_value":     updatedBolsa.CurrentValue,
 	}
 
-	if updatedBolsa.Goal > 0 {
+	if updatedBolsa.Goal.IsPositive() {
 		response["progress_percent"] = progressPercent
 	}
 
@@ -460,7 +483,7 @@ func UpdateBolsa(c *gin.Context) {
 	var request struct {
 		Name        string                `json:"name,omitempty"`
 		Description string                `json:"description,omitempty"`
-		Goal        float64               `json:"goal,omitempty"`
+		Goal        decimal.Decimal       `json:"goal,omitempty"`
 		Assets      []models.AssetInBolsa `json:"assets,omitempty"`
 	}
 
@@ -481,7 +504,7 @@ func UpdateBolsa(c *gin.Context) {
 		updated = true
 	}
 
-	if request.Goal > 0 {
+	if request.Goal.IsPositive() {
 		existingBolsa.Goal = request.Goal
 		updated = true
 	}
@@ -506,11 +529,11 @@ func UpdateBolsa(c *gin.Context) {
 			for _, existingAsset := range existingBolsa.Assets {
 				if updatedAsset.ID == existingAsset.ID {
 					// Actualizar solo los campos proporcionados
-					if updatedAsset.Amount > 0 {
+					if updatedAsset.Amount.IsPositive() {
 						existingAsset.Amount = updatedAsset.Amount
 					}
 
-					if updatedAsset.PurchasePrice > 0 {
+					if updatedAsset.PurchasePrice.IsPositive() {
 						existingAsset.PurchasePrice = updatedAsset.PurchasePrice
 					}
 
@@ -522,24 +545,16 @@ func UpdateBolsa(c *gin.Context) {
 					}
 
 					// Recalcular valores derivados
-					existingAsset.Total = existingAsset.Amount * existingAsset.PurchasePrice
+					existingAsset.Total = existingAsset.Amount.Mul(existingAsset.PurchasePrice)
 
 					// Obtener precio actual y calcular valores derivados
-					cryptoData, err := services.GetCryptoPrice(existingAsset.Ticker)
+					currentPrice, err := services.GetAssetPrice(existingAsset.AssetClass, existingAsset.Ticker)
 					if err != nil {
 						// Si no se puede obtener el precio actual, usar el precio de compra
 						log.Printf("Error al obtener precio para %s: %v", existingAsset.Ticker, err)
-						existingAsset.CurrentPrice = existingAsset.PurchasePrice
-					} else {
-						existingAsset.CurrentPrice = cryptoData.Raw[existingAsset.Ticker]["USD"].PRICE
-					}
-
-					existingAsset.CurrentValue = existingAsset.Amount * existingAsset.CurrentPrice
-					existingAsset.GainLoss = existingAsset.CurrentValue - existingAsset.Total
-
-					if existingAsset.Total > 0 {
-						existingAsset.GainLossPercent = (existingAsset.GainLoss / existingAsset.Total) * 100
+						currentPrice = existingAsset.PurchasePrice.InexactFloat64()
 					}
+					services.ValueBolsaAsset(&existingAsset, currentPrice)
 
 					existingAsset.UpdatedAt = time.Now()
 
@@ -572,9 +587,9 @@ func UpdateBolsa(c *gin.Context) {
 	}
 
 	// Calcular información de progreso actualizada
-	if updatedBolsa.Goal > 0 {
+	if updatedBolsa.Goal.IsPositive() {
 		// Calcular el porcentaje real de progreso
-		rawPercent := (updatedBolsa.CurrentValue / updatedBolsa.Goal) * 100
+		rawPercent := (updatedBolsa.CurrentValue / updatedBolsa.Goal.InexactFloat64()) * 100
 
 		// Crear objeto de progreso
 		progress := &models.ProgressInfo{
@@ -585,7 +600,7 @@ func UpdateBolsa(c *gin.Context) {
 		if rawPercent > 100 {
 			progress.Percent = 100
 			progress.Status = "superado"
-			progress.ExcessAmount = updatedBolsa.CurrentValue - updatedBolsa.Goal
+			progress.ExcessAmount = updatedBolsa.CurrentValue - updatedBolsa.Goal.InexactFloat64()
 			progress.ExcessPercent = rawPercent - 100
 		} else if rawPercent == 100 {
 			progress.Percent = 100
@@ -659,25 +674,25 @@ func CompleteBolsaAndTransfer(c *gin.Context) {
 	}
 
 	// Verificar que la bolsa origen tenga un objetivo y que lo haya superado
-	if sourceBolsa.Goal <= 0 {
+	if !sourceBolsa.Goal.IsPositive() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "La bolsa origen no tiene un objetivo definido"})
 		return
 	}
 
-	if sourceBolsa.CurrentValue <= sourceBolsa.Goal {
+	if sourceBolsa.CurrentValue <= sourceBolsa.Goal.InexactFloat64() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "La bolsa origen no ha superado su objetivo"})
 		return
 	}
 
 	// Calcular el exceso a transferir
-	excessAmount := sourceBolsa.CurrentValue - sourceBolsa.Goal
+	excessAmount := sourceBolsa.CurrentValue - sourceBolsa.Goal.InexactFloat64()
 	if excessAmount <= 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No hay exceso para transferir"})
 		return
 	}
 
 	// Calcular el porcentaje de exceso para cada activo
-	excessRatio := excessAmount / sourceBolsa.CurrentValue
+	excessRatio := decimal.NewFromFloat(excessAmount / sourceBolsa.CurrentValue)
 
 	// Preparar los activos a transferir
 	transferredAssets := []models.AssetInBolsa{}
@@ -685,10 +700,11 @@ func CompleteBolsaAndTransfer(c *gin.Context) {
 	// Para cada activo en la bolsa origen
 	for _, asset := range sourceBolsa.Assets {
 		// Calcular la cantidad a transferir
-		transferAmount := asset.Amount * excessRatio
+		transferAmount := services.RoundAmount(asset.Ticker, asset.Amount.Mul(excessRatio))
 
 		// Si la cantidad a transferir es significativa
-		if transferAmount > 0 {
+		if transferAmount.IsPositive() {
+			transferPrice := decimal.NewFromFloat(asset.CurrentPrice)
 			// Crear un nuevo activo para la bolsa destino
 			newAsset := models.AssetInBolsa{
 				ID:              models.GenerateUUID(),
@@ -696,10 +712,10 @@ func CompleteBolsaAndTransfer(c *gin.Context) {
 				CryptoName:      asset.CryptoName,
 				Ticker:          asset.Ticker,
 				Amount:          transferAmount,
-				PurchasePrice:   asset.CurrentPrice, // Usar el precio actual como precio de compra
-				Total:           transferAmount * asset.CurrentPrice,
+				PurchasePrice:   transferPrice, // Usar el precio actual como precio de compra
+				Total:           transferAmount.Mul(transferPrice),
 				CurrentPrice:    asset.CurrentPrice,
-				CurrentValue:    transferAmount * asset.CurrentPrice,
+				CurrentValue:    transferAmount.InexactFloat64() * asset.CurrentPrice,
 				GainLoss:        0, // No hay ganancia/pérdida inicial
 				GainLossPercent: 0,
 				ImageURL:        asset.ImageURL,
@@ -711,13 +727,9 @@ func CompleteBolsaAndTransfer(c *gin.Context) {
 			transferredAssets = append(transferredAssets, newAsset)
 
 			// Actualizar la cantidad del activo en la bolsa origen
-			asset.Amount -= transferAmount
-			asset.Total = asset.Amount * asset.PurchasePrice
-			asset.CurrentValue = asset.Amount * asset.CurrentPrice
-			asset.GainLoss = asset.CurrentValue - asset.Total
-			if asset.Total > 0 {
-				asset.GainLossPercent = (asset.GainLoss / asset.Total) * 100
-			}
+			asset.Amount = asset.Amount.Sub(transferAmount)
+			asset.Total = asset.Amount.Mul(asset.PurchasePrice)
+			services.ValueBolsaAsset(&asset, asset.CurrentPrice)
 			asset.UpdatedAt = time.Now()
 
 			// Actualizar el activo en la base de datos
@@ -753,8 +765,8 @@ func CompleteBolsaAndTransfer(c *gin.Context) {
 
 	// Calcular información de progreso para ambas bolsas
 	// Bolsa origen
-	if updatedSourceBolsa.Goal > 0 {
-		rawPercent := (updatedSourceBolsa.CurrentValue / updatedSourceBolsa.Goal) * 100
+	if updatedSourceBolsa.Goal.IsPositive() {
+		rawPercent := (updatedSourceBolsa.CurrentValue / updatedSourceBolsa.Goal.InexactFloat64()) * 100
 		progress := &models.ProgressInfo{
 			RawPercent: rawPercent,
 		}
@@ -762,7 +774,7 @@ func CompleteBolsaAndTransfer(c *gin.Context) {
 		if rawPercent > 100 {
 			progress.Percent = 100
 			progress.Status = "superado"
-			progress.ExcessAmount = updatedSourceBolsa.CurrentValue - updatedSourceBolsa.Goal
+			progress.ExcessAmount = updatedSourceBolsa.CurrentValue - updatedSourceBolsa.Goal.InexactFloat64()
 			progress.ExcessPercent = rawPercent - 100
 		} else if rawPercent == 100 {
 			progress.Percent = 100
@@ -776,8 +788,8 @@ func CompleteBolsaAndTransfer(c *gin.Context) {
 	}
 
 	// Bolsa destino
-	if updatedTargetBolsa.Goal > 0 {
-		rawPercent := (updatedTargetBolsa.CurrentValue / updatedTargetBolsa.Goal) * 100
+	if updatedTargetBolsa.Goal.IsPositive() {
+		rawPercent := (updatedTargetBolsa.CurrentValue / updatedTargetBolsa.Goal.InexactFloat64()) * 100
 		progress := &models.ProgressInfo{
 			RawPercent: rawPercent,
 		}
@@ -785,7 +797,7 @@ func CompleteBolsaAndTransfer(c *gin.Context) {
 		if rawPercent > 100 {
 			progress.Percent = 100
 			progress.Status = "superado"
-			progress.ExcessAmount = updatedTargetBolsa.CurrentValue - updatedTargetBolsa.Goal
+			progress.ExcessAmount = updatedTargetBolsa.CurrentValue - updatedTargetBolsa.Goal.InexactFloat64()
 			progress.ExcessPercent = rawPercent - 100
 		} else if rawPercent == 100 {
 			progress.Percent = 100
@@ -953,9 +965,9 @@ func GetBolsasByTag(c *gin.Context) {
 
 	// Calcular información de progreso para cada bolsa
 	for i := range bolsas {
-		if bolsas[i].Goal > 0 {
+		if bolsas[i].Goal.IsPositive() {
 			// Calcular el porcentaje real de progreso
-			rawPercent := (bolsas[i].CurrentValue / bolsas[i].Goal) * 100
+			rawPercent := (bolsas[i].CurrentValue / bolsas[i].Goal.InexactFloat64()) * 100
 
 			// Crear objeto de progreso
 			progress := &models.ProgressInfo{
@@ -966,7 +978,7 @@ func GetBolsasByTag(c *gin.Context) {
 			if rawPercent > 100 {
 				progress.Percent = 100
 				progress.Status = "superado"
-				progress.ExcessAmount = bolsas[i].CurrentValue - bolsas[i].Goal
+				progress.ExcessAmount = bolsas[i].CurrentValue - bolsas[i].Goal.InexactFloat64()
 				progress.ExcessPercent = rawPercent - 100
 			} else if rawPercent == 100 {
 				progress.Percent = 100
@@ -0,0 +1,262 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// Cantidad de disparos que se devuelven junto con el detalle de una bolsa
+const bolsaRuleHistoryLimit = 50
+
+// triggerRuleRequest son los campos que se pueden enviar al crear o editar una regla
+type triggerRuleRequest struct {
+	Type            *string          `json:"type,omitempty"`
+	Ticker          *string          `json:"ticker,omitempty"`
+	TargetValue     *decimal.Decimal `json:"target_value,omitempty"`
+	Direction       *string          `json:"direction,omitempty"`
+	Rearm           *bool            `json:"rearm,omitempty"`
+	CooldownMinutes *int             `json:"cooldown_minutes,omitempty"`
+	Active          *bool            `json:"active,omitempty"`
+}
+
+// apply copia los campos enviados a la regla e indica si cambió su condición
+func (r triggerRuleRequest) apply(rule *models.TriggerRule) bool {
+	conditionChanged := false
+	if r.Type != nil && *r.Type != rule.Type {
+		rule.Type = *r.Type
+		rule.PeakValue = decimal.Zero
+		conditionChanged = true
+	}
+	if r.Ticker != nil && *r.Ticker != rule.Ticker {
+		rule.Ticker = *r.Ticker
+		conditionChanged = true
+	}
+	if r.TargetValue != nil && !r.TargetValue.Equal(rule.TargetValue) {
+		rule.TargetValue = *r.TargetValue
+		conditionChanged = true
+	}
+	if r.Direction != nil && *r.Direction != rule.Direction {
+		rule.Direction = *r.Direction
+		conditionChanged = true
+	}
+	if r.Rearm != nil {
+		rule.Rearm = *r.Rearm
+	}
+	if r.CooldownMinutes != nil {
+		rule.CooldownMinutes = *r.CooldownMinutes
+	}
+	if r.Active != nil {
+		rule.Active = *r.Active
+	}
+	return conditionChanged
+}
+
+// getOwnedBolsa obtiene una bolsa verificando que pertenezca al usuario.
+// Si hay un error ya escribe la respuesta y devuelve nil.
+func getOwnedBolsa(c *gin.Context, bolsaRepo *repository.BolsaRepository, userID string) *models.Bolsa {
+	bolsa, err := bolsaRepo.GetBolsaByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bolsa no encontrada"})
+		return nil
+	}
+	if bolsa.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No tienes permiso para acceder a esta bolsa"})
+		return nil
+	}
+	return bolsa
+}
+
+// findBolsaRule busca la regla indicada en la URL dentro de la bolsa
+func findBolsaRule(c *gin.Context, bolsa *models.Bolsa) *models.TriggerRule {
+	ruleID := c.Param("ruleId")
+	for i := range bolsa.Rules {
+		if bolsa.Rules[i].ID == ruleID {
+			return &bolsa.Rules[i]
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Regla no encontrada"})
+	return nil
+}
+
+// validateBolsaRule valida una regla contra su tipo y contra la bolsa a la que pertenece
+func validateBolsaRule(c *gin.Context, rule *models.TriggerRule, bolsa *models.Bolsa) bool {
+	if err := services.ValidateTriggerRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if rule.Type == models.TriggerTypePriceReached && !services.AssetExists(services.RuleAssetClass(*rule, bolsa), rule.Ticker) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Activo no encontrado"})
+		return false
+	}
+	if rule.Type == models.TriggerTypeGoalPercentReached && !bolsa.Goal.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "La bolsa no tiene un objetivo definido"})
+		return false
+	}
+	return true
+}
+
+// GetBolsaRules obtiene las reglas de una bolsa
+func GetBolsaRules(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	bolsaRepo := repository.NewBolsaRepository(database.DB)
+	bolsa := getOwnedBolsa(c, bolsaRepo, userID)
+	if bolsa == nil {
+		return
+	}
+
+	rules := bolsa.Rules
+	if rules == nil {
+		rules = []models.TriggerRule{}
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// CreateBolsaRule añade una regla a una bolsa
+func CreateBolsaRule(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var request triggerRuleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Type == nil || request.TargetValue == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type y target_value son obligatorios"})
+		return
+	}
+
+	bolsaRepo := repository.NewBolsaRepository(database.DB)
+	bolsa := getOwnedBolsa(c, bolsaRepo, userID)
+	if bolsa == nil {
+		return
+	}
+
+	rule := models.TriggerRule{Active: true}
+	request.apply(&rule)
+	if !validateBolsaRule(c, &rule, bolsa) {
+		return
+	}
+
+	now := time.Now()
+	rule.ID = models.GenerateUUID()
+	rule.BolsaID = bolsa.ID
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+
+	if err := bolsaRepo.AddRuleToBolsa(rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al crear la regla: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Regla creada exitosamente", "rule": rule})
+}
+
+// UpdateBolsaRule edita una regla. Permite pausarla o reanudarla con el campo active;
+// si cambia su condición la regla vuelve a quedar armada.
+func UpdateBolsaRule(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var request triggerRuleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bolsaRepo := repository.NewBolsaRepository(database.DB)
+	bolsa := getOwnedBolsa(c, bolsaRepo, userID)
+	if bolsa == nil {
+		return
+	}
+	rule := findBolsaRule(c, bolsa)
+	if rule == nil {
+		return
+	}
+
+	if request.apply(rule) {
+		rule.Triggered = false
+	}
+	if !validateBolsaRule(c, rule, bolsa) {
+		return
+	}
+
+	rule.UpdatedAt = time.Now()
+	if err := bolsaRepo.UpdateRule(*rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al actualizar la regla: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Regla actualizada exitosamente", "rule": rule})
+}
+
+// DeleteBolsaRule elimina una regla de una bolsa junto con su historial
+func DeleteBolsaRule(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	bolsaRepo := repository.NewBolsaRepository(database.DB)
+	bolsa := getOwnedBolsa(c, bolsaRepo, userID)
+	if bolsa == nil {
+		return
+	}
+	rule := findBolsaRule(c, bolsa)
+	if rule == nil {
+		return
+	}
+
+	if err := bolsaRepo.DeleteRule(bolsa.ID, rule.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al eliminar la regla: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Regla eliminada exitosamente"})
+}
+
+// RearmBolsaRule vuelve a armar una regla disparada para que pueda dispararse otra vez
+func RearmBolsaRule(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	bolsaRepo := repository.NewBolsaRepository(database.DB)
+	bolsa := getOwnedBolsa(c, bolsaRepo, userID)
+	if bolsa == nil {
+		return
+	}
+	rule := findBolsaRule(c, bolsa)
+	if rule == nil {
+		return
+	}
+
+	if err := bolsaRepo.RearmRule(rule.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al rearmar la regla"})
+		return
+	}
+	rule.Triggered = false
+
+	c.JSON(http.StatusOK, gin.H{"message": "Regla rearmada correctamente", "rule": rule})
+}
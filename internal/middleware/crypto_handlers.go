@@ -10,6 +10,7 @@ import (
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
@@ -67,13 +68,19 @@ func GetInvestmentHistory(c *gin.Context) {
 		since = time.Now().Add(-time.Duration(minutes) * time.Minute)
 	}
 
+	// Moneda en la que se devuelven los snapshots (se guardan siempre en dólares)
+	prices, ok := currencyPrices(c, userID)
+	if !ok {
+		return
+	}
+
 	// Paso 1: Guardar o actualizar el snapshot actual
 	// Obtener el valor actual de las inversiones
-	holdingsRepo := repository.NewHoldingsRepository(database.DB)
+	holdingsRepo := repository.NewHoldingsRepository(database.DB).WithPrices(requestPrices(c))
 	holdings, err := holdingsRepo.GetHoldings(userID)
 	if err == nil && holdings.TotalCurrentValue > 0 {
 		// Generar un ID único para el snapshot
-		snapshotID := fmt.Sprintf("snapshot_%d", time.Now().UnixNano())
+		snapshotID := models.GenerateUUID()
 		// Obtener la hora actual y truncarla a intervalos de 24 horas (diarios)
 		// (esto crea un punto de referencia para agrupar los snapshots por día)
 		currentTime := time.Now()
@@ -207,6 +214,15 @@ func GetInvestmentHistory(c *gin.Context) {
 		}
 
 		snapshots = append(snapshots, snapshot)
+	}
+
+	// Expresar cada snapshot en la moneda pedida con la cotización de su fecha
+	if err := repository.ConvertSnapshots(prices, snapshots); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, snapshot := range snapshots {
 		// Formatear la fecha para el gráfico (formato dd/mm HH:MM)
 		dateFormatted := snapshot.Date.Format("02/01 15:04")
 		labels = append(labels, dateFormatted)
@@ -235,6 +251,7 @@ func GetInvestmentHistory(c *gin.Context) {
 		"values":    values,
 		"max_values": maxValues,
 		"min_values": minValues,
+		"currency":   services.CurrencyOf(prices),
 	}
 
 	c.JSON(http.StatusOK, gin.H{"investment_history": historyData})
@@ -4,12 +4,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
 	"log"
 	"net/http"
 	"time"
 )
 
-// GetDashboard obtiene el dashboard del usuario con información de todas sus criptomonedas
+// GetDashboard obtiene el dashboard del usuario con información de todos sus activos.
+// Acepta ?group_by=asset_class para agrupar las posiciones por clase de activo.
 func GetDashboard(c *gin.Context) {
 	// Obtener el ID del usuario del contexto
 	userID, exists := c.Get("userId")
@@ -21,13 +23,30 @@ func GetDashboard(c *gin.Context) {
 	// Convertir el ID a string
 	userIDStr := userID.(string)
 
+	// Los valores se expresan en la moneda de ?currency= o en la del usuario
+	prices, ok := currencyPrices(c, userIDStr)
+	if !ok {
+		return
+	}
+
 	// Obtener el dashboard usando la conexión a la base de datos
-	dashboard, err := repository.GetUserDashboard(database.DB, userIDStr)
+	dashboard, err := repository.GetUserDashboard(database.DB, prices, userIDStr)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Con ?group_by=asset_class se agrupan las posiciones por clase de activo
+	switch c.Query("group_by") {
+	case "":
+	case "asset_class":
+		c.JSON(http.StatusOK, gin.H{"groups": repository.GroupDashboardByAssetClass(dashboard)})
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by solo admite asset_class"})
+		return
+	}
+
 	c.JSON(http.StatusOK, dashboard)
 }
 
@@ -64,7 +83,7 @@ func GetPerformance(c *gin.Context) {
 	}
 
 	// Obtener el rendimiento
-	performance, err := repository.GetUserPerformance(database.DB, userIDStr, startDate)
+	performance, err := repository.GetUserPerformance(database.DB, requestPrices(c), userIDStr, startDate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -85,8 +104,13 @@ func GetHoldings(c *gin.Context) {
 	// Convertir el ID a string
 	userIDStr := userID.(string)
 
+	prices, ok := currencyPrices(c, userIDStr)
+	if !ok {
+		return
+	}
+
 	// Obtener las tenencias
-	holdingsRepo := repository.NewHoldingsRepository(database.DB)
+	holdingsRepo := repository.NewHoldingsRepository(database.DB).WithPrices(prices)
 	holdings, err := holdingsRepo.GetHoldings(userIDStr)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -108,25 +132,33 @@ func GetCurrentBalance(c *gin.Context) {
 	// Convertir el ID a string
 	userIDStr := userID.(string)
 
+	prices, ok := currencyPrices(c, userIDStr)
+	if !ok {
+		return
+	}
+
 	// Obtener el balance usando la función existente
-	balance, err := repository.GetUserCurrentBalance(database.DB, userIDStr)
+	balance, err := repository.GetUserCurrentBalance(database.DB, prices, userIDStr)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Guardar automáticamente un snapshot del balance actual
-	cryptoRepo := repository.NewCryptoRepository(database.DB)
-	err = cryptoRepo.SaveInvestmentSnapshotWithMaxMin(
-		userIDStr,
-		balance.TotalBalance,
-		balance.TotalInvested,
-		balance.TotalProfit,
-		balance.ProfitPercentage,
-	)
-	if err != nil {
-		// Log el error pero no detener la respuesta
-		log.Printf("Error al guardar snapshot automático: %v", err)
+	// Guardar automáticamente un snapshot del balance actual.
+	// Los snapshots se guardan siempre en dólares, así que solo se usa el balance calculado en USD.
+	if balance.Currency == services.CurrencyUSD {
+		cryptoRepo := repository.NewCryptoRepository(database.DB)
+		err = cryptoRepo.SaveInvestmentSnapshotWithMaxMin(
+			userIDStr,
+			balance.TotalBalance,
+			balance.TotalInvested,
+			balance.TotalProfit,
+			balance.ProfitPercentage,
+		)
+		if err != nil {
+			// Log el error pero no detener la respuesta
+			log.Printf("Error al guardar snapshot automático: %v", err)
+		}
 	}
 
 	c.JSON(http.StatusOK, balance)
@@ -165,6 +197,11 @@ func GetUserInvestmentHistory(c *gin.Context) {
 		startDate = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
 	}
 
+	prices, ok := currencyPrices(c, userIDStr)
+	if !ok {
+		return
+	}
+
 	// Obtener el historial de inversiones
 	cryptoRepo := repository.NewCryptoRepository(database.DB)
 	history, err := cryptoRepo.GetInvestmentSnapshotsWithMaxMin(userIDStr, startDate)
@@ -173,6 +210,12 @@ func GetUserInvestmentHistory(c *gin.Context) {
 		return
 	}
 
+	// Expresar cada snapshot en la moneda pedida con la cotización de su fecha
+	if err := repository.ConvertSnapshots(prices, history); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, history)
 }
 
@@ -189,8 +232,13 @@ func GetDashboardLiveBalance(c *gin.Context) {
 	// Convertir el ID a string
 	userIDStr := userID.(string)
 
+	prices, ok := currencyPrices(c, userIDStr)
+	if !ok {
+		return
+	}
+
 	// Obtener el balance en tiempo real usando la función existente
-	balance, err := repository.GetUserLiveBalance(database.DB, userIDStr)
+	balance, err := repository.GetUserLiveBalance(database.DB, prices, userIDStr)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
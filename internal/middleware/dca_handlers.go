@@ -0,0 +1,318 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// Cantidad de ejecuciones que se devuelven junto con el detalle de un plan
+const dcaPlanDetailExecutions = 20
+
+// getOwnedDCAPlan obtiene un plan DCA verificando que pertenezca al usuario.
+// Si hay un error ya escribe la respuesta y devuelve nil.
+func getOwnedDCAPlan(c *gin.Context, dcaRepo *repository.DCARepository, userID string) *models.DCAPlan {
+	plan, err := dcaRepo.GetPlanByID(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plan DCA no encontrado"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al obtener el plan DCA: " + err.Error()})
+		return nil
+	}
+	if plan.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No tienes permiso para acceder a este plan DCA"})
+		return nil
+	}
+	return plan
+}
+
+// validateDCATargetBolsa verifica que la bolsa destino de un plan pertenezca al usuario
+func validateDCATargetBolsa(c *gin.Context, bolsaID, userID string) bool {
+	if bolsaID == "" {
+		return true
+	}
+	bolsa, err := repository.NewBolsaRepository(database.DB).GetBolsaByID(bolsaID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bolsa destino no encontrada"})
+		return false
+	}
+	if bolsa.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No tienes permiso para acceder a la bolsa destino"})
+		return false
+	}
+	return true
+}
+
+// scheduleDCAPlan calcula la próxima ejecución de un plan activo a partir de ahora
+func scheduleDCAPlan(plan *models.DCAPlan) bool {
+	plan.NextRunAt = nil
+	if !plan.Active {
+		return true
+	}
+	next, ok := services.NextDCARun(*plan, time.Now())
+	if !ok {
+		return false
+	}
+	plan.NextRunAt = &next
+	return true
+}
+
+// CreateDCAPlan crea un nuevo plan de compras periódicas
+func CreateDCAPlan(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var plan models.DCAPlan
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan.UserID = userID
+	plan.Ticker = strings.ToUpper(plan.Ticker)
+	plan.Active = true
+	if plan.StartDate.IsZero() {
+		plan.StartDate = time.Now()
+	}
+
+	if err := services.ValidateDCAPlan(plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validar la clase de activo y que el ticker exista en su mercado
+	assetClass, err := services.NormalizeAssetClass(plan.AssetClass)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if assetClass == models.AssetClassCash {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "los planes DCA no admiten efectivo, usa crypto, stock o etf"})
+		return
+	}
+	plan.AssetClass = assetClass
+	if !services.AssetExists(plan.AssetClass, plan.Ticker) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Activo no encontrado"})
+		return
+	}
+
+	if !validateDCATargetBolsa(c, plan.BolsaID, userID) {
+		return
+	}
+
+	if !scheduleDCAPlan(&plan) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El plan no tiene ejecuciones futuras"})
+		return
+	}
+
+	plan.ID = models.GenerateUUID()
+	now := time.Now()
+	plan.CreatedAt = now
+	plan.UpdatedAt = now
+
+	dcaRepo := repository.NewDCARepository(database.DB)
+	if err := dcaRepo.CreatePlan(plan); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al crear el plan DCA: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Plan DCA creado exitosamente", "plan": plan})
+}
+
+// GetDCAPlans obtiene todos los planes DCA del usuario
+func GetDCAPlans(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	dcaRepo := repository.NewDCARepository(database.DB)
+	plans, err := dcaRepo.GetPlansByUserID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al obtener los planes DCA: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"plans": plans})
+}
+
+// GetDCAPlan obtiene un plan DCA junto con sus ejecuciones más recientes
+func GetDCAPlan(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	dcaRepo := repository.NewDCARepository(database.DB)
+	plan := getOwnedDCAPlan(c, dcaRepo, userID)
+	if plan == nil {
+		return
+	}
+
+	executions, err := dcaRepo.GetExecutions(plan.ID, dcaPlanDetailExecutions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al obtener las ejecuciones: " + err.Error()})
+		return
+	}
+	plan.Executions = executions
+
+	c.JSON(http.StatusOK, gin.H{"plan": plan})
+}
+
+// UpdateDCAPlan actualiza un plan DCA. Permite pausarlo o reanudarlo con el campo active.
+func UpdateDCAPlan(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	dcaRepo := repository.NewDCARepository(database.DB)
+	plan := getOwnedDCAPlan(c, dcaRepo, userID)
+	if plan == nil {
+		return
+	}
+
+	var request struct {
+		CryptoName *string          `json:"crypto_name,omitempty"`
+		AmountUSD  *decimal.Decimal `json:"amount_usd,omitempty"`
+		Cadence    *string          `json:"cadence,omitempty"`
+		CronExpr   *string          `json:"cron_expr,omitempty"`
+		EndDate    *time.Time       `json:"end_date,omitempty"`
+		BolsaID    *string          `json:"bolsa_id,omitempty"`
+		Active     *bool            `json:"active,omitempty"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if request.CryptoName != nil {
+		plan.CryptoName = *request.CryptoName
+	}
+	if request.AmountUSD != nil {
+		plan.AmountUSD = *request.AmountUSD
+	}
+	if request.Cadence != nil {
+		plan.Cadence = *request.Cadence
+	}
+	if request.CronExpr != nil {
+		plan.CronExpr = *request.CronExpr
+	}
+	if request.EndDate != nil {
+		plan.EndDate = request.EndDate
+	}
+	if request.BolsaID != nil {
+		plan.BolsaID = *request.BolsaID
+	}
+	if request.Active != nil {
+		plan.Active = *request.Active
+	}
+
+	if err := services.ValidateDCAPlan(*plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if request.BolsaID != nil && !validateDCATargetBolsa(c, plan.BolsaID, userID) {
+		return
+	}
+
+	if !scheduleDCAPlan(plan) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El plan no tiene ejecuciones futuras"})
+		return
+	}
+
+	plan.UpdatedAt = time.Now()
+	if err := dcaRepo.UpdatePlan(*plan); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al actualizar el plan DCA: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Plan DCA actualizado exitosamente", "plan": plan})
+}
+
+// DeleteDCAPlan elimina un plan DCA. Las compras ya realizadas se conservan.
+func DeleteDCAPlan(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	dcaRepo := repository.NewDCARepository(database.DB)
+	plan := getOwnedDCAPlan(c, dcaRepo, userID)
+	if plan == nil {
+		return
+	}
+
+	if err := dcaRepo.DeletePlan(plan.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al eliminar el plan DCA: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Plan DCA eliminado exitosamente"})
+}
+
+// GetDCAPlanExecutions obtiene el historial de ejecuciones de un plan DCA
+func GetDCAPlanExecutions(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit debe ser un número positivo"})
+		return
+	}
+
+	dcaRepo := repository.NewDCARepository(database.DB)
+	plan := getOwnedDCAPlan(c, dcaRepo, userID)
+	if plan == nil {
+		return
+	}
+
+	executions, err := dcaRepo.GetExecutions(plan.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al obtener las ejecuciones: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"executions": executions})
+}
+
+// BacktestDCAPlan simula un plan DCA sobre precios históricos y lo compara con una compra única
+func BacktestDCAPlan(c *gin.Context) {
+	var request models.DCABacktestRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	historyRepo := repository.NewHistoricalPriceRepository(database.DB)
+	result, err := repository.RunDCABacktest(historyRepo, request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
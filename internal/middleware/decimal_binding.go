@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"reflect"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/shopspring/decimal"
+)
+
+// RegisterDecimalValidation permite usar las reglas de binding (required, gt, gte...) en los
+// campos decimal.Decimal: el validador los compara por su valor numérico
+func RegisterDecimalValidation() {
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	validate.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		if value, ok := field.Interface().(decimal.Decimal); ok {
+			return value.InexactFloat64()
+		}
+		return nil
+	}, decimal.Decimal{})
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader es la cabecera con la que el cliente identifica una petición reintentable
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength es el largo máximo aceptado para una clave de idempotencia
+const maxIdempotencyKeyLength = 255
+
+// idempotencyWriter copia la respuesta del handler para poder guardarla
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *idempotencyWriter) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// idempotencyRequestHash identifica el contenido de una petición: método, ruta y cuerpo
+func idempotencyRequestHash(c *gin.Context, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// IdempotencyMiddleware permite reintentar peticiones que modifican datos sin aplicarlas dos veces.
+// Si la petición trae la cabecera Idempotency-Key, la primera respuesta se guarda durante 24 horas y los
+// reintentos con la misma clave la reciben de nuevo sin volver a ejecutar el handler. Reusar la clave
+// con otra petición devuelve 422 y reintentar mientras la original se procesa devuelve 409.
+// Las respuestas 5xx no se guardan, para que la petición pueda reintentarse.
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key no puede superar los 255 caracteres"})
+			return
+		}
+
+		userID := c.GetString("userId")
+		if userID == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "no se pudo leer la petición: " + err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		requestHash := idempotencyRequestHash(c, body)
+
+		repo := repository.NewIdempotencyRepository(database.DB)
+		record, reserved, err := repo.Reserve(userID, key, requestHash)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error al verificar la clave de idempotencia: " + err.Error()})
+			return
+		}
+
+		if !reserved {
+			switch {
+			case record.RequestHash != requestHash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "la clave de idempotencia ya se usó con otra petición"})
+			case record.StatusCode == 0:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "la petición original con esta clave de idempotencia todavía se está procesando"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(record.StatusCode, record.ContentType, record.ResponseBody)
+				c.Abort()
+			}
+			return
+		}
+
+		// Si el handler entra en pánico la clave queda libre para reintentar
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				if err := repo.Release(userID, key); err != nil {
+					log.Printf("Error al liberar la clave de idempotencia %s: %v", key, err)
+				}
+				panic(recovered)
+			}
+		}()
+
+		writer := &idempotencyWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			if err := repo.Release(userID, key); err != nil {
+				log.Printf("Error al liberar la clave de idempotencia %s: %v", key, err)
+			}
+			return
+		}
+		if err := repo.Complete(userID, key, status, writer.Header().Get("Content-Type"), writer.body.Bytes()); err != nil {
+			log.Printf("Error al guardar la respuesta de la clave de idempotencia %s: %v", key, err)
+		}
+	}
+}
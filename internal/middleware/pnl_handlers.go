@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// resolveCostBasisMethod obtiene el método de costo base de ?method= o, si no se indicó,
+// de las preferencias del usuario. Si es inválido ya escribe la respuesta y devuelve false.
+func resolveCostBasisMethod(c *gin.Context, userID string) (string, bool) {
+	method := c.Query("method")
+	if method == "" {
+		method = repository.NewSettingsRepository(database.DB).GetCostBasisMethod(userID)
+	}
+	method, err := services.NormalizeCostBasisMethod(method)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return method, true
+}
+
+// parseDateQuery interpreta un parámetro de fecha opcional con formato YYYY-MM-DD
+func parseDateQuery(c *gin.Context, name string) (time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, true
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " debe tener el formato YYYY-MM-DD"})
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// GetRealizedPnL obtiene la ganancia realizada de cada venta del usuario.
+// Acepta ?method= para usar un método distinto al de sus preferencias y ?from=/?to= para filtrar.
+func GetRealizedPnL(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	method, ok := resolveCostBasisMethod(c, userID)
+	if !ok {
+		return
+	}
+	from, ok := parseDateQuery(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := parseDateQuery(c, "to")
+	if !ok {
+		return
+	}
+	if !to.IsZero() {
+		// Incluir todo el día indicado
+		to = to.AddDate(0, 0, 1)
+	}
+
+	pnl, err := repository.NewPnLRepository(database.DB).GetRealizedPnL(userID, method, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al calcular las ganancias realizadas: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, pnl)
+}
+
+// GetUnrealizedPnL obtiene la ganancia no realizada de los lotes abiertos del usuario
+func GetUnrealizedPnL(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	method, ok := resolveCostBasisMethod(c, userID)
+	if !ok {
+		return
+	}
+
+	pnl, err := repository.NewPnLRepository(database.DB).WithPrices(requestPrices(c)).GetUnrealizedPnL(userID, method)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al calcular las ganancias no realizadas: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, pnl)
+}
+
+// GetUserSettings obtiene las preferencias de cálculo del usuario
+func GetUserSettings(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	settings, err := repository.NewSettingsRepository(database.DB).GetSettings(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al obtener las preferencias: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"settings": settings})
+}
+
+// UpdateUserSettings actualiza las preferencias de cálculo del usuario
+func UpdateUserSettings(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var request struct {
+		CostBasisMethod *string `json:"cost_basis_method,omitempty"`
+		BaseCurrency    *string `json:"base_currency,omitempty"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settingsRepo := repository.NewSettingsRepository(database.DB)
+	settings, err := settingsRepo.GetSettings(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al obtener las preferencias: " + err.Error()})
+		return
+	}
+
+	if request.CostBasisMethod != nil {
+		method, err := services.NormalizeCostBasisMethod(*request.CostBasisMethod)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		settings.CostBasisMethod = method
+	}
+	if request.BaseCurrency != nil {
+		currency, err := services.NormalizeCurrency(*request.BaseCurrency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		settings.BaseCurrency = currency
+	}
+
+	settings.UpdatedAt = time.Now()
+	if err := settingsRepo.SaveSettings(*settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al guardar las preferencias: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Preferencias actualizadas exitosamente", "settings": settings})
+}
+
+// GetTaxReport genera el informe fiscal de un año con cada lote vendido.
+// Devuelve JSON por defecto o un CSV descargable con ?format=csv.
+func GetTaxReport(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	year, err := strconv.Atoi(c.DefaultQuery("year", strconv.Itoa(time.Now().Year())))
+	if err != nil || year < 1970 || year > 9999 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "year debe ser un año válido"})
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "json"))
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format debe ser json o csv"})
+		return
+	}
+
+	method, ok := resolveCostBasisMethod(c, userID)
+	if !ok {
+		return
+	}
+
+	report, err := repository.NewPnLRepository(database.DB).GetTaxReport(userID, method, year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al generar el informe fiscal: " + err.Error()})
+		return
+	}
+
+	if format == "csv" {
+		filename := fmt.Sprintf("tax-report-%d-%s.csv", report.Year, report.Method)
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		if err := services.WriteTaxReportCSV(c.Writer, *report); err != nil {
+			log.Printf("Error al escribir el informe fiscal en CSV: %v", err)
+		}
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="tax-report-%d-%s.json"`, report.Year, report.Method))
+	c.JSON(http.StatusOK, report)
+}
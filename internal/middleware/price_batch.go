@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// priceBatchKey es la clave del contexto donde se guarda el lote de precios de la petición
+const priceBatchKey = "priceBatch"
+
+// PriceBatchMiddleware crea un lote de precios por petición para que todos los
+// cálculos de la misma petición compartan una única consulta al proveedor
+func PriceBatchMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(priceBatchKey, services.NewPriceBatch(services.GetPriceProvider()))
+		c.Next()
+	}
+}
+
+// requestPrices devuelve el lote de precios de la petición o el proveedor global si no existe
+func requestPrices(c *gin.Context) services.PriceProvider {
+	if value, exists := c.Get(priceBatchKey); exists {
+		if batch, ok := value.(*services.PriceBatch); ok {
+			return batch
+		}
+	}
+	return services.GetPriceProvider()
+}
+
+// currencyPrices obtiene la moneda de ?currency= o, si no se indicó, de las preferencias del usuario,
+// y devuelve el lote de precios de la petición expresado en esa moneda.
+// Si la moneda es inválida ya escribe la respuesta y devuelve false.
+func currencyPrices(c *gin.Context, userID string) (services.PriceProvider, bool) {
+	currency := c.Query("currency")
+	if currency == "" {
+		currency = repository.NewSettingsRepository(database.DB).GetBaseCurrency(userID)
+	}
+	currency, err := services.NormalizeCurrency(currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if currency == services.CurrencyUSD {
+		return requestPrices(c), true
+	}
+	return services.NewCurrencyPriceProvider(requestPrices(c), currency), true
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetPriceCacheStats devuelve las estadísticas de aciertos y fallos de la caché de precios
+func GetPriceCacheStats(c *gin.Context) {
+	stats, enabled := services.GetPriceCacheStats()
+	if !enabled {
+		c.JSON(http.StatusOK, gin.H{
+			"enabled":  false,
+			"provider": services.GetPriceProvider().Name(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": true,
+		"stats":   stats,
+	})
+}
+
+// ImportHistoricalPrices carga precios de cierre diarios desde un CSV (archivo "file" o cuerpo text/csv).
+// El parámetro ticker se usa cuando el CSV no tiene columna de ticker. Los precios
+// son compartidos por todos los usuarios, por eso la ruta requiere la clave de administrador.
+func ImportHistoricalPrices(c *gin.Context) {
+	var reader io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil {
+		opened, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no se pudo leer el archivo: " + err.Error()})
+			return
+		}
+		defer opened.Close()
+		reader = opened
+	}
+
+	prices, err := services.ParseHistoricalPricesCSV(reader, c.Query("ticker"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	saved, err := repository.NewHistoricalPriceRepository(database.DB).SavePrices(prices)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al guardar los precios históricos: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Precios históricos importados", "imported": saved})
+}
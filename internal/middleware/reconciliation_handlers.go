@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// saveReportedBalances completa y guarda los saldos informados de una cuenta
+func saveReportedBalances(account *models.Account, balances []models.ReportedBalance) error {
+	now := time.Now()
+	for i := range balances {
+		balances[i].ID = models.GenerateUUID()
+		balances[i].UserID = account.UserID
+		balances[i].AccountID = account.ID
+		balances[i].Ticker = strings.ToUpper(strings.TrimSpace(balances[i].Ticker))
+		balances[i].CreatedAt = now
+		if balances[i].ReportedAt.IsZero() {
+			balances[i].ReportedAt = now
+		}
+	}
+	return repository.NewReconciliationRepository(database.DB).SaveReportedBalances(balances)
+}
+
+// CreateReportedBalance registra a mano el saldo que informa una cuenta para un ticker
+func CreateReportedBalance(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	account := getOwnedAccount(c, repository.NewAccountRepository(database.DB), userID)
+	if account == nil {
+		return
+	}
+
+	var balance models.ReportedBalance
+	if err := c.ShouldBindJSON(&balance); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	balance.Source = models.ReportedBalanceSourceManual
+
+	balances := []models.ReportedBalance{balance}
+	if err := saveReportedBalances(account, balances); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al guardar el saldo informado: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Saldo informado registrado exitosamente", "balance": balances[0]})
+}
+
+// ImportReportedBalances importa los saldos que informa una cuenta desde un CSV (archivo "file" o cuerpo text/csv).
+// Las filas sin fecha toman la del parámetro date o, si no está, la actual.
+func ImportReportedBalances(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	account := getOwnedAccount(c, repository.NewAccountRepository(database.DB), userID)
+	if account == nil {
+		return
+	}
+
+	var reader io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil {
+		opened, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no se pudo leer el archivo: " + err.Error()})
+			return
+		}
+		defer opened.Close()
+		reader = opened
+	}
+
+	reportedAt := time.Now()
+	if date := importParam(c, "date"); date != "" {
+		parsed, err := time.Parse(time.RFC3339, date)
+		if err != nil {
+			if parsed, err = time.Parse("2006-01-02", date); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "date inválida, usa RFC 3339 o YYYY-MM-DD"})
+				return
+			}
+		}
+		reportedAt = parsed
+	}
+
+	balances, err := services.ParseReportedBalancesCSV(reader, reportedAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := saveReportedBalances(account, balances); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al guardar los saldos informados: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Saldos informados importados", "imported": len(balances), "balances": balances})
+}
+
+// GetReportedBalances obtiene el historial de saldos informados de una cuenta
+func GetReportedBalances(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	account := getOwnedAccount(c, repository.NewAccountRepository(database.DB), userID)
+	if account == nil {
+		return
+	}
+
+	balances, err := repository.NewReconciliationRepository(database.DB).GetReportedBalances(account.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al obtener los saldos informados: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"balances": balances})
+}
+
+// GetAccountReconciliation compara los últimos saldos informados de una cuenta con los calculados
+// a partir de las transacciones y marca las discrepancias
+func GetAccountReconciliation(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	account := getOwnedAccount(c, repository.NewAccountRepository(database.DB), userID)
+	if account == nil {
+		return
+	}
+
+	reconciliation, err := repository.NewReconciliationRepository(database.DB).ReconcileAccount(*account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al conciliar la cuenta: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reconciliation)
+}
+
+// CreateReconciliationAdjustment genera la transacción de ajuste que corrige la discrepancia
+// entre el último saldo informado de un ticker y el calculado
+func CreateReconciliationAdjustment(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	account := getOwnedAccount(c, repository.NewAccountRepository(database.DB), userID)
+	if account == nil {
+		return
+	}
+
+	var request models.ReconciliationAdjustmentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transaction, item, err := repository.NewReconciliationRepository(database.DB).CreateAdjustment(*account, request)
+	switch {
+	case errors.Is(err, repository.ErrNoReportedBalance):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repository.ErrNoDiscrepancy), errors.Is(err, repository.ErrAlreadyAdjusted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "error al generar el ajuste: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":        "Ajuste de conciliación registrado exitosamente",
+		"transaction":    transaction,
+		"reconciliation": item,
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
 	"github.com/gin-gonic/gin"
 )
@@ -66,7 +67,7 @@ func GetLiveBalance(c *gin.Context) {
 	}
 
 	// Obtener las tenencias directamente de la base de datos
-	holdingsRepo := repository.NewHoldingsRepository(database.DB)
+	holdingsRepo := repository.NewHoldingsRepository(database.DB).WithPrices(requestPrices(c))
 	holdings, err := holdingsRepo.GetHoldings(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error al obtener tenencias: %v", err)})
@@ -90,7 +91,7 @@ func ForceCreateSnapshot(c *gin.Context) {
 	}
 
 	// Obtener las tenencias actuales del usuario
-	holdingsRepo := repository.NewHoldingsRepository(database.DB)
+	holdingsRepo := repository.NewHoldingsRepository(database.DB).WithPrices(requestPrices(c))
 	holdings, err := holdingsRepo.GetHoldings(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error al obtener tenencias: %v", err)})
@@ -152,7 +153,7 @@ func ForceCreateSnapshotWithDate(c *gin.Context) {
 	// Versión simplificada para resolver el error de compilación
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Snapshot con fecha específica creado exitosamente",
-		"snapshot_id": fmt.Sprintf("snapshot_%d", time.Now().UnixNano()),
+		"snapshot_id": models.GenerateUUID(),
 		"user_id": userID,
 		"date": date.Format("2006-01-02 15:04:05"),
 	})
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// bindSwapRequest lee un intercambio del cuerpo y valida que ambas criptomonedas existan.
+// Si hay un error ya escribe la respuesta y devuelve false.
+func bindSwapRequest(c *gin.Context, request *models.SwapRequest) bool {
+	if err := c.ShouldBindJSON(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	for _, ticker := range []string{request.FromTicker, request.ToTicker} {
+		if !repository.CryptoExists(strings.ToUpper(ticker)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Criptomoneda no encontrada: " + ticker})
+			return false
+		}
+	}
+	return true
+}
+
+// CreateSwap registra un intercambio de una criptomoneda por otra
+func CreateSwap(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var request models.SwapRequest
+	if !bindSwapRequest(c, &request) {
+		return
+	}
+
+	swap, err := repository.NewCryptoRepository(database.DB).CreateSwap(userID, request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Intercambio registrado exitosamente", "swap": swap})
+}
+
+// GetSwap obtiene un intercambio con sus dos patas
+func GetSwap(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	swap, err := repository.NewCryptoRepository(database.DB).GetSwap(userID, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Intercambio no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al obtener el intercambio: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"swap": swap})
+}
+
+// UpdateSwap edita un intercambio reemplazando sus dos patas
+func UpdateSwap(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var request models.SwapRequest
+	if !bindSwapRequest(c, &request) {
+		return
+	}
+
+	swap, err := repository.NewCryptoRepository(database.DB).UpdateSwap(userID, c.Param("id"), request)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Intercambio no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Intercambio actualizado exitosamente", "swap": swap})
+}
+
+// DeleteSwap elimina las dos patas de un intercambio
+func DeleteSwap(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	err := repository.NewCryptoRepository(database.DB).DeleteSwap(userID, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Intercambio no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al eliminar el intercambio: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Intercambio eliminado exitosamente"})
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// validateBatchOperation verifica que una operación del lote tenga los datos que su acción necesita.
+// knownTickers memoriza por clave de cotización (ver services.PriceKey) qué activos existen.
+func validateBatchOperation(operation models.TransactionBatchOperation, knownTickers map[string]bool) error {
+	switch operation.Action {
+	case models.BatchActionCreate, models.BatchActionUpdate:
+		if operation.Transaction == nil {
+			return errors.New("transaction es requerido")
+		}
+		if operation.Action == models.BatchActionUpdate && operation.ID == "" {
+			return errors.New("id es requerido")
+		}
+		assetClass, err := services.NormalizeAssetClass(operation.Transaction.AssetClass)
+		if err != nil {
+			return err
+		}
+		if _, err := services.NormalizeTransactionType(operation.Transaction.Type); err != nil {
+			return err
+		}
+		key := services.PriceKey(assetClass, operation.Transaction.Ticker)
+		if _, checked := knownTickers[key]; !checked {
+			knownTickers[key] = services.AssetExists(assetClass, operation.Transaction.Ticker)
+		}
+		if !knownTickers[key] {
+			return errors.New("Activo no encontrado")
+		}
+	case models.BatchActionDelete:
+		if operation.ID == "" {
+			return errors.New("id es requerido")
+		}
+	default:
+		return fmt.Errorf("acción inválida, debe ser %s, %s o %s",
+			models.BatchActionCreate, models.BatchActionUpdate, models.BatchActionDelete)
+	}
+	return nil
+}
+
+// ApplyTransactionBatch crea, actualiza y elimina varias transacciones en una sola operación.
+// El lote es atómico: si una operación falla no se aplica ninguna y se indica el índice de la que falló.
+func ApplyTransactionBatch(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var request models.TransactionBatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(request.Operations) > models.MaxBatchOperations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("el lote no puede tener más de %d operaciones", models.MaxBatchOperations)})
+		return
+	}
+
+	knownTickers := make(map[string]bool)
+	for index, operation := range request.Operations {
+		if err := validateBatchOperation(operation, knownTickers); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "index": index})
+			return
+		}
+	}
+
+	results, err := repository.NewCryptoRepository(database.DB).ApplyTransactionBatch(userID, request.Operations)
+	if err != nil {
+		var opErr *repository.BatchOperationError
+		if errors.As(err, &opErr) {
+			status := http.StatusBadRequest
+			if errors.Is(err, repository.ErrSwapLeg) {
+				status = http.StatusConflict
+			}
+			c.JSON(status, gin.H{"error": opErr.Err.Error(), "index": opErr.Index})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al aplicar el lote: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lote aplicado exitosamente", "results": results})
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -28,9 +30,19 @@ func CreateTransaction(c *gin.Context) {
 	userIDStr := userID.(string)
 	transaction.UserID = userIDStr
 
-	// Validar que el ticker exista
-	if !repository.CryptoExists(transaction.Ticker) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Criptomoneda no encontrada"})
+	// Validar la clase de activo y que el ticker exista en su mercado
+	assetClass, err := services.NormalizeAssetClass(transaction.AssetClass)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transaction.AssetClass = assetClass
+	if transaction.Type, err = services.NormalizeTransactionType(transaction.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !services.AssetExists(transaction.AssetClass, transaction.Ticker) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Activo no encontrado"})
 		return
 	}
 
@@ -41,7 +53,7 @@ func CreateTransaction(c *gin.Context) {
 	}
 
 	// Actualizar el balance del usuario
-	if err := repository.UpdateUserBalance(userIDStr, transaction.Amount*transaction.PurchasePrice); err != nil {
+	if err := repository.UpdateUserBalance(userIDStr, transaction.Amount.Mul(transaction.PurchasePrice)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar balance"})
 		return
 	}
@@ -53,7 +65,9 @@ func CreateTransaction(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"message": "Transacciu00f3n creada exitosamente", "transaction": transaction})
 }
 
-// GetUserTransactions obtiene todas las transacciones del usuario con detalles adicionales
+// GetUserTransactions obtiene todas las transacciones del usuario con detalles adicionales.
+// Si se indica algún parámetro de listado (ticker, type, from, to, min_total, max_total, q, sort,
+// order, limit o cursor) devuelve una página filtrada; ver listTransactions.
 func GetUserTransactions(c *gin.Context) {
 	// Obtener el ID del usuario del contexto
 	userID, exists := c.Get("userId")
@@ -65,6 +79,12 @@ func GetUserTransactions(c *gin.Context) {
 	// Convertir el ID a string
 	userIDStr := userID.(string)
 
+	// Con filtros, orden o paginación se devuelve una página en lugar del historial completo
+	if hasTransactionListParams(c) {
+		listTransactions(c, userIDStr)
+		return
+	}
+
 	// Obtener transacciones con detalles
 	transactions, err := repository.GetUserTransactionsWithDetails(userIDStr)
 	if err != nil {
@@ -138,6 +158,10 @@ func UpdateTransaction(c *gin.Context) {
 	updatedTransaction.ID = transactionID
 	updatedTransaction.UserID = userIDStr
 	if err := repository.UpdateTransaction(&updatedTransaction); err != nil {
+		if errors.Is(err, repository.ErrSwapLeg) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
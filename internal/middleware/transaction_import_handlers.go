@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// importParam lee un parámetro de la URL o, si no está, del formulario multipart
+func importParam(c *gin.Context, name string) string {
+	if value := c.Query(name); value != "" {
+		return value
+	}
+	return c.PostForm(name)
+}
+
+// importFlag lee un parámetro booleano de la importación (por defecto false)
+func importFlag(c *gin.Context, name string) (bool, error) {
+	value := importParam(c, name)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// ImportTransactions importa transacciones desde el CSV de un exchange (archivo "file" o cuerpo text/csv).
+// Parámetros: format (binance, coinbase, kraken o generic), mapping (JSON con las columnas del formato
+// generic), dry_run para obtener la vista previa sin guardar y skip_invalid para importar solo las filas válidas.
+func ImportTransactions(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	var reader io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil {
+		opened, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no se pudo leer el archivo: " + err.Error()})
+			return
+		}
+		defer opened.Close()
+		reader = opened
+	}
+
+	format := importParam(c, "format")
+	if format == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "se debe indicar el formato: binance, coinbase, kraken o generic"})
+		return
+	}
+
+	var mapping map[string]string
+	if raw := importParam(c, "mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mapping inválido, debe ser un objeto JSON: " + err.Error()})
+			return
+		}
+	}
+
+	var options repository.TransactionImportOptions
+	var err error
+	if options.DryRun, err = importFlag(c, "dry_run"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run inválido"})
+		return
+	}
+	if options.SkipInvalid, err = importFlag(c, "skip_invalid"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "skip_invalid inválido"})
+		return
+	}
+
+	parsed, err := services.ParseTransactionsCSV(reader, format, mapping)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := repository.NewCryptoRepository(database.DB).ImportTransactions(userID, parsed, options)
+	if err == repository.ErrImportInvalidRows {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "import": result})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al importar las transacciones: " + err.Error()})
+		return
+	}
+
+	message := "Transacciones importadas exitosamente"
+	if options.DryRun {
+		message = "Vista previa de la importación, no se guardó ninguna transacción"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "import": result})
+}
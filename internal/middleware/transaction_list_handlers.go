@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/repository"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// transactionListParams son los parámetros que activan el listado paginado de transacciones
+var transactionListParams = []string{
+	"ticker", "type", "account_id", "from", "to", "min_total", "max_total", "q", "sort", "order", "limit", "cursor",
+}
+
+// hasTransactionListParams indica si la petición usa alguno de los parámetros del listado paginado
+func hasTransactionListParams(c *gin.Context) bool {
+	for _, name := range transactionListParams {
+		if _, exists := c.GetQuery(name); exists {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFloatQuery interpreta un parámetro numérico opcional
+func parseFloatQuery(c *gin.Context, name string) (*float64, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " debe ser un número"})
+		return nil, false
+	}
+	return &number, true
+}
+
+// listTransactions devuelve una página de transacciones del usuario.
+// Filtros: ticker (uno o varios separados por coma), type (compra, venta, swap, transferencia, ingreso
+// o un tipo de ingreso), account_id (origen o destino), from y to (YYYY-MM-DD, ambos inclusive), min_total,
+// max_total y q (texto en la nota).
+// Orden: sort=date|total|gain y order=asc|desc (por defecto date desc).
+// Paginación: limit (hasta 200) y cursor, el next_cursor devuelto en la página anterior.
+func listTransactions(c *gin.Context, userID string) {
+	query := models.TransactionQuery{
+		Type:       strings.ToLower(c.Query("type")),
+		AccountID:  c.Query("account_id"),
+		Search:     c.Query("q"),
+		SortBy:     strings.ToLower(c.DefaultQuery("sort", models.TransactionSortDate)),
+		Descending: true,
+		Cursor:     c.Query("cursor"),
+	}
+
+	for _, ticker := range strings.Split(c.Query("ticker"), ",") {
+		if ticker = strings.TrimSpace(ticker); ticker != "" {
+			query.Tickers = append(query.Tickers, ticker)
+		}
+	}
+
+	switch query.Type {
+	case "", models.TransactionTypeBuy, models.TransactionTypeSell, models.TransactionTypeSwap,
+		models.TransactionTypeTransfer, models.TransactionTypeIncome:
+	default:
+		if !services.IsIncomeType(query.Type) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "type debe ser compra, venta, swap, transferencia, ingreso o un tipo de ingreso"})
+			return
+		}
+	}
+
+	switch query.SortBy {
+	case models.TransactionSortDate, models.TransactionSortTotal, models.TransactionSortGain:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort debe ser date, total o gain"})
+		return
+	}
+
+	switch strings.ToLower(c.DefaultQuery("order", "desc")) {
+	case "desc":
+	case "asc":
+		query.Descending = false
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order debe ser asc o desc"})
+		return
+	}
+
+	var ok bool
+	if query.From, ok = parseDateQuery(c, "from"); !ok {
+		return
+	}
+	if query.To, ok = parseDateQuery(c, "to"); !ok {
+		return
+	}
+	if !query.To.IsZero() {
+		// Incluir el día completo indicado en "to"
+		query.To = query.To.AddDate(0, 0, 1)
+	}
+	if query.MinTotal, ok = parseFloatQuery(c, "min_total"); !ok {
+		return
+	}
+	if query.MaxTotal, ok = parseFloatQuery(c, "max_total"); !ok {
+		return
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit debe ser un número mayor a 0"})
+			return
+		}
+		query.Limit = value
+	}
+
+	page, err := repository.NewCryptoRepository(database.DB).WithPrices(requestPrices(c)).ListTransactions(userID, query)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al listar las transacciones: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Tipos de cuenta donde se guardan los activos
+const (
+	AccountTypeExchange       = "exchange"
+	AccountTypeHardwareWallet = "hardware_wallet"
+	AccountTypeSoftwareWallet = "software_wallet"
+	AccountTypeCustodial      = "custodial"
+)
+
+// AccountIDNone se envía como account_id al editar una transacción para quitarle la cuenta;
+// un account_id vacío conserva la que tenía
+const AccountIDNone = "none"
+
+// Account es una cuenta de un exchange o una billetera donde el usuario guarda sus activos
+type Account struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name" binding:"required"`
+	Type      string    `json:"type" binding:"required"` // "exchange", "hardware_wallet", "software_wallet" o "custodial"
+	Provider  string    `json:"provider,omitempty"`      // Exchange o fabricante (ej. Binance, Ledger)
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AccountAsset es la cantidad de un activo guardada en una cuenta
+type AccountAsset struct {
+	Ticker       string          `json:"ticker"`
+	Amount       decimal.Decimal `json:"amount"`
+	CurrentPrice float64         `json:"current_price"` // Campo calculado con el precio de mercado
+	Value        float64         `json:"value"`         // Campo calculado con el precio de mercado
+}
+
+// AccountHoldings son las tenencias guardadas en una cuenta. Las transacciones sin cuenta
+// se agrupan con AccountID vacío.
+type AccountHoldings struct {
+	AccountID   string         `json:"account_id"`
+	AccountName string         `json:"account_name"`
+	AccountType string         `json:"account_type,omitempty"`
+	Value       float64        `json:"value"`
+	Weight      float64        `json:"weight"` // Porcentaje del portafolio (0-100)
+	Assets      []AccountAsset `json:"assets"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// HistoricalPrice es el precio de cierre diario de una criptomoneda
+type HistoricalPrice struct {
+	Ticker string    `json:"ticker"`
+	Date   time.Time `json:"date"`
+	Close  float64   `json:"close"`
+}
+
+// BacktestAllocation es el peso de una criptomoneda dentro de una canasta
+type BacktestAllocation struct {
+	Ticker string  `json:"ticker" binding:"required"`
+	Weight float64 `json:"weight" binding:"required,gt=0"`
+}
+
+// DCABacktestRequest describe el plan DCA a simular
+type DCABacktestRequest struct {
+	Ticker    string               `json:"ticker,omitempty"` // Una sola criptomoneda...
+	Basket    []BacktestAllocation `json:"basket,omitempty"` // ...o una canasta con pesos
+	AmountUSD float64              `json:"amount_usd" binding:"required,gt=0"`
+	Cadence   string               `json:"cadence" binding:"required"`
+	CronExpr  string               `json:"cron_expr,omitempty"`
+	StartDate time.Time            `json:"start_date" binding:"required"`
+	EndDate   time.Time            `json:"end_date" binding:"required"`
+}
+
+// BacktestTransaction es una compra simulada
+type BacktestTransaction struct {
+	Date   time.Time `json:"date"`
+	Ticker string    `json:"ticker"`
+	Price  float64   `json:"price"`
+	Amount float64   `json:"amount"`
+	Total  float64   `json:"total"`
+}
+
+// BacktestSummary resume el resultado de una estrategia simulada
+type BacktestSummary struct {
+	TotalInvested  float64           `json:"total_invested"`
+	FinalValue     float64           `json:"final_value"`
+	Profit         float64           `json:"profit"`
+	ProfitPercent  float64           `json:"profit_percent"`
+	MaxDrawdown    float64           `json:"max_drawdown"` // Mayor caída porcentual desde un máximo del valor por dólar invertido
+	MaxDrawdownAt  *time.Time        `json:"max_drawdown_at,omitempty"`
+	Holdings       []CryptoDashboard `json:"holdings"`
+	AverageCost    float64           `json:"average_cost,omitempty"` // Solo cuando se simula una única criptomoneda
+	PurchasesCount int               `json:"purchases_count"`
+}
+
+// DCABacktestResult contiene la simulación DCA y su comparación con una compra única inicial
+type DCABacktestResult struct {
+	StartDate    time.Time             `json:"start_date"`
+	EndDate      time.Time             `json:"end_date"`
+	Cadence      string                `json:"cadence"`
+	Allocations  []BacktestAllocation  `json:"allocations"`
+	Transactions []BacktestTransaction `json:"transactions"`
+	DCA          BacktestSummary       `json:"dca"`
+	LumpSum      BacktestSummary       `json:"lump_sum"`
+	Difference   float64               `json:"difference"`                  // Valor final DCA menos valor final de la compra única
+	Skipped      int                   `json:"skipped_purchases,omitempty"` // Compras omitidas por falta de un precio histórico reciente
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// BackupVersion es la versión actual del formato de las copias de seguridad
+const BackupVersion = 1
+
+// Políticas para resolver los conflictos al restaurar una copia de seguridad
+const (
+	BackupConflictSkip      = "skip"      // Se conservan los datos existentes
+	BackupConflictOverwrite = "overwrite" // Se reemplazan por los de la copia, incluidos los hijos de las bolsas
+	BackupConflictMerge     = "merge"     // Se completan con la copia: campos vacíos de las transacciones, máximo y mínimo de los snapshots y los hijos de las bolsas
+)
+
+// Backup es la copia de seguridad completa de la cartera de un usuario
+type Backup struct {
+	Version      int                  `json:"version"`
+	ExportedAt   time.Time            `json:"exported_at"`
+	UserID       string               `json:"user_id"`
+	Accounts     []Account            `json:"accounts"`
+	Transactions []CryptoTransaction  `json:"transactions"`
+	Bolsas       []Bolsa              `json:"bolsas"` // Con sus activos, etiquetas y reglas
+	Snapshots    []InvestmentSnapshot `json:"snapshots"`
+}
+
+// BackupEntityResult cuenta lo que pasó con un tipo de entidad al restaurar una copia
+type BackupEntityResult struct {
+	Created  int `json:"created"`
+	Updated  int `json:"updated"`
+	Skipped  int `json:"skipped"`
+	Remapped int `json:"remapped"` // Creadas con un ID nuevo porque el original ya estaba en uso
+}
+
+// BackupRestoreResult es el resumen de la restauración de una copia de seguridad
+type BackupRestoreResult struct {
+	Policy       string             `json:"policy"`
+	Accounts     BackupEntityResult `json:"accounts"`
+	Transactions BackupEntityResult `json:"transactions"`
+	Bolsas       BackupEntityResult `json:"bolsas"`
+	Assets       BackupEntityResult `json:"assets"`
+	Tags         BackupEntityResult `json:"tags"`
+	Rules        BackupEntityResult `json:"rules"`
+	Snapshots    BackupEntityResult `json:"snapshots"`
+}
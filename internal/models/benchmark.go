@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Benchmarks contra los que se puede comparar el portafolio
+const (
+	BenchmarkBTC    = "btc"     // Todo en BTC
+	BenchmarkETH    = "eth"     // Todo en ETH
+	BenchmarkBTCETH = "btc_eth" // 60% BTC y 40% ETH
+	BenchmarkCustom = "custom"  // Canasta con pesos definidos por el usuario
+)
+
+// BenchmarkPoint es el valor del portafolio y del benchmark al final de un día con snapshot
+type BenchmarkPoint struct {
+	Date           time.Time `json:"date"`
+	PortfolioValue float64   `json:"portfolio_value"`
+	BenchmarkValue float64   `json:"benchmark_value"`
+	NetFlows       float64   `json:"net_flows"` // Aportes netos acumulados desde el inicio
+}
+
+// BenchmarkComparison compara la curva del portafolio con la que habrían producido los mismos
+// aportes y retiros invertidos en el benchmark. Los rendimientos son ponderados por tiempo y están en %.
+type BenchmarkComparison struct {
+	Benchmark          string               `json:"benchmark"`
+	Allocations        []BacktestAllocation `json:"allocations"`
+	Period             string               `json:"period"`
+	From               time.Time            `json:"from"`
+	To                 time.Time            `json:"to"`
+	PortfolioReturn    *float64             `json:"portfolio_return"`
+	BenchmarkReturn    *float64             `json:"benchmark_return"`
+	Alpha              *float64             `json:"alpha"`               // Rendimiento del portafolio menos el del benchmark (puntos porcentuales)
+	TrackingDifference float64              `json:"tracking_difference"` // Valor final del portafolio menos el del benchmark (USD)
+	TrackingError      *float64             `json:"tracking_error"`      // Desvío anualizado de la diferencia de rendimientos diarios (%)
+	Points             []BenchmarkPoint     `json:"points"`
+}
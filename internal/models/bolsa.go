@@ -1,14 +1,23 @@
 package models
 
 import (
-	"fmt"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 // Tipos de reglas para triggers
 const (
-	TriggerTypePriceReached = "price_reached"
-	TriggerTypeValueReached = "value_reached"
+	TriggerTypePriceReached       = "price_reached"
+	TriggerTypeValueReached       = "value_reached"
+	TriggerTypeGoalPercentReached = "goal_percent_reached"
+	TriggerTypeDrawdownExceeded   = "drawdown_exceeded"
+)
+
+// Dirección en la que se compara el valor observado con el objetivo de una regla
+const (
+	TriggerDirectionAbove = "above" // Se dispara cuando el valor es mayor o igual al objetivo
+	TriggerDirectionBelow = "below" // Se dispara cuando el valor es menor o igual al objetivo
 )
 
 // ProgressInfo contiene información sobre el progreso hacia el objetivo de una bolsa
@@ -22,53 +31,67 @@ type ProgressInfo struct {
 
 // Bolsa representa una sub-cartera con un objetivo específico
 type Bolsa struct {
-	ID           string         `json:"id"`
-	UserID       string         `json:"user_id"`
-	Name         string         `json:"name" binding:"required"`
-	Description  string         `json:"description"`
-	Goal         float64        `json:"goal"`
-	CurrentValue float64        `json:"current_value"`      // Campo calculado, no almacenado
-	Progress     *ProgressInfo  `json:"progress,omitempty"` // Información de progreso hacia el objetivo
-	Tags         []string       `json:"tags,omitempty"`
-	Assets       []AssetInBolsa `json:"assets,omitempty"`
-	Rules        []TriggerRule  `json:"rules,omitempty"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
+	ID           string          `json:"id"`
+	UserID       string          `json:"user_id"`
+	Name         string          `json:"name" binding:"required"`
+	Description  string          `json:"description"`
+	Goal         decimal.Decimal `json:"goal"`
+	CurrentValue float64         `json:"current_value"`      // Campo calculado, no almacenado
+	Currency     string          `json:"currency,omitempty"` // Moneda de los valores calculados, no almacenada
+	Progress     *ProgressInfo   `json:"progress,omitempty"` // Información de progreso hacia el objetivo
+	Tags         []string        `json:"tags,omitempty"`
+	Assets       []AssetInBolsa  `json:"assets,omitempty"`
+	Rules        []TriggerRule   `json:"rules,omitempty"`
+	RuleHistory  []TriggerEvent  `json:"rule_history,omitempty"` // Disparos recientes de las reglas
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
 }
 
 // AssetInBolsa representa un activo dentro de una bolsa
 type AssetInBolsa struct {
-	ID              string    `json:"id"`
-	BolsaID         string    `json:"bolsa_id"`
-	CryptoName      string    `json:"crypto_name" binding:"required"`
-	Ticker          string    `json:"ticker" binding:"required"`
-	Amount          float64   `json:"amount" binding:"required,gt=0"`
-	PurchasePrice   float64   `json:"purchase_price"`
-	Total           float64   `json:"total"`
-	CurrentPrice    float64   `json:"current_price"`     // Campo calculado, no almacenado
-	CurrentValue    float64   `json:"current_value"`     // Campo calculado, no almacenado
-	GainLoss        float64   `json:"gain_loss"`         // Campo calculado, no almacenado
-	GainLossPercent float64   `json:"gain_loss_percent"` // Campo calculado, no almacenado
-	ImageURL        string    `json:"image_url,omitempty"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID              string          `json:"id"`
+	BolsaID         string          `json:"bolsa_id"`
+	CryptoName      string          `json:"crypto_name" binding:"required"`
+	Ticker          string          `json:"ticker" binding:"required"`
+	Amount          decimal.Decimal `json:"amount" binding:"required,gt=0"`
+	PurchasePrice   decimal.Decimal `json:"purchase_price"`
+	Total           decimal.Decimal `json:"total"`
+	CurrentPrice    float64         `json:"current_price"`     // Campo calculado, no almacenado
+	CurrentValue    float64         `json:"current_value"`     // Campo calculado, no almacenado
+	GainLoss        float64         `json:"gain_loss"`         // Campo calculado, no almacenado
+	GainLossPercent float64         `json:"gain_loss_percent"` // Campo calculado, no almacenado
+	AssetClass      string          `json:"asset_class"`       // crypto (por defecto), stock, etf o cash
+	ImageURL        string          `json:"image_url,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
 }
 
 // TriggerRule representa una regla para una bolsa
 type TriggerRule struct {
-	ID          string    `json:"id"`
-	BolsaID     string    `json:"bolsa_id"`
-	Type        string    `json:"type" binding:"required"` // "price_reached" o "value_reached"
-	Ticker      string    `json:"ticker,omitempty"`        // Solo para reglas de tipo "price_reached"
-	TargetValue float64   `json:"target_value" binding:"required"`
-	Active      bool      `json:"active"`
-	Triggered   bool      `json:"triggered"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID              string          `json:"id"`
+	BolsaID         string          `json:"bolsa_id"`
+	Type            string          `json:"type" binding:"required"` // "price_reached", "value_reached", "goal_percent_reached" o "drawdown_exceeded"
+	Ticker          string          `json:"ticker,omitempty"`        // Solo para reglas de tipo "price_reached"
+	TargetValue     decimal.Decimal `json:"target_value" binding:"required"`
+	Direction       string          `json:"direction"`  // "above" o "below"
+	PeakValue       decimal.Decimal `json:"peak_value"` // Máximo valor observado, solo para "drawdown_exceeded"
+	Active          bool            `json:"active"`
+	Triggered       bool            `json:"triggered"`
+	Rearm           bool            `json:"rearm"`            // Se rearma sola cuando la condición deja de cumplirse
+	CooldownMinutes int             `json:"cooldown_minutes"` // Tiempo mínimo entre dos disparos
+	LastTriggeredAt *time.Time      `json:"last_triggered_at,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
 }
 
-// GenerateUUID - Función auxiliar para generar UUIDs
-func GenerateUUID() string {
-	// Usamos el timestamp en nanosegundos como ID único
-	return fmt.Sprintf("%d", time.Now().UnixNano())
+// TriggerEvent registra un disparo de una regla junto con el valor observado
+type TriggerEvent struct {
+	ID            string          `json:"id"`
+	RuleID        string          `json:"rule_id"`
+	BolsaID       string          `json:"bolsa_id"`
+	Type          string          `json:"type"`
+	Ticker        string          `json:"ticker,omitempty"`
+	TargetValue   decimal.Decimal `json:"target_value"`
+	ObservedValue decimal.Decimal `json:"observed_value"`
+	TriggeredAt   time.Time       `json:"triggered_at"`
 }
@@ -1,25 +1,62 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 // Tipo de transacción
 const (
 	TransactionTypeBuy  = "compra"
 	TransactionTypeSell = "venta"
+	TransactionTypeSwap = "swap" // Operación lógica formada por una venta y una compra enlazadas
+
+	// Mueve activos de una cuenta a otra sin cambiar su costo base
+	TransactionTypeTransfer = "transferencia"
+)
+
+// Tipos de transacción de ingreso: aumentan las tenencias con un costo base igual al valor de
+// mercado al recibirlas, pero no cuentan como dinero invertido
+const (
+	TransactionTypeStakingReward = "staking"
+	TransactionTypeAirdrop       = "airdrop"
+	TransactionTypeInterest      = "interes"
+	TransactionTypeMining        = "mineria"
+	TransactionTypeGift          = "regalo"
+	TransactionTypeIncome        = "ingreso" // Filtro lógico que agrupa todos los tipos de ingreso
 )
 
+// Clase de activo de una transacción o de un activo de una bolsa
+const (
+	AssetClassCrypto = "crypto"
+	AssetClassStock  = "stock"
+	AssetClassETF    = "etf"
+	AssetClassCash   = "cash" // El ticker es el código de la moneda (USD, EUR...)
+)
+
+// FeeCurrencyUSD indica que la comisión se pagó en dólares
+const FeeCurrencyUSD = "USD"
+
 type CryptoTransaction struct {
-	ID            string    `json:"id"`
-	UserID        string    `json:"user_id"`
-	CryptoName    string    `json:"crypto_name" binding:"required"`
-	Ticker        string    `json:"ticker" binding:"required"`
-	Amount        float64   `json:"amount" binding:"required,gt=0"`
-	PurchasePrice float64   `json:"purchase_price"`
-	Total         float64   `json:"total"`
-	Date          time.Time `json:"date"`
-	Note          string    `json:"note,omitempty"`
-	CreatedAt     time.Time `json:"created_at"`
-	Type          string    `json:"type"`                    // "compra" o "venta"
-	USDTReceived  float64   `json:"usdt_received,omitempty"` // Solo para ventas
-	ImageURL      string    `json:"image_url,omitempty"`     // URL de la imagen de la criptomoneda
+	ID            string          `json:"id"`
+	UserID        string          `json:"user_id"`
+	CryptoName    string          `json:"crypto_name" binding:"required"`
+	Ticker        string          `json:"ticker" binding:"required"`
+	Amount        decimal.Decimal `json:"amount" binding:"required,gt=0"`
+	PurchasePrice decimal.Decimal `json:"purchase_price"`
+	Total         decimal.Decimal `json:"total"`
+	Date          time.Time       `json:"date"`
+	Note          string          `json:"note,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Type          string          `json:"type"`                    // "compra", "venta", "transferencia" o un tipo de ingreso
+	USDTReceived  decimal.Decimal `json:"usdt_received"`           // Solo para ventas
+	ImageURL      string          `json:"image_url,omitempty"`     // URL de la imagen de la criptomoneda
+	Fee           decimal.Decimal `json:"fee" binding:"gte=0"`     // Comisión pagada en la operación
+	FeeCurrency   string          `json:"fee_currency,omitempty"`  // "USD", el ticker operado u otra moneda (ej. BNB)
+	FeeUSD        decimal.Decimal `json:"fee_usd"`                 // Valor de la comisión en dólares al momento de la operación
+	SwapID        string          `json:"swap_id,omitempty"`       // Enlaza las dos patas de un intercambio
+	AssetClass    string          `json:"asset_class"`             // crypto (por defecto), stock, etf o cash
+	AccountID     string          `json:"account_id,omitempty"`    // Cuenta donde se guardan los activos; en transferencias, la de origen
+	ToAccountID   string          `json:"to_account_id,omitempty"` // Solo para transferencias: cuenta de destino
 }
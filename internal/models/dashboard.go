@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 type CryptoDashboard struct {
 	Ticker        string  `json:"ticker"`
@@ -12,6 +16,16 @@ type CryptoDashboard struct {
 	CurrentPrice  float64 `json:"current_price"`
 	CurrentProfit float64 `json:"current_profit"`
 	ProfitPercent float64 `json:"profit_percent"`
+	// Ganancia realizada por las ventas según el método de costo base del usuario
+	RealizedProfit float64 `json:"realized_profit"`
+	// Comisiones pagadas en dólares en las operaciones de esta criptomoneda
+	FeesPaid float64 `json:"fees_paid"`
+	// Valor de mercado de los ingresos recibidos (staking, airdrops...), excluido de TotalInvested
+	Income float64 `json:"income"`
+	// Clase de activo (crypto, stock, etf o cash)
+	AssetClass string `json:"asset_class"`
+	// Indica si su mercado está operando; si no, el precio actual es el del último cierre
+	MarketOpen bool `json:"market_open"`
 }
 
 // DailyValue representa el valor total de las inversiones en un día específico
@@ -30,15 +44,15 @@ type InvestmentHistory struct {
 
 // InvestmentSnapshot representa un registro del valor total de las inversiones en un momento específico
 type InvestmentSnapshot struct {
-	ID               string    `json:"id"`
-	UserID           string    `json:"user_id"`
-	Date             time.Time `json:"date"`
-	TotalValue       float64   `json:"total_value"`
-	TotalInvested    float64   `json:"total_invested"`
-	Profit           float64   `json:"profit"`
-	ProfitPercentage float64   `json:"profit_percentage"`
-	MaxValue         float64   `json:"max_value"`
-	MinValue         float64   `json:"min_value"`
+	ID               string          `json:"id"`
+	UserID           string          `json:"user_id"`
+	Date             time.Time       `json:"date"`
+	TotalValue       decimal.Decimal `json:"total_value"`
+	TotalInvested    decimal.Decimal `json:"total_invested"`
+	Profit           decimal.Decimal `json:"profit"`
+	ProfitPercentage float64         `json:"profit_percentage"`
+	MaxValue         decimal.Decimal `json:"max_value"`
+	MinValue         decimal.Decimal `json:"min_value"`
 }
 
 // Balance representa el balance actual del usuario con información sobre sus inversiones
@@ -46,6 +60,8 @@ type Balance struct {
 	TotalBalance     float64   `json:"total_balance"`     // Valor total actual de todas las inversiones
 	TotalInvested    float64   `json:"total_invested"`    // Total invertido en todas las criptomonedas
 	TotalProfit      float64   `json:"total_profit"`      // Ganancia/pérdida total (TotalBalance - TotalInvested)
+	TotalIncome      float64   `json:"total_income"`      // Valor de los ingresos recibidos, incluido en la ganancia
 	ProfitPercentage float64   `json:"profit_percentage"`  // Porcentaje de ganancia/pérdida
+	Currency         string    `json:"currency"`          // Moneda fiat en la que se expresan los valores
 	LastUpdated      time.Time `json:"last_updated"`      // Fecha y hora de la última actualización
 }
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Frecuencias de un plan DCA
+const (
+	DCACadenceDaily   = "daily"
+	DCACadenceWeekly  = "weekly"
+	DCACadenceMonthly = "monthly"
+	DCACadenceCron    = "cron"
+)
+
+// Resultados posibles de una ejecución de un plan DCA
+const (
+	DCAExecutionSuccess = "success"
+	DCAExecutionFailed  = "failed"
+)
+
+// DCAPlan representa un plan de compras periódicas (dollar-cost averaging)
+type DCAPlan struct {
+	ID         string          `json:"id"`
+	UserID     string          `json:"user_id"`
+	CryptoName string          `json:"crypto_name" binding:"required"`
+	Ticker     string          `json:"ticker" binding:"required"`
+	AssetClass string          `json:"asset_class"`                        // crypto (por defecto), stock o etf
+	AmountUSD  decimal.Decimal `json:"amount_usd" binding:"required,gt=0"` // Monto en USD a invertir en cada periodo
+	Cadence    string          `json:"cadence" binding:"required"`         // "daily", "weekly", "monthly" o "cron"
+	CronExpr   string          `json:"cron_expr,omitempty"`                // Solo para la frecuencia "cron"
+	StartDate  time.Time       `json:"start_date"`
+	EndDate    *time.Time      `json:"end_date,omitempty"`
+	BolsaID    string          `json:"bolsa_id,omitempty"` // Bolsa destino opcional de las compras
+	Active     bool            `json:"active"`
+	NextRunAt  *time.Time      `json:"next_run_at,omitempty"`
+	LastRunAt  *time.Time      `json:"last_run_at,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+
+	Executions []DCAExecution `json:"executions,omitempty"`
+}
+
+// DCAExecution registra una ejecución de un plan DCA y su resultado
+type DCAExecution struct {
+	ID            string          `json:"id"`
+	PlanID        string          `json:"plan_id"`
+	UserID        string          `json:"user_id"`
+	ScheduledAt   time.Time       `json:"scheduled_at"`
+	ExecutedAt    time.Time       `json:"executed_at"`
+	Status        string          `json:"status"` // "success" o "failed"
+	Price         decimal.Decimal `json:"price"`
+	Amount        decimal.Decimal `json:"amount"`
+	Total         decimal.Decimal `json:"total"`
+	TransactionID string          `json:"transaction_id,omitempty"`
+	Error         string          `json:"error,omitempty"`
+}
@@ -2,12 +2,31 @@ package models
 
 // Holdings representa el resumen de las tenencias del usuario
 type Holdings struct {
-	TotalCurrentValue float64        `json:"total_current_value"` // Valor total actual de todas las criptomonedas
-	TotalInvested     float64        `json:"total_invested"`      // Total invertido históricamente
-	TotalProfit       float64        `json:"total_profit"`        // Ganancia o pérdida total
-	ProfitPercentage  float64        `json:"profit_percentage"`   // Porcentaje de ganancia/pérdida
-	Distribution      []CryptoWeight `json:"distribution"`        // Para el gráfico de torta
-	ChartData         PieChartData   `json:"chart_data"`          // Datos formateados para el gráfico de torta
+	TotalCurrentValue float64             `json:"total_current_value"` // Valor total actual de todas las criptomonedas
+	TotalInvested     float64             `json:"total_invested"`      // Total invertido históricamente
+	TotalProfit       float64             `json:"total_profit"`        // Ganancia o pérdida total
+	ProfitPercentage  float64             `json:"profit_percentage"`   // Porcentaje de ganancia/pérdida
+	RealizedProfit    float64             `json:"realized_profit"`     // Ganancia realizada por todas las ventas
+	TotalFees         float64             `json:"total_fees"`          // Comisiones pagadas en dólares en todas las operaciones
+	TotalIncome       float64             `json:"total_income"`        // Valor de los ingresos recibidos, que no cuentan como invertido
+	CostBasisMethod   string              `json:"cost_basis_method"`   // Método usado para emparejar ventas y compras
+	Currency          string              `json:"currency"`            // Moneda fiat en la que se expresan los valores
+	Distribution      []CryptoWeight      `json:"distribution"`        // Para el gráfico de torta
+	ChartData         PieChartData        `json:"chart_data"`          // Datos formateados para el gráfico de torta
+	ByAssetClass      []AssetClassSummary `json:"by_asset_class"`      // Totales agrupados por clase de activo
+	ByAccount         []AccountHoldings   `json:"by_account"`          // Tenencias de cada cuenta o billetera
+}
+
+// AssetClassSummary agrupa las tenencias de una clase de activo (crypto, stock, etf o cash)
+type AssetClassSummary struct {
+	AssetClass       string   `json:"asset_class"`
+	CurrentValue     float64  `json:"current_value"`
+	TotalInvested    float64  `json:"total_invested"`
+	Profit           float64  `json:"profit"`
+	ProfitPercentage float64  `json:"profit_percentage"`
+	Weight           float64  `json:"weight"` // Porcentaje del portafolio (0-100)
+	Tickers          []string `json:"tickers"`
+	MarketOpen       bool     `json:"market_open"`
 }
 
 // CryptoWeight representa el peso de una criptomoneda en el portafolio
@@ -21,12 +40,18 @@ type CryptoWeight struct {
 	OthersDetail []CryptoWeight `json:"others_detail,omitempty"` // Nuevo campo para detalles de criptomonedas menores
 }
 
+// AssetClassGroup son las posiciones del dashboard de una clase de activo junto con sus totales
+type AssetClassGroup struct {
+	AssetClassSummary
+	Assets []CryptoDashboard `json:"assets"`
+}
+
 // PieChartData contiene los datos formateados para un gráfico de torta
 type PieChartData struct {
 	Labels   []string  `json:"labels"`   // Etiquetas (tickers)
 	Values   []float64 `json:"values"`   // Valores (porcentajes)
 	Colors   []string  `json:"colors"`   // Colores para cada segmento
-	Currency string    `json:"currency"` // Moneda (USD)
+	Currency string    `json:"currency"` // Moneda de los valores (USD por defecto)
 }
 
 type HoldingDetail struct {
@@ -0,0 +1,13 @@
+package models
+
+import "github.com/google/uuid"
+
+// GenerateUUID genera el ID de cualquier entidad (transacciones, bolsas, reglas, planes, snapshots...).
+// Es un UUIDv7: empieza con el instante de creación en milisegundos, así que no se repite aunque se
+// generen varios en el mismo nanosegundo y los UUID nuevos se ordenan por creación entre sí.
+// Los IDs guardados antes (timestamps en nanosegundos, con o sin el prefijo "snapshot_") siguen siendo
+// válidos porque las columnas de ID son TEXT, pero como texto se ordenan después de cualquier UUID:
+// el orden por ID solo sirve para desempatar de forma estable, no para saber qué se creó primero.
+func GenerateUUID() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IdempotencyKeyTTL es el tiempo durante el que se guarda la respuesta de una clave de idempotencia
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// IdempotencyRecord es la respuesta guardada para una clave de idempotencia.
+// StatusCode es 0 mientras la petición original se está procesando.
+type IdempotencyRecord struct {
+	UserID       string
+	Key          string
+	RequestHash  string
+	StatusCode   int
+	ContentType  string
+	ResponseBody []byte
+	CreatedAt    time.Time
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Métodos de costo base para emparejar ventas con compras
+const (
+	CostBasisFIFO    = "fifo"    // Se venden primero las compras más antiguas
+	CostBasisLIFO    = "lifo"    // Se venden primero las compras más recientes
+	CostBasisHIFO    = "hifo"    // Se venden primero las compras más caras
+	CostBasisAverage = "average" // Costo promedio ponderado
+)
+
+// UserSettings son las preferencias de cálculo de un usuario
+type UserSettings struct {
+	UserID          string    `json:"user_id"`
+	CostBasisMethod string    `json:"cost_basis_method"`
+	BaseCurrency    string    `json:"base_currency"` // Moneda fiat en la que se muestran las valoraciones
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// OpenLot es lo que queda sin vender de una compra
+type OpenLot struct {
+	TransactionID string          `json:"transaction_id"`
+	Ticker        string          `json:"ticker"`
+	AcquiredAt    time.Time       `json:"acquired_at"`
+	Amount        decimal.Decimal `json:"amount"`
+	UnitCost      decimal.Decimal `json:"unit_cost"`
+	CostBasis     decimal.Decimal `json:"cost_basis"`
+	Income        bool            `json:"income,omitempty"` // El lote se recibió como ingreso y no se compró
+}
+
+// IncomeEvent es un ingreso recibido (staking, airdrop, interés, minería o regalo) valorado
+// a precio de mercado en el momento de recibirlo
+type IncomeEvent struct {
+	TransactionID string          `json:"transaction_id"`
+	Ticker        string          `json:"ticker"`
+	CryptoName    string          `json:"crypto_name"`
+	Type          string          `json:"type"`
+	ReceivedAt    time.Time       `json:"received_at"`
+	Amount        decimal.Decimal `json:"amount"`
+	Value         decimal.Decimal `json:"value"` // Valor de mercado al recibirlo, que pasa a ser su costo base
+}
+
+// LotMatch es la porción de un lote consumida por una venta
+type LotMatch struct {
+	LotTransactionID string          `json:"lot_transaction_id,omitempty"` // Vacío si la venta superó las compras registradas
+	AcquiredAt       time.Time       `json:"acquired_at"`
+	Amount           decimal.Decimal `json:"amount"`
+	UnitCost         decimal.Decimal `json:"unit_cost"`
+	CostBasis        decimal.Decimal `json:"cost_basis"`
+	Proceeds         decimal.Decimal `json:"proceeds"`
+	Gain             decimal.Decimal `json:"gain"`
+}
+
+// RealizedGain es la ganancia o pérdida realizada por una venta
+type RealizedGain struct {
+	TransactionID string          `json:"transaction_id"`
+	Ticker        string          `json:"ticker"`
+	CryptoName    string          `json:"crypto_name"`
+	SoldAt        time.Time       `json:"sold_at"`
+	Amount        decimal.Decimal `json:"amount"`
+	Proceeds      decimal.Decimal `json:"proceeds"`
+	CostBasis     decimal.Decimal `json:"cost_basis"`
+	Gain          decimal.Decimal `json:"gain"`
+	Unmatched     decimal.Decimal `json:"unmatched_amount"`       // Cantidad vendida sin compra que la respalde
+	Fee           decimal.Decimal `json:"fee"`                    // Comisión en dólares de la venta
+	FeeDisposal   bool            `json:"fee_disposal,omitempty"` // La venta es el pago de una comisión con esta criptomoneda
+	Lots          []LotMatch      `json:"lots"`
+}
+
+// RealizedPnL es el resumen de ganancias realizadas de un usuario
+type RealizedPnL struct {
+	Method         string          `json:"method"`
+	TotalProceeds  decimal.Decimal `json:"total_proceeds"`
+	TotalCostBasis decimal.Decimal `json:"total_cost_basis"`
+	TotalGain      decimal.Decimal `json:"total_gain"`
+	Sales          []RealizedGain  `json:"sales"`
+	TotalIncome    decimal.Decimal `json:"total_income"` // Ingresos recibidos en el período, aparte de las ventas
+	Income         []IncomeEvent   `json:"income"`
+}
+
+// UnrealizedPosition es la ganancia o pérdida no realizada de una criptomoneda
+type UnrealizedPosition struct {
+	Ticker       string          `json:"ticker"`
+	CryptoName   string          `json:"crypto_name"`
+	Amount       decimal.Decimal `json:"amount"`
+	CostBasis    decimal.Decimal `json:"cost_basis"`
+	AvgCost      decimal.Decimal `json:"avg_cost"`
+	CurrentPrice float64         `json:"current_price"` // Campo calculado con el precio de mercado
+	CurrentValue float64         `json:"current_value"` // Campo calculado con el precio de mercado
+	Gain         float64         `json:"gain"`          // Campo calculado con el precio de mercado
+	GainPercent  float64         `json:"gain_percent"`  // Campo calculado con el precio de mercado
+	Lots         []OpenLot       `json:"lots"`
+}
+
+// UnrealizedPnL es el resumen de ganancias no realizadas de un usuario
+type UnrealizedPnL struct {
+	Method         string               `json:"method"`
+	TotalCostBasis decimal.Decimal      `json:"total_cost_basis"`
+	TotalValue     float64              `json:"total_value"`
+	TotalGain      float64              `json:"total_gain"`
+	GainPercent    float64              `json:"gain_percent"`
+	Positions      []UnrealizedPosition `json:"positions"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Origen de un saldo informado
+const (
+	ReportedBalanceSourceManual = "manual"
+	ReportedBalanceSourceCSV    = "csv"
+)
+
+// ReportedBalance es el saldo de un ticker que el exchange o la billetera informa para una cuenta
+type ReportedBalance struct {
+	ID           string          `json:"id"`
+	UserID       string          `json:"user_id"`
+	AccountID    string          `json:"account_id"`
+	Ticker       string          `json:"ticker" binding:"required"`
+	Amount       decimal.Decimal `json:"amount" binding:"gte=0"`
+	ReportedAt   time.Time       `json:"reported_at"` // Momento del saldo; si falta se usa el actual
+	Source       string          `json:"source"`      // "manual" o "csv"
+	Note         string          `json:"note,omitempty"`
+	AdjustmentID string          `json:"adjustment_id,omitempty"` // Transacción de ajuste generada a partir de este saldo
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// ReconciliationItem compara el último saldo informado de un ticker con el calculado a partir
+// de las transacciones registradas hasta ese momento
+type ReconciliationItem struct {
+	Ticker            string          `json:"ticker"`
+	ReportedBalanceID string          `json:"reported_balance_id"`
+	ReportedAt        time.Time       `json:"reported_at"`
+	Reported          decimal.Decimal `json:"reported"`
+	Ledger            decimal.Decimal `json:"ledger"`
+	Difference        decimal.Decimal `json:"difference"` // Informado menos calculado
+	Discrepancy       bool            `json:"discrepancy"`
+	AdjustmentID      string          `json:"adjustment_id,omitempty"`
+}
+
+// AccountReconciliation es la conciliación de una cuenta con los saldos que informa
+type AccountReconciliation struct {
+	AccountID     string               `json:"account_id"`
+	AccountName   string               `json:"account_name"`
+	Discrepancies int                  `json:"discrepancies"`
+	Items         []ReconciliationItem `json:"items"`
+}
+
+// ReconciliationAdjustmentRequest pide generar la transacción que ajusta el saldo calculado de un
+// ticker al último saldo informado
+type ReconciliationAdjustmentRequest struct {
+	Ticker        string          `json:"ticker" binding:"required"`
+	PurchasePrice decimal.Decimal `json:"purchase_price,omitempty"` // Precio del ajuste; si falta se usa el cierre guardado del día del saldo
+	Note          string          `json:"note,omitempty"`           // Se agrega a la nota de auditoría de la transacción
+}
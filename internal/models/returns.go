@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Períodos para los que se calculan los rendimientos
+const (
+	ReturnPeriod1M  = "1M"
+	ReturnPeriod3M  = "3M"
+	ReturnPeriodYTD = "YTD"
+	ReturnPeriod1Y  = "1Y"
+	ReturnPeriodAll = "all"
+)
+
+// ReturnMetrics son los rendimientos de un período. Los porcentajes quedan en nil cuando no se
+// pueden calcular (por ejemplo, sin valor invertido en el período).
+type ReturnMetrics struct {
+	StartValue    float64  `json:"start_value"`
+	EndValue      float64  `json:"end_value"`
+	NetFlows      float64  `json:"net_flows"` // Aportes menos retiros del período
+	Gain          float64  `json:"gain"`      // Valor final menos valor inicial menos aportes netos
+	TWR           *float64 `json:"twr"`       // Rendimiento ponderado por tiempo del período (%)
+	TWRAnnualized *float64 `json:"twr_annualized,omitempty"`
+	MWR           *float64 `json:"mwr"`        // Rendimiento ponderado por dinero (XIRR) anualizado (%)
+	MWRPeriod     *float64 `json:"mwr_period"` // XIRR llevada a la duración del período (%)
+}
+
+// AssetReturn son los rendimientos de un activo
+type AssetReturn struct {
+	Ticker     string `json:"ticker"`
+	CryptoName string `json:"crypto_name"`
+	AssetClass string `json:"asset_class"`
+	ReturnMetrics
+}
+
+// ReturnsReport son los rendimientos del portafolio y de cada activo en un período
+type ReturnsReport struct {
+	Period    string        `json:"period"`
+	From      time.Time     `json:"from"`
+	To        time.Time     `json:"to"`
+	Portfolio ReturnMetrics `json:"portfolio"`
+	Assets    []AssetReturn `json:"assets"`
+}
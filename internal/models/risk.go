@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// RiskDay es el rendimiento de un día del portafolio sin contar los aportes
+type RiskDay struct {
+	Date   time.Time `json:"date"`
+	Return float64   `json:"return"` // %
+}
+
+// RiskMetrics son las métricas de riesgo del portafolio calculadas con el historial de snapshots.
+// Los rendimientos diarios descuentan los aportes y retiros, así que solo reflejan la variación de precios.
+// Las métricas que necesitan más de un rendimiento quedan en nil si no hay suficientes snapshots.
+type RiskMetrics struct {
+	Period            string     `json:"period"`
+	From              time.Time  `json:"from"`
+	To                time.Time  `json:"to"`
+	Observations      int        `json:"observations"`   // Rendimientos diarios usados
+	RiskFreeRate      float64    `json:"risk_free_rate"` // Tasa libre de riesgo anual (%)
+	Volatility        *float64   `json:"volatility"`     // Desvío estándar anualizado de los rendimientos diarios (%)
+	MaxDrawdown       float64    `json:"max_drawdown"`   // Mayor caída desde un máximo (%)
+	MaxDrawdownPeak   *time.Time `json:"max_drawdown_peak,omitempty"`
+	MaxDrawdownTrough *time.Time `json:"max_drawdown_trough,omitempty"`
+	CurrentDrawdown   float64    `json:"current_drawdown"` // Caída actual desde el último máximo (%)
+	Sharpe            *float64   `json:"sharpe"`
+	Sortino           *float64   `json:"sortino"`
+	BestDay           *RiskDay   `json:"best_day,omitempty"`
+	WorstDay          *RiskDay   `json:"worst_day,omitempty"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// SwapRequest describe un intercambio de una criptomoneda por otra
+type SwapRequest struct {
+	FromTicker     string          `json:"from_ticker" binding:"required"`
+	FromCryptoName string          `json:"from_crypto_name,omitempty"`
+	FromAmount     decimal.Decimal `json:"from_amount" binding:"required,gt=0"`
+	ToTicker       string          `json:"to_ticker" binding:"required"`
+	ToCryptoName   string          `json:"to_crypto_name,omitempty"`
+	ToAmount       decimal.Decimal `json:"to_amount" binding:"required,gt=0"`
+	ValueUSD       decimal.Decimal `json:"value_usd"` // Valor en dólares del intercambio; si falta se usa el cierre guardado del día (o el precio actual si es de hoy)
+	Date           time.Time       `json:"date"`
+	Note           string          `json:"note,omitempty"`
+	Fee            decimal.Decimal `json:"fee" binding:"gte=0"`
+	FeeCurrency    string          `json:"fee_currency,omitempty"`
+	FeeUSD         decimal.Decimal `json:"fee_usd"`
+	AccountID      string          `json:"account_id,omitempty"` // Cuenta donde se hizo el intercambio
+}
+
+// Swap es un intercambio registrado: una venta de la moneda origen y una compra de la moneda destino
+type Swap struct {
+	ID       string            `json:"id"`
+	Type     string            `json:"type"` // Siempre "swap"
+	Date     time.Time         `json:"date"`
+	Note     string            `json:"note,omitempty"`
+	ValueUSD decimal.Decimal   `json:"value_usd"`
+	From     CryptoTransaction `json:"from"`
+	To       CryptoTransaction `json:"to"`
+}
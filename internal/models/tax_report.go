@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Plazos de tenencia de una venta a efectos fiscales
+const (
+	HoldingPeriodShort = "short" // Un año o menos
+	HoldingPeriodLong  = "long"  // Más de un año
+)
+
+// TaxDisposal es la venta de un lote: una venta que consume varios lotes genera varias filas
+type TaxDisposal struct {
+	SaleTransactionID string          `json:"sale_transaction_id"`
+	LotTransactionID  string          `json:"lot_transaction_id,omitempty"`
+	Ticker            string          `json:"ticker"`
+	CryptoName        string          `json:"crypto_name"`
+	Amount            decimal.Decimal `json:"amount"`
+	AcquiredAt        time.Time       `json:"acquired_at"`
+	DisposedAt        time.Time       `json:"disposed_at"`
+	Proceeds          decimal.Decimal `json:"proceeds"`
+	CostBasis         decimal.Decimal `json:"cost_basis"`
+	Gain              decimal.Decimal `json:"gain"`
+	HoldingDays       int             `json:"holding_days"`
+	HoldingPeriod     string          `json:"holding_period"` // "short" o "long"
+}
+
+// TaxTotals son los totales de un informe fiscal
+type TaxTotals struct {
+	Disposals     int             `json:"disposals"`
+	Proceeds      decimal.Decimal `json:"proceeds"`
+	CostBasis     decimal.Decimal `json:"cost_basis"`
+	Gain          decimal.Decimal `json:"gain"`
+	ShortTermGain decimal.Decimal `json:"short_term_gain"`
+	LongTermGain  decimal.Decimal `json:"long_term_gain"`
+	Income        decimal.Decimal `json:"income"` // Ingresos recibidos en el año, que tributan aparte de las ventas
+}
+
+// TaxReport es el informe de ventas de un año fiscal
+type TaxReport struct {
+	Year      int           `json:"year"`
+	Method    string        `json:"method"`
+	Disposals []TaxDisposal `json:"disposals"`
+	Income    []IncomeEvent `json:"income"`
+	Totals    TaxTotals     `json:"totals"`
+}
@@ -0,0 +1,32 @@
+package models
+
+// Acciones que se pueden aplicar en un lote de transacciones
+const (
+	BatchActionCreate = "create"
+	BatchActionUpdate = "update"
+	BatchActionDelete = "delete"
+)
+
+// MaxBatchOperations es la cantidad máxima de operaciones aceptadas en un lote
+const MaxBatchOperations = 500
+
+// TransactionBatchOperation es una operación de un lote: crear, actualizar o eliminar una transacción
+type TransactionBatchOperation struct {
+	Action      string             `json:"action" binding:"required"`
+	ID          string             `json:"id,omitempty"`          // Requerido para update y delete
+	Transaction *CryptoTransaction `json:"transaction,omitempty"` // Requerido para create y update
+}
+
+// TransactionBatchRequest es el cuerpo de POST /transactions/batch
+type TransactionBatchRequest struct {
+	Operations []TransactionBatchOperation `json:"operations" binding:"required,min=1,dive"`
+}
+
+// TransactionBatchResult es el resultado de una operación de un lote ya aplicado
+type TransactionBatchResult struct {
+	Index       int                `json:"index"`
+	Action      string             `json:"action"`
+	ID          string             `json:"id"`
+	Transaction *CryptoTransaction `json:"transaction,omitempty"`
+	DeletedIDs  []string           `json:"deleted_ids,omitempty"` // Incluye la otra pata si era un intercambio
+}
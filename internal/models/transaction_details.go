@@ -6,4 +6,5 @@ type TransactionDetails struct {
 	CurrentValue   float64          `json:"current_value"`    // Amount * CurrentPrice
 	GainLoss      float64          `json:"gain_loss"`        // CurrentValue - Total
 	GainLossPercent float64        `json:"gain_loss_percent"` // (GainLoss / Total) * 100
+	SwapWith       *CryptoTransaction `json:"swap_with,omitempty"` // Pata de compra cuando la transacción es un intercambio
 } 
\ No newline at end of file
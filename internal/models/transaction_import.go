@@ -0,0 +1,34 @@
+package models
+
+// Formatos de CSV aceptados al importar transacciones
+const (
+	ImportFormatBinance  = "binance"  // Historial de operaciones de Binance
+	ImportFormatCoinbase = "coinbase" // Reporte de transacciones de Coinbase
+	ImportFormatKraken   = "kraken"   // Ledger de Kraken
+	ImportFormatGeneric  = "generic"  // Columnas propias con mapeo opcional
+)
+
+// ImportRow es una operación leída de un CSV: una compra/venta o un intercambio entre criptomonedas
+type ImportRow struct {
+	Line        int
+	Transaction *CryptoTransaction
+	Swap        *SwapRequest
+}
+
+// ImportRowIssue describe una fila del CSV que no se importó y el motivo
+type ImportRowIssue struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// TransactionImportResult es el resultado (o la vista previa) de una importación
+type TransactionImportResult struct {
+	Format       string              `json:"format"`
+	DryRun       bool                `json:"dry_run"`
+	Rows         int                 `json:"rows"`     // Operaciones nuevas a importar
+	Imported     int                 `json:"imported"` // Transacciones guardadas (0 en la vista previa)
+	Transactions []CryptoTransaction `json:"transactions"`
+	Duplicates   []ImportRowIssue    `json:"duplicates"`
+	Skipped      []ImportRowIssue    `json:"skipped"` // Filas de tipos no soportados (depósitos, retiros...)
+	Errors       []ImportRowIssue    `json:"errors"`
+}
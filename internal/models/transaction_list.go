@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Campos por los que se puede ordenar el listado de transacciones
+const (
+	TransactionSortDate  = "date"
+	TransactionSortTotal = "total"
+	TransactionSortGain  = "gain" // Ganancia o pérdida calculada con el precio actual
+)
+
+// Límites del tamaño de página del listado de transacciones
+const (
+	DefaultTransactionPageSize = 50
+	MaxTransactionPageSize     = 200
+)
+
+// TransactionQuery son los filtros, el orden y la página pedidos al listar transacciones
+type TransactionQuery struct {
+	Tickers    []string
+	Type       string    // "compra", "venta", "swap", "transferencia", "ingreso" o un tipo de ingreso
+	AccountID  string    // Cuenta de origen o de destino; vacío para no filtrar
+	From       time.Time // Inclusive; vacío para no filtrar
+	To         time.Time // Exclusivo; vacío para no filtrar
+	MinTotal   *float64
+	MaxTotal   *float64
+	Search     string // Texto a buscar en la nota
+	SortBy     string
+	Descending bool
+	Cursor     string // Cursor opaco devuelto en la página anterior
+	Limit      int
+}
+
+// TransactionPage es una página del listado de transacciones
+type TransactionPage struct {
+	Transactions []TransactionDetails `json:"transactions"`
+	NextCursor   string               `json:"next_cursor,omitempty"` // Vacío en la última página
+	HasMore      bool                 `json:"has_more"`
+	SortBy       string               `json:"sort"`
+	Order        string               `json:"order"`
+	Limit        int                  `json:"limit"`
+}
@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/shopspring/decimal"
+)
+
+// ErrAccountInUse indica que la cuenta tiene transacciones y no se puede eliminar
+var ErrAccountInUse = errors.New("la cuenta tiene transacciones registradas")
+
+// unassignedAccountName es el nombre con el que se muestran las tenencias sin cuenta
+const unassignedAccountName = "Sin cuenta"
+
+// AccountRepository maneja las cuentas de exchanges y billeteras de los usuarios
+type AccountRepository struct {
+	db *sql.DB
+}
+
+// NewAccountRepository crea un nuevo repositorio de cuentas
+func NewAccountRepository(db *sql.DB) *AccountRepository {
+	return &AccountRepository{
+		db: db,
+	}
+}
+
+// accountColumns son las columnas que se leen de accounts, en el orden de scanAccount
+const accountColumns = `id, user_id, name, type, COALESCE(provider, ''), COALESCE(note, ''), created_at, updated_at`
+
+// scanAccount lee una cuenta de una fila
+func scanAccount(row rowScanner) (*models.Account, error) {
+	var account models.Account
+	err := row.Scan(
+		&account.ID, &account.UserID, &account.Name, &account.Type, &account.Provider, &account.Note,
+		&account.CreatedAt, &account.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// CreateAccount crea una nueva cuenta
+func (r *AccountRepository) CreateAccount(account models.Account) error {
+	_, err := r.db.Exec(
+		`INSERT INTO accounts (id, user_id, name, type, provider, note, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		account.ID, account.UserID, account.Name, account.Type, account.Provider, account.Note,
+		account.CreatedAt, account.UpdatedAt,
+	)
+	return err
+}
+
+// GetAccountByID obtiene una cuenta por su ID
+func (r *AccountRepository) GetAccountByID(id string) (*models.Account, error) {
+	row := r.db.QueryRow(`SELECT `+accountColumns+` FROM accounts WHERE id = $1`, id)
+	return scanAccount(row)
+}
+
+// GetAccountsByUserID obtiene todas las cuentas de un usuario
+func (r *AccountRepository) GetAccountsByUserID(userID string) ([]models.Account, error) {
+	rows, err := r.db.Query(`SELECT `+accountColumns+` FROM accounts WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []models.Account{}
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+	return accounts, rows.Err()
+}
+
+// UpdateAccount actualiza una cuenta existente
+func (r *AccountRepository) UpdateAccount(account models.Account) error {
+	_, err := r.db.Exec(
+		`UPDATE accounts SET name = $1, type = $2, provider = $3, note = $4, updated_at = $5 WHERE id = $6`,
+		account.Name, account.Type, account.Provider, account.Note, account.UpdatedAt, account.ID,
+	)
+	return err
+}
+
+// DeleteAccount elimina una cuenta sin transacciones. Si alguna transacción la usa devuelve ErrAccountInUse.
+func (r *AccountRepository) DeleteAccount(userID, id string) error {
+	var used int
+	err := r.db.QueryRow(
+		`SELECT COUNT(*) FROM crypto_transactions WHERE user_id = $1 AND (account_id = $2 OR to_account_id = $2)`,
+		userID, id,
+	).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used > 0 {
+		return ErrAccountInUse
+	}
+
+	_, err = r.db.Exec(`DELETE FROM accounts WHERE id = $1 AND user_id = $2`, id, userID)
+	return err
+}
+
+// checkTransactionAccounts verifica que las cuentas de una transacción pertenezcan a su usuario y que la
+// cuenta de origen de una transferencia, o la cuenta de una venta, tenga saldo suficiente de la
+// criptomoneda que sale. Las ventas sin cuenta solo se controlan contra las tenencias totales.
+func checkTransactionAccounts(tx *sql.Tx, transaction models.CryptoTransaction) error {
+	if transaction.Type == models.TransactionTypeTransfer {
+		if err := services.ValidateTransfer(transaction); err != nil {
+			return err
+		}
+	} else if transaction.ToAccountID != "" {
+		return fmt.Errorf("to_account_id solo se admite en transferencias")
+	}
+
+	for _, accountID := range []string{transaction.AccountID, transaction.ToAccountID} {
+		if accountID == "" {
+			continue
+		}
+		var owner string
+		err := tx.QueryRow(`SELECT user_id FROM accounts WHERE id = $1`, accountID).Scan(&owner)
+		if err == sql.ErrNoRows || (err == nil && owner != transaction.UserID) {
+			return fmt.Errorf("cuenta no encontrada: %s", accountID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	isTransfer := transaction.Type == models.TransactionTypeTransfer
+	isAccountSell := transaction.Type == models.TransactionTypeSell && transaction.AccountID != ""
+	if !isTransfer && !isAccountSell {
+		return nil
+	}
+	balances, err := userAccountBalances(tx, transaction.UserID, transaction.ID)
+	if err != nil {
+		return err
+	}
+	amount := transaction.Amount
+	if services.IsTradedCoinFee(transaction) {
+		amount = amount.Add(transaction.Fee)
+	}
+	if balances[transaction.AccountID][strings.ToUpper(transaction.Ticker)].LessThan(services.RoundAmount(transaction.Ticker, amount)) {
+		if isTransfer {
+			return errors.New("saldo insuficiente en la cuenta de origen para realizar la transferencia")
+		}
+		return fmt.Errorf("saldo insuficiente de %s en la cuenta para realizar la venta", strings.ToUpper(transaction.Ticker))
+	}
+	return nil
+}
+
+// userAccountBalances calcula la cantidad de cada ticker en cada cuenta del usuario,
+// sin contar la transacción excludedID (la que se está editando)
+func userAccountBalances(tx *sql.Tx, userID, excludedID string) (map[string]map[string]decimal.Decimal, error) {
+	rows, err := tx.Query(`
+		SELECT ticker, type, amount, fee, fee_currency, COALESCE(account_id, ''), COALESCE(to_account_id, '')
+		FROM crypto_transactions
+		WHERE user_id = $1 AND id <> $2`,
+		userID, excludedID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions, err := scanAccountMovements(rows)
+	if err != nil {
+		return nil, err
+	}
+	return services.AccountBalances(transactions), nil
+}
+
+// scanAccountMovements lee las columnas de las transacciones que mueven saldo entre cuentas
+func scanAccountMovements(rows *sql.Rows) ([]models.CryptoTransaction, error) {
+	var transactions []models.CryptoTransaction
+	for rows.Next() {
+		var transaction models.CryptoTransaction
+		err := rows.Scan(
+			&transaction.Ticker, &transaction.Type, &transaction.Amount, &transaction.Fee,
+			&transaction.FeeCurrency, &transaction.AccountID, &transaction.ToAccountID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
+
+// GetAccountHoldings calcula las tenencias de cada cuenta del usuario valoradas con los precios
+// del dashboard. Las cuentas sin saldo se incluyen vacías y las tenencias sin cuenta se agrupan al final.
+func (r *AccountRepository) GetAccountHoldings(userID string, dashboard []models.CryptoDashboard) ([]models.AccountHoldings, error) {
+	accounts, err := r.GetAccountsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT ticker, type, amount, fee, fee_currency, COALESCE(account_id, ''), COALESCE(to_account_id, '')
+		FROM crypto_transactions
+		WHERE user_id = $1`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions, err := scanAccountMovements(rows)
+	if err != nil {
+		return nil, err
+	}
+	balances := services.AccountBalances(transactions)
+
+	prices := make(map[string]float64, len(dashboard))
+	var totalValue float64
+	for _, crypto := range dashboard {
+		prices[strings.ToUpper(crypto.Ticker)] = crypto.CurrentPrice
+		totalValue += crypto.Holdings * crypto.CurrentPrice
+	}
+
+	result := make([]models.AccountHoldings, 0, len(accounts)+1)
+	for _, account := range accounts {
+		result = append(result, accountHoldings(account.ID, account.Name, account.Type, balances[account.ID], prices, totalValue))
+	}
+	if unassigned := accountHoldings("", unassignedAccountName, "", balances[""], prices, totalValue); len(unassigned.Assets) > 0 {
+		result = append(result, unassigned)
+	}
+	return result, nil
+}
+
+// accountHoldings valora el saldo positivo de cada ticker de una cuenta
+func accountHoldings(accountID, name, accountType string, balance map[string]decimal.Decimal, prices map[string]float64, totalValue float64) models.AccountHoldings {
+	holdings := models.AccountHoldings{
+		AccountID:   accountID,
+		AccountName: name,
+		AccountType: accountType,
+		Assets:      []models.AccountAsset{},
+	}
+	for ticker, amount := range balance {
+		if !amount.IsPositive() {
+			continue
+		}
+		asset := models.AccountAsset{
+			Ticker:       ticker,
+			Amount:       amount,
+			CurrentPrice: prices[ticker],
+		}
+		asset.Value = amount.InexactFloat64() * asset.CurrentPrice
+		holdings.Value += asset.Value
+		holdings.Assets = append(holdings.Assets, asset)
+	}
+
+	sort.Slice(holdings.Assets, func(i, j int) bool {
+		if holdings.Assets[i].Value != holdings.Assets[j].Value {
+			return holdings.Assets[i].Value > holdings.Assets[j].Value
+		}
+		return holdings.Assets[i].Ticker < holdings.Assets[j].Ticker
+	})
+	if totalValue > 0 {
+		holdings.Weight = (holdings.Value / totalValue) * 100
+	}
+	return holdings
+}
@@ -0,0 +1,707 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+)
+
+// BackupRepository exporta y restaura la cartera completa de un usuario
+type BackupRepository struct {
+	db *sql.DB
+}
+
+// NewBackupRepository crea un nuevo repositorio de copias de seguridad
+func NewBackupRepository(db *sql.DB) *BackupRepository {
+	return &BackupRepository{
+		db: db,
+	}
+}
+
+// ExportBackup arma la copia de seguridad de las cuentas, transacciones, bolsas y snapshots de un usuario
+func (r *BackupRepository) ExportBackup(userID string) (*models.Backup, error) {
+	accounts, err := NewAccountRepository(r.db).GetAccountsByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error al exportar las cuentas: %v", err)
+	}
+
+	transactions, err := r.exportTransactions(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error al exportar las transacciones: %v", err)
+	}
+
+	bolsas, err := r.exportBolsas(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error al exportar las bolsas: %v", err)
+	}
+
+	snapshots, err := NewCryptoRepository(r.db).GetInvestmentSnapshotsWithMaxMin(userID, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("error al exportar los snapshots: %v", err)
+	}
+	if snapshots == nil {
+		snapshots = []models.InvestmentSnapshot{}
+	}
+
+	return &models.Backup{
+		Version:      models.BackupVersion,
+		ExportedAt:   time.Now().UTC(),
+		UserID:       userID,
+		Accounts:     accounts,
+		Transactions: transactions,
+		Bolsas:       bolsas,
+		Snapshots:    snapshots,
+	}, nil
+}
+
+// exportTransactions obtiene todas las transacciones del usuario tal como están guardadas
+func (r *BackupRepository) exportTransactions(userID string) ([]models.CryptoTransaction, error) {
+	rows, err := r.db.Query(`
+		SELECT id, user_id, crypto_name, ticker, amount, purchase_price,
+			   total, date, COALESCE(note, ''), created_at, type, usdt_received, COALESCE(image_url, ''),
+			   fee, fee_currency, fee_usd, COALESCE(swap_id, ''), asset_class,
+			   COALESCE(account_id, ''), COALESCE(to_account_id, '')
+		FROM crypto_transactions
+		WHERE user_id = $1
+		ORDER BY date, created_at`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []models.CryptoTransaction{}
+	for rows.Next() {
+		var tx models.CryptoTransaction
+		err := rows.Scan(
+			&tx.ID, &tx.UserID, &tx.CryptoName, &tx.Ticker, &tx.Amount, &tx.PurchasePrice,
+			&tx.Total, &tx.Date, &tx.Note, &tx.CreatedAt, &tx.Type, &tx.USDTReceived, &tx.ImageURL,
+			&tx.Fee, &tx.FeeCurrency, &tx.FeeUSD, &tx.SwapID, &tx.AssetClass,
+			&tx.AccountID, &tx.ToAccountID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, rows.Err()
+}
+
+// exportBolsas obtiene las bolsas del usuario con sus activos, etiquetas y reglas, sin consultar precios
+func (r *BackupRepository) exportBolsas(userID string) ([]models.Bolsa, error) {
+	rows, err := r.db.Query(
+		`SELECT id, user_id, name, COALESCE(description, ''), COALESCE(goal, 0), created_at, updated_at
+		FROM bolsas WHERE user_id = $1 ORDER BY created_at`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bolsas := []models.Bolsa{}
+	for rows.Next() {
+		var bolsa models.Bolsa
+		err := rows.Scan(&bolsa.ID, &bolsa.UserID, &bolsa.Name, &bolsa.Description, &bolsa.Goal, &bolsa.CreatedAt, &bolsa.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		bolsas = append(bolsas, bolsa)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	bolsaRepo := NewBolsaRepository(r.db)
+	for i := range bolsas {
+		if bolsas[i].Tags, err = bolsaRepo.getTagsForBolsa(bolsas[i].ID); err != nil {
+			return nil, err
+		}
+		if bolsas[i].Rules, err = bolsaRepo.getRulesForBolsa(bolsas[i].ID); err != nil {
+			return nil, err
+		}
+		if bolsas[i].Assets, err = r.exportBolsaAssets(bolsas[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return bolsas, nil
+}
+
+// exportBolsaAssets obtiene los activos guardados de una bolsa
+func (r *BackupRepository) exportBolsaAssets(bolsaID string) ([]models.AssetInBolsa, error) {
+	rows, err := r.db.Query(
+		`SELECT id, bolsa_id, crypto_name, ticker, amount, purchase_price, total, COALESCE(image_url, ''), created_at, updated_at, asset_class
+		FROM assets_in_bolsa WHERE bolsa_id = $1 ORDER BY created_at`,
+		bolsaID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assets []models.AssetInBolsa
+	for rows.Next() {
+		var asset models.AssetInBolsa
+		err := rows.Scan(
+			&asset.ID, &asset.BolsaID, &asset.CryptoName, &asset.Ticker, &asset.Amount,
+			&asset.PurchasePrice, &asset.Total, &asset.ImageURL, &asset.CreatedAt, &asset.UpdatedAt, &asset.AssetClass,
+		)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+	return assets, rows.Err()
+}
+
+// RestoreBackup restaura una copia de seguridad en la cuenta del usuario dentro de una única
+// transacción SQL. Los IDs que ya usa otra cuenta se reemplazan por IDs nuevos y los conflictos
+// con datos propios (mismo ID, misma operación o snapshot del mismo día) se resuelven con la política indicada.
+func (r *BackupRepository) RestoreBackup(userID string, backup models.Backup, policy string) (result *models.BackupRestoreResult, err error) {
+	policy, err = services.NormalizeBackupConflictPolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			result = nil
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	restore := &backupRestore{
+		tx:         tx,
+		userID:     userID,
+		policy:     policy,
+		result:     &models.BackupRestoreResult{Policy: policy},
+		nextID:     models.GenerateUUID,
+		swapIDs:    make(map[string]string),
+		accountIDs: make(map[string]string),
+	}
+	if err = restore.accounts(backup.Accounts); err != nil {
+		return nil, fmt.Errorf("error al restaurar las cuentas: %v", err)
+	}
+	if err = restore.transactions(backup.Transactions); err != nil {
+		return nil, fmt.Errorf("error al restaurar las transacciones: %v", err)
+	}
+	if err = restore.bolsas(backup.Bolsas); err != nil {
+		return nil, fmt.Errorf("error al restaurar las bolsas: %v", err)
+	}
+	if err = restore.snapshots(backup.Snapshots); err != nil {
+		return nil, fmt.Errorf("error al restaurar los snapshots: %v", err)
+	}
+
+	return restore.result, nil
+}
+
+// backupRestore es el estado de una restauración en curso
+type backupRestore struct {
+	tx         *sql.Tx
+	userID     string
+	policy     string
+	result     *models.BackupRestoreResult
+	nextID     func() string
+	swapIDs    map[string]string // Intercambios de la copia y el ID con el que se restauran
+	accountIDs map[string]string // Cuentas de la copia y el ID con el que se restauran
+}
+
+// owner devuelve a qué usuario (o bolsa) pertenece una fila, consultando la columna indicada
+func (b *backupRestore) owner(table, ownerColumn, id string) (string, bool, error) {
+	if id == "" {
+		return "", false, nil
+	}
+	var owner string
+	err := b.tx.QueryRow(fmt.Sprintf("SELECT %s FROM %s WHERE id = $1", ownerColumn, table), id).Scan(&owner)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	return owner, err == nil, err
+}
+
+// accounts restaura las cuentas. Una cuenta choca con una existente si tiene su mismo ID.
+func (b *backupRestore) accounts(accounts []models.Account) error {
+	counts := &b.result.Accounts
+	for _, account := range accounts {
+		original := account.ID
+		account.UserID = b.userID
+		now := time.Now()
+		if account.CreatedAt.IsZero() {
+			account.CreatedAt = now
+		}
+		if account.UpdatedAt.IsZero() {
+			account.UpdatedAt = now
+		}
+
+		owner, exists, err := b.owner("accounts", "user_id", account.ID)
+		if err != nil {
+			return err
+		}
+
+		if exists && owner == b.userID {
+			b.accountIDs[original] = account.ID
+			if b.policy == models.BackupConflictSkip {
+				counts.Skipped++
+				continue
+			}
+			_, err := b.tx.Exec(
+				`UPDATE accounts SET name = $1, type = $2, provider = $3, note = $4, updated_at = $5 WHERE id = $6 AND user_id = $7`,
+				account.Name, account.Type, account.Provider, account.Note, account.UpdatedAt, account.ID, b.userID,
+			)
+			if err != nil {
+				return err
+			}
+			counts.Updated++
+			continue
+		}
+
+		if exists || account.ID == "" {
+			account.ID = b.nextID()
+			counts.Remapped++
+		}
+		_, err = b.tx.Exec(
+			`INSERT INTO accounts (id, user_id, name, type, provider, note, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			account.ID, account.UserID, account.Name, account.Type, account.Provider, account.Note,
+			account.CreatedAt, account.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		b.accountIDs[original] = account.ID
+		counts.Created++
+	}
+	return nil
+}
+
+// accountID devuelve el ID con el que se restauró una cuenta de la copia. Las cuentas que no
+// están en la copia se conservan solo si pertenecen al usuario; si no, la transacción queda sin cuenta.
+func (b *backupRestore) accountID(original string) (string, error) {
+	if original == "" {
+		return "", nil
+	}
+	if id, restored := b.accountIDs[original]; restored {
+		return id, nil
+	}
+	owner, exists, err := b.owner("accounts", "user_id", original)
+	if err != nil || !exists || owner != b.userID {
+		return "", err
+	}
+	return original, nil
+}
+
+// transactions restaura las transacciones. Una transacción choca con una existente si tiene su
+// mismo ID o la misma operación (ticker, tipo, fecha y cantidad). Con overwrite se reemplaza por
+// la de la copia; con merge se conservan sus datos y solo se completan los campos vacíos.
+func (b *backupRestore) transactions(transactions []models.CryptoTransaction) error {
+	existing := make(map[string]string)
+	rows, err := b.tx.Query(`SELECT id, ticker, type, date, amount FROM crypto_transactions WHERE user_id = $1`, b.userID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var transaction models.CryptoTransaction
+		if err := rows.Scan(&transaction.ID, &transaction.Ticker, &transaction.Type, &transaction.Date, &transaction.Amount); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[transactionKey(transaction)] = transaction.ID
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	counts := &b.result.Transactions
+	for _, transaction := range transactions {
+		transaction.UserID = b.userID
+		if transaction.SwapID, err = b.swapID(transaction.SwapID); err != nil {
+			return err
+		}
+		if transaction.AccountID, err = b.accountID(transaction.AccountID); err != nil {
+			return err
+		}
+		if transaction.ToAccountID, err = b.accountID(transaction.ToAccountID); err != nil {
+			return err
+		}
+
+		owner, exists, err := b.owner("crypto_transactions", "user_id", transaction.ID)
+		if err != nil {
+			return err
+		}
+		existingID := ""
+		if exists && owner == b.userID {
+			existingID = transaction.ID
+		} else if id, found := existing[transactionKey(transaction)]; found {
+			existingID = id
+		}
+
+		if existingID != "" {
+			if b.policy == models.BackupConflictSkip {
+				counts.Skipped++
+				continue
+			}
+			transaction.ID = existingID
+			update := updateBackupTransaction
+			if b.policy == models.BackupConflictMerge {
+				update = mergeBackupTransaction
+			}
+			if err := update(b.tx, transaction); err != nil {
+				return err
+			}
+			counts.Updated++
+			continue
+		}
+
+		if exists || transaction.ID == "" {
+			transaction.ID = b.nextID()
+			counts.Remapped++
+		}
+		if transaction.CreatedAt.IsZero() {
+			transaction.CreatedAt = time.Now()
+		}
+		if err := insertTransaction(b.tx, transaction); err != nil {
+			return err
+		}
+		counts.Created++
+	}
+	return nil
+}
+
+// swapID devuelve el ID con el que se restaura un intercambio: el original, salvo que lo use otra cuenta
+func (b *backupRestore) swapID(original string) (string, error) {
+	if original == "" {
+		return "", nil
+	}
+	if id, seen := b.swapIDs[original]; seen {
+		return id, nil
+	}
+
+	var others int
+	err := b.tx.QueryRow(
+		`SELECT COUNT(*) FROM crypto_transactions WHERE swap_id = $1 AND user_id <> $2`, original, b.userID,
+	).Scan(&others)
+	if err != nil {
+		return "", err
+	}
+	id := original
+	if others > 0 {
+		id = b.nextID()
+	}
+	b.swapIDs[original] = id
+	return id, nil
+}
+
+// updateBackupTransaction reemplaza los datos de una transacción existente con los de la copia
+func updateBackupTransaction(tx *sql.Tx, transaction models.CryptoTransaction) error {
+	_, err := tx.Exec(`
+		UPDATE crypto_transactions
+		SET crypto_name = $1, ticker = $2, amount = $3, purchase_price = $4, total = $5, date = $6,
+			note = $7, type = $8, usdt_received = $9, image_url = $10, fee = $11, fee_currency = $12,
+			fee_usd = $13, swap_id = NULLIF($14, ''), asset_class = $17,
+			account_id = NULLIF($18, ''), to_account_id = NULLIF($19, '')
+		WHERE id = $15 AND user_id = $16`,
+		transaction.CryptoName, transaction.Ticker, transaction.Amount, transaction.PurchasePrice,
+		transaction.Total, transaction.Date, transaction.Note, transaction.Type, transaction.USDTReceived,
+		transaction.ImageURL, transaction.Fee, transaction.FeeCurrency, transaction.FeeUSD, transaction.SwapID,
+		transaction.ID, transaction.UserID, services.AssetClassOrDefault(transaction.AssetClass),
+		transaction.AccountID, transaction.ToAccountID,
+	)
+	return err
+}
+
+// mergeBackupTransaction completa una transacción existente con los datos de la copia: la nota, la
+// imagen, las cuentas y la comisión solo se toman de la copia si la transacción no los tiene
+func mergeBackupTransaction(tx *sql.Tx, transaction models.CryptoTransaction) error {
+	_, err := tx.Exec(`
+		UPDATE crypto_transactions
+		SET note = COALESCE(NULLIF(note, ''), $1),
+			image_url = COALESCE(NULLIF(image_url, ''), $2),
+			account_id = COALESCE(account_id, NULLIF($3, '')),
+			to_account_id = CASE WHEN type = $4 THEN COALESCE(to_account_id, NULLIF($5, '')) ELSE to_account_id END,
+			fee = CASE WHEN fee = 0 THEN $6 ELSE fee END,
+			fee_currency = CASE WHEN fee = 0 THEN $7 ELSE fee_currency END,
+			fee_usd = CASE WHEN fee = 0 THEN $8 ELSE fee_usd END
+		WHERE id = $9 AND user_id = $10`,
+		transaction.Note, transaction.ImageURL, transaction.AccountID, models.TransactionTypeTransfer,
+		transaction.ToAccountID, transaction.Fee, transaction.FeeCurrency, transaction.FeeUSD,
+		transaction.ID, transaction.UserID,
+	)
+	return err
+}
+
+// bolsas restaura las bolsas y sus hijos. Con overwrite los activos, etiquetas y reglas de una bolsa
+// existente se reemplazan por los de la copia; con merge se conservan los que no están en ella.
+func (b *backupRestore) bolsas(bolsas []models.Bolsa) error {
+	counts := &b.result.Bolsas
+	for _, bolsa := range bolsas {
+		bolsa.UserID = b.userID
+		now := time.Now()
+		if bolsa.CreatedAt.IsZero() {
+			bolsa.CreatedAt = now
+		}
+		if bolsa.UpdatedAt.IsZero() {
+			bolsa.UpdatedAt = now
+		}
+
+		owner, exists, err := b.owner("bolsas", "user_id", bolsa.ID)
+		if err != nil {
+			return err
+		}
+
+		if exists && owner == b.userID {
+			if b.policy == models.BackupConflictSkip {
+				counts.Skipped++
+				continue
+			}
+			_, err := b.tx.Exec(
+				`UPDATE bolsas SET name = $1, description = $2, goal = $3, updated_at = $4 WHERE id = $5 AND user_id = $6`,
+				bolsa.Name, bolsa.Description, bolsa.Goal, bolsa.UpdatedAt, bolsa.ID, b.userID,
+			)
+			if err != nil {
+				return err
+			}
+			if b.policy == models.BackupConflictOverwrite {
+				if err := b.clearBolsaChildren(bolsa.ID); err != nil {
+					return err
+				}
+			}
+			counts.Updated++
+		} else {
+			if exists || bolsa.ID == "" {
+				bolsa.ID = b.nextID()
+				counts.Remapped++
+			}
+			_, err := b.tx.Exec(
+				`INSERT INTO bolsas (id, user_id, name, description, goal, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+				bolsa.ID, bolsa.UserID, bolsa.Name, bolsa.Description, bolsa.Goal, bolsa.CreatedAt, bolsa.UpdatedAt,
+			)
+			if err != nil {
+				return err
+			}
+			counts.Created++
+		}
+
+		if err := b.bolsaChildren(bolsa); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearBolsaChildren elimina los activos, etiquetas, reglas y disparos de una bolsa
+func (b *backupRestore) clearBolsaChildren(bolsaID string) error {
+	for _, table := range []string{"trigger_events", "trigger_rules", "assets_in_bolsa", "bolsa_tags"} {
+		if _, err := b.tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE bolsa_id = $1", table), bolsaID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bolsaChildren restaura los activos, etiquetas y reglas de una bolsa ya restaurada. Un hijo con
+// el ID de otro de la misma bolsa lo actualiza; si el ID lo usa otra bolsa se crea con uno nuevo.
+func (b *backupRestore) bolsaChildren(bolsa models.Bolsa) error {
+	for _, tag := range bolsa.Tags {
+		result, err := b.tx.Exec(
+			`INSERT INTO bolsa_tags (id, bolsa_id, tag) VALUES ($1, $2, $3) ON CONFLICT (bolsa_id, tag) DO NOTHING`,
+			b.nextID(), bolsa.ID, tag,
+		)
+		if err != nil {
+			return err
+		}
+		if inserted, _ := result.RowsAffected(); inserted > 0 {
+			b.result.Tags.Created++
+		} else {
+			b.result.Tags.Skipped++
+		}
+	}
+
+	for _, asset := range bolsa.Assets {
+		asset.BolsaID = bolsa.ID
+		now := time.Now()
+		if asset.CreatedAt.IsZero() {
+			asset.CreatedAt = now
+		}
+		if asset.UpdatedAt.IsZero() {
+			asset.UpdatedAt = now
+		}
+
+		owner, exists, err := b.owner("assets_in_bolsa", "bolsa_id", asset.ID)
+		if err != nil {
+			return err
+		}
+		if exists && owner == bolsa.ID {
+			_, err := b.tx.Exec(
+				`UPDATE assets_in_bolsa SET crypto_name = $1, ticker = $2, amount = $3, purchase_price = $4,
+				total = $5, image_url = $6, updated_at = $7, asset_class = $9 WHERE id = $8`,
+				asset.CryptoName, asset.Ticker, asset.Amount, asset.PurchasePrice,
+				asset.Total, asset.ImageURL, asset.UpdatedAt, asset.ID, services.AssetClassOrDefault(asset.AssetClass),
+			)
+			if err != nil {
+				return err
+			}
+			b.result.Assets.Updated++
+			continue
+		}
+
+		if exists || asset.ID == "" {
+			asset.ID = b.nextID()
+			b.result.Assets.Remapped++
+		}
+		_, err = b.tx.Exec(
+			`INSERT INTO assets_in_bolsa (id, bolsa_id, crypto_name, ticker, amount, purchase_price, total, image_url, created_at, updated_at, asset_class)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			asset.ID, asset.BolsaID, asset.CryptoName, asset.Ticker, asset.Amount,
+			asset.PurchasePrice, asset.Total, asset.ImageURL, asset.CreatedAt, asset.UpdatedAt, services.AssetClassOrDefault(asset.AssetClass),
+		)
+		if err != nil {
+			return err
+		}
+		b.result.Assets.Created++
+	}
+
+	for _, rule := range bolsa.Rules {
+		rule.BolsaID = bolsa.ID
+		if err := services.ValidateTriggerRule(&rule); err != nil {
+			return fmt.Errorf("regla %s de la bolsa %q: %v", rule.ID, bolsa.Name, err)
+		}
+		now := time.Now()
+		if rule.CreatedAt.IsZero() {
+			rule.CreatedAt = now
+		}
+		if rule.UpdatedAt.IsZero() {
+			rule.UpdatedAt = now
+		}
+
+		owner, exists, err := b.owner("trigger_rules", "bolsa_id", rule.ID)
+		if err != nil {
+			return err
+		}
+		if exists && owner == bolsa.ID {
+			_, err := b.tx.Exec(
+				`UPDATE trigger_rules SET type = $1, ticker = $2, target_value = $3, active = $4, triggered = $5,
+				rearm = $6, cooldown_minutes = $7, last_triggered_at = $8, direction = $9, peak_value = $10, updated_at = $11
+				WHERE id = $12`,
+				rule.Type, rule.Ticker, rule.TargetValue, boolToInt(rule.Active), boolToInt(rule.Triggered),
+				boolToInt(rule.Rearm), rule.CooldownMinutes, rule.LastTriggeredAt, rule.Direction, rule.PeakValue,
+				rule.UpdatedAt, rule.ID,
+			)
+			if err != nil {
+				return err
+			}
+			b.result.Rules.Updated++
+			continue
+		}
+
+		if exists || rule.ID == "" {
+			rule.ID = b.nextID()
+			b.result.Rules.Remapped++
+		}
+		_, err = b.tx.Exec(
+			`INSERT INTO trigger_rules (id, bolsa_id, type, ticker, target_value, active, triggered, rearm, cooldown_minutes,
+			last_triggered_at, direction, peak_value, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+			rule.ID, rule.BolsaID, rule.Type, rule.Ticker, rule.TargetValue,
+			boolToInt(rule.Active), boolToInt(rule.Triggered), boolToInt(rule.Rearm), rule.CooldownMinutes,
+			rule.LastTriggeredAt, rule.Direction, rule.PeakValue, rule.CreatedAt, rule.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		b.result.Rules.Created++
+	}
+	return nil
+}
+
+// snapshots restaura el historial de inversión. Como hay un snapshot por día, uno de la copia
+// choca con el existente del mismo día. Con overwrite se reemplazan sus valores; con merge se
+// conservan y solo se amplían el máximo y el mínimo del día con los de la copia.
+func (b *backupRestore) snapshots(snapshots []models.InvestmentSnapshot) error {
+	existing := make(map[string]string)
+	rows, err := b.tx.Query(`SELECT id, date FROM investment_snapshots WHERE user_id = $1`, b.userID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id string
+		var date time.Time
+		if err := rows.Scan(&id, &date); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[date.Format("2006-01-02")] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	counts := &b.result.Snapshots
+	for _, snapshot := range snapshots {
+		day := snapshot.Date.Format("2006-01-02")
+		if existingID, found := existing[day]; found {
+			if b.policy == models.BackupConflictSkip {
+				counts.Skipped++
+				continue
+			}
+			var err error
+			if b.policy == models.BackupConflictMerge {
+				_, err = b.tx.Exec(
+					`UPDATE investment_snapshots SET max_value = GREATEST(COALESCE(max_value, 0), $1::NUMERIC),
+					min_value = CASE
+						WHEN COALESCE(min_value, 0) <= 0 THEN $2::NUMERIC
+						WHEN $2::NUMERIC <= 0 THEN min_value
+						ELSE LEAST(min_value, $2::NUMERIC)
+					END
+					WHERE id = $3`,
+					snapshot.MaxValue, snapshot.MinValue, existingID,
+				)
+			} else {
+				_, err = b.tx.Exec(
+					`UPDATE investment_snapshots SET total_value = $1, total_invested = $2, profit = $3,
+					profit_percentage = $4, max_value = $5, min_value = $6 WHERE id = $7`,
+					snapshot.TotalValue, snapshot.TotalInvested, snapshot.Profit,
+					snapshot.ProfitPercentage, snapshot.MaxValue, snapshot.MinValue, existingID,
+				)
+			}
+			if err != nil {
+				return err
+			}
+			counts.Updated++
+			continue
+		}
+
+		_, exists, err := b.owner("investment_snapshots", "user_id", snapshot.ID)
+		if err != nil {
+			return err
+		}
+		if exists || snapshot.ID == "" {
+			snapshot.ID = b.nextID()
+			counts.Remapped++
+		}
+		_, err = b.tx.Exec(
+			`INSERT INTO investment_snapshots (id, user_id, date, total_value, total_invested, profit, profit_percentage, max_value, min_value)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			snapshot.ID, b.userID, snapshot.Date, snapshot.TotalValue, snapshot.TotalInvested,
+			snapshot.Profit, snapshot.ProfitPercentage, snapshot.MaxValue, snapshot.MinValue,
+		)
+		if err != nil {
+			return err
+		}
+		existing[day] = snapshot.ID
+		counts.Created++
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+)
+
+// BenchmarkRepository compara el portafolio con lo que habrían rendido los mismos aportes en un benchmark
+type BenchmarkRepository struct {
+	db     *sql.DB
+	prices services.PriceProvider
+}
+
+// NewBenchmarkRepository crea un nuevo repositorio de comparación con benchmarks
+func NewBenchmarkRepository(db *sql.DB) *BenchmarkRepository {
+	return &BenchmarkRepository{
+		db: db,
+	}
+}
+
+// WithPrices devuelve una copia del repositorio que obtiene los precios del proveedor indicado
+func (r *BenchmarkRepository) WithPrices(prices services.PriceProvider) *BenchmarkRepository {
+	clone := *r
+	clone.prices = prices
+	return &clone
+}
+
+// priceProvider devuelve el proveedor de precios del repositorio o el global si no se indicó ninguno
+func (r *BenchmarkRepository) priceProvider() services.PriceProvider {
+	if r.prices != nil {
+		return r.prices
+	}
+	return services.GetPriceProvider()
+}
+
+// benchmarkHoldings son las unidades de cada ticker del benchmark compradas con los aportes del portafolio
+type benchmarkHoldings struct {
+	allocations []models.BacktestAllocation
+	valuation   returnValuation
+	units       map[string]float64
+}
+
+// value devuelve el valor de las unidades al final del día indicado.
+// Falla si algún ticker del benchmark no tiene un precio reciente ese día.
+func (b *benchmarkHoldings) value(day time.Time) (float64, error) {
+	var total float64
+	for ticker, units := range b.units {
+		price, ok := b.valuation.price(ticker, day)
+		if !ok {
+			return 0, missingPriceError(ticker, day)
+		}
+		total += units * price
+	}
+	return total, nil
+}
+
+// apply invierte un aporte repartido según los pesos del benchmark o, si es un retiro,
+// vende la misma proporción de cada ticker. Si un ticker no tiene cierre hasta ese día se compra
+// con el primero posterior; sin ningún precio el aporte no se puede invertir y se devuelve un error.
+func (b *benchmarkHoldings) apply(flow float64, day time.Time) error {
+	if flow > 0 {
+		for _, allocation := range b.allocations {
+			price, _ := b.valuation.price(allocation.Ticker, day)
+			if price <= 0 {
+				price, _ = b.valuation.series[allocation.Ticker].firstFrom(day)
+			}
+			if price <= 0 {
+				return fmt.Errorf("no hay precio de %s para invertir el aporte del %s", allocation.Ticker, day.Format("2006-01-02"))
+			}
+			b.units[allocation.Ticker] += flow * allocation.Weight / price
+		}
+		return nil
+	}
+
+	value, err := b.value(day)
+	if err != nil {
+		return err
+	}
+	if flow == 0 || value <= 0 {
+		return nil
+	}
+	remaining := 1 + flow/value
+	if remaining < 0 {
+		remaining = 0
+	}
+	for ticker := range b.units {
+		b.units[ticker] *= remaining
+	}
+	return nil
+}
+
+// GetBenchmarkComparison compara la curva de snapshots del período con la de invertir en el benchmark
+// el valor inicial del portafolio y los mismos aportes y retiros, cada uno con el precio del día de
+// la transacción. La comparación empieza en el primer snapshot con precio para todo el benchmark.
+func (r *BenchmarkRepository) GetBenchmarkComparison(userID, period, benchmark string, basket []models.BacktestAllocation) (*models.BenchmarkComparison, error) {
+	allocations, err := normalizeBasket(basket)
+	if err != nil {
+		return nil, err
+	}
+	if len(allocations) == 0 {
+		return nil, fmt.Errorf("el benchmark necesita al menos un ticker")
+	}
+
+	now := time.Now()
+	from := services.ReturnPeriodStart(period, now)
+	emptyComparison := func() *models.BenchmarkComparison {
+		comparison := services.CompareBenchmark(nil, nil)
+		comparison.Benchmark, comparison.Allocations = benchmark, allocations
+		comparison.Period, comparison.From, comparison.To = period, from, now
+		return &comparison
+	}
+
+	snapshots, err := NewCryptoRepository(r.db).GetInvestmentSnapshotsWithMaxMin(userID, from)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return emptyComparison(), nil
+	}
+
+	transactions, err := returnTransactions(r.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	valuation, err := r.valuation(allocations, backtestDay(snapshots[0].Date), backtestDay(now))
+	if err != nil {
+		return nil, err
+	}
+
+	// Primer snapshot en el que todos los tickers del benchmark tienen precio
+	points := snapshotReturnPoints(snapshots, transactions)
+	for len(points) > 0 && !valuation.pricedOn(allocations, points[0].Date) {
+		points = points[1:]
+	}
+	if len(points) == 0 {
+		return emptyComparison(), nil
+	}
+
+	holdings := &benchmarkHoldings{
+		allocations: allocations,
+		valuation:   valuation,
+		units:       make(map[string]float64),
+	}
+	if err := holdings.apply(points[0].Value, points[0].Date); err != nil {
+		return nil, err
+	}
+
+	benchmarkPoints := make([]services.ReturnPoint, 0, len(points))
+	benchmarkPoints = append(benchmarkPoints, services.ReturnPoint{Date: points[0].Date, Value: points[0].Value})
+	next := 0
+	for next < len(transactions) && !backtestDay(transactions[next].Date).After(points[0].Date) {
+		next++
+	}
+	for _, point := range points[1:] {
+		for next < len(transactions) && !backtestDay(transactions[next].Date).After(point.Date) {
+			if flow := services.TransactionCashFlow(transactions[next]); flow != 0 {
+				if err := holdings.apply(flow, backtestDay(transactions[next].Date)); err != nil {
+					return nil, err
+				}
+			}
+			next++
+		}
+		value, err := holdings.value(point.Date)
+		if err != nil {
+			return nil, err
+		}
+		benchmarkPoints = append(benchmarkPoints, services.ReturnPoint{Date: point.Date, Value: value, Flow: point.Flow})
+	}
+
+	comparison := services.CompareBenchmark(points, benchmarkPoints)
+	comparison.Benchmark = benchmark
+	comparison.Allocations = allocations
+	comparison.Period = period
+	comparison.From = points[0].Date
+	comparison.To = points[len(points)-1].Date
+	return &comparison, nil
+}
+
+// pricedOn indica si todos los tickers del benchmark tienen precio en el día indicado
+func (v returnValuation) pricedOn(allocations []models.BacktestAllocation, day time.Time) bool {
+	for _, allocation := range allocations {
+		if price, ok := v.price(allocation.Ticker, day); !ok || price <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// valuation arma las series de cierres guardados de los tickers del benchmark y obtiene sus precios actuales
+func (r *BenchmarkRepository) valuation(allocations []models.BacktestAllocation, from, today time.Time) (returnValuation, error) {
+	valuation := returnValuation{
+		series: make(map[string]priceSeries, len(allocations)),
+		today:  today,
+	}
+
+	history := NewHistoricalPriceRepository(r.db)
+	assetClasses := make(map[string]string, len(allocations))
+	for _, allocation := range allocations {
+		closes, err := history.GetHistoricalPrices(allocation.Ticker, from.Add(-backtestLookback), today)
+		if err != nil {
+			return valuation, fmt.Errorf("error al obtener precios históricos de %s: %v", allocation.Ticker, err)
+		}
+		valuation.series[allocation.Ticker] = closes
+		assetClasses[allocation.Ticker] = models.AssetClassCrypto
+	}
+
+	quotes, err := services.GetAssetQuotes(r.priceProvider(), assetClasses)
+	if err != nil {
+		// Sin precios actuales se valora con el último cierre guardado
+		quotes = map[string]services.PriceQuote{}
+	}
+	valuation.quotes = quotes
+
+	for _, allocation := range allocations {
+		if len(valuation.series[allocation.Ticker]) == 0 && valuation.quotes[allocation.Ticker].Price <= 0 {
+			return valuation, fmt.Errorf("no hay precios históricos para %s", allocation.Ticker)
+		}
+	}
+	return valuation, nil
+}
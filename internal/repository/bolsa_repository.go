@@ -7,6 +7,7 @@ import (
 
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/shopspring/decimal"
 )
 
 // BolsaRepository maneja las operaciones de base de datos para bolsas
@@ -74,7 +75,7 @@ func (r *BolsaRepository) GetBolsaByID(id string) (*models.Bolsa, error) {
 
 	// Obtener los activos de la bolsa
 	rows, err := r.db.Query(
-		`SELECT id, bolsa_id, crypto_name, ticker, amount, purchase_price, total, image_url, created_at, updated_at 
+		`SELECT id, bolsa_id, crypto_name, ticker, amount, purchase_price, total, image_url, created_at, updated_at, asset_class
 		FROM assets_in_bolsa WHERE bolsa_id = $1`, id,
 	)
 
@@ -88,27 +89,19 @@ func (r *BolsaRepository) GetBolsaByID(id string) (*models.Bolsa, error) {
 		var asset models.AssetInBolsa
 		err := rows.Scan(
 			&asset.ID, &asset.BolsaID, &asset.CryptoName, &asset.Ticker, &asset.Amount,
-			&asset.PurchasePrice, &asset.Total, &asset.ImageURL, &asset.CreatedAt, &asset.UpdatedAt,
+			&asset.PurchasePrice, &asset.Total, &asset.ImageURL, &asset.CreatedAt, &asset.UpdatedAt, &asset.AssetClass,
 		)
 		if err != nil {
 			return nil, err
 		}
 
 		// Obtener precio actual y calcular valores
-		cryptoData, err := services.GetCryptoPriceFromCoinGecko(asset.Ticker)
+		currentPrice, err := services.GetAssetPrice(asset.AssetClass, asset.Ticker)
 		if err != nil {
 			// Si no podemos obtener el precio actual, usamos el precio de compra
-			asset.CurrentPrice = asset.PurchasePrice
-		} else {
-			asset.CurrentPrice = cryptoData.Price
-		}
-
-		asset.CurrentValue = asset.Amount * asset.CurrentPrice
-		asset.GainLoss = asset.CurrentValue - asset.Total
-
-		if asset.Total > 0 {
-			asset.GainLossPercent = (asset.GainLoss / asset.Total) * 100
+			currentPrice = asset.PurchasePrice.InexactFloat64()
 		}
+		services.ValueBolsaAsset(&asset, currentPrice)
 
 		assets = append(assets, asset)
 		bolsa.CurrentValue += asset.CurrentValue
@@ -117,34 +110,10 @@ func (r *BolsaRepository) GetBolsaByID(id string) (*models.Bolsa, error) {
 	bolsa.Assets = assets
 
 	// Obtener las reglas de la bolsa
-	rows, err = r.db.Query(
-		`SELECT id, bolsa_id, type, ticker, target_value, active, triggered, created_at, updated_at 
-		FROM trigger_rules WHERE bolsa_id = $1`, id,
-	)
-
+	rules, err := r.getRulesForBolsa(id)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var rules []models.TriggerRule
-	for rows.Next() {
-		var rule models.TriggerRule
-		var active, triggered int
-		err := rows.Scan(
-			&rule.ID, &rule.BolsaID, &rule.Type, &rule.Ticker, &rule.TargetValue,
-			&active, &triggered, &rule.CreatedAt, &rule.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		// Convertir enteros a booleanos
-		rule.Active = active == 1
-		rule.Triggered = triggered == 1
-
-		rules = append(rules, rule)
-	}
 
 	bolsa.Rules = rules
 
@@ -176,7 +145,7 @@ func (r *BolsaRepository) GetBolsasByUserID(userID string) ([]models.Bolsa, erro
 
 		// Obtener los activos de la bolsa
 		assetsRows, err := r.db.Query(
-			`SELECT id, bolsa_id, crypto_name, ticker, amount, purchase_price, total, image_url, created_at, updated_at 
+			`SELECT id, bolsa_id, crypto_name, ticker, amount, purchase_price, total, image_url, created_at, updated_at, asset_class
 			FROM assets_in_bolsa WHERE bolsa_id = $1`, bolsa.ID,
 		)
 
@@ -189,7 +158,7 @@ func (r *BolsaRepository) GetBolsasByUserID(userID string) ([]models.Bolsa, erro
 			var asset models.AssetInBolsa
 			err := assetsRows.Scan(
 				&asset.ID, &asset.BolsaID, &asset.CryptoName, &asset.Ticker, &asset.Amount,
-				&asset.PurchasePrice, &asset.Total, &asset.ImageURL, &asset.CreatedAt, &asset.UpdatedAt,
+				&asset.PurchasePrice, &asset.Total, &asset.ImageURL, &asset.CreatedAt, &asset.UpdatedAt, &asset.AssetClass,
 			)
 			if err != nil {
 				assetsRows.Close()
@@ -197,23 +166,14 @@ func (r *BolsaRepository) GetBolsasByUserID(userID string) ([]models.Bolsa, erro
 			}
 
 			// Obtener precio actual y calcular valores
-			cryptoData, err := services.GetCryptoPriceFromCoinGecko(asset.Ticker)
+			currentPrice, err := services.GetAssetPrice(asset.AssetClass, asset.Ticker)
 			if err != nil {
 				// Si no podemos obtener el precio actual, usamos el precio de compra
 				// pero registramos el error para depuraciu00f3n
 				log.Printf("Error al obtener precio actual para %s: %v", asset.Ticker, err)
-				asset.CurrentPrice = asset.PurchasePrice
-			} else {
-				// Siempre usar el precio actual de la API
-				asset.CurrentPrice = cryptoData.Price
-			}
-
-			asset.CurrentValue = asset.Amount * asset.CurrentPrice
-			asset.GainLoss = asset.CurrentValue - asset.Total
-
-			if asset.Total > 0 {
-				asset.GainLossPercent = (asset.GainLoss / asset.Total) * 100
+				currentPrice = asset.PurchasePrice.InexactFloat64()
 			}
+			services.ValueBolsaAsset(&asset, currentPrice)
 
 			assets = append(assets, asset)
 			bolsa.CurrentValue += asset.CurrentValue
@@ -223,36 +183,11 @@ func (r *BolsaRepository) GetBolsasByUserID(userID string) ([]models.Bolsa, erro
 		bolsa.Assets = assets
 
 		// Obtener las reglas de la bolsa
-		rulesRows, err := r.db.Query(
-			`SELECT id, bolsa_id, type, ticker, target_value, active, triggered, created_at, updated_at 
-			FROM trigger_rules WHERE bolsa_id = $1`, bolsa.ID,
-		)
-
+		rules, err := r.getRulesForBolsa(bolsa.ID)
 		if err != nil {
 			return nil, err
 		}
 
-		var rules []models.TriggerRule
-		for rulesRows.Next() {
-			var rule models.TriggerRule
-			var active, triggered int
-			err := rulesRows.Scan(
-				&rule.ID, &rule.BolsaID, &rule.Type, &rule.Ticker, &rule.TargetValue,
-				&active, &triggered, &rule.CreatedAt, &rule.UpdatedAt,
-			)
-			if err != nil {
-				rulesRows.Close()
-				return nil, err
-			}
-
-			// Convertir enteros a booleanos
-			rule.Active = active == 1
-			rule.Triggered = triggered == 1
-
-			rules = append(rules, rule)
-		}
-		rulesRows.Close()
-
 		bolsa.Rules = rules
 
 		bolsas = append(bolsas, bolsa)
@@ -281,17 +216,22 @@ func (r *BolsaRepository) AddAssetToBolsa(asset models.AssetInBolsa) error {
 		asset.ID = models.GenerateUUID()
 	}
 
+	if asset.AssetClass, err = services.NormalizeAssetClass(asset.AssetClass); err != nil {
+		return err
+	}
+
 	// Establecer timestamps
 	now := time.Now()
 	asset.CreatedAt = now
 	asset.UpdatedAt = now
+	services.RoundBolsaAsset(&asset)
 
 	// Insertar el activo en la base de datos
 	_, err = tx.Exec(
-		`INSERT INTO assets_in_bolsa (id, bolsa_id, crypto_name, ticker, amount, purchase_price, total, image_url, created_at, updated_at) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		`INSERT INTO assets_in_bolsa (id, bolsa_id, crypto_name, ticker, amount, purchase_price, total, image_url, created_at, updated_at, asset_class) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
 		asset.ID, asset.BolsaID, asset.CryptoName, asset.Ticker, asset.Amount,
-		asset.PurchasePrice, asset.Total, asset.ImageURL, asset.CreatedAt, asset.UpdatedAt,
+		asset.PurchasePrice, asset.Total, asset.ImageURL, asset.CreatedAt, asset.UpdatedAt, asset.AssetClass,
 	)
 
 	return err
@@ -335,10 +275,11 @@ func (r *BolsaRepository) AddRuleToBolsa(rule models.TriggerRule) error {
 
 	// Insertar la regla en la base de datos
 	_, err = tx.Exec(
-		`INSERT INTO trigger_rules (id, bolsa_id, type, ticker, target_value, active, triggered, created_at, updated_at) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		`INSERT INTO trigger_rules (id, bolsa_id, type, ticker, target_value, active, triggered, rearm, cooldown_minutes, direction, peak_value, created_at, updated_at) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
 		rule.ID, rule.BolsaID, rule.Type, rule.Ticker, rule.TargetValue,
-		active, triggered, rule.CreatedAt, rule.UpdatedAt,
+		active, triggered, boolToInt(rule.Rearm), rule.CooldownMinutes, rule.Direction, rule.PeakValue,
+		rule.CreatedAt, rule.UpdatedAt,
 	)
 
 	return err
@@ -381,9 +322,14 @@ func (r *BolsaRepository) UpdateRule(rule models.TriggerRule) error {
 			target_value = $4, 
 			active = $5, 
 			triggered = $6, 
-			updated_at = $7 
+			updated_at = $7,
+			rearm = $8,
+			cooldown_minutes = $9,
+			direction = $10,
+			peak_value = $11
 		WHERE id = $1`,
 		rule.ID, rule.Type, rule.Ticker, rule.TargetValue, active, triggered, rule.UpdatedAt,
+		boolToInt(rule.Rearm), rule.CooldownMinutes, rule.Direction, rule.PeakValue,
 	)
 
 	return err
@@ -434,6 +380,7 @@ func (r *BolsaRepository) UpdateAsset(asset models.AssetInBolsa) error {
 	}()
 
 	// Actualizar el activo en la base de datos
+	services.RoundBolsaAsset(&asset)
 	_, err = tx.Exec(
 		`UPDATE assets_in_bolsa SET 
 			crypto_name = $2, 
@@ -442,10 +389,11 @@ func (r *BolsaRepository) UpdateAsset(asset models.AssetInBolsa) error {
 			purchase_price = $5, 
 			total = $6, 
 			image_url = $7, 
-			updated_at = $8 
+			updated_at = $8, 
+			asset_class = $9 
 		WHERE id = $1`,
 		asset.ID, asset.CryptoName, asset.Ticker, asset.Amount, asset.PurchasePrice,
-		asset.Total, asset.ImageURL, time.Now(),
+		asset.Total, asset.ImageURL, time.Now(), services.AssetClassOrDefault(asset.AssetClass),
 	)
 
 	return err
@@ -593,8 +541,7 @@ func (r *BolsaRepository) getTagsForBolsa(bolsaID string) ([]string, error) {
 // getRulesForBolsa obtiene todas las reglas de una bolsa
 func (r *BolsaRepository) getRulesForBolsa(bolsaID string) ([]models.TriggerRule, error) {
 	rows, err := r.db.Query(
-		`SELECT id, bolsa_id, type, ticker, target_value, active, triggered, created_at, updated_at 
-		FROM trigger_rules WHERE bolsa_id = $1`, bolsaID,
+		`SELECT `+triggerRuleColumns+` FROM trigger_rules WHERE bolsa_id = $1`, bolsaID,
 	)
 
 	if err != nil {
@@ -604,30 +551,194 @@ func (r *BolsaRepository) getRulesForBolsa(bolsaID string) ([]models.TriggerRule
 
 	var rules []models.TriggerRule
 	for rows.Next() {
-		var rule models.TriggerRule
-		var active, triggered int
-		err := rows.Scan(
-			&rule.ID, &rule.BolsaID, &rule.Type, &rule.Ticker, &rule.TargetValue,
-			&active, &triggered, &rule.CreatedAt, &rule.UpdatedAt,
-		)
+		rule, err := scanTriggerRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// triggerRuleColumns son las columnas que se leen de trigger_rules, en el orden de scanTriggerRule
+const triggerRuleColumns = `id, bolsa_id, type, ticker, target_value, active, triggered,
+	rearm, cooldown_minutes, last_triggered_at, direction, peak_value, created_at, updated_at`
+
+// scanTriggerRule lee una regla de una fila
+func scanTriggerRule(row rowScanner) (*models.TriggerRule, error) {
+	var rule models.TriggerRule
+	var ticker, direction sql.NullString
+	var active, triggered, rearm int
+	var lastTriggeredAt sql.NullTime
+	var peakValue decimal.NullDecimal
+
+	err := row.Scan(
+		&rule.ID, &rule.BolsaID, &rule.Type, &ticker, &rule.TargetValue,
+		&active, &triggered, &rearm, &rule.CooldownMinutes, &lastTriggeredAt,
+		&direction, &peakValue, &rule.CreatedAt, &rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.Ticker = ticker.String
+	rule.Direction = direction.String
+	if rule.Direction == "" {
+		rule.Direction = models.TriggerDirectionAbove
+	}
+	rule.PeakValue = peakValue.Decimal
+
+	// Convertir enteros a booleanos
+	rule.Active = active == 1
+	rule.Triggered = triggered == 1
+	rule.Rearm = rearm == 1
+	if lastTriggeredAt.Valid {
+		rule.LastTriggeredAt = &lastTriggeredAt.Time
+	}
+
+	return &rule, nil
+}
+
+// GetActiveRules obtiene todas las reglas activas de todas las bolsas
+func (r *BolsaRepository) GetActiveRules() ([]models.TriggerRule, error) {
+	rows, err := r.db.Query(
+		`SELECT ` + triggerRuleColumns + ` FROM trigger_rules WHERE active = 1 ORDER BY bolsa_id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.TriggerRule
+	for rows.Next() {
+		rule, err := scanTriggerRule(rows)
 		if err != nil {
 			return nil, err
 		}
+		rules = append(rules, *rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// MarkRuleTriggered marca una regla como disparada y registra el evento en una sola transacción.
+// Devuelve false si la regla ya estaba disparada, de modo que cada disparo se registra una única vez.
+func (r *BolsaRepository) MarkRuleTriggered(event models.TriggerEvent) (triggered bool, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
 
-		// Convertir enteros a booleanos
-		rule.Active = active == 1
-		rule.Triggered = triggered == 1
+	result, err := tx.Exec(
+		`UPDATE trigger_rules SET triggered = 1, last_triggered_at = $2, updated_at = $2
+		WHERE id = $1 AND active = 1 AND triggered = 0`,
+		event.RuleID, event.TriggeredAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
 
-		rules = append(rules, rule)
+	if event.ID == "" {
+		event.ID = models.GenerateUUID()
 	}
+	_, err = tx.Exec(
+		`INSERT INTO trigger_events (id, rule_id, bolsa_id, type, ticker, target_value, observed_value, triggered_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		event.ID, event.RuleID, event.BolsaID, event.Type, event.Ticker,
+		event.TargetValue, event.ObservedValue, event.TriggeredAt,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// RearmRule vuelve a armar una regla disparada para que pueda dispararse de nuevo
+func (r *BolsaRepository) RearmRule(ruleID string) error {
+	_, err := r.db.Exec(
+		`UPDATE trigger_rules SET triggered = 0, updated_at = $2 WHERE id = $1`,
+		ruleID, time.Now(),
+	)
+	return err
+}
 
-	return rules, nil
+// UpdateRulePeak guarda el máximo valor observado por una regla de caída
+func (r *BolsaRepository) UpdateRulePeak(ruleID string, peak decimal.Decimal) error {
+	_, err := r.db.Exec(
+		`UPDATE trigger_rules SET peak_value = $2 WHERE id = $1`,
+		ruleID, peak,
+	)
+	return err
+}
+
+// DeleteRule elimina una regla de una bolsa junto con su historial de disparos
+func (r *BolsaRepository) DeleteRule(bolsaID, ruleID string) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM trigger_events WHERE rule_id = $1`, ruleID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM trigger_rules WHERE id = $1 AND bolsa_id = $2`, ruleID, bolsaID)
+	return err
+}
+
+// GetTriggerEvents obtiene los disparos más recientes de las reglas de una bolsa
+func (r *BolsaRepository) GetTriggerEvents(bolsaID string, limit int) ([]models.TriggerEvent, error) {
+	rows, err := r.db.Query(
+		`SELECT id, rule_id, bolsa_id, type, ticker, target_value, observed_value, triggered_at
+		FROM trigger_events WHERE bolsa_id = $1 ORDER BY triggered_at DESC LIMIT $2`,
+		bolsaID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.TriggerEvent{}
+	for rows.Next() {
+		var event models.TriggerEvent
+		var ticker sql.NullString
+		err := rows.Scan(
+			&event.ID, &event.RuleID, &event.BolsaID, &event.Type, &ticker,
+			&event.TargetValue, &event.ObservedValue, &event.TriggeredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		event.Ticker = ticker.String
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
 }
 
 // getAssetsForBolsa obtiene todos los activos de una bolsa
 func (r *BolsaRepository) getAssetsForBolsa(bolsaID string) ([]models.AssetInBolsa, error) {
 	rows, err := r.db.Query(
-		`SELECT id, bolsa_id, crypto_name, ticker, amount, purchase_price, total, image_url, created_at, updated_at 
+		`SELECT id, bolsa_id, crypto_name, ticker, amount, purchase_price, total, image_url, created_at, updated_at, asset_class
 		FROM assets_in_bolsa WHERE bolsa_id = $1`, bolsaID,
 	)
 
@@ -641,27 +752,19 @@ func (r *BolsaRepository) getAssetsForBolsa(bolsaID string) ([]models.AssetInBol
 		var asset models.AssetInBolsa
 		err := rows.Scan(
 			&asset.ID, &asset.BolsaID, &asset.CryptoName, &asset.Ticker, &asset.Amount,
-			&asset.PurchasePrice, &asset.Total, &asset.ImageURL, &asset.CreatedAt, &asset.UpdatedAt,
+			&asset.PurchasePrice, &asset.Total, &asset.ImageURL, &asset.CreatedAt, &asset.UpdatedAt, &asset.AssetClass,
 		)
 		if err != nil {
 			return nil, err
 		}
 
 		// Obtener precio actual y calcular valores
-		cryptoData, err := services.GetCryptoPriceFromCoinGecko(asset.Ticker)
+		currentPrice, err := services.GetAssetPrice(asset.AssetClass, asset.Ticker)
 		if err != nil {
 			// Si no podemos obtener el precio actual, usamos el precio de compra
-			asset.CurrentPrice = asset.PurchasePrice
-		} else {
-			asset.CurrentPrice = cryptoData.Price
-		}
-
-		asset.CurrentValue = asset.Amount * asset.CurrentPrice
-		asset.GainLoss = asset.CurrentValue - asset.Total
-
-		if asset.Total > 0 {
-			asset.GainLossPercent = (asset.GainLoss / asset.Total) * 100
+			currentPrice = asset.PurchasePrice.InexactFloat64()
 		}
+		services.ValueBolsaAsset(&asset, currentPrice)
 
 		assets = append(assets, asset)
 	}
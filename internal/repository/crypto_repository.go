@@ -5,17 +5,21 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/shopspring/decimal"
 )
 
 // CryptoRepository maneja las operaciones de base de datos para criptomonedas
 type CryptoRepository struct {
 	db           *sql.DB
 	holdingsRepo *HoldingsRepository
+	prices       services.PriceProvider
 }
 
 // NewCryptoRepository crea un nuevo repositorio de criptomonedas
@@ -26,13 +30,33 @@ func NewCryptoRepository(db *sql.DB) *CryptoRepository {
 	}
 }
 
-// Función para generar un ID único para transacciones
-func generateTransactionId() string {
-	return fmt.Sprintf("%d", time.Now().UnixNano())
+// WithPrices devuelve una copia del repositorio que obtiene los precios del proveedor indicado
+// (por ejemplo, el lote de precios compartido de una petición)
+func (r *CryptoRepository) WithPrices(prices services.PriceProvider) *CryptoRepository {
+	clone := *r
+	clone.prices = prices
+	clone.holdingsRepo = r.holdingsRepo.WithPrices(prices)
+	return &clone
+}
+
+// withPriceBatch garantiza que todas las consultas de precios del repositorio compartan un único lote
+func (r *CryptoRepository) withPriceBatch() *CryptoRepository {
+	if _, isBatch := r.priceProvider().(*services.PriceBatch); isBatch {
+		return r
+	}
+	return r.WithPrices(services.NewPriceBatch(r.priceProvider()))
+}
+
+// priceProvider devuelve el proveedor de precios del repositorio o el global si no se indicó ninguno
+func (r *CryptoRepository) priceProvider() services.PriceProvider {
+	if r.prices != nil {
+		return r.prices
+	}
+	return services.GetPriceProvider()
 }
 
 // CreateTransaction crea una nueva transacción de criptomoneda
-func (r *CryptoRepository) CreateTransaction(transaction models.CryptoTransaction) error {
+func (r *CryptoRepository) CreateTransaction(transaction models.CryptoTransaction) (err error) {
 	// Iniciar transacción SQL
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -47,53 +71,191 @@ func (r *CryptoRepository) CreateTransaction(transaction models.CryptoTransactio
 	}()
 
 	// Generar ID único para la transacción
-	transaction.ID = generateTransactionId()
+	transaction.ID = models.GenerateUUID()
 
-	// Si es una venta, verificar si el usuario tiene suficiente saldo
-	if transaction.Type == models.TransactionTypeSell {
-		err = r.holdingsRepo.UpdateHoldingsAfterSale(tx, transaction.UserID, transaction.Ticker, transaction.Amount)
-		if err != nil {
-			return err
-		}
+	_, err = r.createTransactionTx(tx, transaction, models.GenerateUUID)
+	return err
+}
+
+// createTransactionTx completa e inserta una transacción (con ID ya asignado) dentro de una
+// transacción SQL abierta, junto con la compra automática de USDT si es una venta por USDT.
+// nextID genera el ID de esa compra automática.
+func (r *CryptoRepository) createTransactionTx(tx *sql.Tx, transaction models.CryptoTransaction, nextID func() string) (models.CryptoTransaction, error) {
+	if err := checkAssetClass(tx, &transaction); err != nil {
+		return transaction, err
+	}
+	transactionType, err := services.NormalizeTransactionType(transaction.Type)
+	if err != nil {
+		return transaction, err
+	}
+	transaction.Type = transactionType
+	if err := checkTransactionAccounts(tx, transaction); err != nil {
+		return transaction, err
 	}
 
-	// Insertar la transacción en la base de datos
-	query := `
-		INSERT INTO crypto_transactions (
-			id, user_id, crypto_name, ticker, amount, purchase_price, 
-			total, date, note, created_at, type, usdt_received, image_url
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
-	`
+	// Si es una venta, verificar si el usuario tiene suficiente saldo
+	if err := r.checkSaleHoldings(tx, transaction); err != nil {
+		return transaction, err
+	}
 
 	// Si la fecha está vacía, usar la fecha actual
 	if transaction.Date.IsZero() {
 		transaction.Date = time.Now()
 	}
 
-	// Si el tipo está vacío, establecer como compra por defecto
-	if transaction.Type == "" {
-		transaction.Type = models.TransactionTypeBuy
+	// Un ingreso sin precio se valora con el cierre guardado del día en que se recibió. Si no lo hay,
+	// el precio actual solo sirve para un ingreso de hoy: uno anterior necesita purchase_price.
+	if services.IsIncomeType(transaction.Type) && !transaction.PurchasePrice.IsPositive() {
+		if price, ok := importHistoricalPrice(NewHistoricalPriceRepository(r.db), transaction.Ticker, transaction.Date); ok {
+			transaction.PurchasePrice = price
+		} else if services.IsPastDay(transaction.Date, time.Now()) {
+			return transaction, fmt.Errorf("no hay precio histórico de %s para el %s, indica purchase_price con su valor al recibirlo",
+				transaction.Ticker, transaction.Date.Format("2006-01-02"))
+		}
 	}
 
 	// Si no se especificó el precio, obtener precio actual
-	if transaction.PurchasePrice <= 0 {
-		cryptoData, err := services.GetCryptoPrice(transaction.Ticker)
+	if !transaction.PurchasePrice.IsPositive() {
+		currentPrice, err := services.GetAssetPrice(transaction.AssetClass, transaction.Ticker)
 		if err != nil {
-			return fmt.Errorf("error al obtener precio de %s: %v", transaction.Ticker, err)
+			return transaction, fmt.Errorf("error al obtener precio de %s: %v", transaction.Ticker, err)
 		}
-		// Usar el precio actual de la API
-		transaction.PurchasePrice = cryptoData.Raw[transaction.Ticker]["USD"].PRICE
+		// Usar el precio actual del proveedor
+		transaction.PurchasePrice = decimal.NewFromFloat(currentPrice)
 	}
 
 	// Calcular el total si no se especificó
-	if transaction.Total <= 0 {
-		transaction.Total = transaction.Amount * transaction.PurchasePrice
+	if !transaction.Total.IsPositive() {
+		transaction.Total = transaction.Amount.Mul(transaction.PurchasePrice)
+	}
+
+	// Valorar la comisión en dólares
+	if err := services.ResolveTransactionFee(&transaction); err != nil {
+		return transaction, err
 	}
+	services.RoundTransaction(&transaction)
 
 	// Establecer la fecha de creación
 	transaction.CreatedAt = time.Now()
 
-	_, err = tx.Exec(
+	// Una venta por USDT es un intercambio: enlazar la venta con la compra automática de USDT
+	if transaction.Type == models.TransactionTypeSell && transaction.USDTReceived.IsPositive() {
+		transaction.SwapID = models.GenerateUUID()
+	}
+
+	// Insertar la transacción en la base de datos
+	if err := insertTransaction(tx, transaction); err != nil {
+		return transaction, err
+	}
+
+	// Si es una venta y se recibió USDT, crear automáticamente una transacción de compra de USDT
+	if transaction.Type == models.TransactionTypeSell && transaction.USDTReceived.IsPositive() {
+		usdtTransaction := usdtPurchaseLeg(transaction)
+
+		// Generar ID único para la transacción de USDT
+		usdtTransaction.ID = nextID()
+
+		// Insertar la transacción de USDT
+		if usdtErr := insertTransaction(tx, usdtTransaction); usdtErr != nil {
+			// Loguear el error pero no interrumpir el flujo principal
+			log.Printf("Error al crear transacción automática de USDT: %v", usdtErr)
+		}
+	}
+
+	return transaction, nil
+}
+
+// checkSaleHoldings verifica que el usuario tenga saldo para una venta (y su comisión si se paga con
+// la misma moneda) sin contar la propia transacción, que puede ser una venta que se está editando
+func (r *CryptoRepository) checkSaleHoldings(tx *sql.Tx, transaction models.CryptoTransaction) error {
+	if transaction.Type != models.TransactionTypeSell {
+		return nil
+	}
+	amountToSell := transaction.Amount
+	if services.IsTradedCoinFee(transaction) {
+		amountToSell = amountToSell.Add(transaction.Fee)
+	}
+	return r.holdingsRepo.UpdateHoldingsAfterSale(tx, transaction.UserID, transaction.Ticker, transaction.ID, amountToSell)
+}
+
+// usdtPurchaseLeg arma la compra automática de USDT que acompaña a una venta por USDT
+func usdtPurchaseLeg(sale models.CryptoTransaction) models.CryptoTransaction {
+	return models.CryptoTransaction{
+		UserID:        sale.UserID,
+		CryptoName:    "Tether",
+		Ticker:        "USDT",
+		Amount:        sale.USDTReceived,
+		PurchasePrice: decimal.NewFromInt(1), // USDT está anclado a 1 USD
+		Total:         sale.USDTReceived,
+		Date:          sale.Date,
+		Note:          fmt.Sprintf("Compra automática de USDT por venta de %s", sale.Ticker),
+		CreatedAt:     time.Now(),
+		Type:          models.TransactionTypeBuy,
+		SwapID:        sale.SwapID,
+		AssetClass:    models.AssetClassCrypto,
+		AccountID:     sale.AccountID,
+	}
+}
+
+// userAssetClasses devuelve la clase de activo de cada ticker con transacciones del usuario
+func userAssetClasses(db *sql.DB, userID string) (map[string]string, error) {
+	rows, err := db.Query(`SELECT DISTINCT ticker, asset_class FROM crypto_transactions WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assetClasses := make(map[string]string)
+	for rows.Next() {
+		var ticker, assetClass string
+		if err := rows.Scan(&ticker, &assetClass); err != nil {
+			return nil, err
+		}
+		assetClasses[ticker] = assetClass
+	}
+	return assetClasses, rows.Err()
+}
+
+// checkAssetClass normaliza la clase de activo de una transacción y verifica que coincida con la de
+// las demás transacciones del usuario con el mismo ticker: cada ticker pertenece a una sola clase.
+func checkAssetClass(tx *sql.Tx, transaction *models.CryptoTransaction) error {
+	assetClass, err := services.NormalizeAssetClass(transaction.AssetClass)
+	if err != nil {
+		return err
+	}
+	transaction.AssetClass = assetClass
+
+	var existing string
+	err = tx.QueryRow(
+		`SELECT asset_class FROM crypto_transactions WHERE user_id = $1 AND ticker = $2 AND id <> $3 LIMIT 1`,
+		transaction.UserID, transaction.Ticker, transaction.ID,
+	).Scan(&existing)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing != assetClass {
+		return fmt.Errorf("%s ya está registrado como %s, no se puede usar como %s", transaction.Ticker, existing, assetClass)
+	}
+	return nil
+}
+
+// insertTransaction inserta una transacción ya preparada dentro de una transacción SQL,
+// redondeando sus valores a la precisión con la que se guardan
+func insertTransaction(tx *sql.Tx, transaction models.CryptoTransaction) error {
+	services.RoundTransaction(&transaction)
+	query := `
+		INSERT INTO crypto_transactions (
+			id, user_id, crypto_name, ticker, amount, purchase_price, 
+			total, date, note, created_at, type, usdt_received, image_url,
+			fee, fee_currency, fee_usd, swap_id, asset_class, account_id, to_account_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, NULLIF($17, ''), $18,
+			NULLIF($19, ''), NULLIF($20, ''))
+	`
+
+	_, err := tx.Exec(
 		query,
 		transaction.ID,
 		transaction.UserID,
@@ -108,97 +270,106 @@ func (r *CryptoRepository) CreateTransaction(transaction models.CryptoTransactio
 		transaction.Type,
 		transaction.USDTReceived,
 		transaction.ImageURL,
+		transaction.Fee,
+		transaction.FeeCurrency,
+		transaction.FeeUSD,
+		transaction.SwapID,
+		services.AssetClassOrDefault(transaction.AssetClass),
+		transaction.AccountID,
+		transaction.ToAccountID,
 	)
+	return err
+}
 
+// UpdateTransaction actualiza una transacción existente
+func (r *CryptoRepository) UpdateTransaction(transaction models.CryptoTransaction) (err error) {
+	// Iniciar transacción SQL
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
-
-	// Si es una venta y se recibió USDT, crear automáticamente una transacción de compra de USDT
-	if transaction.Type == models.TransactionTypeSell && transaction.USDTReceived > 0 {
-		usdtTransaction := models.CryptoTransaction{
-			UserID:        transaction.UserID,
-			CryptoName:    "Tether",
-			Ticker:        "USDT",
-			Amount:        transaction.USDTReceived,
-			PurchasePrice: 1.0, // USDT está anclado a 1 USD
-			Total:         transaction.USDTReceived,
-			Date:          transaction.Date,
-			Note:          fmt.Sprintf("Compra automática de USDT por venta de %s", transaction.Ticker),
-			Type:          models.TransactionTypeBuy,
-		}
-
-		// Generar ID único para la transacción de USDT
-		usdtTransaction.ID = generateTransactionId()
-		usdtTransaction.CreatedAt = time.Now()
-
-		// Insertar la transacción de USDT
-		_, err = tx.Exec(
-			query,
-			usdtTransaction.ID,
-			usdtTransaction.UserID,
-			usdtTransaction.CryptoName,
-			usdtTransaction.Ticker,
-			usdtTransaction.Amount,
-			usdtTransaction.PurchasePrice,
-			usdtTransaction.Total,
-			usdtTransaction.Date,
-			usdtTransaction.Note,
-			usdtTransaction.CreatedAt,
-			usdtTransaction.Type,
-			0,  // No hay USDT recibido en una compra
-			"", // No hay imagen URL para la transacción automática
-		)
-
+	defer func() {
 		if err != nil {
-			// Loguear el error pero no interrumpir el flujo principal
-			log.Printf("Error al crear transacción automática de USDT: %v", err)
+			tx.Rollback()
+			return
 		}
-	}
+		err = tx.Commit()
+	}()
 
-	return nil
+	_, err = r.updateTransactionTx(tx, transaction)
+	return err
 }
 
-// UpdateTransaction actualiza una transacción existente
-func (r *CryptoRepository) UpdateTransaction(transaction models.CryptoTransaction) error {
+// updateTransactionTx actualiza una transacción existente dentro de una transacción SQL abierta
+func (r *CryptoRepository) updateTransactionTx(tx *sql.Tx, transaction models.CryptoTransaction) (models.CryptoTransaction, error) {
 	// Verificar que la transacción exista y pertenezca al usuario
-	var existingUserId string
-	err := r.db.QueryRow("SELECT user_id FROM crypto_transactions WHERE id = $1", transaction.ID).Scan(&existingUserId)
+	var existingUserId, swapID, assetClass, transactionType, accountID string
+	err := tx.QueryRow("SELECT user_id, COALESCE(swap_id, ''), asset_class, type, COALESCE(account_id, '') FROM crypto_transactions WHERE id = $1", transaction.ID).Scan(&existingUserId, &swapID, &assetClass, &transactionType, &accountID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("transacción no encontrada.")
+			return transaction, fmt.Errorf("transacción no encontrada.")
 		}
-		return err
+		return transaction, err
 	}
 
 	if existingUserId != transaction.UserID {
-		return fmt.Errorf("no tienes permiso para modificar esta transacción")
+		return transaction, fmt.Errorf("no tienes permiso para modificar esta transacción")
 	}
 
-	// Iniciar transacción SQL
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
+	// Las patas de un intercambio solo se editan juntas
+	if swapID != "" {
+		return transaction, ErrSwapLeg
+	}
+
+	// Si no se indica la clase de activo se conserva la que tenía
+	if transaction.AssetClass == "" {
+		transaction.AssetClass = assetClass
+	}
+	if err := checkAssetClass(tx, &transaction); err != nil {
+		return transaction, err
+	}
+	// Lo mismo con el tipo de transacción
+	if transaction.Type == "" {
+		transaction.Type = transactionType
+	}
+	if transaction.Type, err = services.NormalizeTransactionType(transaction.Type); err != nil {
+		return transaction, err
+	}
+	// Y con la cuenta, salvo que se pida quitarla explícitamente
+	switch transaction.AccountID {
+	case "":
+		transaction.AccountID = accountID
+	case models.AccountIDNone:
+		transaction.AccountID = ""
+	}
+	if err := checkTransactionAccounts(tx, transaction); err != nil {
+		return transaction, err
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-			return
-		}
-		err = tx.Commit()
-	}()
 
 	// Actualizar la transacción
 	query := `
 		UPDATE crypto_transactions 
 		SET crypto_name = $1, ticker = $2, amount = $3, purchase_price = $4, 
-			total = $5, date = $6, note = $7, type = $8, usdt_received = $9, image_url = $10
+			total = $5, date = $6, note = $7, type = $8, usdt_received = $9, image_url = $10,
+			fee = $13, fee_currency = $14, fee_usd = $15, asset_class = $16,
+			account_id = NULLIF($17, ''), to_account_id = NULLIF($18, '')
 		WHERE id = $11 AND user_id = $12
 	`
 
 	// Calcular el total si no se especificó
-	if transaction.Total <= 0 {
-		transaction.Total = transaction.Amount * transaction.PurchasePrice
+	if !transaction.Total.IsPositive() {
+		transaction.Total = transaction.Amount.Mul(transaction.PurchasePrice)
+	}
+
+	// Valorar la comisión en dólares
+	if err = services.ResolveTransactionFee(&transaction); err != nil {
+		return transaction, err
+	}
+	services.RoundTransaction(&transaction)
+
+	// Una venta editada (o una compra convertida en venta) necesita saldo como una nueva
+	if err = r.checkSaleHoldings(tx, transaction); err != nil {
+		return transaction, err
 	}
 
 	_, err = tx.Exec(
@@ -215,30 +386,74 @@ func (r *CryptoRepository) UpdateTransaction(transaction models.CryptoTransactio
 		transaction.ImageURL,
 		transaction.ID,
 		transaction.UserID,
+		transaction.Fee,
+		transaction.FeeCurrency,
+		transaction.FeeUSD,
+		transaction.AssetClass,
+		transaction.AccountID,
+		transaction.ToAccountID,
 	)
 
-	return err
+	return transaction, err
 }
 
 // DeleteTransaction elimina una transacción
-func (r *CryptoRepository) DeleteTransaction(userID, transactionID string) error {
-	// Verificar que la transacción pertenezca al usuario
-	var count int
-	err := r.db.QueryRow("SELECT COUNT(*) FROM crypto_transactions WHERE id = $1 AND user_id = $2",
-		transactionID, userID).Scan(&count)
+func (r *CryptoRepository) DeleteTransaction(userID, transactionID string) (err error) {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
-	if count == 0 {
-		return errors.New("transacción no encontrada o no tienes permiso para eliminarla")
-	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
 
-	// Eliminar la transacción
-	_, err = r.db.Exec("DELETE FROM crypto_transactions WHERE id = $1 AND user_id = $2",
-		transactionID, userID)
+	_, err = deleteTransactionTx(tx, userID, transactionID)
 	return err
 }
 
+// deleteTransactionTx elimina una transacción dentro de una transacción SQL abierta y devuelve
+// los IDs eliminados: si es parte de un intercambio se eliminan ambas patas
+func deleteTransactionTx(tx *sql.Tx, userID, transactionID string) ([]string, error) {
+	// Verificar que la transacción pertenezca al usuario
+	var swapID string
+	err := tx.QueryRow("SELECT COALESCE(swap_id, '') FROM crypto_transactions WHERE id = $1 AND user_id = $2",
+		transactionID, userID).Scan(&swapID)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("transacción no encontrada o no tienes permiso para eliminarla")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Si es parte de un intercambio, eliminar ambas patas para no dejar ninguna huérfana
+	query := "DELETE FROM crypto_transactions WHERE id = $1 AND user_id = $2 RETURNING id"
+	key := transactionID
+	if swapID != "" {
+		query = "DELETE FROM crypto_transactions WHERE swap_id = $1 AND user_id = $2 RETURNING id"
+		key = swapID
+	}
+
+	rows, err := tx.Query(query, key, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deleted []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, id)
+	}
+	return deleted, rows.Err()
+}
+
 // DeleteTransactionsByTicker elimina todas las transacciones de una criptomoneda específica para un usuario
 func (r *CryptoRepository) DeleteTransactionsByTicker(userID, ticker string) error {
 	// Verificar que el ticker exista para el usuario
@@ -264,8 +479,13 @@ func (r *CryptoRepository) DeleteTransactionsByTicker(userID, ticker string) err
 		}
 	}()
 
-	// Eliminar todas las transacciones con ese ticker
-	deleteQuery := `DELETE FROM crypto_transactions WHERE user_id = $1 AND ticker = $2`
+	// Eliminar todas las transacciones con ese ticker junto con la otra pata de sus intercambios
+	deleteQuery := `
+		DELETE FROM crypto_transactions
+		WHERE user_id = $1 AND (ticker = $2 OR swap_id IN (
+			SELECT swap_id FROM crypto_transactions
+			WHERE user_id = $1 AND ticker = $2 AND swap_id IS NOT NULL
+		))`
 	result, err := tx.Exec(deleteQuery, userID, ticker)
 	if err != nil {
 		return err
@@ -293,7 +513,9 @@ func (r *CryptoRepository) DeleteTransactionsByTicker(userID, ticker string) err
 func (r *CryptoRepository) GetUserTransactionsWithDetails(userID string) ([]models.TransactionDetails, error) {
 	query := `
 		SELECT id, user_id, crypto_name, ticker, amount, purchase_price, 
-			   total, date, note, created_at, type, usdt_received, image_url
+			   total, date, note, created_at, type, usdt_received, image_url,
+			   fee, fee_currency, fee_usd, COALESCE(swap_id, ''), asset_class,
+			   COALESCE(account_id, ''), COALESCE(to_account_id, '')
 		FROM crypto_transactions 
 		WHERE user_id = $1 
 		ORDER BY date DESC
@@ -322,6 +544,13 @@ func (r *CryptoRepository) GetUserTransactionsWithDetails(userID string) ([]mode
 			&tx.Type,
 			&tx.USDTReceived,
 			&tx.ImageURL,
+			&tx.Fee,
+			&tx.FeeCurrency,
+			&tx.FeeUSD,
+			&tx.SwapID,
+			&tx.AssetClass,
+			&tx.AccountID,
+			&tx.ToAccountID,
 		)
 		if err != nil {
 			return nil, err
@@ -333,268 +562,409 @@ func (r *CryptoRepository) GetUserTransactionsWithDetails(userID string) ([]mode
 		return nil, err
 	}
 
-	var details []models.TransactionDetails
+	quotes := r.transactionQuotes(transactions)
+	details := make([]models.TransactionDetails, 0, len(transactions))
 	for _, tx := range transactions {
-		// Crear el objeto de detalles con la transacción base
-		detail := models.TransactionDetails{
-			Transaction: tx,
-		}
+		details = append(details, r.transactionDetail(tx, quotes, nil))
+	}
 
-		// Obtener el precio actual de la criptomoneda
-		cryptoData, err := services.GetCryptoPrice(tx.Ticker)
-		if err == nil && cryptoData.Raw[tx.Ticker]["USD"].PRICE > 0 {
-			// Si se obtiene el precio actual correctamente
-			currentPrice := cryptoData.Raw[tx.Ticker]["USD"].PRICE
+	return collapseSwapLegs(details), nil
+}
 
-			// Calcular ganancia/pérdida según el tipo de transacción
-			if tx.Type == models.TransactionTypeBuy {
-				// Para compras:
-				// Precio: precio de compra
-				// Precio actual: obtenido de la API
-				detail.CurrentPrice = currentPrice
+// transactionQuotes obtiene en una sola consulta los precios actuales de los activos de las transacciones
+func (r *CryptoRepository) transactionQuotes(transactions []models.CryptoTransaction) map[string]services.PriceQuote {
+	assets := make(map[string]string, len(transactions))
+	for _, tx := range transactions {
+		assets[tx.Ticker] = tx.AssetClass
+	}
+	if len(assets) == 0 {
+		return map[string]services.PriceQuote{}
+	}
 
-				// Asegurarse de que tx.Total tenga el valor correcto (precio * cantidad)
-				if tx.Total <= 0 {
-					tx.Total = tx.Amount * tx.PurchasePrice
-				}
+	quotes, err := services.GetAssetQuotes(r.priceProvider(), assets)
+	if err != nil {
+		// Si no podemos obtener los precios seguimos con los valores de respaldo
+		log.Printf("Error al obtener precios de las transacciones: %v", err)
+		return map[string]services.PriceQuote{}
+	}
+	return quotes
+}
 
-				// Valor actual: precio actual * cantidad
-				detail.CurrentValue = tx.Amount * currentPrice
+// transactionDetail calcula el valor actual y la ganancia o pérdida de una transacción. saleCosts
+// tiene el costo promedio por unidad de cada venta; si es nil se consulta el de cada venta.
+func (r *CryptoRepository) transactionDetail(tx models.CryptoTransaction, quotes map[string]services.PriceQuote, saleCosts map[string]float64) models.TransactionDetails {
+	// Crear el objeto de detalles con la transacción base
+	detail := models.TransactionDetails{
+		Transaction: tx,
+	}
 
-				// Ganancia/pérdida: valor actual - total
-				detail.GainLoss = detail.CurrentValue - tx.Total
+	// La valoración a precios de mercado es aproximada: se calcula con float64
+	amount := tx.Amount.InexactFloat64()
+	purchasePrice := tx.PurchasePrice.InexactFloat64()
+	total := tx.Total.InexactFloat64()
+	usdtReceived := tx.USDTReceived.InexactFloat64()
 
-				// Porcentaje de ganancia/pérdida
-				if tx.Total > 0 {
-					detail.GainLossPercent = (detail.GainLoss / tx.Total) * 100
-				}
-			} else if tx.Type == models.TransactionTypeSell {
-				// Para ventas: necesitamos obtener el precio promedio de compra para calcular la ganancia/pérdida
-				// Obtener el precio promedio de compra de la criptomoneda
-				avgPrice, err := r.getAveragePurchasePrice(tx.UserID, tx.Ticker, tx.Date)
-				if err != nil || avgPrice <= 0 {
-					// Si hay un error o el precio promedio es 0, usar el precio de compra de la transacción
-					avgPrice = tx.PurchasePrice
-				}
-				// Calcular el costo base usando el precio promedio
-				costBasis := avgPrice * tx.Amount
+	// Obtener el precio actual de la criptomoneda
+	quote, found := quotes[strings.ToUpper(tx.Ticker)]
+	currentPrice := quote.Price
+	if found && currentPrice > 0 {
+		// Si se obtiene el precio actual correctamente
 
-				// Asegurarse de que el total sea correcto (lo que se recibió por la venta)
-				if tx.USDTReceived > 0 {
-					tx.Total = tx.USDTReceived
-				} else if tx.Total <= 0 {
-					tx.Total = tx.Amount * tx.PurchasePrice
-				}
+		// Calcular ganancia/pérdida según el tipo de transacción
+		if services.IsAcquisitionType(tx.Type) {
+			// Para compras e ingresos (valorados al precio de mercado al recibirlos):
+			// Precio: precio de compra
+			// Precio actual: obtenido de la API
+			detail.CurrentPrice = currentPrice
 
-				// El precio actual para mostrar
-				detail.CurrentPrice = currentPrice
-				// El valor actual es lo que valdría si aún tuviéramos la criptomoneda
-				detail.CurrentValue = tx.Amount * currentPrice
+			// Asegurarse de que tx.Total tenga el valor correcto (precio * cantidad)
+			if total <= 0 {
+				total = amount * purchasePrice
+			}
 
-				// La ganancia/pérdida es lo que se recibió menos lo que costó
-				detail.GainLoss = tx.Total - costBasis
+			// Valor actual: precio actual * cantidad
+			detail.CurrentValue = amount * currentPrice
 
-				// Calcular el porcentaje de ganancia/pérdida
-				if costBasis > 0 {
-					detail.GainLossPercent = (detail.GainLoss / costBasis) * 100
-				}
-			}
-		} else {
-			// Si hay un error, usar el precio de compra como respaldo
-			detail.CurrentPrice = tx.PurchasePrice
+			// Ganancia/pérdida: valor actual - total
+			detail.GainLoss = detail.CurrentValue - total
 
-			// Para ventas, aún podemos calcular la ganancia/pérdida
-			if tx.Type == models.TransactionTypeSell {
-				// Intentar obtener el precio promedio de compra
-				avgPrice, err := r.getAveragePurchasePrice(tx.UserID, tx.Ticker, tx.Date)
-				if err != nil || avgPrice <= 0 {
-					// Si hay un error o el precio promedio es 0, usar el precio de compra de la transacción
-					avgPrice = tx.PurchasePrice
-				}
-				// costBasis := avgPrice * tx.Amount
+			// Porcentaje de ganancia/pérdida
+			if total > 0 {
+				detail.GainLossPercent = (detail.GainLoss / total) * 100
+			}
+		} else if tx.Type == models.TransactionTypeSell {
+			// Para ventas: necesitamos obtener el precio promedio de compra para calcular la ganancia/pérdida
+			// Obtener el precio promedio de compra de la criptomoneda
+			avgPrice := r.saleAverageCost(tx, saleCosts)
+			// Calcular el costo base usando el precio promedio
+			costBasis := avgPrice * amount
 
-				// Asegurarse de que el total sea correcto (lo que se recibió por la venta)
-				if tx.USDTReceived > 0 {
-					tx.Total = tx.USDTReceived
-				} else if tx.Total <= 0 {
-					tx.Total = tx.Amount * tx.PurchasePrice
-				}
+			// Asegurarse de que el total sea correcto (lo que se recibió por la venta)
+			if usdtReceived > 0 {
+				total = usdtReceived
+			} else if total <= 0 {
+				total = amount * purchasePrice
+			}
 
-				// El valor actual es lo que valdría si aún tuviéramos la criptomoneda
-				detail.CurrentValue = tx.Amount * tx.PurchasePrice
+			// El precio actual para mostrar
+			detail.CurrentPrice = currentPrice
+			// El valor actual es lo que valdría si aún tuviéramos la criptomoneda
+			detail.CurrentValue = amount * currentPrice
 
-				// La ganancia/pérdida es lo que se recibió menos lo que valdría ahora
-				detail.GainLoss = tx.Total - detail.CurrentValue
+			// La ganancia/pérdida es lo que se recibió menos lo que costó
+			detail.GainLoss = total - costBasis
 
-				// Calcular el porcentaje de ganancia/pérdida
-				if detail.CurrentValue > 0 {
-					detail.GainLossPercent = (detail.GainLoss / detail.CurrentValue) * 100
-				}
-			} else {
-				detail.CurrentValue = tx.Amount * tx.PurchasePrice
-				detail.GainLoss = 0
-				detail.GainLossPercent = 0
+			// Calcular el porcentaje de ganancia/pérdida
+			if costBasis > 0 {
+				detail.GainLossPercent = (detail.GainLoss / costBasis) * 100
 			}
+		} else if tx.Type == models.TransactionTypeTransfer {
+			// Las transferencias no tienen ganancia: solo se muestra el valor actual de lo movido
+			detail.CurrentPrice = currentPrice
+			detail.CurrentValue = amount * currentPrice
 		}
+	} else {
+		// Si hay un error, usar el precio de compra como respaldo
+		detail.CurrentPrice = purchasePrice
+
+		// Para ventas, aún podemos calcular la ganancia/pérdida
+		if tx.Type == models.TransactionTypeSell {
+			// Asegurarse de que el total sea correcto (lo que se recibió por la venta)
+			if usdtReceived > 0 {
+				total = usdtReceived
+			} else if total <= 0 {
+				total = amount * purchasePrice
+			}
+
+			// El valor actual es lo que valdría si aún tuviéramos la criptomoneda
+			detail.CurrentValue = amount * purchasePrice
+
+			// La ganancia/pérdida es lo que se recibió menos lo que valdría ahora
+			detail.GainLoss = total - detail.CurrentValue
 
-		details = append(details, detail)
+			// Calcular el porcentaje de ganancia/pérdida
+			if detail.CurrentValue > 0 {
+				detail.GainLossPercent = (detail.GainLoss / detail.CurrentValue) * 100
+			}
+		} else {
+			detail.CurrentValue = amount * purchasePrice
+			detail.GainLoss = 0
+			detail.GainLossPercent = 0
+		}
 	}
 
-	return details, nil
+	return detail
+}
+
+// saleAverageCost devuelve el costo promedio por unidad de lo vendido en una venta. Si no se
+// conoce o es 0 se usa el precio de la transacción.
+func (r *CryptoRepository) saleAverageCost(tx models.CryptoTransaction, saleCosts map[string]float64) float64 {
+	avgPrice := saleCosts[tx.ID]
+	if saleCosts == nil {
+		var err error
+		if avgPrice, err = r.getAveragePurchasePrice(tx.UserID, tx.Ticker, tx.Date); err != nil {
+			avgPrice = 0
+		}
+	}
+	if avgPrice <= 0 {
+		return tx.PurchasePrice.InexactFloat64()
+	}
+	return avgPrice
 }
 
 func (r *CryptoRepository) GetCryptoDashboard(userID string) ([]models.CryptoDashboard, error) {
+	dashboard, _, err := r.getDashboardWithLedger(userID)
+	return dashboard, err
+}
+
+// getDashboardWithLedger calcula el dashboard con el método de costo base del usuario
+// y devuelve también el emparejamiento de lotes con las ganancias realizadas
+func (r *CryptoRepository) getDashboardWithLedger(userID string) ([]models.CryptoDashboard, *services.LotLedger, error) {
 	// Obtener todas las transacciones del usuario ordenadas por fecha
 	query := `
-		SELECT id, ticker, crypto_name, amount, purchase_price, total, type, image_url, date, usdt_received
+		SELECT id, ticker, crypto_name, amount, purchase_price, total, type, image_url, date, usdt_received,
+			fee, fee_currency, fee_usd, asset_class
 		FROM crypto_transactions
 		WHERE user_id = $1
 		ORDER BY date ASC` // Ordenamos por fecha ascendente para procesar cronológicamente
 
 	rows, err := r.db.Query(query, userID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	// Mapa para acumular datos por criptomoneda
-	cryptoMap := make(map[string]*models.CryptoDashboard)
-
-	// Procesar cada transacción cronológicamente
+	// Leer todas las transacciones y recopilar los activos para pedir los precios en un solo lote
+	var txRows []dashboardRow
+	assets := make(map[string]string)
 	for rows.Next() {
-		var id, ticker, cryptoName, txType string
-		var amount, purchasePrice, total float64
-		var imageURL sql.NullString
-		var date time.Time
-		var usdtReceived float64
+		var row dashboardRow
 
-		err := rows.Scan(&id, &ticker, &cryptoName, &amount, &purchasePrice, &total, &txType, &imageURL, &date, &usdtReceived)
+		err := rows.Scan(&row.id, &row.ticker, &row.cryptoName, &row.amount, &row.purchasePrice, &row.total, &row.txType, &row.imageURL, &row.date, &row.usdtReceived, &row.fee, &row.feeCurrency, &row.feeUSD, &row.assetClass)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		// Si es USDT, tratarlo de manera especial
-		if ticker == "USDT" {
-			// Inicializar si no existe
-			if _, exists := cryptoMap[ticker]; !exists {
-				cryptoMap[ticker] = &models.CryptoDashboard{
-					Ticker:        ticker,
-					CryptoName:    cryptoName,
-					ImageURL:      imageURL.String,
-					TotalInvested: 0,
-					Holdings:      0,
-					AvgPrice:      1.0,
-					CurrentPrice:  1.0,
-				}
-			}
+		txRows = append(txRows, row)
+		if row.ticker != "USDT" {
+			assets[row.ticker] = row.assetClass
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
 
-			// Actualizar tenencias
-			if txType == models.TransactionTypeBuy {
-				cryptoMap[ticker].Holdings += amount
-				cryptoMap[ticker].TotalInvested += amount
-			} else if txType == models.TransactionTypeSell {
-				cryptoMap[ticker].Holdings -= amount
-			}
+	// Si se pidió otra moneda, expresar el costo base con la cotización de la fecha de cada operación
+	converter := currencyConverter(r.priceProvider())
+	if converter != nil {
+		if err := convertDashboardRows(converter, txRows); err != nil {
+			return nil, nil, err
+		}
+	}
 
-			// Para USDT, siempre mantener TotalInvested = Holdings
-			cryptoMap[ticker].TotalInvested = cryptoMap[ticker].Holdings
-			cryptoMap[ticker].CurrentProfit = 0
-			cryptoMap[ticker].ProfitPercent = 0
-			continue
+	// Obtener los precios actuales de todos los activos en una sola consulta
+	quotes := map[string]services.PriceQuote{}
+	if len(assets) > 0 {
+		quotes, err = services.GetAssetQuotes(r.priceProvider(), assets)
+		if err != nil {
+			// Si no podemos obtener los precios seguimos con los valores de respaldo
+			log.Printf("Error al obtener precios para el dashboard: %v", err)
+			quotes = map[string]services.PriceQuote{}
 		}
+	}
+
+	method := NewSettingsRepository(r.db).GetCostBasisMethod(userID)
+	dashboard, ledger, err := aggregateCostBasis(txRows, quotes, method)
+	if err != nil {
+		return nil, nil, err
+	}
+	if converter != nil {
+		rate, err := converter.Rate()
+		if err != nil {
+			return nil, nil, err
+		}
+		applyUSDTRate(dashboard, rate)
+	}
+	return dashboard, ledger, nil
+}
+
+// dashboardRow es una transacción con los campos necesarios para calcular el costo base
+type dashboardRow struct {
+	id, ticker, cryptoName, txType, feeCurrency string
+	assetClass                                  string
+	amount, purchasePrice, total, usdtReceived  decimal.Decimal
+	fee, feeUSD                                 decimal.Decimal
+	rate                                        decimal.Decimal // Cotización de la moneda el día de la operación; cero en dólares
+	date                                        time.Time
+	imageURL                                    sql.NullString
+}
 
-		// Para otras criptomonedas
+// aggregateCostBasis acumula las transacciones (en orden cronológico) por criptomoneda
+// emparejando ventas y compras con el método de costo base indicado, y valora las
+// tenencias con los precios indicados. Solo se devuelven las criptomonedas con tenencias positivas.
+func aggregateCostBasis(txRows []dashboardRow, quotes map[string]services.PriceQuote, method string) ([]models.CryptoDashboard, *services.LotLedger, error) {
+	// Mapa para acumular datos por criptomoneda
+	cryptoMap := make(map[string]*models.CryptoDashboard)
+	order := make([]string, 0)
+	transactions := make([]models.CryptoTransaction, 0, len(txRows))
+	usdtFees, usdtIncome := decimal.Zero, decimal.Zero
+	// Valor de los USDT comprados y recibidos con la cotización de cada operación, para que
+	// lo invertido en otra moneda no dependa de la cotización actual
+	usdtBought, usdtCost, usdtIncomeValue := decimal.Zero, decimal.Zero, decimal.Zero
+	now := time.Now()
+
+	for _, row := range txRows {
+		ticker := row.ticker
 		if _, exists := cryptoMap[ticker]; !exists {
+			assetClass := services.AssetClassOrDefault(row.assetClass)
 			cryptoMap[ticker] = &models.CryptoDashboard{
-				Ticker:        ticker,
-				CryptoName:    cryptoName,
-				ImageURL:      imageURL.String,
-				TotalInvested: 0,
-				Holdings:      0,
+				Ticker:     ticker,
+				CryptoName: row.cryptoName,
+				ImageURL:   row.imageURL.String,
+				AssetClass: assetClass,
+				MarketOpen: services.MarketOpen(assetClass, now),
 			}
+			order = append(order, ticker)
 		}
 
-		// Actualizar tenencias según el tipo de transacción
-		if txType == models.TransactionTypeBuy {
-			cryptoMap[ticker].Holdings += amount
-			// Si el precio de compra es 0, intentar obtener el precio actual
-			if purchasePrice <= 0 {
-				// Obtener precio actual para calcular el total
-				cryptoData, err := services.GetCryptoPrice(ticker)
-				if err == nil && cryptoData != nil {
-					purchasePrice = cryptoData.Raw[ticker]["USD"].PRICE
-					total = amount * purchasePrice
-				} else {
-					// Si no se puede obtener el precio, usar un valor predeterminado
-					purchasePrice = 1.0
-					total = amount
-				}
+		// Si es USDT, tratarlo de manera especial: siempre vale 1 USD
+		if ticker == "USDT" {
+			if services.IsAcquisitionType(row.txType) {
+				cryptoMap[ticker].Holdings += row.amount.InexactFloat64()
+			} else if row.txType == models.TransactionTypeSell {
+				cryptoMap[ticker].Holdings -= row.amount.InexactFloat64()
 			}
-			cryptoMap[ticker].TotalInvested += total
-		} else if txType == models.TransactionTypeSell {
-			// Calcular el costo promedio por unidad antes de la venta
-			var costPerUnit float64
-			if cryptoMap[ticker].Holdings > 0 {
-				costPerUnit = cryptoMap[ticker].TotalInvested / cryptoMap[ticker].Holdings
+			rate := row.rate
+			if rate.IsZero() {
+				rate = decimal.NewFromInt(1)
+			}
+			// Los intereses en USDT son ganancia, no dinero invertido
+			if services.IsIncomeType(row.txType) {
+				usdtIncome = usdtIncome.Add(row.amount)
+				usdtIncomeValue = usdtIncomeValue.Add(row.amount.Mul(rate))
+			} else if services.IsAcquisitionType(row.txType) {
+				usdtBought = usdtBought.Add(row.amount)
+				usdtCost = usdtCost.Add(row.amount.Mul(rate))
 			}
+			continue
+		}
 
-			// Calcular el costo base de las unidades vendidas
-			costBasisSold := costPerUnit * amount
+		purchasePrice, total := row.purchasePrice, row.total
+		if services.IsAcquisitionType(row.txType) && !purchasePrice.IsPositive() {
+			// Si el precio de compra es 0, usar el precio actual para calcular el total
+			if quote, exists := quotes[ticker]; exists {
+				purchasePrice = decimal.NewFromFloat(quote.Price)
+			} else {
+				// Si no se puede obtener el precio, usar un valor predeterminado
+				purchasePrice = decimal.NewFromInt(1)
+			}
+			total = row.amount.Mul(purchasePrice)
+		}
 
-			// Ajustar el total invertido restando el costo base de las unidades vendidas
-			cryptoMap[ticker].TotalInvested -= costBasisSold
+		transaction := models.CryptoTransaction{
+			ID:            row.id,
+			Ticker:        ticker,
+			CryptoName:    row.cryptoName,
+			Type:          row.txType,
+			Amount:        row.amount,
+			PurchasePrice: purchasePrice,
+			Total:         total,
+			USDTReceived:  row.usdtReceived,
+			Date:          row.date,
+			Fee:           row.fee,
+			FeeCurrency:   row.feeCurrency,
+			FeeUSD:        row.feeUSD,
+		}
+		transactions = append(transactions, transaction)
 
-			// Actualizar las tenencias
-			cryptoMap[ticker].Holdings -= amount
+		// Las comisiones pagadas en USDT reducen el saldo de USDT
+		if transaction.FeeCurrency == "USDT" {
+			usdtFees = usdtFees.Add(transaction.Fee)
 		}
 	}
 
+	ledger, err := services.MatchLots(transactions, method)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Convertir el mapa a un slice
 	dashboard := make([]models.CryptoDashboard, 0, len(cryptoMap))
-	for _, crypto := range cryptoMap {
+	for _, ticker := range order {
+		crypto := cryptoMap[ticker]
+
+		// Costo base de las tenencias, incluidos los ingresos a su valor de mercado
+		var costBasis float64
+		if ticker == "USDT" {
+			// Para USDT, lo invertido es el saldo menos los intereses recibidos, valorado con
+			// la cotización promedio de las compras (1 en dólares)
+			crypto.Holdings -= usdtFees.InexactFloat64()
+			crypto.Income = usdtIncomeValue.InexactFloat64()
+			crypto.AvgPrice = 1.0
+			if usdtBought.IsPositive() {
+				crypto.AvgPrice = usdtCost.Div(usdtBought).InexactFloat64()
+			}
+			crypto.TotalInvested = math.Max(crypto.Holdings-usdtIncome.InexactFloat64(), 0) * crypto.AvgPrice
+			crypto.CurrentPrice = 1.0
+			applyCurrentPrice(crypto)
+		} else {
+			holdings, position := ledger.Position(ticker)
+			crypto.Holdings, costBasis = holdings.InexactFloat64(), position.InexactFloat64()
+			// Los ingresos no cuentan como invertido para no distorsionar el rendimiento
+			crypto.TotalInvested = ledger.Invested(ticker).InexactFloat64()
+			crypto.Income = ledger.IncomeValue(ticker).InexactFloat64()
+			crypto.RealizedProfit = ledger.RealizedGain(ticker).InexactFloat64()
+			crypto.FeesPaid = ledger.FeesPaid(ticker).InexactFloat64()
+		}
+
 		// Solo incluir criptomonedas con tenencias positivas
-		if crypto.Holdings > 0 {
+		if crypto.Holdings <= 0 {
+			continue
+		}
+
+		if ticker != "USDT" {
 			// Calcular precio promedio
-			if crypto.Holdings > 0 && crypto.TotalInvested > 0 {
-				crypto.AvgPrice = crypto.TotalInvested / crypto.Holdings
+			if costBasis > 0 {
+				crypto.AvgPrice = costBasis / crypto.Holdings
 			}
 
 			// Obtener precio actual
-			if crypto.Ticker != "USDT" {
-				cryptoData, err := services.GetCryptoPrice(crypto.Ticker)
-				if err == nil && cryptoData != nil {
-					crypto.CurrentPrice = cryptoData.Raw[crypto.Ticker]["USD"].PRICE
-
-					// Calcular el valor actual de las tenencias
-					currentValue := crypto.CurrentPrice * crypto.Holdings
-
-					// Calcular el profit basado en el valor actual vs total invertido
-					crypto.CurrentProfit = currentValue - crypto.TotalInvested
-
-					// Calcular el porcentaje de ganancia/pérdida
-					if crypto.TotalInvested > 0 {
-						crypto.ProfitPercent = (crypto.CurrentProfit / crypto.TotalInvested) * 100
-					}
-				} else {
-					// Si no podemos obtener el precio actual, usamos el promedio como respaldo
-					crypto.CurrentPrice = crypto.AvgPrice
-					crypto.CurrentProfit = 0
-					crypto.ProfitPercent = 0
-				}
+			if quote, exists := quotes[ticker]; exists {
+				crypto.CurrentPrice = quote.Price
+				applyCurrentPrice(crypto)
+			} else {
+				// Si no podemos obtener el precio actual, usamos el promedio como respaldo
+				crypto.CurrentPrice = crypto.AvgPrice
+				crypto.CurrentProfit = 0
+				crypto.ProfitPercent = 0
 			}
-
-			dashboard = append(dashboard, *crypto)
 		}
+
+		dashboard = append(dashboard, *crypto)
 	}
 
-	return dashboard, nil
+	return dashboard, ledger, nil
+}
+
+// applyCurrentPrice recalcula la ganancia/pérdida de una criptomoneda a partir de su precio actual
+func applyCurrentPrice(crypto *models.CryptoDashboard) {
+	// Calcular el valor actual de las tenencias
+	currentValue := crypto.CurrentPrice * crypto.Holdings
+
+	// Calcular el profit basado en el valor actual vs total invertido
+	crypto.CurrentProfit = currentValue - crypto.TotalInvested
+
+	// Calcular el porcentaje de ganancia/pérdida
+	if crypto.TotalInvested > 0 {
+		crypto.ProfitPercent = (crypto.CurrentProfit / crypto.TotalInvested) * 100
+	}
 }
 
 // GetUserDashboard obtiene el dashboard de criptomonedas para un usuario específico
 // Esta función es un wrapper para GetCryptoDashboard que permite su uso desde los handlers
-func GetUserDashboard(db *sql.DB, userID string) ([]models.CryptoDashboard, error) {
+func GetUserDashboard(db *sql.DB, prices services.PriceProvider, userID string) ([]models.CryptoDashboard, error) {
 	// Crear una instancia del repositorio de criptomonedas
-	repo := NewCryptoRepository(db)
+	repo := NewCryptoRepository(db).WithPrices(prices)
 
 	// Llamar a la función GetCryptoDashboard para obtener el dashboard
 	dashboard, err := repo.GetCryptoDashboard(userID)
@@ -602,23 +972,23 @@ func GetUserDashboard(db *sql.DB, userID string) ([]models.CryptoDashboard, erro
 		return nil, fmt.Errorf("error al obtener el dashboard: %v", err)
 	}
 
-	// Asegurarse de que los precios actuales sean diferentes de los precios de compra
-	// según lo mencionado en la memoria sobre el problema identificado en el dashboard
+	// Asegurarse de que los precios actuales sean diferentes de los precios de compra:
+	// si el precio actual es igual al promedio es porque no se pudo obtener, reintentar en un solo lote
+	retryAssets := make(map[string]string)
 	for i := range dashboard {
-		// Si el precio actual es igual al precio de compra, intentar obtener el precio actual de nuevo
 		if dashboard[i].CurrentPrice == dashboard[i].AvgPrice && dashboard[i].Ticker != "USDT" {
-			cryptoData, err := services.GetCryptoPrice(dashboard[i].Ticker)
-			if err == nil && cryptoData != nil {
-				// Actualizar el precio actual con el precio obtenido de la API
-				dashboard[i].CurrentPrice = cryptoData.Raw[dashboard[i].Ticker]["USD"].PRICE
-
-				// Recalcular la ganancia/pérdida
-				currentValue := dashboard[i].CurrentPrice * dashboard[i].Holdings
-				dashboard[i].CurrentProfit = currentValue - dashboard[i].TotalInvested
-
-				// Recalcular el porcentaje de ganancia/pérdida
-				if dashboard[i].TotalInvested > 0 {
-					dashboard[i].ProfitPercent = (dashboard[i].CurrentProfit / dashboard[i].TotalInvested) * 100
+			retryAssets[dashboard[i].Ticker] = dashboard[i].AssetClass
+		}
+	}
+
+	if len(retryAssets) > 0 {
+		quotes, err := services.GetAssetQuotes(repo.priceProvider(), retryAssets)
+		if err == nil {
+			for i := range dashboard {
+				if quote, exists := quotes[dashboard[i].Ticker]; exists && dashboard[i].CurrentPrice == dashboard[i].AvgPrice {
+					// Actualizar el precio actual y recalcular la ganancia/pérdida
+					dashboard[i].CurrentPrice = quote.Price
+					applyCurrentPrice(&dashboard[i])
 				}
 			}
 		}
@@ -630,7 +1000,9 @@ func GetUserDashboard(db *sql.DB, userID string) ([]models.CryptoDashboard, erro
 func (r *CryptoRepository) GetTransactionDetails(userID string, transactionID string) (*models.TransactionDetails, error) {
 	query := `
 		SELECT id, user_id, crypto_name, ticker, amount, purchase_price, 
-			   total, date, note, created_at, type, usdt_received, image_url
+			   total, date, note, created_at, type, usdt_received, image_url,
+			   fee, fee_currency, fee_usd, COALESCE(swap_id, ''), asset_class,
+			   COALESCE(account_id, ''), COALESCE(to_account_id, '')
 		FROM crypto_transactions 
 		WHERE id = $1 AND user_id = $2
 	`
@@ -650,6 +1022,13 @@ func (r *CryptoRepository) GetTransactionDetails(userID string, transactionID st
 		&tx.Type,
 		&tx.USDTReceived,
 		&tx.ImageURL,
+		&tx.Fee,
+		&tx.FeeCurrency,
+		&tx.FeeUSD,
+		&tx.SwapID,
+		&tx.AssetClass,
+		&tx.AccountID,
+		&tx.ToAccountID,
 	)
 
 	if err != nil {
@@ -663,98 +1042,95 @@ func (r *CryptoRepository) GetTransactionDetails(userID string, transactionID st
 		Transaction: tx,
 	}
 
+	// La valoración a precios de mercado es aproximada: se calcula con float64
+	amount := tx.Amount.InexactFloat64()
+	purchasePrice := tx.PurchasePrice.InexactFloat64()
+	total := tx.Total.InexactFloat64()
+	usdtReceived := tx.USDTReceived.InexactFloat64()
+
 	// Obtener el precio actual de la criptomoneda
-	cryptoData, err := services.GetCryptoPrice(tx.Ticker)
-	if err == nil && cryptoData.Raw[tx.Ticker]["USD"].PRICE > 0 {
+	currentPrice, err := services.GetAssetPrice(tx.AssetClass, tx.Ticker)
+	if err == nil && currentPrice > 0 {
 		// Si se obtiene el precio actual correctamente
-		currentPrice := cryptoData.Raw[tx.Ticker]["USD"].PRICE
 
 		// Calcular ganancia/pérdida según el tipo de transacción
-		if tx.Type == models.TransactionTypeBuy {
-			// Para compras:
+		if services.IsAcquisitionType(tx.Type) {
+			// Para compras e ingresos (valorados al precio de mercado al recibirlos):
 			// Precio: precio de compra
 			// Precio actual: obtenido de la API
 			details.CurrentPrice = currentPrice
 
-			// Asegurarse de que tx.Total tenga el valor correcto (precio * cantidad)
-			if tx.Total <= 0 {
-				tx.Total = tx.Amount * tx.PurchasePrice
+			// Asegurarse de que total tenga el valor correcto (precio * cantidad)
+			if total <= 0 {
+				total = amount * purchasePrice
 			}
 
 			// Valor actual: precio actual * cantidad
-			details.CurrentValue = tx.Amount * currentPrice
+			details.CurrentValue = amount * currentPrice
 
 			// Ganancia/pérdida: valor actual - total
-			details.GainLoss = details.CurrentValue - tx.Total
+			details.GainLoss = details.CurrentValue - total
 
 			// Porcentaje de ganancia/pérdida
-			if tx.Total > 0 {
-				details.GainLossPercent = (details.GainLoss / tx.Total) * 100
+			if total > 0 {
+				details.GainLossPercent = (details.GainLoss / total) * 100
 			}
 		} else if tx.Type == models.TransactionTypeSell {
 			// Para ventas: necesitamos obtener el precio promedio de compra para calcular la ganancia/pérdida
 			// Obtener el precio promedio de compra de la criptomoneda
-			avgPrice, err := r.getAveragePurchasePrice(tx.UserID, tx.Ticker, tx.Date)
-			if err != nil || avgPrice <= 0 {
-				// Si hay un error o el precio promedio es 0, usar el precio de compra de la transacción
-				avgPrice = tx.PurchasePrice
-			}
+			avgPrice := r.saleAverageCost(tx, nil)
 			// Calcular el costo base usando el precio promedio
-			costBasis := avgPrice * tx.Amount
+			costBasis := avgPrice * amount
 
 			// Asegurarse de que el total sea correcto (lo que se recibió por la venta)
-			if tx.USDTReceived > 0 {
-				tx.Total = tx.USDTReceived
-			} else if tx.Total <= 0 {
-				tx.Total = tx.Amount * tx.PurchasePrice
+			if usdtReceived > 0 {
+				total = usdtReceived
+			} else if total <= 0 {
+				total = amount * purchasePrice
 			}
 
 			// El precio actual para mostrar
 			details.CurrentPrice = currentPrice
 			// El valor actual es lo que valdría si aún tuviéramos la criptomoneda
-			details.CurrentValue = tx.Amount * currentPrice
+			details.CurrentValue = amount * currentPrice
 
 			// La ganancia/pérdida es lo que se recibió menos lo que costó
-			details.GainLoss = tx.Total - costBasis
+			details.GainLoss = total - costBasis
 
 			// Calcular el porcentaje de ganancia/pérdida
 			if costBasis > 0 {
 				details.GainLossPercent = (details.GainLoss / costBasis) * 100
 			}
+		} else if tx.Type == models.TransactionTypeTransfer {
+			// Las transferencias no tienen ganancia: solo se muestra el valor actual de lo movido
+			details.CurrentPrice = currentPrice
+			details.CurrentValue = amount * currentPrice
 		}
 	} else {
 		// Si hay un error, usar el precio de compra como respaldo
-		details.CurrentPrice = tx.PurchasePrice
+		details.CurrentPrice = purchasePrice
 
 		// Para ventas, aún podemos calcular la ganancia/pérdida
 		if tx.Type == models.TransactionTypeSell {
-			// Intentar obtener el precio promedio de compra
-			avgPrice, err := r.getAveragePurchasePrice(tx.UserID, tx.Ticker, tx.Date)
-			if err != nil || avgPrice <= 0 {
-				// Si hay un error o el precio promedio es 0, usar el precio de compra de la transacción
-				avgPrice = tx.PurchasePrice
-			}
-			// costBasis := avgPrice * tx.Amount
-
 			// Asegurarse de que el total sea correcto (lo que se recibió por la venta)
-			if tx.USDTReceived > 0 {
-				tx.Total = tx.USDTReceived
-			} else if tx.Total <= 0 {
-				tx.Total = tx.Amount * tx.PurchasePrice
+			if usdtReceived > 0 {
+				total = usdtReceived
+			} else if total <= 0 {
+				total = amount * purchasePrice
 			}
 
 			// El valor actual es lo que valdría si aún tuviéramos la criptomoneda
-			details.CurrentValue = tx.Amount * tx.PurchasePrice
+			details.CurrentValue = amount * purchasePrice
 
 			// La ganancia/pérdida es lo que se recibió menos lo que valdría ahora
-			details.GainLoss = tx.Total - details.CurrentValue
+			details.GainLoss = total - details.CurrentValue
 
 			// Calcular el porcentaje de ganancia/pérdida
 			if details.CurrentValue > 0 {
 				details.GainLossPercent = (details.GainLoss / details.CurrentValue) * 100
 			}
 		} else {
-			details.CurrentValue = tx.Amount * tx.PurchasePrice
+			details.CurrentValue = amount * purchasePrice
 			details.GainLoss = 0
 			details.GainLossPercent = 0
 		}
@@ -770,11 +1146,13 @@ func (r *CryptoRepository) GetRecentTransactions(userID string, limit int) ([]mo
 
 	query := `
 		SELECT id, user_id, crypto_name, ticker, amount, purchase_price, 
-			   total, date, note, created_at, type, usdt_received, image_url
+			   total, date, note, created_at, type, usdt_received, image_url,
+			   fee, fee_currency, fee_usd, COALESCE(swap_id, ''), asset_class,
+			   COALESCE(account_id, ''), COALESCE(to_account_id, '')
 		FROM crypto_transactions 
 		WHERE user_id = $1 
 		ORDER BY date DESC
-		LIMIT ?
+		LIMIT $2
 	`
 
 	rows, err := r.db.Query(query, userID, limit)
@@ -800,6 +1178,13 @@ func (r *CryptoRepository) GetRecentTransactions(userID string, limit int) ([]mo
 			&tx.Type,
 			&tx.USDTReceived,
 			&tx.ImageURL,
+			&tx.Fee,
+			&tx.FeeCurrency,
+			&tx.FeeUSD,
+			&tx.SwapID,
+			&tx.AssetClass,
+			&tx.AccountID,
+			&tx.ToAccountID,
 		)
 		if err != nil {
 			return nil, err
@@ -809,32 +1194,37 @@ func (r *CryptoRepository) GetRecentTransactions(userID string, limit int) ([]mo
 			Transaction: tx,
 		}
 
+		// La valoración a precios de mercado es aproximada: se calcula con float64
+		amount := tx.Amount.InexactFloat64()
+		purchasePrice := tx.PurchasePrice.InexactFloat64()
+		total := tx.Total.InexactFloat64()
+		usdtReceived := tx.USDTReceived.InexactFloat64()
+
 		// Obtener el precio actual
-		cryptoData, err := services.GetCryptoPrice(tx.Ticker)
-		if err == nil && cryptoData.Raw[tx.Ticker]["USD"].PRICE > 0 {
-			currentPrice := cryptoData.Raw[tx.Ticker]["USD"].PRICE
+		currentPrice, err := services.GetAssetPrice(tx.AssetClass, tx.Ticker)
+		if err == nil && currentPrice > 0 {
 
 			// Calcular ganancia/pérdida según el tipo de transacción
-			if tx.Type == models.TransactionTypeBuy {
-				// Para compras:
+			if services.IsAcquisitionType(tx.Type) {
+				// Para compras e ingresos (valorados al precio de mercado al recibirlos):
 				// Precio: precio de compra
 				// Precio actual: obtenido de la API
 				details.CurrentPrice = currentPrice
 
-				// Asegurarse de que tx.Total tenga el valor correcto (precio * cantidad)
-				if tx.Total <= 0 {
-					tx.Total = tx.Amount * tx.PurchasePrice
+				// Asegurarse de que total tenga el valor correcto (precio * cantidad)
+				if total <= 0 {
+					total = amount * purchasePrice
 				}
 
 				// Valor actual: precio actual * cantidad
-				details.CurrentValue = tx.Amount * currentPrice
+				details.CurrentValue = amount * currentPrice
 
 				// Ganancia/pérdida: valor actual - total
-				details.GainLoss = details.CurrentValue - tx.Total
+				details.GainLoss = details.CurrentValue - total
 
 				// Porcentaje de ganancia/pérdida
-				if tx.Total > 0 {
-					details.GainLossPercent = (details.GainLoss / tx.Total) * 100
+				if total > 0 {
+					details.GainLossPercent = (details.GainLoss / total) * 100
 				}
 			} else if tx.Type == models.TransactionTypeSell {
 				// Para ventas:
@@ -846,32 +1236,36 @@ func (r *CryptoRepository) GetRecentTransactions(userID string, limit int) ([]mo
 				avgPrice, err := r.getAveragePurchasePrice(tx.UserID, tx.Ticker, tx.Date)
 				if err != nil || avgPrice <= 0 {
 					// Si hay un error o el precio promedio es 0, usar el precio de compra de la transacción
-					avgPrice = tx.PurchasePrice
+					avgPrice = purchasePrice
 				}
 
-				// costBasis := avgPrice * tx.Amount
+				// costBasis := avgPrice * amount
 
 				// Asegurarse de que el total sea correcto (lo que se recibió por la venta)
-				if tx.USDTReceived > 0 {
-					tx.Total = tx.USDTReceived
-				} else if tx.Total <= 0 {
-					tx.Total = tx.Amount * tx.PurchasePrice
+				if usdtReceived > 0 {
+					total = usdtReceived
+				} else if total <= 0 {
+					total = amount * purchasePrice
 				}
 
 				// El valor actual es lo que valdría si aún tuviéramos la criptomoneda
-				details.CurrentValue = tx.Amount * currentPrice
+				details.CurrentValue = amount * currentPrice
 
-				// Para ventas, la ganancia/pérdida debe ser lo que se recibió por la venta (tx.Total) menos lo que valdría ahora (details.CurrentValue)
-				details.GainLoss = tx.Total - details.CurrentValue
+				// Para ventas, la ganancia/pérdida debe ser lo que se recibió por la venta (total) menos lo que valdría ahora (details.CurrentValue)
+				details.GainLoss = total - details.CurrentValue
 
 				// Calcular el porcentaje de ganancia/pérdida
 				if details.CurrentValue > 0 {
 					details.GainLossPercent = (details.GainLoss / details.CurrentValue) * 100
 				}
+			} else if tx.Type == models.TransactionTypeTransfer {
+				// Las transferencias no tienen ganancia: solo se muestra el valor actual de lo movido
+				details.CurrentPrice = currentPrice
+				details.CurrentValue = amount * currentPrice
 			}
 		} else {
 			// Si hay un error, usar el precio de compra
-			details.CurrentPrice = tx.PurchasePrice
+			details.CurrentPrice = purchasePrice
 
 			// Para ventas, aún podemos calcular la ganancia/pérdida
 			if tx.Type == models.TransactionTypeSell {
@@ -879,23 +1273,23 @@ func (r *CryptoRepository) GetRecentTransactions(userID string, limit int) ([]mo
 				avgPrice, err := r.getAveragePurchasePrice(tx.UserID, tx.Ticker, tx.Date)
 				if err != nil || avgPrice <= 0 {
 					// Si hay un error o el precio promedio es 0, usar el precio de compra de la transacción
-					avgPrice = tx.PurchasePrice
+					avgPrice = purchasePrice
 				}
 
-				// costBasis := avgPrice * tx.Amount
+				// costBasis := avgPrice * amount
 
 				// Asegurarse de que el total sea correcto (lo que se recibió por la venta)
-				if tx.USDTReceived > 0 {
-					tx.Total = tx.USDTReceived
-				} else if tx.Total <= 0 {
-					tx.Total = tx.Amount * tx.PurchasePrice
+				if usdtReceived > 0 {
+					total = usdtReceived
+				} else if total <= 0 {
+					total = amount * purchasePrice
 				}
 
 				// El valor actual es lo que valdría si aún tuviéramos la criptomoneda
-				details.CurrentValue = tx.Amount * tx.PurchasePrice
+				details.CurrentValue = amount * purchasePrice
 
 				// La ganancia/pérdida es lo que se recibió menos lo que valdría ahora
-				details.GainLoss = tx.Total - details.CurrentValue
+				details.GainLoss = total - details.CurrentValue
 
 				// Calcular el porcentaje de ganancia/pérdida
 				if details.CurrentValue > 0 {
@@ -903,7 +1297,7 @@ func (r *CryptoRepository) GetRecentTransactions(userID string, limit int) ([]mo
 				}
 			} else {
 				// Para compras, el valor actual es la cantidad multiplicada por el precio actual (que en este caso es el precio de compra)
-				details.CurrentValue = tx.Amount * tx.PurchasePrice
+				details.CurrentValue = amount * purchasePrice
 				details.GainLoss = 0
 				details.GainLossPercent = 0
 			}
@@ -916,7 +1310,7 @@ func (r *CryptoRepository) GetRecentTransactions(userID string, limit int) ([]mo
 		return nil, err
 	}
 
-	return transactions, nil
+	return collapseSwapLegs(transactions), nil
 }
 
 func (r *CryptoRepository) getAveragePurchasePrice(userID string, ticker string, date time.Time) (float64, error) {
@@ -1074,7 +1468,7 @@ func (r *CryptoRepository) SaveInvestmentSnapshot(userID string, totalValue, tot
 	}
 
 	// Generar un ID único para el snapshot
-	snapshotID := fmt.Sprintf("snapshot_%d", time.Now().UnixNano())
+	snapshotID := models.GenerateUUID()
 
 	// Obtener la fecha actual y truncarla al intervalo de 5 minutos
 	currentTime := time.Now()
@@ -1425,7 +1819,7 @@ func (r *CryptoRepository) GetInvestmentHistoryFromSnapshots(userID string) (mod
 		// Crear el valor diario
 		dailyValue := models.DailyValue{
 			Date:       dateStr,
-			TotalValue: snapshot.TotalValue,
+			TotalValue: snapshot.TotalValue.InexactFloat64(),
 		}
 
 		// Calcular el porcentaje de cambio
@@ -7,7 +7,8 @@ import (
 // GetTransaction obtiene una transacciu00f3n por su ID
 func (r *CryptoRepository) GetTransaction(transactionID string) (*models.CryptoTransaction, error) {
 	query := `
-		SELECT id, user_id, crypto_name, ticker, amount, purchase_price, total, date, note
+		SELECT id, user_id, crypto_name, ticker, amount, purchase_price, total, date, note,
+			fee, fee_currency, fee_usd
 		FROM crypto_transactions
 		WHERE id = $1
 	`
@@ -23,6 +24,9 @@ func (r *CryptoRepository) GetTransaction(transactionID string) (*models.CryptoT
 		&transaction.Total,
 		&transaction.Date,
 		&transaction.Note,
+		&transaction.Fee,
+		&transaction.FeeCurrency,
+		&transaction.FeeUSD,
 	)
 
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/shopspring/decimal"
 )
 
 // Variables globales para mantener instancias de los repositorios
@@ -21,17 +22,11 @@ func InitRepositories(db *sql.DB) {
 
 // CryptoExists verifica si una criptomoneda existe consultando su precio actual
 func CryptoExists(ticker string) bool {
-	// Utilizar el servicio GetCryptoPrice para verificar si la criptomoneda existe
-	cryptoData, err := services.GetCryptoPrice(ticker)
-	
+	// Utilizar el proveedor de precios para verificar si la criptomoneda existe
+	_, err := services.GetPriceProvider().GetQuote(ticker)
+
 	// Si hay un error o no se obtienen datos, la criptomoneda no existe
-	if err != nil || cryptoData == nil {
-		return false
-	}
-	
-	// Verificar si el ticker existe en los datos obtenidos
-	_, exists := cryptoData.Raw[ticker]
-	return exists
+	return err == nil
 }
 
 // CreateTransaction crea una nueva transacción de criptomoneda
@@ -43,7 +38,7 @@ func CreateTransaction(transaction *models.CryptoTransaction) error {
 }
 
 // UpdateUserBalance actualiza el balance del usuario
-func UpdateUserBalance(userID string, amount float64) error {
+func UpdateUserBalance(userID string, amount decimal.Decimal) error {
 	// Esta función debería implementarse según la lógica de tu aplicación
 	// Por ahora, simplemente registramos la operación y no hacemos nada
 	return nil
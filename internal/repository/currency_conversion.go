@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/shopspring/decimal"
+)
+
+// currencyConverter devuelve el conversor de moneda del proveedor, o nil si los valores se expresan en dólares
+func currencyConverter(prices services.PriceProvider) *services.CurrencyPriceProvider {
+	converter, ok := prices.(*services.CurrencyPriceProvider)
+	if !ok || converter.Currency() == services.CurrencyUSD {
+		return nil
+	}
+	return converter
+}
+
+// convertDashboardRows expresa los precios, totales y comisiones de las transacciones (ordenadas
+// por fecha) en la moneda del conversor, con la cotización del día de cada operación.
+// Así el costo base refleja lo que costó cada compra en esa moneda y no lo que costaría hoy.
+func convertDashboardRows(converter *services.CurrencyPriceProvider, txRows []dashboardRow) error {
+	if len(txRows) == 0 {
+		return nil
+	}
+	if err := converter.ConvertHistory(txRows[0].date); err != nil {
+		return err
+	}
+
+	for i := range txRows {
+		rate, err := converter.RateOn(txRows[i].date)
+		if err != nil {
+			return err
+		}
+		factor := decimal.NewFromFloat(rate)
+		row := &txRows[i]
+		row.rate = factor
+		row.purchasePrice = row.purchasePrice.Mul(factor)
+		row.total = row.total.Mul(factor)
+		row.usdtReceived = row.usdtReceived.Mul(factor)
+		row.feeUSD = row.feeUSD.Mul(factor)
+	}
+	return nil
+}
+
+// applyUSDTRate valora los USDT del dashboard con la cotización actual de la moneda, ya que en
+// dólares siempre valen 1. Lo invertido ya está convertido con la cotización de cada compra.
+func applyUSDTRate(dashboard []models.CryptoDashboard, rate float64) {
+	for i := range dashboard {
+		if dashboard[i].Ticker != "USDT" {
+			continue
+		}
+		dashboard[i].CurrentPrice = rate
+		applyCurrentPrice(&dashboard[i])
+	}
+}
+
+// ConvertSnapshots expresa los snapshots guardados en dólares en la moneda del proveedor,
+// con la cotización del día de cada snapshot. El porcentaje de ganancia no cambia.
+func ConvertSnapshots(prices services.PriceProvider, snapshots []models.InvestmentSnapshot) error {
+	converter := currencyConverter(prices)
+	if converter == nil || len(snapshots) == 0 {
+		return nil
+	}
+	if err := converter.ConvertHistory(snapshots[0].Date); err != nil {
+		return err
+	}
+
+	for i := range snapshots {
+		rate, err := converter.RateOn(snapshots[i].Date)
+		if err != nil {
+			return err
+		}
+		factor := decimal.NewFromFloat(rate)
+		snapshots[i].TotalValue = snapshots[i].TotalValue.Mul(factor)
+		snapshots[i].TotalInvested = snapshots[i].TotalInvested.Mul(factor)
+		snapshots[i].Profit = snapshots[i].Profit.Mul(factor)
+		snapshots[i].MaxValue = snapshots[i].MaxValue.Mul(factor)
+		snapshots[i].MinValue = snapshots[i].MinValue.Mul(factor)
+	}
+	return nil
+}
+
+// ConvertBolsa expresa los precios de compra, totales y el objetivo de una bolsa en la moneda
+// del proveedor: las compras con la cotización del día en que se añadió cada activo y el
+// objetivo con la cotización actual. Debe llamarse antes de valorar los activos.
+func ConvertBolsa(prices services.PriceProvider, bolsa *models.Bolsa) error {
+	converter := currencyConverter(prices)
+	if converter == nil || bolsa == nil {
+		return nil
+	}
+
+	rate, err := converter.Rate()
+	if err != nil {
+		return err
+	}
+	bolsa.Goal = bolsa.Goal.Mul(decimal.NewFromFloat(rate))
+
+	if len(bolsa.Assets) == 0 {
+		return nil
+	}
+	earliest := bolsa.Assets[0].CreatedAt
+	for _, asset := range bolsa.Assets {
+		if asset.CreatedAt.Before(earliest) {
+			earliest = asset.CreatedAt
+		}
+	}
+	if err := converter.ConvertHistory(earliest); err != nil {
+		return err
+	}
+
+	for i := range bolsa.Assets {
+		rate, err := converter.RateOn(bolsa.Assets[i].CreatedAt)
+		if err != nil {
+			return err
+		}
+		factor := decimal.NewFromFloat(rate)
+		bolsa.Assets[i].PurchasePrice = bolsa.Assets[i].PurchasePrice.Mul(factor)
+		bolsa.Assets[i].Total = bolsa.Assets[i].Total.Mul(factor)
+	}
+	return nil
+}
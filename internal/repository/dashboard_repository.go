@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/shopspring/decimal"
 )
 
 // GetUserInvestmentHistory obtiene el historial de inversiones del usuario desde una fecha específica
@@ -44,7 +46,7 @@ func GetUserInvestmentHistory(db *sql.DB, userID string, startDate time.Time) (m
 	for i, snapshot := range snapshots {
 		history.History[i] = models.DailyValue{
 			Date:             snapshot.Date.Format("2006-01-02"),
-			TotalValue:       snapshot.TotalValue,
+			TotalValue:       snapshot.TotalValue.InexactFloat64(),
 			ChangePercentage: snapshot.ProfitPercentage,
 		}
 	}
@@ -54,8 +56,8 @@ func GetUserInvestmentHistory(db *sql.DB, userID string, startDate time.Time) (m
 		firstValue := snapshots[0].TotalValue
 		lastValue := snapshots[len(snapshots)-1].TotalValue
 		
-		if firstValue > 0 {
-			history.TrendPercentage = ((lastValue - firstValue) / firstValue) * 100
+		if firstValue.IsPositive() {
+			history.TrendPercentage = lastValue.Sub(firstValue).Div(firstValue).Mul(decimal.NewFromInt(100)).InexactFloat64()
 		}
 	}
 	
@@ -64,9 +66,9 @@ func GetUserInvestmentHistory(db *sql.DB, userID string, startDate time.Time) (m
 
 // GetUserLiveBalance obtiene el balance en tiempo real del usuario
 // Esta función calcula el balance actual utilizando el dashboard
-func GetUserLiveBalance(db *sql.DB, userID string) (*models.Balance, error) {
+func GetUserLiveBalance(db *sql.DB, prices services.PriceProvider, userID string) (*models.Balance, error) {
 	// Crear una instancia del repositorio de criptomonedas
-	repo := NewCryptoRepository(db)
+	repo := NewCryptoRepository(db).WithPrices(prices)
 	
 	// Obtener el dashboard que contiene las tenencias actualizadas
 	dashboard, err := repo.GetCryptoDashboard(userID)
@@ -98,6 +100,7 @@ func GetUserLiveBalance(db *sql.DB, userID string) (*models.Balance, error) {
 		TotalInvested:    totalInvested,
 		TotalProfit:      totalProfit,
 		ProfitPercentage: profitPercentage,
+		Currency:         services.CurrencyOf(prices),
 		LastUpdated:      time.Now(),
 	}
 	
@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// backtestMaxPurchases limita el tamaño de una simulación
+	backtestMaxPurchases = 10000
+	// backtestLookback permite usar el último cierre conocido si el rango empieza en un día sin precio
+	backtestLookback = historicalCloseMaxAgeDays * 24 * time.Hour
+)
+
+// HistoricalPriceSource es cualquier origen de precios de cierre diarios
+type HistoricalPriceSource interface {
+	GetHistoricalPrices(ticker string, from, to time.Time) ([]models.HistoricalPrice, error)
+}
+
+// priceSeries son los cierres diarios de un ticker ordenados por fecha
+type priceSeries []models.HistoricalPrice
+
+// asOf devuelve el último cierre conocido en la fecha indicada o antes, con la misma
+// antigüedad máxima que GetCloseOnOrBefore
+func (s priceSeries) asOf(date time.Time) (float64, bool) {
+	idx := sort.Search(len(s), func(i int) bool { return s[i].Date.After(date) })
+	if idx == 0 || s[idx-1].Date.Before(date.AddDate(0, 0, -historicalCloseMaxAgeDays)) {
+		return 0, false
+	}
+	return s[idx-1].Close, true
+}
+
+// firstFrom devuelve el primer cierre en la fecha indicada o después
+func (s priceSeries) firstFrom(date time.Time) (float64, bool) {
+	idx := sort.Search(len(s), func(i int) bool { return !s[i].Date.Before(date) })
+	if idx == len(s) {
+		return 0, false
+	}
+	return s[idx].Close, true
+}
+
+// RunDCABacktest simula un plan DCA sobre precios históricos y lo compara con
+// invertir el mismo monto total en una única compra el primer día
+func RunDCABacktest(source HistoricalPriceSource, request models.DCABacktestRequest) (*models.DCABacktestResult, error) {
+	allocations, err := normalizeAllocations(request)
+	if err != nil {
+		return nil, err
+	}
+
+	endDate := request.EndDate
+	plan := models.DCAPlan{
+		AmountUSD: decimal.NewFromFloat(request.AmountUSD),
+		Cadence:   request.Cadence,
+		CronExpr:  request.CronExpr,
+		StartDate: request.StartDate,
+		EndDate:   &endDate,
+	}
+	if err := services.ValidateDCAPlan(plan); err != nil {
+		return nil, err
+	}
+
+	// Cargar los precios históricos de cada criptomoneda
+	series := make(map[string]priceSeries, len(allocations))
+	for _, allocation := range allocations {
+		prices, err := source.GetHistoricalPrices(allocation.Ticker, request.StartDate.Add(-backtestLookback), request.EndDate)
+		if err != nil {
+			return nil, fmt.Errorf("error al obtener precios históricos de %s: %v", allocation.Ticker, err)
+		}
+		if len(prices) == 0 {
+			return nil, fmt.Errorf("no hay precios históricos para %s en el rango indicado", allocation.Ticker)
+		}
+		series[allocation.Ticker] = prices
+	}
+
+	result := &models.DCABacktestResult{
+		StartDate:    request.StartDate,
+		EndDate:      request.EndDate,
+		Cadence:      request.Cadence,
+		Allocations:  allocations,
+		Transactions: []models.BacktestTransaction{},
+	}
+
+	// Reproducir cada ejecución del plan
+	var firstRun *time.Time
+	from := request.StartDate
+	for {
+		runAt, ok := services.NextDCARun(plan, from)
+		if !ok {
+			break
+		}
+		from = runAt.Add(time.Nanosecond)
+		if firstRun == nil {
+			firstRun = &runAt
+		}
+
+		for _, allocation := range allocations {
+			price, ok := series[allocation.Ticker].asOf(runAt)
+			if !ok {
+				result.Skipped++
+				continue
+			}
+			total := request.AmountUSD * allocation.Weight
+			result.Transactions = append(result.Transactions, models.BacktestTransaction{
+				Date:   runAt,
+				Ticker: allocation.Ticker,
+				Price:  price,
+				Amount: total / price,
+				Total:  total,
+			})
+		}
+
+		if len(result.Transactions) > backtestMaxPurchases {
+			return nil, fmt.Errorf("la simulación supera el máximo de %d compras, reduce el rango o la frecuencia", backtestMaxPurchases)
+		}
+	}
+
+	if len(result.Transactions) == 0 {
+		return nil, fmt.Errorf("no se pudo simular ninguna compra con los precios disponibles")
+	}
+
+	// Compra única inicial por el mismo monto total invertido con DCA
+	var totalInvested float64
+	for _, transaction := range result.Transactions {
+		totalInvested += transaction.Total
+	}
+	lumpSumTransactions := make([]models.BacktestTransaction, 0, len(allocations))
+	for _, allocation := range allocations {
+		price, ok := series[allocation.Ticker].asOf(*firstRun)
+		if !ok {
+			price, ok = series[allocation.Ticker].firstFrom(request.StartDate)
+		}
+		if !ok {
+			continue
+		}
+		total := totalInvested * allocation.Weight
+		lumpSumTransactions = append(lumpSumTransactions, models.BacktestTransaction{
+			Date:   *firstRun,
+			Ticker: allocation.Ticker,
+			Price:  price,
+			Amount: total / price,
+			Total:  total,
+		})
+	}
+
+	// Precios al final del periodo para valorar las tenencias
+	finalQuotes := make(map[string]services.PriceQuote, len(series))
+	for _, allocation := range allocations {
+		price, ok := series[allocation.Ticker].asOf(request.EndDate)
+		if !ok {
+			return nil, fmt.Errorf("no hay un precio reciente de %s al final del rango para valorar las tenencias", allocation.Ticker)
+		}
+		finalQuotes[allocation.Ticker] = services.PriceQuote{Ticker: allocation.Ticker, Price: price}
+	}
+
+	if result.DCA, err = summarizeBacktest(result.Transactions, series, finalQuotes, request); err != nil {
+		return nil, err
+	}
+	if result.LumpSum, err = summarizeBacktest(lumpSumTransactions, series, finalQuotes, request); err != nil {
+		return nil, err
+	}
+	result.Difference = result.DCA.FinalValue - result.LumpSum.FinalValue
+
+	return result, nil
+}
+
+// normalizeAllocations valida la canasta (o el ticker único) y normaliza los pesos para que sumen 1
+func normalizeAllocations(request models.DCABacktestRequest) ([]models.BacktestAllocation, error) {
+	basket := request.Basket
+	if len(basket) == 0 {
+		if strings.TrimSpace(request.Ticker) == "" {
+			return nil, fmt.Errorf("se debe indicar un ticker o una canasta")
+		}
+		basket = []models.BacktestAllocation{{Ticker: request.Ticker, Weight: 1}}
+	}
+	return normalizeBasket(basket)
+}
+
+// normalizeBasket agrupa los tickers repetidos de una canasta y normaliza los pesos para que sumen 1
+func normalizeBasket(basket []models.BacktestAllocation) ([]models.BacktestAllocation, error) {
+	weights := make(map[string]float64)
+	order := make([]string, 0, len(basket))
+	var totalWeight float64
+	for _, allocation := range basket {
+		ticker := strings.ToUpper(strings.TrimSpace(allocation.Ticker))
+		if ticker == "" || allocation.Weight <= 0 {
+			return nil, fmt.Errorf("cada elemento de la canasta necesita un ticker y un peso mayor a 0")
+		}
+		if _, exists := weights[ticker]; !exists {
+			order = append(order, ticker)
+		}
+		weights[ticker] += allocation.Weight
+		totalWeight += allocation.Weight
+	}
+
+	allocations := make([]models.BacktestAllocation, 0, len(order))
+	for _, ticker := range order {
+		allocations = append(allocations, models.BacktestAllocation{
+			Ticker: ticker,
+			Weight: weights[ticker] / totalWeight,
+		})
+	}
+	return allocations, nil
+}
+
+// summarizeBacktest calcula tenencias, valor final y máxima caída de una serie de compras simuladas
+func summarizeBacktest(transactions []models.BacktestTransaction, series map[string]priceSeries, finalQuotes map[string]services.PriceQuote, request models.DCABacktestRequest) (models.BacktestSummary, error) {
+	// Reutilizar el cálculo de costo base del dashboard
+	rows := make([]dashboardRow, 0, len(transactions))
+	for _, transaction := range transactions {
+		rows = append(rows, dashboardRow{
+			ticker:        transaction.Ticker,
+			cryptoName:    transaction.Ticker,
+			txType:        models.TransactionTypeBuy,
+			amount:        decimal.NewFromFloat(transaction.Amount),
+			purchasePrice: decimal.NewFromFloat(transaction.Price),
+			total:         decimal.NewFromFloat(transaction.Total),
+			date:          transaction.Date,
+			imageURL:      sql.NullString{},
+		})
+	}
+	holdings, _, err := aggregateCostBasis(rows, finalQuotes, models.CostBasisAverage)
+	if err != nil {
+		return models.BacktestSummary{}, err
+	}
+	sort.Slice(holdings, func(i, j int) bool { return holdings[i].Ticker < holdings[j].Ticker })
+
+	summary := models.BacktestSummary{
+		Holdings:       holdings,
+		PurchasesCount: len(transactions),
+	}
+	for _, holding := range holdings {
+		summary.TotalInvested += holding.TotalInvested
+		summary.FinalValue += holding.Holdings * holding.CurrentPrice
+	}
+	summary.Profit = summary.FinalValue - summary.TotalInvested
+	if summary.TotalInvested > 0 {
+		summary.ProfitPercent = (summary.Profit / summary.TotalInvested) * 100
+	}
+	if len(holdings) == 1 {
+		summary.AverageCost = holdings[0].AvgPrice
+	}
+
+	summary.MaxDrawdown, summary.MaxDrawdownAt = backtestMaxDrawdown(transactions, series, request.StartDate, request.EndDate)
+	return summary, nil
+}
+
+// backtestMaxDrawdown recorre cada día con precios y calcula la mayor caída porcentual
+// del valor por dólar invertido, para que los aportes periódicos no oculten las caídas.
+// Los días en que algún ticker comprado no tiene un cierre reciente no se valoran.
+func backtestMaxDrawdown(transactions []models.BacktestTransaction, series map[string]priceSeries, start, end time.Time) (float64, *time.Time) {
+	// Días con algún precio dentro del rango
+	daySeen := make(map[time.Time]bool)
+	var days []time.Time
+	for _, prices := range series {
+		for _, price := range prices {
+			if price.Date.Before(backtestDay(start)) || price.Date.After(end) || daySeen[price.Date] {
+				continue
+			}
+			daySeen[price.Date] = true
+			days = append(days, price.Date)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	holdings := make(map[string]float64)
+	var invested, peak, maxDrawdown float64
+	var maxDrawdownAt *time.Time
+	next := 0
+
+	for _, day := range days {
+		// Aplicar las compras realizadas hasta el final de ese día
+		for next < len(transactions) && !backtestDay(transactions[next].Date).After(day) {
+			holdings[transactions[next].Ticker] += transactions[next].Amount
+			invested += transactions[next].Total
+			next++
+		}
+		if invested <= 0 {
+			continue
+		}
+
+		var value float64
+		priced := true
+		for ticker, amount := range holdings {
+			price, ok := series[ticker].asOf(day)
+			if !ok {
+				priced = false
+				break
+			}
+			value += amount * price
+		}
+		if !priced {
+			continue
+		}
+
+		ratio := value / invested
+		if ratio > peak {
+			peak = ratio
+		}
+		if peak > 0 {
+			drawdown := (peak - ratio) / peak * 100
+			if drawdown > maxDrawdown {
+				maxDrawdown = drawdown
+				drawdownDay := day
+				maxDrawdownAt = &drawdownDay
+			}
+		}
+	}
+
+	return maxDrawdown, maxDrawdownAt
+}
+
+// backtestDay trunca una fecha al día en UTC, igual que los precios históricos
+func backtestDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
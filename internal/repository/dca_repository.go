@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+)
+
+// ErrDCAPlanAlreadyExecuted indica que otra instancia ya ejecutó el plan para ese periodo
+var ErrDCAPlanAlreadyExecuted = errors.New("el plan DCA ya fue ejecutado para este periodo")
+
+// DCARepository maneja las operaciones de base de datos para planes DCA
+type DCARepository struct {
+	db *sql.DB
+}
+
+// NewDCARepository crea un nuevo repositorio de planes DCA
+func NewDCARepository(db *sql.DB) *DCARepository {
+	return &DCARepository{
+		db: db,
+	}
+}
+
+// dcaPlanColumns son las columnas que se leen de dca_plans, en el orden de scanDCAPlan
+const dcaPlanColumns = `id, user_id, crypto_name, ticker, amount_usd, cadence, cron_expr, start_date, end_date,
+	bolsa_id, active, next_run_at, last_run_at, created_at, updated_at, asset_class`
+
+// rowScanner permite escanear tanto *sql.Row como *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanDCAPlan lee un plan DCA de una fila
+func scanDCAPlan(row rowScanner) (*models.DCAPlan, error) {
+	var plan models.DCAPlan
+	var cronExpr, bolsaID sql.NullString
+	var endDate, nextRunAt, lastRunAt sql.NullTime
+	var active int
+
+	err := row.Scan(
+		&plan.ID, &plan.UserID, &plan.CryptoName, &plan.Ticker, &plan.AmountUSD, &plan.Cadence,
+		&cronExpr, &plan.StartDate, &endDate, &bolsaID, &active, &nextRunAt, &lastRunAt,
+		&plan.CreatedAt, &plan.UpdatedAt, &plan.AssetClass,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.CronExpr = cronExpr.String
+	plan.BolsaID = bolsaID.String
+	plan.Active = active == 1
+	if endDate.Valid {
+		plan.EndDate = &endDate.Time
+	}
+	if nextRunAt.Valid {
+		plan.NextRunAt = &nextRunAt.Time
+	}
+	if lastRunAt.Valid {
+		plan.LastRunAt = &lastRunAt.Time
+	}
+
+	return &plan, nil
+}
+
+// nullableTime convierte un puntero a fecha en un valor apto para la base de datos
+func nullableTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+// boolToInt convierte un booleano al entero que guardamos en la base de datos
+func boolToInt(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// CreatePlan crea un nuevo plan DCA
+func (r *DCARepository) CreatePlan(plan models.DCAPlan) error {
+	_, err := r.db.Exec(
+		`INSERT INTO dca_plans (`+dcaPlanColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
+		plan.ID, plan.UserID, plan.CryptoName, plan.Ticker, plan.AmountUSD, plan.Cadence, plan.CronExpr,
+		plan.StartDate, nullableTime(plan.EndDate), plan.BolsaID, boolToInt(plan.Active),
+		nullableTime(plan.NextRunAt), nullableTime(plan.LastRunAt), plan.CreatedAt, plan.UpdatedAt,
+		services.AssetClassOrDefault(plan.AssetClass),
+	)
+	return err
+}
+
+// GetPlanByID obtiene un plan DCA por su ID
+func (r *DCARepository) GetPlanByID(id string) (*models.DCAPlan, error) {
+	row := r.db.QueryRow(`SELECT `+dcaPlanColumns+` FROM dca_plans WHERE id = $1`, id)
+	return scanDCAPlan(row)
+}
+
+// GetPlansByUserID obtiene todos los planes DCA de un usuario
+func (r *DCARepository) GetPlansByUserID(userID string) ([]models.DCAPlan, error) {
+	rows, err := r.db.Query(
+		`SELECT `+dcaPlanColumns+` FROM dca_plans WHERE user_id = $1 ORDER BY created_at DESC`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []models.DCAPlan{}
+	for rows.Next() {
+		plan, err := scanDCAPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, *plan)
+	}
+
+	return plans, rows.Err()
+}
+
+// UpdatePlan actualiza un plan DCA existente
+func (r *DCARepository) UpdatePlan(plan models.DCAPlan) error {
+	_, err := r.db.Exec(
+		`UPDATE dca_plans
+		SET crypto_name = $1, amount_usd = $2, cadence = $3, cron_expr = $4, end_date = $5,
+			bolsa_id = $6, active = $7, next_run_at = $8, updated_at = $9
+		WHERE id = $10`,
+		plan.CryptoName, plan.AmountUSD, plan.Cadence, plan.CronExpr, nullableTime(plan.EndDate),
+		plan.BolsaID, boolToInt(plan.Active), nullableTime(plan.NextRunAt), plan.UpdatedAt, plan.ID,
+	)
+	return err
+}
+
+// DeletePlan elimina un plan DCA y su historial de ejecuciones
+func (r *DCARepository) DeletePlan(id string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM dca_executions WHERE plan_id = $1`, id); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM dca_plans WHERE id = $1`, id)
+	return err
+}
+
+// GetExecutions obtiene las ejecuciones más recientes de un plan DCA
+func (r *DCARepository) GetExecutions(planID string, limit int) ([]models.DCAExecution, error) {
+	rows, err := r.db.Query(
+		`SELECT id, plan_id, user_id, scheduled_at, executed_at, status, price, amount, total, transaction_id, error
+		FROM dca_executions WHERE plan_id = $1 ORDER BY executed_at DESC LIMIT $2`,
+		planID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	executions := []models.DCAExecution{}
+	for rows.Next() {
+		var execution models.DCAExecution
+		var transactionID, errorMessage sql.NullString
+		err := rows.Scan(
+			&execution.ID, &execution.PlanID, &execution.UserID, &execution.ScheduledAt, &execution.ExecutedAt,
+			&execution.Status, &execution.Price, &execution.Amount, &execution.Total, &transactionID, &errorMessage,
+		)
+		if err != nil {
+			return nil, err
+		}
+		execution.TransactionID = transactionID.String
+		execution.Error = errorMessage.String
+		executions = append(executions, execution)
+	}
+
+	return executions, rows.Err()
+}
+
+// GetDueDCAPlans obtiene los planes activos cuya próxima ejecución ya llegó
+func (r *DCARepository) GetDueDCAPlans(now time.Time) ([]models.DCAPlan, error) {
+	rows, err := r.db.Query(
+		`SELECT `+dcaPlanColumns+` FROM dca_plans
+		WHERE active = 1 AND next_run_at IS NOT NULL AND next_run_at <= $1
+		ORDER BY next_run_at`, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []models.DCAPlan{}
+	for rows.Next() {
+		plan, err := scanDCAPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, *plan)
+	}
+
+	return plans, rows.Err()
+}
+
+// RecordDCAExecution guarda en una sola transacción SQL el resultado de una ejecución:
+// la compra generada (si la hay), el activo en la bolsa destino, el registro de la
+// ejecución y la próxima fecha del plan. Si nextRunAt es nil el plan se desactiva.
+// Devuelve ErrDCAPlanAlreadyExecuted si el periodo ya fue ejecutado por otra instancia.
+func (r *DCARepository) RecordDCAExecution(plan models.DCAPlan, execution models.DCAExecution, transaction *models.CryptoTransaction, nextRunAt *time.Time) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	// Reclamar el periodo: solo avanza si nadie más lo ejecutó
+	result, err := tx.Exec(
+		`UPDATE dca_plans SET next_run_at = $1, last_run_at = $2, active = $3, updated_at = $2
+		WHERE id = $4 AND next_run_at = $5`,
+		nullableTime(nextRunAt), execution.ExecutedAt, boolToInt(nextRunAt != nil), plan.ID, execution.ScheduledAt,
+	)
+	if err != nil {
+		return err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if claimed == 0 {
+		err = ErrDCAPlanAlreadyExecuted
+		return err
+	}
+
+	if transaction != nil {
+		transaction.ID = models.GenerateUUID()
+		transaction.CreatedAt = execution.ExecutedAt
+		services.RoundTransaction(transaction)
+		if err = insertTransaction(tx, *transaction); err != nil {
+			return fmt.Errorf("error al crear la compra del plan: %v", err)
+		}
+		execution.TransactionID = transaction.ID
+
+		// Añadir la compra a la bolsa destino si el plan tiene una
+		if plan.BolsaID != "" {
+			_, err = tx.Exec(
+				`INSERT INTO assets_in_bolsa (id, bolsa_id, crypto_name, ticker, amount, purchase_price, total, image_url, created_at, updated_at, asset_class)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+				models.GenerateUUID(), plan.BolsaID, transaction.CryptoName, transaction.Ticker, transaction.Amount,
+				transaction.PurchasePrice, transaction.Total, transaction.ImageURL, execution.ExecutedAt, execution.ExecutedAt,
+				transaction.AssetClass,
+			)
+			if err != nil {
+				return fmt.Errorf("error al añadir la compra a la bolsa: %v", err)
+			}
+		}
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO dca_executions (id, plan_id, user_id, scheduled_at, executed_at, status, price, amount, total, transaction_id, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		execution.ID, execution.PlanID, execution.UserID, execution.ScheduledAt, execution.ExecutedAt,
+		execution.Status, execution.Price, execution.Amount, execution.Total, execution.TransactionID,
+		strings.TrimSpace(execution.Error),
+	)
+	return err
+}
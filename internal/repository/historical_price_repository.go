@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+)
+
+// historicalCloseMaxAgeDays es la antigüedad máxima del cierre usado para valorar una fecha
+const historicalCloseMaxAgeDays = 7
+
+// HistoricalPriceRepository maneja los precios históricos diarios guardados en la base de datos
+type HistoricalPriceRepository struct {
+	db *sql.DB
+}
+
+// NewHistoricalPriceRepository crea un nuevo repositorio de precios históricos
+func NewHistoricalPriceRepository(db *sql.DB) *HistoricalPriceRepository {
+	return &HistoricalPriceRepository{
+		db: db,
+	}
+}
+
+// GetHistoricalPrices obtiene los precios de cierre de un ticker entre dos fechas, ordenados por fecha
+func (r *HistoricalPriceRepository) GetHistoricalPrices(ticker string, from, to time.Time) ([]models.HistoricalPrice, error) {
+	rows, err := r.db.Query(
+		`SELECT ticker, date, close FROM historical_prices
+		WHERE ticker = $1 AND date >= $2 AND date <= $3
+		ORDER BY date`,
+		strings.ToUpper(ticker), from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prices []models.HistoricalPrice
+	for rows.Next() {
+		var price models.HistoricalPrice
+		if err := rows.Scan(&price.Ticker, &price.Date, &price.Close); err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+
+	return prices, rows.Err()
+}
+
+// GetCloseOnOrBefore obtiene el último precio de cierre de un ticker hasta la fecha indicada.
+// Devuelve sql.ErrNoRows si no hay precios guardados en los días previos.
+func (r *HistoricalPriceRepository) GetCloseOnOrBefore(ticker string, date time.Time) (float64, error) {
+	var closePrice float64
+	err := r.db.QueryRow(
+		`SELECT close FROM historical_prices
+		WHERE ticker = $1 AND date <= $2 AND date >= $3
+		ORDER BY date DESC LIMIT 1`,
+		strings.ToUpper(ticker), date, date.AddDate(0, 0, -historicalCloseMaxAgeDays),
+	).Scan(&closePrice)
+	return closePrice, err
+}
+
+// SavePrices guarda (o reemplaza) precios históricos y devuelve cuántos se guardaron
+func (r *HistoricalPriceRepository) SavePrices(prices []models.HistoricalPrice) (saved int, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	stmt, err := tx.Prepare(
+		`INSERT INTO historical_prices (ticker, date, close) VALUES ($1, $2, $3)
+		ON CONFLICT (ticker, date) DO UPDATE SET close = EXCLUDED.close`,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for _, price := range prices {
+		if _, err = stmt.Exec(strings.ToUpper(price.Ticker), price.Date, price.Close); err != nil {
+			return 0, err
+		}
+		saved++
+	}
+
+	return saved, nil
+}
+
+// SeedHistoricalPricesFromCSV carga en la base de datos los precios del CSV indicado
+// por HISTORICAL_PRICES_CSV, para poder ejecutar backtests sin conexión
+func SeedHistoricalPricesFromCSV(db *sql.DB) {
+	path := os.Getenv("HISTORICAL_PRICES_CSV")
+	if path == "" {
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Printf("Error al abrir el CSV de precios históricos %q: %v", path, err)
+		return
+	}
+	defer file.Close()
+
+	prices, err := services.ParseHistoricalPricesCSV(file, "")
+	if err != nil {
+		log.Printf("Error al leer el CSV de precios históricos %q: %v", path, err)
+		return
+	}
+
+	saved, err := NewHistoricalPriceRepository(db).SavePrices(prices)
+	if err != nil {
+		log.Printf("Error al guardar precios históricos: %v", err)
+		return
+	}
+	log.Printf("Precios históricos cargados desde %s: %d", path, saved)
+}
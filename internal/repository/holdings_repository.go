@@ -4,13 +4,17 @@ import (
 	"database/sql"
 	"errors"
 	"sort"
+	"strings"
 
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/shopspring/decimal"
 )
 
 // HoldingsRepository maneja las operaciones relacionadas con las tenencias de criptomonedas
 type HoldingsRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	prices services.PriceProvider
 }
 
 // NewHoldingsRepository crea un nuevo repositorio de tenencias
@@ -20,39 +24,58 @@ func NewHoldingsRepository(db *sql.DB) *HoldingsRepository {
 	}
 }
 
-// UpdateHoldingsAfterSale verifica si el usuario tiene suficiente criptomoneda para vender
-func (r *HoldingsRepository) UpdateHoldingsAfterSale(tx *sql.Tx, userID, ticker string, amountToSell float64) error {
-	// Obtener todas las transacciones del usuario para esta criptomoneda
+// WithPrices devuelve una copia del repositorio que obtiene los precios del proveedor indicado
+func (r *HoldingsRepository) WithPrices(prices services.PriceProvider) *HoldingsRepository {
+	clone := *r
+	clone.prices = prices
+	return &clone
+}
+
+// UpdateHoldingsAfterSale verifica si el usuario tiene suficiente criptomoneda para vender.
+// El saldo se calcula con decimales exactos, así que se puede vender el saldo completo.
+// Las comisiones pagadas con esta criptomoneda en operaciones de otras (ej. BNB) también lo reducen.
+// La transacción excludedID (la venta que se está editando) no cuenta para el saldo.
+func (r *HoldingsRepository) UpdateHoldingsAfterSale(tx *sql.Tx, userID, ticker, excludedID string, amountToSell decimal.Decimal) error {
+	// Obtener las transacciones de esta criptomoneda y las que pagaron comisión con ella
 	query := `
-		SELECT type, amount
+		SELECT ticker, type, amount, fee, fee_currency
 		FROM crypto_transactions
-		WHERE user_id = $1 AND ticker = $2
+		WHERE user_id = $1 AND (ticker = $2 OR UPPER(fee_currency) = UPPER($2)) AND id <> $3
 	`
-	rows, err := tx.Query(query, userID, ticker)
+	rows, err := tx.Query(query, userID, ticker, excludedID)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
 	// Calcular el balance actual
-	var balance float64
+	balance := decimal.Zero
 	for rows.Next() {
-		var txType string
-		var amount float64
-		err := rows.Scan(&txType, &amount)
+		var transaction models.CryptoTransaction
+		err := rows.Scan(&transaction.Ticker, &transaction.Type, &transaction.Amount, &transaction.Fee, &transaction.FeeCurrency)
 		if err != nil {
 			return err
 		}
 
-		if txType == models.TransactionTypeBuy {
-			balance += amount
-		} else if txType == models.TransactionTypeSell {
-			balance -= amount
+		if strings.EqualFold(transaction.Ticker, ticker) {
+			if services.IsAcquisitionType(transaction.Type) {
+				balance = balance.Add(transaction.Amount)
+			} else if transaction.Type == models.TransactionTypeSell {
+				balance = balance.Sub(transaction.Amount)
+			}
+		}
+
+		// Las comisiones pagadas con esta criptomoneda reducen el saldo, sea cual sea el ticker operado
+		if strings.EqualFold(services.FeeCoin(transaction), ticker) {
+			balance = balance.Sub(transaction.Fee)
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
 
 	// Verificar si hay suficiente balance para vender
-	if balance < amountToSell {
+	if balance.LessThan(services.RoundAmount(ticker, amountToSell)) {
 		return errors.New("saldo insuficiente para realizar la venta")
 	}
 
@@ -62,8 +85,14 @@ func (r *HoldingsRepository) UpdateHoldingsAfterSale(tx *sql.Tx, userID, ticker
 // GetHoldings obtiene las tenencias de criptomonedas de un usuario
 func (r *HoldingsRepository) GetHoldings(userID string) (models.Holdings, error) {
 	// Obtener el dashboard para calcular las tenencias
-	cryptoRepo := NewCryptoRepository(r.db)
-	dashboard, err := cryptoRepo.GetCryptoDashboard(userID)
+	cryptoRepo := NewCryptoRepository(r.db).WithPrices(r.prices)
+	dashboard, ledger, err := cryptoRepo.getDashboardWithLedger(userID)
+	if err != nil {
+		return models.Holdings{}, err
+	}
+
+	// Tenencias de cada exchange o billetera, valoradas con los precios del dashboard
+	byAccount, err := NewAccountRepository(r.db).GetAccountHoldings(userID, dashboard)
 	if err != nil {
 		return models.Holdings{}, err
 	}
@@ -75,25 +104,35 @@ func (r *HoldingsRepository) GetHoldings(userID string) (models.Holdings, error)
 			TotalInvested:     0,
 			TotalProfit:       0,
 			ProfitPercentage:  0,
+			RealizedProfit:    ledger.TotalRealizedGain().InexactFloat64(),
+			TotalFees:         ledger.TotalFees().InexactFloat64(),
+			TotalIncome:       ledger.TotalIncome().InexactFloat64(),
+			CostBasisMethod:   ledger.Method,
+			Currency:          services.CurrencyOf(r.prices),
 			Distribution:      []models.CryptoWeight{},
+			ByAssetClass:      []models.AssetClassSummary{},
+			ByAccount:         byAccount,
 			ChartData: models.PieChartData{
 				Labels:   []string{},
 				Values:   []float64{},
-				Currency: "USD",
+				Currency: services.CurrencyOf(r.prices),
 			},
 		}, nil
 	}
 
 	// Calcular totales
-	var totalCurrentValue, totalInvested, totalProfit float64
+	// Los totales se suman como decimales para no acumular errores de redondeo entre posiciones
+	var totalCurrentValueDec, totalInvestedDec, totalProfitDec, totalIncomeDec decimal.Decimal
 	var cryptoWeights []models.CryptoWeight
 
 	// Procesar cada criptomoneda en el dashboard
 	for _, crypto := range dashboard {
-		currentValue := crypto.Holdings * crypto.CurrentPrice
-		totalCurrentValue += currentValue
-		totalInvested += crypto.TotalInvested
-		totalProfit += crypto.CurrentProfit
+		currentValueDec := decimal.NewFromFloat(crypto.Holdings).Mul(decimal.NewFromFloat(crypto.CurrentPrice))
+		currentValue := currentValueDec.InexactFloat64()
+		totalCurrentValueDec = totalCurrentValueDec.Add(currentValueDec)
+		totalInvestedDec = totalInvestedDec.Add(decimal.NewFromFloat(crypto.TotalInvested))
+		totalProfitDec = totalProfitDec.Add(decimal.NewFromFloat(crypto.CurrentProfit))
+		totalIncomeDec = totalIncomeDec.Add(decimal.NewFromFloat(crypto.Income))
 
 		// Guardar información para calcular la distribución
 		cryptoWeights = append(cryptoWeights, models.CryptoWeight{
@@ -103,10 +142,15 @@ func (r *HoldingsRepository) GetHoldings(userID string) (models.Holdings, error)
 		})
 	}
 
+	totalCurrentValue := totalCurrentValueDec.InexactFloat64()
+	totalInvested := totalInvestedDec.InexactFloat64()
+	totalProfit := totalProfitDec.InexactFloat64()
+	totalIncome := totalIncomeDec.InexactFloat64()
+
 	// Calcular porcentaje de ganancia
 	var profitPercentage float64
-	if totalInvested > 0 {
-		profitPercentage = (totalProfit / totalInvested) * 100
+	if totalInvestedDec.IsPositive() {
+		profitPercentage = totalProfitDec.Div(totalInvestedDec).Mul(decimal.NewFromInt(100)).InexactFloat64()
 	}
 
 	// Calcular la distribución (peso) de cada criptomoneda
@@ -172,7 +216,7 @@ func (r *HoldingsRepository) GetHoldings(userID string) (models.Holdings, error)
 
 	// Generar datos para el gráfico de torta
 	pieChartData := models.PieChartData{
-		Currency: "USD",
+		Currency: services.CurrencyOf(r.prices),
 	}
 
 	// Generar etiquetas y valores para el gráfico
@@ -187,7 +231,75 @@ func (r *HoldingsRepository) GetHoldings(userID string) (models.Holdings, error)
 		TotalInvested:     totalInvested,
 		TotalProfit:       totalProfit,
 		ProfitPercentage:  profitPercentage,
+		RealizedProfit:    ledger.TotalRealizedGain().InexactFloat64(),
+		TotalFees:         ledger.TotalFees().InexactFloat64(),
+		TotalIncome:       totalIncome,
+		CostBasisMethod:   ledger.Method,
+		Currency:          services.CurrencyOf(r.prices),
 		Distribution:      distribution,
 		ChartData:         pieChartData,
+		ByAssetClass:      groupByAssetClass(dashboard, totalCurrentValue),
+		ByAccount:         byAccount,
 	}, nil
 }
+
+// GroupDashboardByAssetClass agrupa las posiciones del dashboard por clase de activo
+func GroupDashboardByAssetClass(dashboard []models.CryptoDashboard) []models.AssetClassGroup {
+	var totalCurrentValue decimal.Decimal
+	for _, crypto := range dashboard {
+		totalCurrentValue = totalCurrentValue.Add(decimal.NewFromFloat(crypto.Holdings).Mul(decimal.NewFromFloat(crypto.CurrentPrice)))
+	}
+
+	groups := make([]models.AssetClassGroup, 0)
+	for _, summary := range groupByAssetClass(dashboard, totalCurrentValue.InexactFloat64()) {
+		group := models.AssetClassGroup{AssetClassSummary: summary, Assets: []models.CryptoDashboard{}}
+		for _, crypto := range dashboard {
+			if services.AssetClassOrDefault(crypto.AssetClass) == summary.AssetClass {
+				group.Assets = append(group.Assets, crypto)
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// assetClassOrder es el orden en que se listan las clases de activo en los resúmenes
+var assetClassOrder = []string{models.AssetClassCrypto, models.AssetClassStock, models.AssetClassETF, models.AssetClassCash}
+
+// groupByAssetClass agrupa el valor, lo invertido y la ganancia de las tenencias por clase de activo
+func groupByAssetClass(dashboard []models.CryptoDashboard, totalCurrentValue float64) []models.AssetClassSummary {
+	summaries := make(map[string]*models.AssetClassSummary)
+	currentValues := make(map[string]decimal.Decimal)
+	invested := make(map[string]decimal.Decimal)
+	for _, crypto := range dashboard {
+		assetClass := services.AssetClassOrDefault(crypto.AssetClass)
+		summary, exists := summaries[assetClass]
+		if !exists {
+			summary = &models.AssetClassSummary{AssetClass: assetClass, Tickers: []string{}, MarketOpen: crypto.MarketOpen}
+			summaries[assetClass] = summary
+		}
+		currentValues[assetClass] = currentValues[assetClass].Add(decimal.NewFromFloat(crypto.Holdings).Mul(decimal.NewFromFloat(crypto.CurrentPrice)))
+		invested[assetClass] = invested[assetClass].Add(decimal.NewFromFloat(crypto.TotalInvested))
+		summary.Tickers = append(summary.Tickers, crypto.Ticker)
+	}
+
+	result := make([]models.AssetClassSummary, 0, len(summaries))
+	for _, assetClass := range assetClassOrder {
+		summary, exists := summaries[assetClass]
+		if !exists {
+			continue
+		}
+		profit := currentValues[assetClass].Sub(invested[assetClass])
+		summary.CurrentValue = currentValues[assetClass].InexactFloat64()
+		summary.TotalInvested = invested[assetClass].InexactFloat64()
+		summary.Profit = profit.InexactFloat64()
+		if invested[assetClass].IsPositive() {
+			summary.ProfitPercentage = profit.Div(invested[assetClass]).Mul(decimal.NewFromInt(100)).InexactFloat64()
+		}
+		if totalCurrentValue > 0 {
+			summary.Weight = (summary.CurrentValue / totalCurrentValue) * 100
+		}
+		result = append(result, *summary)
+	}
+	return result
+}
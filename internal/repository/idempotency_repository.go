@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+)
+
+// IdempotencyRepository guarda las respuestas de las peticiones con clave de idempotencia
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+// NewIdempotencyRepository crea un nuevo repositorio de claves de idempotencia
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		db: db,
+	}
+}
+
+// Reserve reserva una clave para la petición con el hash indicado. Si la clave es nueva (o había
+// vencido) devuelve true; si no, devuelve el registro existente sin modificarlo.
+func (r *IdempotencyRepository) Reserve(userID, key, requestHash string) (*models.IdempotencyRecord, bool, error) {
+	// Liberar las claves vencidas del usuario para que puedan volver a usarse
+	_, err := r.db.Exec(
+		`DELETE FROM idempotency_keys WHERE user_id = $1 AND created_at < $2`,
+		userID, time.Now().Add(-models.IdempotencyKeyTTL),
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	result, err := r.db.Exec(
+		`INSERT INTO idempotency_keys (user_id, idempotency_key, request_hash, status_code, created_at)
+		VALUES ($1, $2, $3, 0, $4)
+		ON CONFLICT (user_id, idempotency_key) DO NOTHING`,
+		userID, key, requestHash, time.Now(),
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	if inserted, err := result.RowsAffected(); err != nil {
+		return nil, false, err
+	} else if inserted > 0 {
+		return nil, true, nil
+	}
+
+	record := &models.IdempotencyRecord{UserID: userID, Key: key}
+	var contentType, body sql.NullString
+	err = r.db.QueryRow(
+		`SELECT request_hash, status_code, content_type, response_body, created_at
+		FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2`,
+		userID, key,
+	).Scan(&record.RequestHash, &record.StatusCode, &contentType, &body, &record.CreatedAt)
+	if err != nil {
+		return nil, false, err
+	}
+	record.ContentType = contentType.String
+	record.ResponseBody = []byte(body.String)
+	return record, false, nil
+}
+
+// Complete guarda la respuesta de la petición que reservó la clave
+func (r *IdempotencyRepository) Complete(userID, key string, statusCode int, contentType string, body []byte) error {
+	_, err := r.db.Exec(
+		`UPDATE idempotency_keys SET status_code = $1, content_type = $2, response_body = $3
+		WHERE user_id = $4 AND idempotency_key = $5`,
+		statusCode, contentType, string(body), userID, key,
+	)
+	return err
+}
+
+// Release libera una clave reservada para que la petición pueda reintentarse
+func (r *IdempotencyRepository) Release(userID, key string) error {
+	_, err := r.db.Exec(
+		`DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2`,
+		userID, key,
+	)
+	return err
+}
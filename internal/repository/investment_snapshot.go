@@ -2,7 +2,6 @@ package repository
 
 import (
 	"database/sql"
-	"fmt"
 	"log"
 	"time"
 
@@ -46,7 +45,7 @@ func (r *CryptoRepository) SaveInvestmentSnapshotWithMaxMin(userID string, total
 	)
 
 	// Generar un ID único para el snapshot
-	snapshotID := fmt.Sprintf("snapshot_%d", time.Now().UnixNano())
+	snapshotID := models.GenerateUUID()
 
 	if err == nil {
 		// Ya existe un snapshot para este intervalo
@@ -10,6 +10,9 @@ import (
 )
 
 func (r *CryptoRepository) GetPerformance(userID string) (*models.Performance, error) {
+	// Compartir las cotizaciones entre el dashboard y el cálculo del rendimiento
+	r = r.withPriceBatch()
+
 	// Obtener el dashboard para calcular el rendimiento
 	dashboard, err := r.GetCryptoDashboard(userID)
 	if err != nil {
@@ -39,20 +42,16 @@ func (r *CryptoRepository) GetPerformance(userID string) (*models.Performance, e
 		crypto := dashboard[0]
 
 		// Obtener datos de cambio en 24h
-		cryptoData, err := services.GetCryptoPrice(crypto.Ticker)
+		quote, err := r.priceProvider().GetQuote(services.PriceKey(crypto.AssetClass, crypto.Ticker))
 		if err != nil {
 			return nil, err
 		}
 
-		changePct24h := cryptoData.Raw[crypto.Ticker]["USD"].CHANGEPCT24HOUR
-		priceChange := cryptoData.Raw[crypto.Ticker]["USD"].CHANGE24HOUR
+		changePct24h := quote.ChangePct24h
+		priceChange := quote.Change24h
 
-		// Obtener la URL de la imagen
-		imageURL, err := services.GetCryptoImageURL(crypto.Ticker)
-		if err != nil {
-			// Si hay un error, usar una URL vacía
-			imageURL = ""
-		}
+		// La URL de la imagen viene en la misma cotización
+		imageURL := quote.ImageURL
 
 		// Si el cambio es positivo, ponerla como ganadora
 		if changePct24h >= 0 {
@@ -94,6 +93,21 @@ func (r *CryptoRepository) GetPerformance(userID string) (*models.Performance, e
 	topGainer.ChangePct24h = -999999
 	topLoser.ChangePct24h = 999999
 
+	// Obtener los datos de cambio en 24h de todas las criptomonedas en una sola consulta
+	assets := make(map[string]string, len(dashboard))
+	for _, crypto := range dashboard {
+		if crypto.Ticker != "USDT" {
+			assets[crypto.Ticker] = crypto.AssetClass
+		}
+	}
+	quotes := map[string]services.PriceQuote{}
+	if len(assets) > 0 {
+		quotes, err = services.GetAssetQuotes(r.priceProvider(), assets)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	for _, crypto := range dashboard {
 		// Ignorar USDT para el cálculo de rendimiento
 		if crypto.Ticker == "USDT" {
@@ -101,20 +115,16 @@ func (r *CryptoRepository) GetPerformance(userID string) (*models.Performance, e
 		}
 
 		// Obtener datos de cambio en 24h
-		cryptoData, err := services.GetCryptoPrice(crypto.Ticker)
-		if err != nil {
+		quote, exists := quotes[crypto.Ticker]
+		if !exists {
 			continue
 		}
 
-		changePct24h := cryptoData.Raw[crypto.Ticker]["USD"].CHANGEPCT24HOUR
-		priceChange := cryptoData.Raw[crypto.Ticker]["USD"].CHANGE24HOUR
+		changePct24h := quote.ChangePct24h
+		priceChange := quote.Change24h
 
-		// Obtener la URL de la imagen
-		imageURL, err := services.GetCryptoImageURL(crypto.Ticker)
-		if err != nil {
-			// Si hay un error, usar una URL vacía
-			imageURL = ""
-		}
+		// La URL de la imagen viene en la misma cotización
+		imageURL := quote.ImageURL
 
 		// Actualizar el mejor rendimiento
 		if changePct24h > topGainer.ChangePct24h {
@@ -154,9 +164,9 @@ func (r *CryptoRepository) GetPerformance(userID string) (*models.Performance, e
 
 // GetUserPerformance obtiene el rendimiento de las inversiones del usuario desde una fecha específica
 // Esta función es un wrapper para GetPerformance que permite su uso desde los handlers
-func GetUserPerformance(db *sql.DB, userID string, startDate time.Time) (*models.Performance, error) {
+func GetUserPerformance(db *sql.DB, prices services.PriceProvider, userID string, startDate time.Time) (*models.Performance, error) {
 	// Crear una instancia del repositorio de criptomonedas
-	repo := NewCryptoRepository(db)
+	repo := NewCryptoRepository(db).WithPrices(prices)
 	
 	// Llamar a la función GetPerformance para obtener el rendimiento
 	performance, err := repo.GetPerformance(userID)
@@ -172,9 +182,9 @@ func GetUserPerformance(db *sql.DB, userID string, startDate time.Time) (*models
 
 // GetUserHoldings obtiene las tenencias actuales del usuario
 // Esta función utiliza el dashboard para obtener las tenencias
-func GetUserHoldings(db *sql.DB, userID string) ([]models.CryptoDashboard, error) {
+func GetUserHoldings(db *sql.DB, prices services.PriceProvider, userID string) ([]models.CryptoDashboard, error) {
 	// Crear una instancia del repositorio de criptomonedas
-	repo := NewCryptoRepository(db)
+	repo := NewCryptoRepository(db).WithPrices(prices)
 	
 	// Obtener el dashboard que ya contiene las tenencias
 	dashboard, err := repo.GetCryptoDashboard(userID)
@@ -195,9 +205,9 @@ func GetUserHoldings(db *sql.DB, userID string) ([]models.CryptoDashboard, error
 
 // GetUserCurrentBalance obtiene el balance actual del usuario
 // Esta función calcula el balance sumando el valor actual de todas las tenencias
-func GetUserCurrentBalance(db *sql.DB, userID string) (*models.Balance, error) {
+func GetUserCurrentBalance(db *sql.DB, prices services.PriceProvider, userID string) (*models.Balance, error) {
 	// Crear una instancia del repositorio de criptomonedas
-	repo := NewCryptoRepository(db)
+	repo := NewCryptoRepository(db).WithPrices(prices)
 	
 	// Obtener el dashboard que contiene las tenencias
 	dashboard, err := repo.GetCryptoDashboard(userID)
@@ -206,12 +216,13 @@ func GetUserCurrentBalance(db *sql.DB, userID string) (*models.Balance, error) {
 	}
 	
 	// Calcular el balance total y el total invertido
-	var totalBalance, totalInvested, totalProfit float64
+	var totalBalance, totalInvested, totalProfit, totalIncome float64
 	for _, crypto := range dashboard {
 		// Calcular el valor actual de las tenencias
 		currentValue := crypto.CurrentPrice * crypto.Holdings
 		totalBalance += currentValue
 		totalInvested += crypto.TotalInvested
+		totalIncome += crypto.Income
 	}
 	
 	// Calcular la ganancia/pérdida total
@@ -228,7 +239,9 @@ func GetUserCurrentBalance(db *sql.DB, userID string) (*models.Balance, error) {
 		TotalBalance:      totalBalance,
 		TotalInvested:     totalInvested,
 		TotalProfit:       totalProfit,
+		TotalIncome:       totalIncome,
 		ProfitPercentage:  profitPercentage,
+		Currency:          services.CurrencyOf(prices),
 		LastUpdated:       time.Now(),
 	}
 	
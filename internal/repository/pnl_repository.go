@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+)
+
+// PnLRepository calcula ganancias realizadas y no realizadas emparejando ventas con compras
+type PnLRepository struct {
+	db     *sql.DB
+	prices services.PriceProvider
+}
+
+// NewPnLRepository crea un nuevo repositorio de ganancias
+func NewPnLRepository(db *sql.DB) *PnLRepository {
+	return &PnLRepository{
+		db: db,
+	}
+}
+
+// WithPrices devuelve una copia del repositorio que obtiene los precios del proveedor indicado
+func (r *PnLRepository) WithPrices(prices services.PriceProvider) *PnLRepository {
+	clone := *r
+	clone.prices = prices
+	return &clone
+}
+
+// priceProvider devuelve el proveedor de precios del repositorio o el global si no se indicó ninguno
+func (r *PnLRepository) priceProvider() services.PriceProvider {
+	if r.prices != nil {
+		return r.prices
+	}
+	return services.GetPriceProvider()
+}
+
+// GetLedger empareja todas las transacciones del usuario con el método indicado.
+// USDT se excluye porque es el saldo en dólares que reciben las ventas.
+func (r *PnLRepository) GetLedger(userID, method string) (*services.LotLedger, error) {
+	rows, err := r.db.Query(`
+		SELECT id, ticker, crypto_name, amount, purchase_price, total, type, date, COALESCE(usdt_received, 0),
+			fee, fee_currency, fee_usd
+		FROM crypto_transactions
+		WHERE user_id = $1 AND ticker <> 'USDT'
+		ORDER BY date ASC, created_at ASC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []models.CryptoTransaction
+	for rows.Next() {
+		var transaction models.CryptoTransaction
+		err := rows.Scan(
+			&transaction.ID, &transaction.Ticker, &transaction.CryptoName, &transaction.Amount,
+			&transaction.PurchasePrice, &transaction.Total, &transaction.Type, &transaction.Date,
+			&transaction.USDTReceived, &transaction.Fee, &transaction.FeeCurrency, &transaction.FeeUSD,
+		)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return services.MatchLots(transactions, method)
+}
+
+// GetRealizedPnL obtiene la ganancia realizada de cada venta hecha entre from y to, y aparte
+// los ingresos recibidos en ese período. Las fechas en cero no limitan el rango.
+func (r *PnLRepository) GetRealizedPnL(userID, method string, from, to time.Time) (*models.RealizedPnL, error) {
+	ledger, err := r.GetLedger(userID, method)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.RealizedPnL{
+		Method: ledger.Method,
+		Sales:  []models.RealizedGain{},
+		Income: []models.IncomeEvent{},
+	}
+	for _, sale := range ledger.Realized {
+		if (!from.IsZero() && sale.SoldAt.Before(from)) || (!to.IsZero() && !sale.SoldAt.Before(to)) {
+			continue
+		}
+		result.Sales = append(result.Sales, sale)
+		result.TotalProceeds = result.TotalProceeds.Add(sale.Proceeds)
+		result.TotalCostBasis = result.TotalCostBasis.Add(sale.CostBasis)
+		result.TotalGain = result.TotalGain.Add(sale.Gain)
+	}
+	for _, income := range ledger.Income {
+		if (!from.IsZero() && income.ReceivedAt.Before(from)) || (!to.IsZero() && !income.ReceivedAt.Before(to)) {
+			continue
+		}
+		result.Income = append(result.Income, income)
+		result.TotalIncome = result.TotalIncome.Add(income.Value)
+	}
+
+	return result, nil
+}
+
+// GetUnrealizedPnL valora los lotes abiertos del usuario con los precios actuales
+func (r *PnLRepository) GetUnrealizedPnL(userID, method string) (*models.UnrealizedPnL, error) {
+	ledger, err := r.GetLedger(userID, method)
+	if err != nil {
+		return nil, err
+	}
+
+	tickers := make([]string, 0)
+	for _, ticker := range ledger.Tickers() {
+		if amount, _ := ledger.Position(ticker); amount.IsPositive() {
+			tickers = append(tickers, ticker)
+		}
+	}
+
+	quotes := map[string]services.PriceQuote{}
+	if len(tickers) > 0 {
+		assetClasses, err := userAssetClasses(r.db, userID)
+		if err != nil {
+			return nil, err
+		}
+		assets := make(map[string]string, len(tickers))
+		for _, ticker := range tickers {
+			assets[ticker] = assetClasses[ticker]
+		}
+		quotes, err = services.GetAssetQuotes(r.priceProvider(), assets)
+		if err != nil {
+			log.Printf("Error al obtener precios para las ganancias no realizadas: %v", err)
+			quotes = map[string]services.PriceQuote{}
+		}
+	}
+
+	result := &models.UnrealizedPnL{
+		Method:    ledger.Method,
+		Positions: []models.UnrealizedPosition{},
+	}
+	for _, ticker := range tickers {
+		amount, costBasis := ledger.Position(ticker)
+		position := models.UnrealizedPosition{
+			Ticker:     ticker,
+			CryptoName: ledger.CryptoName(ticker),
+			Amount:     amount,
+			CostBasis:  costBasis,
+			AvgCost:    costBasis.Div(amount),
+			Lots:       ledger.OpenLots(ticker),
+		}
+
+		// Si no hay precio actual se valora al costo
+		position.CurrentPrice = position.AvgCost.InexactFloat64()
+		if quote, exists := quotes[ticker]; exists {
+			position.CurrentPrice = quote.Price
+		}
+		position.CurrentValue = amount.InexactFloat64() * position.CurrentPrice
+		position.Gain = position.CurrentValue - costBasis.InexactFloat64()
+		if costBasis.IsPositive() {
+			position.GainPercent = position.Gain / costBasis.InexactFloat64() * 100
+		}
+
+		result.Positions = append(result.Positions, position)
+		result.TotalCostBasis = result.TotalCostBasis.Add(costBasis)
+		result.TotalValue += position.CurrentValue
+		result.TotalGain += position.Gain
+	}
+	if result.TotalCostBasis.IsPositive() {
+		result.GainPercent = result.TotalGain / result.TotalCostBasis.InexactFloat64() * 100
+	}
+
+	return result, nil
+}
+
+// GetTaxReport arma el informe fiscal de un año. Las ventas se emparejan con todo el
+// historial de compras para que el costo base de lotes de años anteriores sea correcto.
+func (r *PnLRepository) GetTaxReport(userID, method string, year int) (*models.TaxReport, error) {
+	ledger, err := r.GetLedger(userID, method)
+	if err != nil {
+		return nil, err
+	}
+
+	report := services.BuildTaxReport(ledger, year)
+	return &report, nil
+}
@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+)
+
+// Errores al generar un ajuste de conciliación
+var (
+	ErrNoReportedBalance = errors.New("no hay un saldo informado para ese ticker en la cuenta")
+	ErrNoDiscrepancy     = errors.New("el saldo informado coincide con el calculado, no hace falta un ajuste")
+	ErrAlreadyAdjusted   = errors.New("ya se generó un ajuste para el último saldo informado")
+	ErrAdjustmentPrice   = errors.New("no hay precio histórico del ticker para el día del saldo informado, indica purchase_price")
+)
+
+// ReconciliationRepository guarda los saldos informados por las cuentas y los concilia con las transacciones
+type ReconciliationRepository struct {
+	db *sql.DB
+}
+
+// NewReconciliationRepository crea un nuevo repositorio de conciliaciones
+func NewReconciliationRepository(db *sql.DB) *ReconciliationRepository {
+	return &ReconciliationRepository{
+		db: db,
+	}
+}
+
+// reportedBalanceColumns son las columnas que se leen de reported_balances, en el orden de scanReportedBalance
+const reportedBalanceColumns = `id, user_id, account_id, ticker, amount, reported_at, source, COALESCE(note, ''),
+	COALESCE(adjustment_id, ''), created_at`
+
+// scanReportedBalance lee un saldo informado de una fila
+func scanReportedBalance(row rowScanner) (*models.ReportedBalance, error) {
+	var balance models.ReportedBalance
+	err := row.Scan(
+		&balance.ID, &balance.UserID, &balance.AccountID, &balance.Ticker, &balance.Amount, &balance.ReportedAt,
+		&balance.Source, &balance.Note, &balance.AdjustmentID, &balance.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
+
+// SaveReportedBalances guarda los saldos informados de una cuenta en una única transacción SQL
+func (r *ReconciliationRepository) SaveReportedBalances(balances []models.ReportedBalance) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	for _, balance := range balances {
+		_, err = tx.Exec(
+			`INSERT INTO reported_balances (id, user_id, account_id, ticker, amount, reported_at, source, note, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			balance.ID, balance.UserID, balance.AccountID, strings.ToUpper(balance.Ticker),
+			services.RoundAmount(balance.Ticker, balance.Amount), balance.ReportedAt, balance.Source, balance.Note,
+			balance.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetReportedBalances obtiene el historial de saldos informados de una cuenta, del más reciente al más antiguo
+func (r *ReconciliationRepository) GetReportedBalances(accountID string) ([]models.ReportedBalance, error) {
+	rows, err := r.db.Query(
+		`SELECT `+reportedBalanceColumns+` FROM reported_balances
+		WHERE account_id = $1 ORDER BY reported_at DESC, created_at DESC`,
+		accountID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanReportedBalances(rows)
+}
+
+// latestReportedBalances obtiene el último saldo informado de cada ticker de una cuenta
+func (r *ReconciliationRepository) latestReportedBalances(accountID string) ([]models.ReportedBalance, error) {
+	rows, err := r.db.Query(
+		`SELECT DISTINCT ON (ticker) `+reportedBalanceColumns+` FROM reported_balances
+		WHERE account_id = $1 ORDER BY ticker, reported_at DESC, created_at DESC`,
+		accountID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanReportedBalances(rows)
+}
+
+// scanReportedBalances lee todos los saldos informados de un resultado
+func scanReportedBalances(rows *sql.Rows) ([]models.ReportedBalance, error) {
+	balances := []models.ReportedBalance{}
+	for rows.Next() {
+		balance, err := scanReportedBalance(rows)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, *balance)
+	}
+	return balances, rows.Err()
+}
+
+// accountMovements obtiene las transacciones del usuario que mueven saldo de una cuenta
+func (r *ReconciliationRepository) accountMovements(userID, accountID string) ([]models.CryptoTransaction, error) {
+	rows, err := r.db.Query(`
+		SELECT ticker, type, amount, fee, fee_currency, COALESCE(account_id, ''), COALESCE(to_account_id, ''), date
+		FROM crypto_transactions
+		WHERE user_id = $1 AND (account_id = $2 OR to_account_id = $2)`,
+		userID, accountID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []models.CryptoTransaction
+	for rows.Next() {
+		var transaction models.CryptoTransaction
+		err := rows.Scan(
+			&transaction.Ticker, &transaction.Type, &transaction.Amount, &transaction.Fee,
+			&transaction.FeeCurrency, &transaction.AccountID, &transaction.ToAccountID, &transaction.Date,
+		)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
+
+// ReconcileAccount compara el último saldo informado de cada ticker de la cuenta con el calculado
+// a partir de las transacciones registradas hasta el momento de ese saldo
+func (r *ReconciliationRepository) ReconcileAccount(account models.Account) (*models.AccountReconciliation, error) {
+	reported, err := r.latestReportedBalances(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := r.accountMovements(account.UserID, account.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	reconciliation := &models.AccountReconciliation{
+		AccountID:   account.ID,
+		AccountName: account.Name,
+		Items:       make([]models.ReconciliationItem, 0, len(reported)),
+	}
+	for _, balance := range reported {
+		ledger := services.AccountBalanceAt(transactions, account.ID, balance.Ticker, balance.ReportedAt)
+		item := services.ReconcileBalance(balance, ledger)
+		if item.Discrepancy {
+			reconciliation.Discrepancies++
+		}
+		reconciliation.Items = append(reconciliation.Items, item)
+	}
+	return reconciliation, nil
+}
+
+// CreateAdjustment genera la transacción que ajusta el saldo calculado de un ticker de la cuenta
+// al último saldo informado, y la vincula con ese saldo para dejar constancia del ajuste.
+func (r *ReconciliationRepository) CreateAdjustment(account models.Account, request models.ReconciliationAdjustmentRequest) (*models.CryptoTransaction, *models.ReconciliationItem, error) {
+	ticker := strings.ToUpper(strings.TrimSpace(request.Ticker))
+	row := r.db.QueryRow(
+		`SELECT `+reportedBalanceColumns+` FROM reported_balances
+		WHERE account_id = $1 AND ticker = $2 ORDER BY reported_at DESC, created_at DESC LIMIT 1`,
+		account.ID, ticker,
+	)
+	balance, err := scanReportedBalance(row)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrNoReportedBalance
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if balance.AdjustmentID != "" {
+		// Si la transacción de ajuste se eliminó se puede volver a generar
+		var adjusted int
+		err := r.db.QueryRow(`SELECT COUNT(*) FROM crypto_transactions WHERE id = $1`, balance.AdjustmentID).Scan(&adjusted)
+		if err != nil {
+			return nil, nil, err
+		}
+		if adjusted > 0 {
+			return nil, nil, ErrAlreadyAdjusted
+		}
+	}
+
+	transactions, err := r.accountMovements(account.UserID, account.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	item := services.ReconcileBalance(*balance, services.AccountBalanceAt(transactions, account.ID, ticker, balance.ReportedAt))
+	if !item.Discrepancy {
+		return nil, nil, ErrNoDiscrepancy
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Las monedas registradas sin cuenta permiten ajustar un faltante con una transferencia
+	balances, err := userAccountBalances(tx, account.UserID, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	adjustment := services.ReconciliationAdjustment(item, account.ID, request.Note, balances[""][ticker])
+	adjustment.ID = models.GenerateUUID()
+	adjustment.UserID = account.UserID
+	adjustment.PurchasePrice = request.PurchasePrice
+	if err = r.describeAdjustment(&adjustment); err != nil {
+		return nil, nil, err
+	}
+
+	created, err := NewCryptoRepository(r.db).createTransactionTx(tx, adjustment, models.GenerateUUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Vincular el saldo solo si nadie lo ajustó desde que se leyó: otra solicitud concurrente
+	// pudo crear su propio ajuste después de la verificación anterior
+	result, err := tx.Exec(
+		`UPDATE reported_balances SET adjustment_id = $1
+		WHERE id = $2 AND COALESCE(adjustment_id, '') = $3`,
+		created.ID, balance.ID, balance.AdjustmentID,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return nil, nil, err
+	}
+	if updated == 0 {
+		err = ErrAlreadyAdjusted
+		return nil, nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	item.AdjustmentID = created.ID
+	return &created, &item, nil
+}
+
+// describeAdjustment completa el nombre y la clase de activo de una transacción de ajuste con los de
+// las transacciones ya registradas del ticker y, si no se indicó el precio, lo toma del cierre guardado
+// del día del saldo informado. Sin precio devuelve ErrAdjustmentPrice: el actual no sirve para un saldo pasado.
+func (r *ReconciliationRepository) describeAdjustment(adjustment *models.CryptoTransaction) error {
+	var imageURL sql.NullString
+	err := r.db.QueryRow(
+		`SELECT crypto_name, asset_class, image_url FROM crypto_transactions
+		WHERE user_id = $1 AND ticker = $2 ORDER BY date DESC LIMIT 1`,
+		adjustment.UserID, adjustment.Ticker,
+	).Scan(&adjustment.CryptoName, &adjustment.AssetClass, &imageURL)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	adjustment.ImageURL = imageURL.String
+	if adjustment.CryptoName == "" {
+		adjustment.CryptoName = adjustment.Ticker
+	}
+
+	if adjustment.PurchasePrice.IsPositive() {
+		return nil
+	}
+	price, ok := importHistoricalPrice(NewHistoricalPriceRepository(r.db), adjustment.Ticker, adjustment.Date)
+	if !ok {
+		return ErrAdjustmentPrice
+	}
+	adjustment.PurchasePrice = price
+	return nil
+}
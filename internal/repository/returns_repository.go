@@ -0,0 +1,298 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/shopspring/decimal"
+)
+
+// ReturnsRepository calcula los rendimientos ponderados por tiempo y por dinero del portafolio
+type ReturnsRepository struct {
+	db     *sql.DB
+	prices services.PriceProvider
+}
+
+// NewReturnsRepository crea un nuevo repositorio de rendimientos
+func NewReturnsRepository(db *sql.DB) *ReturnsRepository {
+	return &ReturnsRepository{
+		db: db,
+	}
+}
+
+// WithPrices devuelve una copia del repositorio que obtiene los precios del proveedor indicado
+func (r *ReturnsRepository) WithPrices(prices services.PriceProvider) *ReturnsRepository {
+	clone := *r
+	clone.prices = prices
+	return &clone
+}
+
+// priceProvider devuelve el proveedor de precios del repositorio o el global si no se indicó ninguno
+func (r *ReturnsRepository) priceProvider() services.PriceProvider {
+	if r.prices != nil {
+		return r.prices
+	}
+	return services.GetPriceProvider()
+}
+
+// returnValuation valora las tenencias en una fecha: con los precios actuales el día de hoy y,
+// los días anteriores, con el último cierre guardado o, si no lo hay, el último precio operado,
+// siempre que no tenga más de historicalCloseMaxAgeDays días
+type returnValuation struct {
+	series map[string]priceSeries
+	quotes map[string]services.PriceQuote
+	today  time.Time
+}
+
+// price devuelve el precio de un ticker al final del día indicado y si había un precio reciente
+func (v returnValuation) price(ticker string, day time.Time) (float64, bool) {
+	if ticker == "USDT" {
+		return 1, true
+	}
+	if !day.Before(v.today) {
+		if quote, exists := v.quotes[ticker]; exists && quote.Price > 0 {
+			return quote.Price, true
+		}
+	}
+	return v.series[ticker].asOf(day)
+}
+
+// values devuelve el valor de cada ticker y el total de las tenencias al final del día indicado.
+// Falla si algún ticker con tenencias no tiene un precio reciente ese día.
+func (v returnValuation) values(holdings map[string]decimal.Decimal, day time.Time) (map[string]float64, float64, error) {
+	values := make(map[string]float64, len(holdings))
+	var total float64
+	for ticker, amount := range holdings {
+		if !amount.IsPositive() {
+			continue
+		}
+		price, ok := v.price(ticker, day)
+		if !ok {
+			return nil, 0, missingPriceError(ticker, day)
+		}
+		values[ticker] = amount.InexactFloat64() * price
+		total += values[ticker]
+	}
+	return values, total, nil
+}
+
+// missingPriceError indica que no hay un precio reciente para valorar un ticker en un día
+func missingPriceError(ticker string, day time.Time) error {
+	return fmt.Errorf("no hay un precio de %s de los %d días previos al %s para valorarlo", ticker, historicalCloseMaxAgeDays, day.Format("2006-01-02"))
+}
+
+// GetReturns calcula los rendimientos del portafolio y de cada activo en el período indicado.
+// Las tenencias de cada día se reconstruyen con las transacciones y se valoran con los precios
+// históricos; el valor inicial del portafolio se toma del snapshot de ese día si lo hay y no hubo
+// operaciones, y el valor final con los precios actuales.
+func (r *ReturnsRepository) GetReturns(userID, period string) (*models.ReturnsReport, error) {
+	now := time.Now()
+	today := backtestDay(now)
+	report := &models.ReturnsReport{
+		Period: period,
+		From:   services.ReturnPeriodStart(period, now),
+		To:     now,
+		Assets: []models.AssetReturn{},
+	}
+
+	transactions, err := returnTransactions(r.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(transactions) == 0 {
+		return report, nil
+	}
+
+	// El período no empieza antes del día previo a la primera transacción
+	if firstDay := backtestDay(transactions[0].Date).AddDate(0, 0, -1); report.From.Before(firstDay) {
+		report.From = firstDay
+	}
+	from := report.From
+
+	valuation, err := r.valuation(transactions, from, today)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tenencias al final del día de inicio
+	holdings := make(map[string]decimal.Decimal)
+	next := 0
+	for next < len(transactions) && !backtestDay(transactions[next].Date).After(from) {
+		services.ApplyHoldingsChange(holdings, transactions[next])
+		next++
+	}
+	startValues, startTotal, err := valuation.values(holdings, from)
+	if err != nil {
+		return nil, err
+	}
+	if snapshotValue, ok := r.snapshotValue(userID, from, transactions); ok {
+		startTotal = snapshotValue
+	}
+
+	// Valor al final de cada día con aportes y al final del período
+	portfolioPoints := []services.ReturnPoint{}
+	assetPoints := make(map[string][]services.ReturnPoint)
+	for next < len(transactions) {
+		day := backtestDay(transactions[next].Date)
+		flows := make(map[string]float64)
+		for next < len(transactions) && backtestDay(transactions[next].Date).Equal(day) {
+			services.ApplyHoldingsChange(holdings, transactions[next])
+			if flow := services.TransactionCashFlow(transactions[next]); flow != 0 {
+				flows[strings.ToUpper(transactions[next].Ticker)] += flow
+			}
+			next++
+		}
+		if len(flows) == 0 {
+			continue
+		}
+
+		if day.After(today) {
+			day = today
+		}
+		values, total, err := valuation.values(holdings, day)
+		if err != nil {
+			return nil, err
+		}
+		var dayFlow float64
+		for ticker, flow := range flows {
+			dayFlow += flow
+			assetPoints[ticker] = append(assetPoints[ticker], services.ReturnPoint{Date: day, Value: values[ticker], Flow: flow})
+		}
+		portfolioPoints = append(portfolioPoints, services.ReturnPoint{Date: day, Value: total, Flow: dayFlow})
+	}
+
+	endValues, endTotal, err := valuation.values(holdings, today)
+	if err != nil {
+		return nil, err
+	}
+	if last := len(portfolioPoints) - 1; last < 0 || portfolioPoints[last].Date.Before(today) {
+		portfolioPoints = append(portfolioPoints, services.ReturnPoint{Date: today, Value: endTotal})
+	}
+	report.Portfolio = services.ComputeReturns(services.ReturnPoint{Date: from, Value: startTotal}, portfolioPoints)
+
+	// Rendimientos de cada activo con posición o aportes en el período
+	names := make(map[string]models.CryptoTransaction)
+	for _, transaction := range transactions {
+		names[strings.ToUpper(transaction.Ticker)] = transaction
+	}
+	for ticker, transaction := range names {
+		points := assetPoints[ticker]
+		if startValues[ticker] <= 0 && len(points) == 0 {
+			continue
+		}
+		if last := len(points) - 1; last < 0 || points[last].Date.Before(today) {
+			points = append(points, services.ReturnPoint{Date: today, Value: endValues[ticker]})
+		}
+		report.Assets = append(report.Assets, models.AssetReturn{
+			Ticker:        ticker,
+			CryptoName:    transaction.CryptoName,
+			AssetClass:    services.AssetClassOrDefault(transaction.AssetClass),
+			ReturnMetrics: services.ComputeReturns(services.ReturnPoint{Date: from, Value: startValues[ticker]}, points),
+		})
+	}
+	sort.Slice(report.Assets, func(i, j int) bool {
+		if report.Assets[i].EndValue != report.Assets[j].EndValue {
+			return report.Assets[i].EndValue > report.Assets[j].EndValue
+		}
+		return report.Assets[i].Ticker < report.Assets[j].Ticker
+	})
+
+	return report, nil
+}
+
+// returnTransactions obtiene las transacciones del usuario en orden cronológico con los datos
+// necesarios para reconstruir sus tenencias y sus aportes
+func returnTransactions(db *sql.DB, userID string) ([]models.CryptoTransaction, error) {
+	rows, err := db.Query(`
+		SELECT ticker, crypto_name, asset_class, type, amount, purchase_price, total, COALESCE(usdt_received, 0),
+			fee, fee_currency, fee_usd, date
+		FROM crypto_transactions
+		WHERE user_id = $1
+		ORDER BY date ASC, created_at ASC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []models.CryptoTransaction
+	for rows.Next() {
+		var transaction models.CryptoTransaction
+		err := rows.Scan(
+			&transaction.Ticker, &transaction.CryptoName, &transaction.AssetClass, &transaction.Type,
+			&transaction.Amount, &transaction.PurchasePrice, &transaction.Total, &transaction.USDTReceived,
+			&transaction.Fee, &transaction.FeeCurrency, &transaction.FeeUSD, &transaction.Date,
+		)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
+
+// valuation arma las series de precios de cada ticker con los precios operados y los cierres
+// guardados desde el inicio del período, y obtiene los precios actuales
+func (r *ReturnsRepository) valuation(transactions []models.CryptoTransaction, from, today time.Time) (returnValuation, error) {
+	valuation := returnValuation{
+		series: make(map[string]priceSeries),
+		today:  today,
+	}
+
+	assetClasses := make(map[string]string)
+	for _, transaction := range transactions {
+		ticker := strings.ToUpper(transaction.Ticker)
+		assetClasses[ticker] = transaction.AssetClass
+		if transaction.PurchasePrice.IsPositive() {
+			valuation.series[ticker] = append(valuation.series[ticker], models.HistoricalPrice{
+				Ticker: ticker,
+				Date:   backtestDay(transaction.Date),
+				Close:  transaction.PurchasePrice.InexactFloat64(),
+			})
+		}
+	}
+
+	history := NewHistoricalPriceRepository(r.db)
+	for ticker := range assetClasses {
+		closes, err := history.GetHistoricalPrices(ticker, from.Add(-backtestLookback), today)
+		if err != nil {
+			return valuation, err
+		}
+		// Los cierres van después de los precios operados para que asOf los prefiera el mismo día
+		series := append(valuation.series[ticker], closes...)
+		sort.SliceStable(series, func(i, j int) bool { return series[i].Date.Before(series[j].Date) })
+		valuation.series[ticker] = series
+	}
+
+	quotes, err := services.GetAssetQuotes(r.priceProvider(), assetClasses)
+	if err != nil {
+		// Sin precios actuales se valora con el último precio conocido
+		quotes = map[string]services.PriceQuote{}
+	}
+	valuation.quotes = quotes
+	return valuation, nil
+}
+
+// snapshotValue devuelve el valor del portafolio registrado en el snapshot de un día sin operaciones
+func (r *ReturnsRepository) snapshotValue(userID string, day time.Time, transactions []models.CryptoTransaction) (float64, bool) {
+	for _, transaction := range transactions {
+		if backtestDay(transaction.Date).Equal(day) {
+			return 0, false
+		}
+	}
+
+	var value float64
+	err := r.db.QueryRow(
+		`SELECT total_value FROM investment_snapshots
+		WHERE user_id = $1 AND date >= $2 AND date < $3
+		ORDER BY date DESC LIMIT 1`,
+		userID, day, day.AddDate(0, 0, 1),
+	).Scan(&value)
+	return value, err == nil && value > 0
+}
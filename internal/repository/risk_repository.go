@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+)
+
+// RiskRepository calcula las métricas de riesgo del portafolio con el historial de snapshots
+type RiskRepository struct {
+	db *sql.DB
+}
+
+// NewRiskRepository crea un nuevo repositorio de métricas de riesgo
+func NewRiskRepository(db *sql.DB) *RiskRepository {
+	return &RiskRepository{
+		db: db,
+	}
+}
+
+// GetRiskMetrics calcula las métricas de riesgo con los snapshots diarios del período. El rendimiento
+// de cada día descuenta los aportes y retiros registrados desde el snapshot anterior.
+// riskFreeRate es la tasa libre de riesgo anual en porcentaje.
+func (r *RiskRepository) GetRiskMetrics(userID, period string, riskFreeRate float64) (*models.RiskMetrics, error) {
+	now := time.Now()
+	from := services.ReturnPeriodStart(period, now)
+
+	snapshots, err := NewCryptoRepository(r.db).GetInvestmentSnapshotsWithMaxMin(userID, from)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		metrics := services.ComputeRiskMetrics(from, nil, riskFreeRate)
+		metrics.Period, metrics.From, metrics.To = period, from, now
+		return &metrics, nil
+	}
+
+	transactions, err := returnTransactions(r.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	points := snapshotReturnPoints(snapshots, transactions)
+	metrics := services.ComputeRiskMetrics(points[0].Date, services.FlowNeutralReturns(points), riskFreeRate)
+	metrics.Period = period
+	metrics.From = points[0].Date
+	metrics.To = points[len(points)-1].Date
+	return &metrics, nil
+}
+
+// snapshotReturnPoints convierte los snapshots en puntos de valor con los aportes netos de las
+// transacciones hechas desde el día del snapshot anterior hasta el día del snapshot
+func snapshotReturnPoints(snapshots []models.InvestmentSnapshot, transactions []models.CryptoTransaction) []services.ReturnPoint {
+	points := make([]services.ReturnPoint, 0, len(snapshots))
+	next := 0
+	for _, snapshot := range snapshots {
+		day := backtestDay(snapshot.Date)
+		var flow float64
+		for next < len(transactions) && !backtestDay(transactions[next].Date).After(day) {
+			flow += services.TransactionCashFlow(transactions[next])
+			next++
+		}
+		points = append(points, services.ReturnPoint{Date: day, Value: snapshot.TotalValue.InexactFloat64(), Flow: flow})
+	}
+	return points
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+)
+
+// SettingsRepository maneja las preferencias de cálculo de los usuarios
+type SettingsRepository struct {
+	db *sql.DB
+}
+
+// NewSettingsRepository crea un nuevo repositorio de preferencias
+func NewSettingsRepository(db *sql.DB) *SettingsRepository {
+	return &SettingsRepository{
+		db: db,
+	}
+}
+
+// GetSettings obtiene las preferencias de un usuario, con los valores por defecto si nunca las guardó
+func (r *SettingsRepository) GetSettings(userID string) (*models.UserSettings, error) {
+	settings := &models.UserSettings{UserID: userID}
+	err := r.db.QueryRow(
+		`SELECT cost_basis_method, base_currency, updated_at FROM user_settings WHERE user_id = $1`,
+		userID,
+	).Scan(&settings.CostBasisMethod, &settings.BaseCurrency, &settings.UpdatedAt)
+	if err == sql.ErrNoRows {
+		settings.CostBasisMethod = models.CostBasisAverage
+		settings.BaseCurrency = services.CurrencyUSD
+		return settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// SaveSettings guarda las preferencias de un usuario
+func (r *SettingsRepository) SaveSettings(settings models.UserSettings) error {
+	_, err := r.db.Exec(
+		`INSERT INTO user_settings (user_id, cost_basis_method, base_currency, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET
+			cost_basis_method = EXCLUDED.cost_basis_method,
+			base_currency = EXCLUDED.base_currency,
+			updated_at = EXCLUDED.updated_at`,
+		settings.UserID, settings.CostBasisMethod, settings.BaseCurrency, settings.UpdatedAt,
+	)
+	return err
+}
+
+// GetCostBasisMethod obtiene el método de costo base del usuario.
+// Si no se puede leer la preferencia se usa el costo promedio.
+func (r *SettingsRepository) GetCostBasisMethod(userID string) string {
+	settings, err := r.GetSettings(userID)
+	if err != nil {
+		log.Printf("Error al obtener las preferencias del usuario %s: %v", userID, err)
+		return models.CostBasisAverage
+	}
+	return settings.CostBasisMethod
+}
+
+// GetBaseCurrency obtiene la moneda en la que el usuario ve sus valoraciones.
+// Si no se puede leer la preferencia se usa el dólar.
+func (r *SettingsRepository) GetBaseCurrency(userID string) string {
+	settings, err := r.GetSettings(userID)
+	if err != nil {
+		log.Printf("Error al obtener las preferencias del usuario %s: %v", userID, err)
+		return services.CurrencyUSD
+	}
+	return settings.BaseCurrency
+}
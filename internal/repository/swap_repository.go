@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+)
+
+// ErrSwapLeg indica que se intentó editar por separado una de las patas de un intercambio
+var ErrSwapLeg = errors.New("la transacción es parte de un intercambio, edítala desde /swaps/:id")
+
+// CreateSwap registra atómicamente las dos patas de un intercambio
+func (r *CryptoRepository) CreateSwap(userID string, request models.SwapRequest) (*models.Swap, error) {
+	request = historicalSwapValue(NewHistoricalPriceRepository(r.db), request)
+	sell, buy, err := services.BuildSwapLegs(userID, models.GenerateUUID(), request)
+	if err != nil {
+		return nil, err
+	}
+	sell.ID = models.GenerateUUID()
+	buy.ID = models.GenerateUUID()
+
+	if err := r.saveSwapLegs(userID, "", sell, buy); err != nil {
+		return nil, err
+	}
+	return swapFromLegs(sell, buy), nil
+}
+
+// historicalSwapValue completa el valor en dólares de un intercambio sin value_usd con el cierre
+// guardado del día de la moneda recibida o, si no lo hay, de la entregada
+func historicalSwapValue(history *HistoricalPriceRepository, request models.SwapRequest) models.SwapRequest {
+	if request.ValueUSD.IsPositive() || request.Date.IsZero() {
+		return request
+	}
+	if price, ok := importHistoricalPrice(history, request.ToTicker, request.Date); ok {
+		request.ValueUSD = request.ToAmount.Mul(price)
+	} else if price, ok := importHistoricalPrice(history, request.FromTicker, request.Date); ok {
+		request.ValueUSD = request.FromAmount.Mul(price)
+	}
+	return request
+}
+
+// GetSwap obtiene un intercambio a partir de sus dos patas
+func (r *CryptoRepository) GetSwap(userID, swapID string) (*models.Swap, error) {
+	rows, err := r.db.Query(`
+		SELECT id, user_id, crypto_name, ticker, amount, purchase_price,
+			   total, date, COALESCE(note, ''), created_at, type, usdt_received, COALESCE(image_url, ''),
+			   fee, fee_currency, fee_usd, COALESCE(swap_id, ''), asset_class,
+			   COALESCE(account_id, ''), COALESCE(to_account_id, '')
+		FROM crypto_transactions
+		WHERE swap_id = $1 AND user_id = $2`,
+		swapID, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sell, buy *models.CryptoTransaction
+	for rows.Next() {
+		var leg models.CryptoTransaction
+		err := rows.Scan(
+			&leg.ID, &leg.UserID, &leg.CryptoName, &leg.Ticker, &leg.Amount, &leg.PurchasePrice,
+			&leg.Total, &leg.Date, &leg.Note, &leg.CreatedAt, &leg.Type, &leg.USDTReceived, &leg.ImageURL,
+			&leg.Fee, &leg.FeeCurrency, &leg.FeeUSD, &leg.SwapID, &leg.AssetClass,
+			&leg.AccountID, &leg.ToAccountID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if leg.Type == models.TransactionTypeSell {
+			sell = &leg
+		} else {
+			buy = &leg
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if sell == nil || buy == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	return swapFromLegs(*sell, *buy), nil
+}
+
+// UpdateSwap reemplaza las dos patas de un intercambio conservando sus identificadores
+func (r *CryptoRepository) UpdateSwap(userID, swapID string, request models.SwapRequest) (*models.Swap, error) {
+	existing, err := r.GetSwap(userID, swapID)
+	if err != nil {
+		return nil, err
+	}
+
+	request = historicalSwapValue(NewHistoricalPriceRepository(r.db), request)
+	sell, buy, err := services.BuildSwapLegs(userID, swapID, request)
+	if err != nil {
+		return nil, err
+	}
+	sell.ID, sell.CreatedAt = existing.From.ID, existing.From.CreatedAt
+	buy.ID, buy.CreatedAt = existing.To.ID, existing.To.CreatedAt
+
+	if err := r.saveSwapLegs(userID, swapID, sell, buy); err != nil {
+		return nil, err
+	}
+	return swapFromLegs(sell, buy), nil
+}
+
+// DeleteSwap elimina las dos patas de un intercambio
+func (r *CryptoRepository) DeleteSwap(userID, swapID string) error {
+	result, err := r.db.Exec(`DELETE FROM crypto_transactions WHERE swap_id = $1 AND user_id = $2`, swapID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// saveSwapLegs inserta las patas de un intercambio en una única transacción SQL,
+// reemplazando antes las del intercambio replacedSwapID si se indica
+func (r *CryptoRepository) saveSwapLegs(userID, replacedSwapID string, sell, buy models.CryptoTransaction) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if replacedSwapID != "" {
+		if _, err = tx.Exec(`DELETE FROM crypto_transactions WHERE swap_id = $1 AND user_id = $2`, replacedSwapID, userID); err != nil {
+			return err
+		}
+	}
+
+	if err = checkTransactionAccounts(tx, sell); err != nil {
+		return err
+	}
+
+	// Verificar que haya saldo suficiente de la moneda entregada
+	amountToSell := sell.Amount
+	if services.IsTradedCoinFee(sell) {
+		amountToSell = amountToSell.Add(sell.Fee)
+	}
+	if err = r.holdingsRepo.UpdateHoldingsAfterSale(tx, userID, sell.Ticker, "", amountToSell); err != nil {
+		return err
+	}
+
+	if err = insertTransaction(tx, sell); err != nil {
+		return err
+	}
+	err = insertTransaction(tx, buy)
+	return err
+}
+
+// swapFromLegs arma la vista de un intercambio a partir de sus patas
+func swapFromLegs(sell, buy models.CryptoTransaction) *models.Swap {
+	return &models.Swap{
+		ID:       sell.SwapID,
+		Type:     models.TransactionTypeSwap,
+		Date:     sell.Date,
+		Note:     sell.Note,
+		ValueUSD: buy.Total,
+		From:     sell,
+		To:       buy,
+	}
+}
+
+// collapseSwapLegs muestra cada intercambio como una sola operación: la pata de venta
+// lleva la compra en swap_with y la pata de compra se omite del listado
+func collapseSwapLegs(details []models.TransactionDetails) []models.TransactionDetails {
+	buyLegs := make(map[string]models.CryptoTransaction)
+	sellLegs := make(map[string]bool)
+	for _, detail := range details {
+		if detail.Transaction.SwapID == "" {
+			continue
+		}
+		if detail.Transaction.Type == models.TransactionTypeSell {
+			sellLegs[detail.Transaction.SwapID] = true
+		} else {
+			buyLegs[detail.Transaction.SwapID] = detail.Transaction
+		}
+	}
+
+	collapsed := make([]models.TransactionDetails, 0, len(details))
+	for _, detail := range details {
+		swapID := detail.Transaction.SwapID
+		if swapID != "" {
+			if detail.Transaction.Type == models.TransactionTypeSell {
+				if buy, exists := buyLegs[swapID]; exists {
+					detail.SwapWith = &buy
+				}
+			} else if sellLegs[swapID] {
+				continue
+			}
+		}
+		collapsed = append(collapsed, detail)
+	}
+	return collapsed
+}
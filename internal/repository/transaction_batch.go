@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+)
+
+// BatchOperationError indica qué operación de un lote falló; el lote completo se descarta
+type BatchOperationError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchOperationError) Error() string {
+	return fmt.Sprintf("operación %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchOperationError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyTransactionBatch aplica las operaciones de un lote en orden dentro de una única transacción SQL:
+// o se aplican todas o ninguna. Cada operación ve el resultado de las anteriores (por ejemplo,
+// una venta puede usar el saldo de una compra creada antes en el mismo lote).
+func (r *CryptoRepository) ApplyTransactionBatch(userID string, operations []models.TransactionBatchOperation) (results []models.TransactionBatchResult, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			results = nil
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	results = make([]models.TransactionBatchResult, 0, len(operations))
+	for index, operation := range operations {
+		result := models.TransactionBatchResult{Index: index, Action: operation.Action, ID: operation.ID}
+
+		switch operation.Action {
+		case models.BatchActionCreate:
+			transaction := *operation.Transaction
+			transaction.ID = models.GenerateUUID()
+			transaction.UserID = userID
+			transaction.SwapID = ""
+			created, opErr := r.createTransactionTx(tx, transaction, models.GenerateUUID)
+			if opErr != nil {
+				return nil, &BatchOperationError{Index: index, Err: opErr}
+			}
+			result.ID = created.ID
+			result.Transaction = &created
+
+		case models.BatchActionUpdate:
+			transaction := *operation.Transaction
+			transaction.ID = operation.ID
+			transaction.UserID = userID
+			updated, opErr := r.updateTransactionTx(tx, transaction)
+			if opErr != nil {
+				return nil, &BatchOperationError{Index: index, Err: opErr}
+			}
+			result.Transaction = &updated
+
+		case models.BatchActionDelete:
+			deleted, opErr := deleteTransactionTx(tx, userID, operation.ID)
+			if opErr != nil {
+				return nil, &BatchOperationError{Index: index, Err: opErr}
+			}
+			result.DeletedIDs = deleted
+
+		default:
+			return nil, &BatchOperationError{Index: index, Err: fmt.Errorf("acción desconocida: %s", operation.Action)}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
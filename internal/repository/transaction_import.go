@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/shopspring/decimal"
+)
+
+// ErrImportInvalidRows indica que la importación se canceló porque el archivo tiene filas inválidas
+var ErrImportInvalidRows = errors.New("el archivo tiene filas inválidas, corrígelas o usa skip_invalid=true para importar solo las válidas")
+
+// TransactionImportOptions controla cómo se aplica una importación
+type TransactionImportOptions struct {
+	DryRun      bool // Solo devolver la vista previa, sin guardar nada
+	SkipInvalid bool // Importar las filas válidas aunque otras tengan errores
+}
+
+// ImportTransactions guarda las operaciones leídas de un CSV en una única transacción SQL.
+// Las filas que ya existen (mismo ticker, tipo, fecha y cantidad) se informan como duplicadas y no se guardan.
+// No se verifica el saldo de las ventas: los historiales de los exchanges no incluyen los depósitos,
+// así que una venta sin compras registradas queda con costo base cero en el cálculo por lotes.
+func (r *CryptoRepository) ImportTransactions(userID string, parsed *services.ParsedImport, options TransactionImportOptions) (*models.TransactionImportResult, error) {
+	result := &models.TransactionImportResult{
+		Format:       parsed.Format,
+		DryRun:       options.DryRun,
+		Transactions: []models.CryptoTransaction{},
+		Duplicates:   []models.ImportRowIssue{},
+		Skipped:      append([]models.ImportRowIssue{}, parsed.Skipped...),
+		Errors:       append([]models.ImportRowIssue{}, parsed.Errors...),
+	}
+
+	existing, err := r.transactionKeyCounts(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]models.ImportRow, len(parsed.Rows))
+	copy(rows, parsed.Rows)
+	sort.SliceStable(rows, func(i, j int) bool { return importRowDate(rows[i]).Before(importRowDate(rows[j])) })
+
+	nextID := models.GenerateUUID
+	history := NewHistoricalPriceRepository(r.db)
+	for _, row := range rows {
+		legs, err := importRowLegs(userID, row, nextID, history)
+		if err != nil {
+			result.Errors = append(result.Errors, models.ImportRowIssue{Line: row.Line, Reason: err.Error()})
+			continue
+		}
+
+		// Cada transacción existente solo puede marcar como duplicada a una fila del archivo
+		key := transactionKey(legs[0])
+		if existing[key] > 0 {
+			existing[key]--
+			result.Duplicates = append(result.Duplicates, models.ImportRowIssue{Line: row.Line, Reason: "la transacción ya existe"})
+			continue
+		}
+
+		result.Rows++
+		result.Transactions = append(result.Transactions, legs...)
+	}
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Line < result.Errors[j].Line })
+	sort.SliceStable(result.Duplicates, func(i, j int) bool { return result.Duplicates[i].Line < result.Duplicates[j].Line })
+
+	if len(result.Errors) > 0 && !options.SkipInvalid {
+		return result, ErrImportInvalidRows
+	}
+	if options.DryRun || len(result.Transactions) == 0 {
+		return result, nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	for _, transaction := range result.Transactions {
+		if err := insertTransaction(tx, transaction); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("error al guardar la transacción de %s del %s: %v", transaction.Ticker, transaction.Date.Format("2006-01-02"), err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	result.Imported = len(result.Transactions)
+	return result, nil
+}
+
+// importRowLegs arma las transacciones de una fila importada: una compra o venta (más la compra
+// automática de USDT si se vendió por USDT) o las dos patas de un intercambio. Las comisiones y los
+// intercambios sin valor en dólares se valoran con el cierre guardado del día; si no lo hay la fila
+// es inválida, para no valorar operaciones pasadas con el precio actual.
+func importRowLegs(userID string, row models.ImportRow, nextID func() string, history *HistoricalPriceRepository) ([]models.CryptoTransaction, error) {
+	now := time.Now()
+
+	if row.Swap != nil {
+		request := historicalSwapValue(history, *row.Swap)
+		if !request.ValueUSD.IsPositive() {
+			return nil, fmt.Errorf("no hay precio histórico de %s ni de %s para el %s, indica el valor en dólares del intercambio (total)",
+				strings.ToUpper(request.ToTicker), strings.ToUpper(request.FromTicker), request.Date.Format("2006-01-02"))
+		}
+		feeCurrency := strings.ToUpper(strings.TrimSpace(request.FeeCurrency))
+		if request.Fee.IsPositive() && !request.FeeUSD.IsPositive() && feeCurrency != "" && feeCurrency != models.FeeCurrencyUSD && feeCurrency != "USDT" &&
+			!strings.EqualFold(feeCurrency, request.FromTicker) && !strings.EqualFold(feeCurrency, request.ToTicker) {
+			price, ok := importHistoricalPrice(history, feeCurrency, request.Date)
+			if !ok {
+				return nil, importFeePriceError(feeCurrency, request.Date)
+			}
+			request.FeeUSD = request.Fee.Mul(price)
+		}
+
+		sell, buy, err := services.BuildSwapLegs(userID, nextID(), request)
+		if err != nil {
+			return nil, err
+		}
+		sell.ID, sell.CreatedAt = nextID(), now
+		buy.ID, buy.CreatedAt = nextID(), now
+		return []models.CryptoTransaction{sell, buy}, nil
+	}
+
+	if row.Transaction == nil {
+		return nil, fmt.Errorf("fila sin operación")
+	}
+	transaction := *row.Transaction
+	transaction.ID = nextID()
+	transaction.UserID = userID
+	transaction.CreatedAt = now
+	if transaction.CryptoName == "" {
+		transaction.CryptoName = transaction.Ticker
+	}
+	transaction.FeeCurrency = strings.ToUpper(strings.TrimSpace(transaction.FeeCurrency))
+	if services.IsOtherCoinFee(transaction) && !transaction.FeeUSD.IsPositive() {
+		price, ok := importHistoricalPrice(history, transaction.FeeCurrency, transaction.Date)
+		if !ok {
+			return nil, importFeePriceError(transaction.FeeCurrency, transaction.Date)
+		}
+		transaction.FeeUSD = transaction.Fee.Mul(price)
+	}
+	if err := services.ResolveTransactionFee(&transaction); err != nil {
+		return nil, err
+	}
+
+	if transaction.Type == models.TransactionTypeSell && transaction.USDTReceived.IsPositive() {
+		transaction.SwapID = nextID()
+		usdtTransaction := usdtPurchaseLeg(transaction)
+		usdtTransaction.ID = nextID()
+		return []models.CryptoTransaction{transaction, usdtTransaction}, nil
+	}
+	return []models.CryptoTransaction{transaction}, nil
+}
+
+// importFeePriceError es el error de una fila con comisión en otra moneda sin cierre guardado para su fecha
+func importFeePriceError(feeCurrency string, date time.Time) error {
+	return fmt.Errorf("no hay precio histórico de %s para el %s, indica fee_usd", feeCurrency, date.Format("2006-01-02"))
+}
+
+// importHistoricalPrice busca el cierre guardado de un ticker para la fecha de una operación
+func importHistoricalPrice(history *HistoricalPriceRepository, ticker string, date time.Time) (decimal.Decimal, bool) {
+	price, err := history.GetCloseOnOrBefore(ticker, date)
+	return decimal.NewFromFloat(price), err == nil && price > 0
+}
+
+// importRowDate devuelve la fecha de la operación de una fila
+func importRowDate(row models.ImportRow) time.Time {
+	if row.Swap != nil {
+		return row.Swap.Date
+	}
+	if row.Transaction != nil {
+		return row.Transaction.Date
+	}
+	return time.Time{}
+}
+
+// transactionKey identifica una transacción para detectar importaciones repetidas
+func transactionKey(transaction models.CryptoTransaction) string {
+	return fmt.Sprintf("%s|%s|%d|%s",
+		strings.ToUpper(transaction.Ticker), transaction.Type, transaction.Date.UTC().Unix(), transaction.Amount.StringFixed(8))
+}
+
+// transactionKeyCounts cuenta las transacciones existentes del usuario por clave de duplicado
+func (r *CryptoRepository) transactionKeyCounts(userID string) (map[string]int, error) {
+	rows, err := r.db.Query(`SELECT ticker, type, date, amount FROM crypto_transactions WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var transaction models.CryptoTransaction
+		if err := rows.Scan(&transaction.Ticker, &transaction.Type, &transaction.Date, &transaction.Amount); err != nil {
+			return nil, err
+		}
+		counts[transactionKey(transaction)]++
+	}
+	return counts, rows.Err()
+}
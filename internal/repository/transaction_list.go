@@ -0,0 +1,397 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/services"
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidCursor indica que el cursor de paginación no es válido para el orden pedido
+var ErrInvalidCursor = errors.New("cursor inválido")
+
+// transactionListColumns son las columnas que se leen en el listado, en el orden de scanListedTransaction
+const transactionListColumns = `t.id, t.user_id, t.crypto_name, t.ticker, t.amount, t.purchase_price,
+	t.total, t.date, COALESCE(t.note, ''), t.created_at, t.type, t.usdt_received, COALESCE(t.image_url, ''),
+	t.fee, t.fee_currency, t.fee_usd, COALESCE(t.swap_id, ''), t.asset_class,
+	COALESCE(t.account_id, ''), COALESCE(t.to_account_id, '')`
+
+// transactionCursor es la posición de la última transacción de una página: el valor del campo
+// de orden y el ID, que desempata para que el orden sea estable
+type transactionCursor struct {
+	SortBy     string `json:"s"`
+	Descending bool   `json:"d"`
+	Value      string `json:"v"`
+	ID         string `json:"id"`
+}
+
+// encode convierte el cursor en una cadena opaca para el cliente
+func (c transactionCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeTransactionCursor lee un cursor y verifica que corresponda al orden pedido
+func decodeTransactionCursor(raw string, query models.TransactionQuery) (*transactionCursor, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor transactionCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.SortBy != query.SortBy || cursor.Descending != query.Descending {
+		return nil, fmt.Errorf("%w: corresponde a otro orden", ErrInvalidCursor)
+	}
+	return &cursor, nil
+}
+
+// ListTransactions obtiene una página de transacciones filtradas y ordenadas. Los intercambios se
+// muestran como una sola fila (la venta, con la compra en swap_with). Al ordenar por fecha o total
+// la página se pide directamente a la base de datos; al ordenar por ganancia hay que calcularla
+// con el precio actual, así que se ordenan en memoria todas las transacciones que cumplen los filtros.
+func (r *CryptoRepository) ListTransactions(userID string, query models.TransactionQuery) (*models.TransactionPage, error) {
+	if query.SortBy == "" {
+		query.SortBy = models.TransactionSortDate
+	}
+	if query.Limit <= 0 {
+		query.Limit = models.DefaultTransactionPageSize
+	}
+	if query.Limit > models.MaxTransactionPageSize {
+		query.Limit = models.MaxTransactionPageSize
+	}
+	cursor, err := decodeTransactionCursor(query.Cursor, query)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions, args := transactionListFilters(userID, query)
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	direction, comparison := "ASC", ">"
+	if query.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	var details []models.TransactionDetails
+	switch query.SortBy {
+	case models.TransactionSortDate, models.TransactionSortTotal:
+		column := "t.date"
+		if query.SortBy == models.TransactionSortTotal {
+			column = "t.total"
+		}
+		if cursor != nil {
+			value, err := cursorSortValue(*cursor)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, fmt.Sprintf("(%s, t.id) %s (%s, %s)", column, comparison, arg(value), arg(cursor.ID)))
+		}
+		sqlQuery := fmt.Sprintf(
+			"SELECT %s FROM crypto_transactions t WHERE %s ORDER BY %s %s, t.id %s LIMIT %s",
+			transactionListColumns, strings.Join(conditions, " AND "), column, direction, direction, arg(query.Limit+1),
+		)
+		transactions, err := r.queryListedTransactions(sqlQuery, args...)
+		if err != nil {
+			return nil, err
+		}
+		if details, err = r.listedTransactionDetails(userID, transactions); err != nil {
+			return nil, err
+		}
+
+	case models.TransactionSortGain:
+		sqlQuery := fmt.Sprintf("SELECT %s FROM crypto_transactions t WHERE %s", transactionListColumns, strings.Join(conditions, " AND "))
+		transactions, err := r.queryListedTransactions(sqlQuery, args...)
+		if err != nil {
+			return nil, err
+		}
+		if details, err = r.listedTransactionDetails(userID, transactions); err != nil {
+			return nil, err
+		}
+		sort.SliceStable(details, func(i, j int) bool {
+			return gainPrecedes(details[i], details[j], query.Descending)
+		})
+		if cursor != nil {
+			gain, err := strconv.ParseFloat(cursor.Value, 64)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			position := models.TransactionDetails{GainLoss: gain, Transaction: models.CryptoTransaction{ID: cursor.ID}}
+			start := sort.Search(len(details), func(i int) bool { return gainPrecedes(position, details[i], query.Descending) })
+			details = details[start:]
+		}
+		if len(details) > query.Limit+1 {
+			details = details[:query.Limit+1]
+		}
+
+	default:
+		return nil, fmt.Errorf("orden inválido %q, debe ser date, total o gain", query.SortBy)
+	}
+
+	page := &models.TransactionPage{
+		Transactions: details,
+		SortBy:       query.SortBy,
+		Order:        "asc",
+		Limit:        query.Limit,
+	}
+	if query.Descending {
+		page.Order = "desc"
+	}
+	if len(details) > query.Limit {
+		page.HasMore = true
+		page.Transactions = details[:query.Limit]
+		page.NextCursor = pageCursor(page.Transactions[query.Limit-1], query).encode()
+	}
+
+	if page.Transactions, err = r.attachSwapLegs(userID, page.Transactions); err != nil {
+		return nil, err
+	}
+	if page.Transactions == nil {
+		page.Transactions = []models.TransactionDetails{}
+	}
+	return page, nil
+}
+
+// listedTransactionDetails valora las transacciones del listado. El costo promedio de las ventas
+// se calcula en una sola pasada sobre el historial de sus tickers en lugar de consultarlo por venta.
+func (r *CryptoRepository) listedTransactionDetails(userID string, transactions []models.CryptoTransaction) ([]models.TransactionDetails, error) {
+	saleCosts, err := r.saleAverageCosts(userID, transactions)
+	if err != nil {
+		return nil, err
+	}
+	quotes := r.transactionQuotes(transactions)
+	details := make([]models.TransactionDetails, 0, len(transactions))
+	for _, tx := range transactions {
+		details = append(details, r.transactionDetail(tx, quotes, saleCosts))
+	}
+	return details, nil
+}
+
+// saleAverageCosts empareja con costo promedio el historial de los tickers vendidos en la lista y
+// devuelve el costo por unidad de cada venta (ID de la transacción → costo)
+func (r *CryptoRepository) saleAverageCosts(userID string, transactions []models.CryptoTransaction) (map[string]float64, error) {
+	args := []interface{}{userID}
+	seen := make(map[string]bool)
+	var placeholders []string
+	for _, tx := range transactions {
+		ticker := strings.ToUpper(tx.Ticker)
+		if tx.Type != models.TransactionTypeSell || seen[ticker] {
+			continue
+		}
+		seen[ticker] = true
+		args = append(args, ticker)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+	saleCosts := make(map[string]float64)
+	if len(placeholders) == 0 {
+		return saleCosts, nil
+	}
+
+	// Las comisiones pagadas con un ticker vendido también consumen sus lotes
+	in := strings.Join(placeholders, ", ")
+	history, err := r.queryListedTransactions(fmt.Sprintf(
+		"SELECT %s FROM crypto_transactions t WHERE t.user_id = $1 AND (UPPER(t.ticker) IN (%s) OR UPPER(t.fee_currency) IN (%s))",
+		transactionListColumns, in, in,
+	), args...)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := services.MatchLots(history, models.CostBasisAverage)
+	if err != nil {
+		return nil, err
+	}
+	for _, sale := range ledger.Realized {
+		if !sale.FeeDisposal && sale.Amount.IsPositive() {
+			saleCosts[sale.TransactionID] = sale.CostBasis.Div(sale.Amount).InexactFloat64()
+		}
+	}
+	return saleCosts, nil
+}
+
+// transactionListFilters arma las condiciones SQL de los filtros del listado
+func transactionListFilters(userID string, query models.TransactionQuery) ([]string, []interface{}) {
+	args := []interface{}{userID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{
+		"t.user_id = $1",
+		// La compra de un intercambio se muestra dentro de su venta
+		`NOT (t.type = 'compra' AND t.swap_id IS NOT NULL AND EXISTS (
+			SELECT 1 FROM crypto_transactions s WHERE s.swap_id = t.swap_id AND s.user_id = t.user_id AND s.type = 'venta'))`,
+	}
+
+	if len(query.Tickers) > 0 {
+		placeholders := make([]string, 0, len(query.Tickers))
+		for _, ticker := range query.Tickers {
+			placeholders = append(placeholders, arg(strings.ToUpper(ticker)))
+		}
+		in := strings.Join(placeholders, ", ")
+		// Un intercambio aparece al filtrar por cualquiera de sus dos criptomonedas
+		conditions = append(conditions, fmt.Sprintf(`(UPPER(t.ticker) IN (%s) OR (t.swap_id IS NOT NULL AND EXISTS (
+			SELECT 1 FROM crypto_transactions s WHERE s.swap_id = t.swap_id AND s.user_id = t.user_id AND UPPER(s.ticker) IN (%s))))`, in, in))
+	}
+	switch query.Type {
+	case "":
+	case models.TransactionTypeSwap:
+		conditions = append(conditions, "t.swap_id IS NOT NULL")
+	case models.TransactionTypeIncome:
+		placeholders := make([]string, 0, len(services.IncomeTransactionTypes))
+		for _, incomeType := range services.IncomeTransactionTypes {
+			placeholders = append(placeholders, arg(incomeType))
+		}
+		conditions = append(conditions, fmt.Sprintf("t.type IN (%s)", strings.Join(placeholders, ", ")))
+	default:
+		conditions = append(conditions, "t.type = "+arg(query.Type))
+	}
+	if query.AccountID != "" {
+		account := arg(query.AccountID)
+		conditions = append(conditions, fmt.Sprintf("(t.account_id = %s OR t.to_account_id = %s)", account, account))
+	}
+	if !query.From.IsZero() {
+		conditions = append(conditions, "t.date >= "+arg(query.From))
+	}
+	if !query.To.IsZero() {
+		conditions = append(conditions, "t.date < "+arg(query.To))
+	}
+	if query.MinTotal != nil {
+		conditions = append(conditions, "t.total >= "+arg(*query.MinTotal))
+	}
+	if query.MaxTotal != nil {
+		conditions = append(conditions, "t.total <= "+arg(*query.MaxTotal))
+	}
+	if search := strings.TrimSpace(query.Search); search != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(search)
+		conditions = append(conditions, "t.note ILIKE "+arg("%"+escaped+"%"))
+	}
+
+	return conditions, args
+}
+
+// queryListedTransactions ejecuta una consulta del listado y lee sus transacciones
+func (r *CryptoRepository) queryListedTransactions(query string, args ...interface{}) ([]models.CryptoTransaction, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []models.CryptoTransaction
+	for rows.Next() {
+		tx, err := scanListedTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, rows.Err()
+}
+
+// scanListedTransaction lee una transacción con las columnas de transactionListColumns
+func scanListedTransaction(row rowScanner) (models.CryptoTransaction, error) {
+	var tx models.CryptoTransaction
+	err := row.Scan(
+		&tx.ID, &tx.UserID, &tx.CryptoName, &tx.Ticker, &tx.Amount, &tx.PurchasePrice,
+		&tx.Total, &tx.Date, &tx.Note, &tx.CreatedAt, &tx.Type, &tx.USDTReceived, &tx.ImageURL,
+		&tx.Fee, &tx.FeeCurrency, &tx.FeeUSD, &tx.SwapID, &tx.AssetClass, &tx.AccountID, &tx.ToAccountID,
+	)
+	return tx, err
+}
+
+// attachSwapLegs agrega a las ventas de intercambios de la página la compra correspondiente en swap_with
+func (r *CryptoRepository) attachSwapLegs(userID string, details []models.TransactionDetails) ([]models.TransactionDetails, error) {
+	args := []interface{}{userID}
+	var placeholders []string
+	for _, detail := range details {
+		if detail.Transaction.SwapID != "" && detail.Transaction.Type == models.TransactionTypeSell {
+			args = append(args, detail.Transaction.SwapID)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		}
+	}
+	if len(placeholders) == 0 {
+		return details, nil
+	}
+
+	legs, err := r.queryListedTransactions(fmt.Sprintf(
+		"SELECT %s FROM crypto_transactions t WHERE t.user_id = $1 AND t.type = 'compra' AND t.swap_id IN (%s)",
+		transactionListColumns, strings.Join(placeholders, ", "),
+	), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	withLegs := make([]models.TransactionDetails, 0, len(details)+len(legs))
+	withLegs = append(withLegs, details...)
+	for _, leg := range legs {
+		withLegs = append(withLegs, models.TransactionDetails{Transaction: leg})
+	}
+	return collapseSwapLegs(withLegs), nil
+}
+
+// gainPrecedes indica si a va antes que b al ordenar por ganancia, desempatando por ID
+func gainPrecedes(a, b models.TransactionDetails, descending bool) bool {
+	if a.GainLoss != b.GainLoss {
+		if descending {
+			return a.GainLoss > b.GainLoss
+		}
+		return a.GainLoss < b.GainLoss
+	}
+	if descending {
+		return a.Transaction.ID > b.Transaction.ID
+	}
+	return a.Transaction.ID < b.Transaction.ID
+}
+
+// pageCursor arma el cursor que apunta a la última transacción de una página
+func pageCursor(last models.TransactionDetails, query models.TransactionQuery) transactionCursor {
+	cursor := transactionCursor{SortBy: query.SortBy, Descending: query.Descending, ID: last.Transaction.ID}
+	switch query.SortBy {
+	case models.TransactionSortDate:
+		cursor.Value = last.Transaction.Date.Format(time.RFC3339Nano)
+	case models.TransactionSortTotal:
+		cursor.Value = last.Transaction.Total.String()
+	case models.TransactionSortGain:
+		cursor.Value = strconv.FormatFloat(last.GainLoss, 'g', -1, 64)
+	}
+	return cursor
+}
+
+// cursorSortValue convierte el valor de un cursor al tipo de la columna de orden
+func cursorSortValue(cursor transactionCursor) (interface{}, error) {
+	if cursor.SortBy == models.TransactionSortDate {
+		date, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return date, nil
+	}
+	if cursor.SortBy == models.TransactionSortTotal {
+		total, err := decimal.NewFromString(cursor.Value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return total, nil
+	}
+	value, err := strconv.ParseFloat(cursor.Value, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return value, nil
+}
@@ -31,15 +31,19 @@ func RegisterRoutes(router *gin.Engine) {
 
 
 	protected := router.Group("/")
-	protected.Use(middleware.SimpleAPIKeyMiddleware())
+	protected.Use(middleware.SimpleAPIKeyMiddleware(), middleware.PriceBatchMiddleware())
 	{
+		// Las rutas que modifican transacciones y bolsas aceptan la cabecera Idempotency-Key
+		idempotent := middleware.IdempotencyMiddleware()
 
-		protected.POST("/transactions", middleware.CreateTransaction)
+		protected.POST("/transactions", idempotent, middleware.CreateTransaction)
+		protected.POST("/transactions/batch", idempotent, middleware.ApplyTransactionBatch)
+		protected.POST("/transactions/import", idempotent, middleware.ImportTransactions)
 		protected.GET("/transactions", middleware.GetUserTransactions)
 		protected.GET("/transactions/:id", middleware.GetTransactionDetails)
-		protected.PUT("/transactions/:id", middleware.UpdateTransaction)
-		protected.DELETE("/transactions/:id", middleware.DeleteTransaction)
-		protected.DELETE("/transactions/ticker/:ticker", middleware.DeleteTransactionsByTicker)
+		protected.PUT("/transactions/:id", idempotent, middleware.UpdateTransaction)
+		protected.DELETE("/transactions/:id", idempotent, middleware.DeleteTransaction)
+		protected.DELETE("/transactions/ticker/:ticker", idempotent, middleware.DeleteTransactionsByTicker)
 		protected.GET("/recent-transactions", middleware.GetRecentTransactions)
 		protected.GET("/dashboard", middleware.GetDashboard)
 		protected.GET("/performance", middleware.GetPerformance)
@@ -47,28 +51,87 @@ func RegisterRoutes(router *gin.Engine) {
 		protected.GET("/current-balance", middleware.GetCurrentBalance)
 		protected.GET("/investment-history", middleware.GetInvestmentHistory)
 
+		// Intercambios entre criptomonedas
+		protected.POST("/swaps", idempotent, middleware.CreateSwap)
+		protected.GET("/swaps/:id", middleware.GetSwap)
+		protected.PUT("/swaps/:id", idempotent, middleware.UpdateSwap)
+		protected.DELETE("/swaps/:id", idempotent, middleware.DeleteSwap)
+
+		// Cuentas de exchanges y billeteras
+		protected.POST("/accounts", idempotent, middleware.CreateAccount)
+		protected.GET("/accounts", middleware.GetAccounts)
+		protected.GET("/accounts/:id", middleware.GetAccount)
+		protected.PUT("/accounts/:id", idempotent, middleware.UpdateAccount)
+		protected.DELETE("/accounts/:id", idempotent, middleware.DeleteAccount)
+		protected.GET("/accounts/:id/holdings", middleware.GetAccountHoldings)
+		protected.POST("/accounts/:id/balances", idempotent, middleware.CreateReportedBalance)
+		protected.POST("/accounts/:id/balances/import", idempotent, middleware.ImportReportedBalances)
+		protected.GET("/accounts/:id/balances", middleware.GetReportedBalances)
+		protected.GET("/accounts/:id/reconciliation", middleware.GetAccountReconciliation)
+		protected.POST("/accounts/:id/reconciliation/adjust", idempotent, middleware.CreateReconciliationAdjustment)
+
 		// Nuevas rutas para bolsas
-		protected.POST("/bolsas", middleware.CreateBolsa)
+		protected.POST("/bolsas", idempotent, middleware.CreateBolsa)
 		protected.GET("/bolsas", middleware.GetUserBolsas)
 		protected.GET("/bolsas/:id", middleware.GetBolsaDetails)
-		protected.POST("/bolsas/:id/assets", middleware.AddAssetsToBolsa)
-		protected.DELETE("/bolsas/:id/assets/:assetId", middleware.RemoveAssetFromBolsa)
-		protected.PUT("/bolsas/:id", middleware.UpdateBolsa)
-		protected.DELETE("/bolsas/:id", middleware.DeleteBolsa)
-		protected.POST("/bolsas/:id/complete", middleware.CompleteBolsaAndTransfer)
+		protected.POST("/bolsas/:id/assets", idempotent, middleware.AddAssetsToBolsa)
+		protected.DELETE("/bolsas/:id/assets/:assetId", idempotent, middleware.RemoveAssetFromBolsa)
+		protected.PUT("/bolsas/:id", idempotent, middleware.UpdateBolsa)
+		protected.DELETE("/bolsas/:id", idempotent, middleware.DeleteBolsa)
+		protected.POST("/bolsas/:id/complete", idempotent, middleware.CompleteBolsaAndTransfer)
+		protected.GET("/bolsas/:id/rules", middleware.GetBolsaRules)
+		protected.POST("/bolsas/:id/rules", idempotent, middleware.CreateBolsaRule)
+		protected.PUT("/bolsas/:id/rules/:ruleId", idempotent, middleware.UpdateBolsaRule)
+		protected.DELETE("/bolsas/:id/rules/:ruleId", idempotent, middleware.DeleteBolsaRule)
+		protected.POST("/bolsas/:id/rules/:ruleId/rearm", idempotent, middleware.RearmBolsaRule)
 
 		// Rutas para etiquetas de bolsas
-		protected.POST("/bolsas/:id/tags", middleware.ManageBolsaTags)
+		protected.POST("/bolsas/:id/tags", idempotent, middleware.ManageBolsaTags)
 		protected.GET("/bolsas/tags/:tag", middleware.GetBolsasByTag)
 
 		// Agregar la ruta para balance en tiempo real
 		protected.GET("/live-balance", middleware.GetDashboardLiveBalance)
 
+		// Rutas para planes DCA
+		protected.POST("/dca/plans", middleware.CreateDCAPlan)
+		protected.GET("/dca/plans", middleware.GetDCAPlans)
+		protected.GET("/dca/plans/:id", middleware.GetDCAPlan)
+		protected.PUT("/dca/plans/:id", middleware.UpdateDCAPlan)
+		protected.DELETE("/dca/plans/:id", middleware.DeleteDCAPlan)
+		protected.GET("/dca/plans/:id/executions", middleware.GetDCAPlanExecutions)
+		protected.POST("/dca/backtest", middleware.BacktestDCAPlan)
+
+		// Ganancias realizadas y no realizadas según el método de costo base
+		protected.GET("/pnl/realized", middleware.GetRealizedPnL)
+		protected.GET("/pnl/unrealized", middleware.GetUnrealizedPnL)
+		protected.GET("/reports/tax", middleware.GetTaxReport)
+		protected.GET("/settings", middleware.GetUserSettings)
+		protected.PUT("/settings", middleware.UpdateUserSettings)
+
+		// Rendimientos y riesgo del portafolio
+		protected.GET("/analytics/returns", middleware.GetReturns)
+		protected.GET("/analytics/risk", middleware.GetRiskMetrics)
+		protected.GET("/analytics/benchmark", middleware.GetBenchmark)
+
+		// Copia de seguridad completa de la cartera
+		protected.GET("/export", middleware.ExportBackup)
+		protected.POST("/import/backup", middleware.RestoreBackup)
+
+		// Estadísticas de la caché de precios
+		protected.GET("/prices/cache/stats", middleware.GetPriceCacheStats)
+
 		// Rutas para snapshots de inversión
 		protected.POST("/investment/snapshots/force-create", middleware.ForceCreateSnapshot)
 		protected.POST("/investment/snapshots/force-create-with-date", middleware.ForceCreateSnapshotWithDate)
 		protected.DELETE("/investment/snapshots/:id", middleware.DeleteInvestmentSnapshot)
 	}
 
+	// Los precios históricos son compartidos por todos los usuarios: solo un administrador puede cargarlos
+	admin := router.Group("/")
+	admin.Use(middleware.SimpleAPIKeyMiddleware(), middleware.AdminAuth())
+	{
+		admin.POST("/prices/history/import", middleware.ImportHistoricalPrices)
+	}
+
 
 }
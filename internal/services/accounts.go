@@ -0,0 +1,93 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// NormalizeAccountType valida el tipo de una cuenta
+func NormalizeAccountType(accountType string) (string, error) {
+	accountType = strings.ToLower(strings.TrimSpace(accountType))
+	switch accountType {
+	case models.AccountTypeExchange, models.AccountTypeHardwareWallet, models.AccountTypeSoftwareWallet, models.AccountTypeCustodial:
+		return accountType, nil
+	default:
+		return "", fmt.Errorf("tipo de cuenta inválido: %s (usa exchange, hardware_wallet, software_wallet o custodial)", accountType)
+	}
+}
+
+// ValidateTransfer verifica que una transferencia tenga una cuenta de destino distinta de la de origen.
+// La cuenta de origen puede estar vacía para mover a una cuenta activos registrados sin cuenta.
+func ValidateTransfer(transaction models.CryptoTransaction) error {
+	if transaction.ToAccountID == "" {
+		return fmt.Errorf("to_account_id es requerido en las transferencias")
+	}
+	if transaction.ToAccountID == transaction.AccountID {
+		return fmt.Errorf("la cuenta de destino debe ser distinta de la de origen")
+	}
+	if transaction.USDTReceived.IsPositive() {
+		return fmt.Errorf("usdt_received solo se admite en ventas")
+	}
+	return nil
+}
+
+// accountMovement es el cambio en la cantidad de un ticker guardada en una cuenta
+type accountMovement struct {
+	accountID string
+	ticker    string
+	amount    decimal.Decimal
+}
+
+// accountMovements devuelve cómo cambia una transacción la cantidad de cada ticker en cada cuenta.
+// Las compras e ingresos suman en su cuenta, las ventas restan y las transferencias mueven la cantidad
+// de la cuenta de origen a la de destino. Las comisiones pagadas con una criptomoneda se descuentan
+// de la cuenta de la operación.
+func accountMovements(transaction models.CryptoTransaction) []accountMovement {
+	var movements []accountMovement
+	switch {
+	case IsAcquisitionType(transaction.Type):
+		movements = append(movements, accountMovement{transaction.AccountID, transaction.Ticker, transaction.Amount})
+	case transaction.Type == models.TransactionTypeSell:
+		movements = append(movements, accountMovement{transaction.AccountID, transaction.Ticker, transaction.Amount.Neg()})
+	case transaction.Type == models.TransactionTypeTransfer:
+		movements = append(movements,
+			accountMovement{transaction.AccountID, transaction.Ticker, transaction.Amount.Neg()},
+			accountMovement{transaction.ToAccountID, transaction.Ticker, transaction.Amount},
+		)
+	}
+
+	// Las comisiones pagadas con la criptomoneda operada, con otra (ej. BNB) o con USDT salen del saldo
+	// de esa moneda en la cuenta de la operación, como en el dashboard
+	if feeCoin := FeeCoin(transaction); feeCoin != "" {
+		movements = append(movements, accountMovement{transaction.AccountID, feeCoin, transaction.Fee.Neg()})
+	}
+	return movements
+}
+
+// AccountBalances calcula la cantidad de cada ticker guardada en cada cuenta (cuenta → ticker → cantidad).
+// Las transacciones sin cuenta se acumulan con la cuenta vacía.
+func AccountBalances(transactions []models.CryptoTransaction) map[string]map[string]decimal.Decimal {
+	balances := make(map[string]map[string]decimal.Decimal)
+	for _, transaction := range transactions {
+		for _, movement := range accountMovements(transaction) {
+			ticker := strings.ToUpper(movement.ticker)
+			if balances[movement.accountID] == nil {
+				balances[movement.accountID] = make(map[string]decimal.Decimal)
+			}
+			balances[movement.accountID][ticker] = balances[movement.accountID][ticker].Add(movement.amount)
+		}
+	}
+	return balances
+}
+
+// ApplyHoldingsChange actualiza las tenencias totales (ticker → cantidad) con una transacción,
+// sin importar en qué cuenta estén
+func ApplyHoldingsChange(holdings map[string]decimal.Decimal, transaction models.CryptoTransaction) {
+	for _, movement := range accountMovements(transaction) {
+		ticker := strings.ToUpper(movement.ticker)
+		holdings[ticker] = holdings[ticker].Add(movement.amount)
+	}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	_ "time/tzdata" // Zona horaria de los mercados aunque el sistema no tenga la base de zonas
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+)
+
+// Horario de la sesión regular de los mercados de acciones de Estados Unidos (NYSE y NASDAQ).
+// No se tienen en cuenta los feriados: esos días el mercado figura abierto pero la cotización no cambia.
+const (
+	equityMarketZone        = "America/New_York"
+	equityMarketOpenMinute  = 9*60 + 30
+	equityMarketCloseMinute = 16 * 60
+)
+
+// equityMarketLocation es la zona horaria del mercado, con un desfase fijo si no se puede cargar
+var equityMarketLocation = loadEquityMarketLocation()
+
+func loadEquityMarketLocation() *time.Location {
+	location, err := time.LoadLocation(equityMarketZone)
+	if err != nil {
+		log.Printf("No se pudo cargar la zona horaria %s, usando UTC-5: %v", equityMarketZone, err)
+		return time.FixedZone("EST", -5*60*60)
+	}
+	return location
+}
+
+// NormalizeAssetClass valida una clase de activo. Si está vacía devuelve cripto.
+func NormalizeAssetClass(assetClass string) (string, error) {
+	assetClass = strings.ToLower(strings.TrimSpace(assetClass))
+	switch assetClass {
+	case "":
+		return models.AssetClassCrypto, nil
+	case models.AssetClassCrypto, models.AssetClassStock, models.AssetClassETF, models.AssetClassCash:
+		return assetClass, nil
+	default:
+		return "", fmt.Errorf("clase de activo inválida: %s (usa crypto, stock, etf o cash)", assetClass)
+	}
+}
+
+// AssetClassOrDefault devuelve la clase de activo indicada o cripto si está vacía
+func AssetClassOrDefault(assetClass string) string {
+	if assetClass == "" {
+		return models.AssetClassCrypto
+	}
+	return assetClass
+}
+
+// IsEquity indica si la clase de activo cotiza en un mercado de acciones
+func IsEquity(assetClass string) bool {
+	return assetClass == models.AssetClassStock || assetClass == models.AssetClassETF
+}
+
+// MarketOpen indica si el mercado de la clase de activo está operando en el momento indicado.
+// Las criptomonedas y el efectivo cotizan siempre.
+func MarketOpen(assetClass string, at time.Time) bool {
+	if !IsEquity(assetClass) {
+		return true
+	}
+	local := at.In(equityMarketLocation)
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+		return false
+	}
+	minute := local.Hour()*60 + local.Minute()
+	return minute >= equityMarketOpenMinute && minute < equityMarketCloseMinute
+}
+
+// lastEquityMarketClose devuelve el cierre de la última sesión terminada antes del momento indicado
+func lastEquityMarketClose(at time.Time) time.Time {
+	local := at.In(equityMarketLocation)
+	for day := 0; day < 7; day++ {
+		date := local.AddDate(0, 0, -day)
+		if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+			continue
+		}
+		closeAt := time.Date(date.Year(), date.Month(), date.Day(), 0, equityMarketCloseMinute, 0, 0, equityMarketLocation)
+		if !closeAt.After(at) {
+			return closeAt
+		}
+	}
+	return at
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+)
+
+// PriceKey devuelve la clave con la que se pide la cotización de un activo al proveedor de precios.
+// Las criptomonedas usan el ticker tal cual; el resto lleva la clase como prefijo (por ejemplo "ETF:SPY").
+func PriceKey(assetClass, ticker string) string {
+	ticker = strings.ToUpper(strings.TrimSpace(ticker))
+	assetClass = AssetClassOrDefault(assetClass)
+	if assetClass == models.AssetClassCrypto {
+		return ticker
+	}
+	return strings.ToUpper(assetClass) + ":" + ticker
+}
+
+// splitPriceKey separa una clave de cotización en su clase de activo y su ticker
+func splitPriceKey(key string) (string, string) {
+	if prefix, ticker, found := strings.Cut(key, ":"); found {
+		assetClass := strings.ToLower(prefix)
+		if assetClass == models.AssetClassStock || assetClass == models.AssetClassETF || assetClass == models.AssetClassCash {
+			return assetClass, ticker
+		}
+	}
+	return models.AssetClassCrypto, key
+}
+
+// AssetPriceProvider reparte las cotizaciones entre el proveedor de criptomonedas y el de
+// acciones y ETFs según la clase de activo de cada clave (ver PriceKey). El efectivo se
+// valora con la cotización de su moneda contra el dólar.
+type AssetPriceProvider struct {
+	crypto PriceProvider
+	equity PriceProvider
+}
+
+// NewAssetPriceProvider crea un proveedor que combina criptomonedas y acciones
+func NewAssetPriceProvider(crypto, equity PriceProvider) *AssetPriceProvider {
+	return &AssetPriceProvider{
+		crypto: crypto,
+		equity: equity,
+	}
+}
+
+// Name devuelve el identificador del proveedor de criptomonedas
+func (p *AssetPriceProvider) Name() string {
+	return p.crypto.Name()
+}
+
+// GetQuote obtiene la cotización de una única clave
+func (p *AssetPriceProvider) GetQuote(key string) (PriceQuote, error) {
+	return quoteFromBatch(p, key)
+}
+
+// GetQuotes obtiene las cotizaciones de varias claves consultando a cada proveedor una sola vez.
+// Si un proveedor falla se devuelven las cotizaciones de los demás.
+func (p *AssetPriceProvider) GetQuotes(keys []string) (map[string]PriceQuote, error) {
+	keys = normalizeTickers(keys)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no se proporcionaron tickers")
+	}
+
+	var cryptoTickers, equityTickers, cashTickers []string
+	equityKeys := make(map[string][]string)
+	for _, key := range keys {
+		assetClass, ticker := splitPriceKey(key)
+		switch {
+		case assetClass == models.AssetClassCrypto:
+			cryptoTickers = append(cryptoTickers, ticker)
+		case IsEquity(assetClass):
+			if len(equityKeys[ticker]) == 0 {
+				equityTickers = append(equityTickers, ticker)
+			}
+			equityKeys[ticker] = append(equityKeys[ticker], key)
+		default:
+			cashTickers = append(cashTickers, ticker)
+		}
+	}
+
+	quotes := make(map[string]PriceQuote, len(keys))
+	var firstErr error
+	if len(cryptoTickers) > 0 {
+		cryptoQuotes, err := p.crypto.GetQuotes(cryptoTickers)
+		if err != nil {
+			firstErr = err
+		}
+		for ticker, quote := range cryptoQuotes {
+			quotes[ticker] = quote
+		}
+	}
+	if len(equityTickers) > 0 {
+		equityQuotes, err := p.equity.GetQuotes(equityTickers)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		for ticker, quote := range equityQuotes {
+			for _, key := range equityKeys[ticker] {
+				quotes[key] = quote
+			}
+		}
+	}
+	for _, currency := range cashTickers {
+		quote, err := p.cashQuote(currency)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		quotes[PriceKey(models.AssetClassCash, currency)] = quote
+	}
+
+	if firstErr != nil {
+		if len(quotes) == 0 {
+			return nil, firstErr
+		}
+		log.Printf("Cotizaciones incompletas: %v", firstErr)
+	}
+	return quotes, nil
+}
+
+// cashQuote valora una unidad de una moneda fiat en dólares
+func (p *AssetPriceProvider) cashQuote(currency string) (PriceQuote, error) {
+	currency, err := NormalizeCurrency(currency)
+	if err != nil {
+		return PriceQuote{}, err
+	}
+	quote := PriceQuote{Ticker: currency, Price: 1}
+	if currency == CurrencyUSD {
+		return quote, nil
+	}
+
+	rate, err := p.GetFXRate(currency)
+	if err != nil {
+		return PriceQuote{}, err
+	}
+	if rate <= 0 {
+		return PriceQuote{}, fmt.Errorf("cotización inválida para %s", currency)
+	}
+	quote.Price = 1 / rate
+	return quote, nil
+}
+
+// GetFXRate obtiene la cotización de una moneda desde el proveedor de criptomonedas
+func (p *AssetPriceProvider) GetFXRate(currency string) (float64, error) {
+	rates, err := fxRatesFrom(p.crypto)
+	if err != nil {
+		return 0, err
+	}
+	return rates.GetFXRate(currency)
+}
+
+// GetFXHistory obtiene el historial de una moneda desde el proveedor de criptomonedas
+func (p *AssetPriceProvider) GetFXHistory(currency string, from, to time.Time) (map[string]float64, error) {
+	rates, err := fxRatesFrom(p.crypto)
+	if err != nil {
+		return nil, err
+	}
+	return rates.GetFXHistory(currency, from, to)
+}
+
+// GetAssetQuotes obtiene las cotizaciones de activos de distintas clases (ticker → clase de activo)
+// y las devuelve indexadas por ticker
+func GetAssetQuotes(provider PriceProvider, assets map[string]string) (map[string]PriceQuote, error) {
+	keys := make([]string, 0, len(assets))
+	for ticker, assetClass := range assets {
+		keys = append(keys, PriceKey(assetClass, ticker))
+	}
+
+	quotes, err := provider.GetQuotes(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]PriceQuote, len(assets))
+	for ticker, assetClass := range assets {
+		if quote, exists := quotes[PriceKey(assetClass, ticker)]; exists {
+			result[strings.ToUpper(ticker)] = quote
+		}
+	}
+	return result, nil
+}
+
+// GetAssetQuote obtiene la cotización actual de un activo usando el proveedor configurado
+func GetAssetQuote(assetClass, ticker string) (PriceQuote, error) {
+	return GetPriceProvider().GetQuote(PriceKey(assetClass, ticker))
+}
+
+// GetAssetPrice obtiene el precio actual de un activo usando el proveedor configurado
+func GetAssetPrice(assetClass, ticker string) (float64, error) {
+	quote, err := GetAssetQuote(assetClass, ticker)
+	if err != nil {
+		return 0, err
+	}
+	return quote.Price, nil
+}
+
+// AssetExists verifica si un activo existe consultando su precio actual
+func AssetExists(assetClass, ticker string) bool {
+	_, err := GetAssetPrice(assetClass, ticker)
+	return err == nil
+}
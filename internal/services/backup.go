@@ -0,0 +1,272 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+)
+
+// backupJSONFile es el archivo del que se restaura un archivo de copia de seguridad;
+// los CSV lo acompañan para poder abrir los datos en una planilla
+const backupJSONFile = "backup.json"
+
+// maxBackupJSONSize es el tamaño máximo de backup.json descomprimido, para que un ZIP pequeño
+// no pueda ocupar toda la memoria al expandirse
+const maxBackupJSONSize = 200 << 20
+
+// NormalizeBackupConflictPolicy valida una política de conflictos. Si está vacía devuelve skip.
+func NormalizeBackupConflictPolicy(policy string) (string, error) {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	switch policy {
+	case "":
+		return models.BackupConflictSkip, nil
+	case models.BackupConflictSkip, models.BackupConflictOverwrite, models.BackupConflictMerge:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("política de conflictos inválida %q, debe ser skip, overwrite o merge", policy)
+	}
+}
+
+// ReadBackup lee una copia de seguridad en JSON o dentro de un archivo ZIP generado por la exportación
+func ReadBackup(data []byte) (*models.Backup, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("archivo ZIP inválido: %v", err)
+		}
+		file, err := archive.Open(backupJSONFile)
+		if err != nil {
+			return nil, fmt.Errorf("el archivo ZIP no contiene %s", backupJSONFile)
+		}
+		defer file.Close()
+		if data, err = io.ReadAll(io.LimitReader(file, maxBackupJSONSize+1)); err != nil {
+			return nil, err
+		}
+		if len(data) > maxBackupJSONSize {
+			return nil, fmt.Errorf("%s supera el tamaño máximo de %d MB", backupJSONFile, maxBackupJSONSize>>20)
+		}
+	}
+
+	var backup models.Backup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("copia de seguridad inválida: %v", err)
+	}
+	if backup.Version < 1 || backup.Version > models.BackupVersion {
+		return nil, fmt.Errorf("versión de copia de seguridad no soportada: %d", backup.Version)
+	}
+	return &backup, nil
+}
+
+// WriteBackupArchive escribe un ZIP con la copia en JSON y un CSV por cada tipo de entidad
+func WriteBackupArchive(writer io.Writer, backup models.Backup) error {
+	archive := zip.NewWriter(writer)
+
+	jsonFile, err := archive.Create(backupJSONFile)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(jsonFile)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(backup); err != nil {
+		return err
+	}
+
+	files := []struct {
+		name  string
+		write func(*csv.Writer) error
+	}{
+		{"accounts.csv", func(w *csv.Writer) error { return writeAccountsCSV(w, backup.Accounts) }},
+		{"transactions.csv", func(w *csv.Writer) error { return writeTransactionsCSV(w, backup.Transactions) }},
+		{"bolsas.csv", func(w *csv.Writer) error { return writeBolsasCSV(w, backup.Bolsas) }},
+		{"bolsa_assets.csv", func(w *csv.Writer) error { return writeBolsaAssetsCSV(w, backup.Bolsas) }},
+		{"bolsa_tags.csv", func(w *csv.Writer) error { return writeBolsaTagsCSV(w, backup.Bolsas) }},
+		{"bolsa_rules.csv", func(w *csv.Writer) error { return writeBolsaRulesCSV(w, backup.Bolsas) }},
+		{"snapshots.csv", func(w *csv.Writer) error { return writeSnapshotsCSV(w, backup.Snapshots) }},
+	}
+	for _, file := range files {
+		entry, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		csvWriter := csv.NewWriter(entry)
+		if err := file.write(csvWriter); err != nil {
+			return err
+		}
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// writeCSVRows escribe el encabezado y las filas de un CSV
+func writeCSVRows(writer *csv.Writer, header []string, records [][]string) error {
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	return writer.WriteAll(records)
+}
+
+// formatCSVFloat formatea un número sin notación científica ni ceros sobrantes
+func formatCSVFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// formatCSVTime formatea una fecha en RFC 3339 (UTC)
+func formatCSVTime(value time.Time) string {
+	if value.IsZero() {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
+
+func writeAccountsCSV(writer *csv.Writer, accounts []models.Account) error {
+	header := []string{"id", "name", "type", "provider", "note", "created_at", "updated_at"}
+	records := make([][]string, 0, len(accounts))
+	for _, account := range accounts {
+		records = append(records, []string{
+			account.ID,
+			account.Name,
+			account.Type,
+			account.Provider,
+			account.Note,
+			formatCSVTime(account.CreatedAt),
+			formatCSVTime(account.UpdatedAt),
+		})
+	}
+	return writeCSVRows(writer, header, records)
+}
+
+func writeTransactionsCSV(writer *csv.Writer, transactions []models.CryptoTransaction) error {
+	header := []string{
+		"id", "date", "type", "ticker", "crypto_name", "amount", "purchase_price", "total",
+		"usdt_received", "fee", "fee_currency", "fee_usd", "swap_id", "note", "created_at", "asset_class",
+		"account_id", "to_account_id",
+	}
+	records := make([][]string, 0, len(transactions))
+	for _, transaction := range transactions {
+		records = append(records, []string{
+			transaction.ID,
+			formatCSVTime(transaction.Date),
+			transaction.Type,
+			transaction.Ticker,
+			transaction.CryptoName,
+			transaction.Amount.String(),
+			transaction.PurchasePrice.String(),
+			transaction.Total.String(),
+			transaction.USDTReceived.String(),
+			transaction.Fee.String(),
+			transaction.FeeCurrency,
+			transaction.FeeUSD.String(),
+			transaction.SwapID,
+			transaction.Note,
+			formatCSVTime(transaction.CreatedAt),
+			AssetClassOrDefault(transaction.AssetClass),
+			transaction.AccountID,
+			transaction.ToAccountID,
+		})
+	}
+	return writeCSVRows(writer, header, records)
+}
+
+func writeBolsasCSV(writer *csv.Writer, bolsas []models.Bolsa) error {
+	header := []string{"id", "name", "description", "goal", "created_at", "updated_at"}
+	records := make([][]string, 0, len(bolsas))
+	for _, bolsa := range bolsas {
+		records = append(records, []string{
+			bolsa.ID,
+			bolsa.Name,
+			bolsa.Description,
+			bolsa.Goal.String(),
+			formatCSVTime(bolsa.CreatedAt),
+			formatCSVTime(bolsa.UpdatedAt),
+		})
+	}
+	return writeCSVRows(writer, header, records)
+}
+
+func writeBolsaAssetsCSV(writer *csv.Writer, bolsas []models.Bolsa) error {
+	header := []string{"id", "bolsa_id", "ticker", "crypto_name", "amount", "purchase_price", "total", "created_at"}
+	var records [][]string
+	for _, bolsa := range bolsas {
+		for _, asset := range bolsa.Assets {
+			records = append(records, []string{
+				asset.ID,
+				bolsa.ID,
+				asset.Ticker,
+				asset.CryptoName,
+				asset.Amount.String(),
+				asset.PurchasePrice.String(),
+				asset.Total.String(),
+				formatCSVTime(asset.CreatedAt),
+			})
+		}
+	}
+	return writeCSVRows(writer, header, records)
+}
+
+func writeBolsaTagsCSV(writer *csv.Writer, bolsas []models.Bolsa) error {
+	var records [][]string
+	for _, bolsa := range bolsas {
+		for _, tag := range bolsa.Tags {
+			records = append(records, []string{bolsa.ID, tag})
+		}
+	}
+	return writeCSVRows(writer, []string{"bolsa_id", "tag"}, records)
+}
+
+func writeBolsaRulesCSV(writer *csv.Writer, bolsas []models.Bolsa) error {
+	header := []string{
+		"id", "bolsa_id", "type", "ticker", "target_value", "direction", "active",
+		"triggered", "rearm", "cooldown_minutes", "peak_value",
+	}
+	var records [][]string
+	for _, bolsa := range bolsas {
+		for _, rule := range bolsa.Rules {
+			records = append(records, []string{
+				rule.ID,
+				bolsa.ID,
+				rule.Type,
+				rule.Ticker,
+				rule.TargetValue.String(),
+				rule.Direction,
+				strconv.FormatBool(rule.Active),
+				strconv.FormatBool(rule.Triggered),
+				strconv.FormatBool(rule.Rearm),
+				strconv.Itoa(rule.CooldownMinutes),
+				rule.PeakValue.String(),
+			})
+		}
+	}
+	return writeCSVRows(writer, header, records)
+}
+
+func writeSnapshotsCSV(writer *csv.Writer, snapshots []models.InvestmentSnapshot) error {
+	header := []string{
+		"id", "date", "total_value", "total_invested", "profit", "profit_percentage", "max_value", "min_value",
+	}
+	records := make([][]string, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		records = append(records, []string{
+			snapshot.ID,
+			formatCSVTime(snapshot.Date),
+			snapshot.TotalValue.String(),
+			snapshot.TotalInvested.String(),
+			snapshot.Profit.String(),
+			formatCSVFloat(snapshot.ProfitPercentage),
+			snapshot.MaxValue.String(),
+			snapshot.MinValue.String(),
+		})
+	}
+	return writeCSVRows(writer, header, records)
+}
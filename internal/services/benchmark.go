@@ -0,0 +1,114 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+)
+
+// BenchmarkAllocations devuelve el nombre del benchmark y sus pesos. Si está vacío se usa BTC.
+// Para custom la canasta tiene el formato "BTC:50,ETH:30,SOL:20"; los pesos se normalizan después.
+func BenchmarkAllocations(benchmark, basket string) (string, []models.BacktestAllocation, error) {
+	switch strings.ToLower(strings.TrimSpace(benchmark)) {
+	case "", models.BenchmarkBTC:
+		return models.BenchmarkBTC, []models.BacktestAllocation{{Ticker: "BTC", Weight: 1}}, nil
+	case models.BenchmarkETH:
+		return models.BenchmarkETH, []models.BacktestAllocation{{Ticker: "ETH", Weight: 1}}, nil
+	case models.BenchmarkBTCETH:
+		return models.BenchmarkBTCETH, []models.BacktestAllocation{{Ticker: "BTC", Weight: 0.6}, {Ticker: "ETH", Weight: 0.4}}, nil
+	case models.BenchmarkCustom:
+		allocations, err := parseBenchmarkBasket(basket)
+		if err != nil {
+			return "", nil, err
+		}
+		return models.BenchmarkCustom, allocations, nil
+	default:
+		return "", nil, fmt.Errorf("benchmark inválido: %s (usa btc, eth, btc_eth o custom)", benchmark)
+	}
+}
+
+// parseBenchmarkBasket interpreta una canasta "TICKER:PESO" separada por comas
+func parseBenchmarkBasket(basket string) ([]models.BacktestAllocation, error) {
+	if strings.TrimSpace(basket) == "" {
+		return nil, fmt.Errorf("el benchmark custom necesita una canasta, por ejemplo basket=BTC:50,ETH:50")
+	}
+
+	var allocations []models.BacktestAllocation
+	for _, item := range strings.Split(basket, ",") {
+		ticker, weight, found := strings.Cut(strings.TrimSpace(item), ":")
+		if !found {
+			return nil, fmt.Errorf("elemento de canasta inválido: %s (usa TICKER:PESO)", item)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("peso inválido para %s: %s", strings.TrimSpace(ticker), weight)
+		}
+		allocations = append(allocations, models.BacktestAllocation{Ticker: strings.TrimSpace(ticker), Weight: value})
+	}
+	return allocations, nil
+}
+
+// CompareBenchmark alinea la curva del portafolio con la del benchmark y calcula los rendimientos
+// ponderados por tiempo de ambas, el alpha y el tracking error. Las dos curvas deben tener las mismas
+// fechas y los mismos aportes; el aporte del primer punto ya forma parte del valor inicial.
+func CompareBenchmark(portfolio, benchmark []ReturnPoint) models.BenchmarkComparison {
+	comparison := models.BenchmarkComparison{Points: make([]models.BenchmarkPoint, 0, len(portfolio))}
+	if len(portfolio) == 0 || len(portfolio) != len(benchmark) {
+		return comparison
+	}
+
+	var netFlows float64
+	for i, point := range portfolio {
+		if i > 0 {
+			netFlows += point.Flow
+		}
+		comparison.Points = append(comparison.Points, models.BenchmarkPoint{
+			Date:           point.Date,
+			PortfolioValue: point.Value,
+			BenchmarkValue: benchmark[i].Value,
+			NetFlows:       netFlows,
+		})
+	}
+	last := len(portfolio) - 1
+	comparison.TrackingDifference = portfolio[last].Value - benchmark[last].Value
+
+	comparison.PortfolioReturn = ComputeReturns(startPoint(portfolio[0]), portfolio[1:]).TWR
+	comparison.BenchmarkReturn = ComputeReturns(startPoint(benchmark[0]), benchmark[1:]).TWR
+	if comparison.PortfolioReturn != nil && comparison.BenchmarkReturn != nil {
+		alpha := *comparison.PortfolioReturn - *comparison.BenchmarkReturn
+		comparison.Alpha = &alpha
+	}
+
+	// Diferencia de rendimientos de los días en que ambas curvas tienen valor anterior
+	benchmarkReturns := make(map[time.Time]float64)
+	for _, day := range FlowNeutralReturns(benchmark) {
+		benchmarkReturns[day.Date] = day.Return
+	}
+	var differences []float64
+	var mean float64
+	for _, day := range FlowNeutralReturns(portfolio) {
+		if benchmarkReturn, exists := benchmarkReturns[day.Date]; exists {
+			differences = append(differences, day.Return-benchmarkReturn)
+			mean += day.Return - benchmarkReturn
+		}
+	}
+	if len(differences) >= 2 {
+		mean /= float64(len(differences))
+		var variance float64
+		for _, difference := range differences {
+			variance += (difference - mean) * (difference - mean)
+		}
+		trackingError := math.Sqrt(variance/float64(len(differences)-1)) * math.Sqrt(daysPerYear)
+		comparison.TrackingError = &trackingError
+	}
+	return comparison
+}
+
+// startPoint devuelve el punto inicial de una curva sin el aporte de ese día
+func startPoint(point ReturnPoint) ReturnPoint {
+	return ReturnPoint{Date: point.Date, Value: point.Value}
+}
@@ -2,22 +2,15 @@ package services
 
 import (
 	"log"
+	"strings"
 	"sync"
-	"time"
 
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
 )
 
-// BolsaPriceService es un servicio para mantener actualizados los precios de las criptomonedas en las bolsas
-type BolsaPriceService struct {
-	priceCache map[string]cachedCryptoPrice
-	mutex      sync.RWMutex
-}
-
-type cachedCryptoPrice struct {
-	Price     float64
-	Timestamp time.Time
-}
+// BolsaPriceService es un servicio para mantener actualizados los precios de las criptomonedas en las bolsas.
+// Los precios se obtienen del proveedor global, que ya comparte una caché en todo el proceso.
+type BolsaPriceService struct{}
 
 // Singleton para el servicio
 var (
@@ -28,75 +21,83 @@ var (
 // GetBolsaPriceService devuelve la instancia del servicio
 func GetBolsaPriceService() *BolsaPriceService {
 	bolsaPriceServiceOnce.Do(func() {
-		bolsaPriceService = &BolsaPriceService{
-			priceCache: make(map[string]cachedCryptoPrice),
-		}
+		bolsaPriceService = &BolsaPriceService{}
 	})
 
 	return bolsaPriceService
 }
 
-// GetCurrentPrice obtiene el precio actual de una criptomoneda
-// Si el precio está en caché y es reciente (menos de 1 minuto), lo devuelve
-// De lo contrario, obtiene el precio actual de la API
+// GetCurrentPrice obtiene el precio actual de una criptomoneda desde el proveedor configurado
 func (s *BolsaPriceService) GetCurrentPrice(ticker string) (float64, error) {
-	// Siempre obtenemos el precio actual de la API para asegurar que esté actualizado
-	// No usamos caché para garantizar que siempre tengamos el precio más reciente
-
-	// Obtener el precio actual de la API
-	cryptoData, err := GetCryptoPriceFromCoinGecko(ticker)
+	price, err := GetPrice(ticker)
 	if err != nil {
 		log.Printf("Error al obtener precio actual para %s: %v", ticker, err)
 		return 0, err
 	}
-
-	// Actualizar el caché
-	s.mutex.Lock()
-	s.priceCache[ticker] = cachedCryptoPrice{
-		Price:     cryptoData.Price,
-		Timestamp: time.Now(),
-	}
-	s.mutex.Unlock()
-
-	log.Printf("Precio actualizado para %s: %.2f", ticker, cryptoData.Price)
-	return cryptoData.Price, nil
+	return price, nil
 }
 
 // UpdateAssetPrices actualiza los precios de los activos en una bolsa
 func (s *BolsaPriceService) UpdateAssetPrices(assets []models.AssetInBolsa) []models.AssetInBolsa {
+	assets, _ = s.updateAssetPrices(assets)
+	return assets
+}
+
+// updateAssetPrices actualiza los precios de los activos y devuelve los tickers sin cotización actual,
+// que quedan valorados con su precio de compra
+func (s *BolsaPriceService) updateAssetPrices(assets []models.AssetInBolsa) ([]models.AssetInBolsa, map[string]bool) {
+	// Obtener los precios de todos los activos en una sola consulta
+	assetClasses := make(map[string]string, len(assets))
+	for _, asset := range assets {
+		assetClasses[asset.Ticker] = asset.AssetClass
+	}
+	quotes, err := GetAssetQuotes(GetPriceProvider(), assetClasses)
+	if err != nil {
+		log.Printf("Error al obtener precios de la bolsa: %v", err)
+	}
+
+	missing := make(map[string]bool)
 	for i := range assets {
-		// Obtener directamente el precio de la API de CoinGecko para asegurar que esté actualizado
-		cryptoData, err := GetCryptoPriceFromCoinGecko(assets[i].Ticker)
-		if err != nil {
+		quote, exists := quotes[strings.ToUpper(assets[i].Ticker)]
+		if !exists {
 			// Si no podemos obtener el precio actual, usamos el precio de compra
-			log.Printf("Error al obtener precio para %s, usando precio de compra: %.2f", assets[i].Ticker, assets[i].PurchasePrice)
-			assets[i].CurrentPrice = assets[i].PurchasePrice
-		} else {
-			// Siempre usar el precio actual de la API
-			assets[i].CurrentPrice = cryptoData.Price
-			log.Printf("Precio actualizado para %s: %.2f (precio anterior: %.2f)", assets[i].Ticker, cryptoData.Price, assets[i].CurrentPrice)
+			log.Printf("Error al obtener precio para %s, usando precio de compra: %s", assets[i].Ticker, assets[i].PurchasePrice)
+			quote.Price = assets[i].PurchasePrice.InexactFloat64()
+			missing[strings.ToUpper(assets[i].Ticker)] = true
 		}
+		ValueBolsaAsset(&assets[i], quote.Price)
+	}
 
-		// Recalcular valores derivados
-		assets[i].CurrentValue = assets[i].Amount * assets[i].CurrentPrice
-		assets[i].GainLoss = assets[i].CurrentValue - assets[i].Total
+	return assets, missing
+}
 
-		if assets[i].Total > 0 {
-			assets[i].GainLossPercent = (assets[i].GainLoss / assets[i].Total) * 100
-		}
+// ValueBolsaAsset calcula el valor actual y la ganancia de un activo de una bolsa con el precio indicado
+func ValueBolsaAsset(asset *models.AssetInBolsa, price float64) {
+	total := asset.Total.InexactFloat64()
+	asset.CurrentPrice = price
+	asset.CurrentValue = asset.Amount.InexactFloat64() * price
+	asset.GainLoss = asset.CurrentValue - total
+	if total > 0 {
+		asset.GainLossPercent = (asset.GainLoss / total) * 100
 	}
-
-	return assets
 }
 
 // UpdateBolsaPrices actualiza los precios de todos los activos en una bolsa
 func (s *BolsaPriceService) UpdateBolsaPrices(bolsa *models.Bolsa) *models.Bolsa {
+	bolsa, _ = s.QuoteBolsa(bolsa)
+	return bolsa
+}
+
+// QuoteBolsa actualiza los precios de la bolsa como UpdateBolsaPrices y además devuelve los tickers
+// que no tienen cotización actual. Si hay alguno, el valor de la bolsa es solo aproximado.
+func (s *BolsaPriceService) QuoteBolsa(bolsa *models.Bolsa) (*models.Bolsa, map[string]bool) {
 	if bolsa == nil || len(bolsa.Assets) == 0 {
-		return bolsa
+		return bolsa, map[string]bool{}
 	}
 
 	// Actualizar los precios de los activos
-	bolsa.Assets = s.UpdateAssetPrices(bolsa.Assets)
+	var missing map[string]bool
+	bolsa.Assets, missing = s.updateAssetPrices(bolsa.Assets)
 
 	// Recalcular el valor actual total de la bolsa
 	bolsa.CurrentValue = 0
@@ -105,9 +106,9 @@ func (s *BolsaPriceService) UpdateBolsaPrices(bolsa *models.Bolsa) *models.Bolsa
 	}
 
 	// Actualizar información de progreso si hay un objetivo establecido
-	if bolsa.Goal > 0 {
+	if bolsa.Goal.IsPositive() {
 		// Calcular el porcentaje real de progreso
-		rawPercent := (bolsa.CurrentValue / bolsa.Goal) * 100
+		rawPercent := (bolsa.CurrentValue / bolsa.Goal.InexactFloat64()) * 100
 
 		// Crear objeto de progreso si no existe
 		if bolsa.Progress == nil {
@@ -120,7 +121,7 @@ func (s *BolsaPriceService) UpdateBolsaPrices(bolsa *models.Bolsa) *models.Bolsa
 		if rawPercent > 100 {
 			bolsa.Progress.Percent = 100
 			bolsa.Progress.Status = "superado"
-			bolsa.Progress.ExcessAmount = bolsa.CurrentValue - bolsa.Goal
+			bolsa.Progress.ExcessAmount = bolsa.CurrentValue - bolsa.Goal.InexactFloat64()
 			bolsa.Progress.ExcessPercent = rawPercent - 100
 		} else if rawPercent == 100 {
 			bolsa.Progress.Percent = 100
@@ -131,5 +132,5 @@ func (s *BolsaPriceService) UpdateBolsaPrices(bolsa *models.Bolsa) *models.Bolsa
 		}
 	}
 
-	return bolsa
+	return bolsa, missing
 }
@@ -0,0 +1,365 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// LotLedger es el resultado de emparejar las ventas de un usuario con sus compras
+type LotLedger struct {
+	Method   string
+	Realized []models.RealizedGain // Ventas en orden cronológico
+	Income   []models.IncomeEvent  // Ingresos en orden cronológico
+
+	open    map[string][]models.OpenLot // Lotes abiertos por ticker, en orden de compra
+	fees    map[string]decimal.Decimal  // Comisiones pagadas en dólares por ticker operado
+	names   map[string]string
+	tickers []string
+}
+
+// NormalizeCostBasisMethod valida un método de costo base. Si está vacío devuelve el promedio.
+func NormalizeCostBasisMethod(method string) (string, error) {
+	method = strings.ToLower(strings.TrimSpace(method))
+	switch method {
+	case "":
+		return models.CostBasisAverage, nil
+	case models.CostBasisFIFO, models.CostBasisLIFO, models.CostBasisHIFO, models.CostBasisAverage:
+		return method, nil
+	default:
+		return "", fmt.Errorf("método de costo base inválido %q, debe ser fifo, lifo, hifo o average", method)
+	}
+}
+
+// MatchLots recorre las transacciones en orden cronológico: cada compra o ingreso abre un lote y
+// cada venta consume lotes según el método indicado, registrando la ganancia realizada.
+// Los ingresos se registran aparte con su valor de mercado, que pasa a ser el costo base del lote.
+func MatchLots(transactions []models.CryptoTransaction, method string) (*LotLedger, error) {
+	method, err := NormalizeCostBasisMethod(method)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := make([]models.CryptoTransaction, len(transactions))
+	copy(ordered, transactions)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Date.Before(ordered[j].Date) })
+
+	ledger := &LotLedger{
+		Method: method,
+		open:   make(map[string][]models.OpenLot),
+		fees:   make(map[string]decimal.Decimal),
+		names:  make(map[string]string),
+	}
+	for _, transaction := range ordered {
+		ticker := strings.ToUpper(transaction.Ticker)
+		if _, seen := ledger.names[ticker]; !seen {
+			ledger.tickers = append(ledger.tickers, ticker)
+			ledger.names[ticker] = transaction.CryptoName
+		}
+
+		switch {
+		case transaction.Type == models.TransactionTypeBuy:
+			ledger.buy(ticker, transaction)
+		case transaction.Type == models.TransactionTypeSell:
+			ledger.sell(ticker, transaction)
+		case IsIncomeType(transaction.Type):
+			ledger.receive(ticker, transaction)
+		case transaction.Type == models.TransactionTypeTransfer:
+			ledger.transfer(ticker, transaction)
+		default:
+			continue
+		}
+
+		ledger.fees[ticker] = ledger.fees[ticker].Add(transaction.FeeUSD)
+		if IsOtherCoinFee(transaction) {
+			// Pagar la comisión con otra criptomoneda es venderla por el valor de la comisión
+			feeTicker := strings.ToUpper(transaction.FeeCurrency)
+			sale := ledger.consume(feeTicker, transaction.Fee, transaction.FeeUSD, transaction)
+			sale.CryptoName = ledger.names[feeTicker]
+			sale.FeeDisposal = true
+			ledger.Realized = append(ledger.Realized, sale)
+		}
+	}
+
+	return ledger, nil
+}
+
+// receive abre un lote con el valor de mercado del ingreso como costo base y lo registra como ingreso
+func (l *LotLedger) receive(ticker string, transaction models.CryptoTransaction) {
+	l.buy(ticker, transaction)
+
+	value := transaction.Total
+	if !value.IsPositive() {
+		value = transaction.Amount.Mul(transaction.PurchasePrice)
+	}
+	l.Income = append(l.Income, models.IncomeEvent{
+		TransactionID: transaction.ID,
+		Ticker:        ticker,
+		CryptoName:    transaction.CryptoName,
+		Type:          transaction.Type,
+		ReceivedAt:    transaction.Date,
+		Amount:        transaction.Amount,
+		Value:         value,
+	})
+}
+
+// transfer no toca los lotes: mover activos entre cuentas no cambia su costo base. La comisión
+// pagada con la misma criptomoneda es una venta de esa cantidad por el valor de la comisión.
+func (l *LotLedger) transfer(ticker string, transaction models.CryptoTransaction) {
+	if !IsTradedCoinFee(transaction) {
+		return
+	}
+	sale := l.consume(ticker, transaction.Fee, transaction.FeeUSD, transaction)
+	sale.FeeDisposal = true
+	l.Realized = append(l.Realized, sale)
+}
+
+// buy abre un lote nuevo con el costo total de la compra. Las comisiones en dólares o en
+// otra criptomoneda se suman al costo; las pagadas con la misma criptomoneda reducen la cantidad.
+func (l *LotLedger) buy(ticker string, transaction models.CryptoTransaction) {
+	amount := transaction.Amount
+	cost := transaction.Total
+	if !cost.IsPositive() {
+		cost = transaction.Amount.Mul(transaction.PurchasePrice)
+	}
+	if IsTradedCoinFee(transaction) {
+		amount = amount.Sub(transaction.Fee)
+	} else {
+		cost = cost.Add(transaction.FeeUSD)
+	}
+	if !amount.IsPositive() {
+		return
+	}
+
+	l.open[ticker] = append(l.open[ticker], models.OpenLot{
+		TransactionID: transaction.ID,
+		Ticker:        ticker,
+		AcquiredAt:    transaction.Date,
+		Amount:        amount,
+		UnitCost:      cost.Div(amount),
+		CostBasis:     cost,
+		Income:        IsIncomeType(transaction.Type),
+	})
+}
+
+// sell consume los lotes abiertos en el orden del método y registra la ganancia realizada.
+// Las comisiones pagadas con la misma criptomoneda se venden junto con la cantidad operada;
+// las demás se restan de lo recibido salvo que usdt_received ya sea el neto.
+func (l *LotLedger) sell(ticker string, transaction models.CryptoTransaction) {
+	amount := transaction.Amount
+	proceeds := saleProceeds(transaction)
+	if IsTradedCoinFee(transaction) {
+		amount = amount.Add(transaction.Fee)
+	} else if !transaction.USDTReceived.IsPositive() {
+		proceeds = proceeds.Sub(transaction.FeeUSD)
+	}
+	if !amount.IsPositive() {
+		return
+	}
+
+	sale := l.consume(ticker, amount, proceeds, transaction)
+	sale.Fee = transaction.FeeUSD
+	l.Realized = append(l.Realized, sale)
+}
+
+// consume descuenta una cantidad de los lotes abiertos de un ticker y devuelve la ganancia
+// realizada, repartiendo lo recibido entre los lotes en proporción a la cantidad
+func (l *LotLedger) consume(ticker string, amount, proceeds decimal.Decimal, transaction models.CryptoTransaction) models.RealizedGain {
+	lots := l.open[ticker]
+
+	if l.Method == models.CostBasisAverage {
+		// Con costo promedio todos los lotes pasan a valer lo mismo por unidad
+		held, cost := decimal.Zero, decimal.Zero
+		for _, lot := range lots {
+			held = held.Add(lot.Amount)
+			cost = cost.Add(lot.CostBasis)
+		}
+		if held.IsPositive() {
+			for i := range lots {
+				lots[i].UnitCost = cost.Div(held)
+				lots[i].CostBasis = lots[i].Amount.Mul(lots[i].UnitCost)
+			}
+		}
+	}
+
+	gain := models.RealizedGain{
+		TransactionID: transaction.ID,
+		Ticker:        ticker,
+		CryptoName:    transaction.CryptoName,
+		SoldAt:        transaction.Date,
+		Amount:        amount,
+		Proceeds:      proceeds,
+		Lots:          []models.LotMatch{},
+	}
+
+	remaining := amount
+	for _, i := range consumptionOrder(lots, l.Method) {
+		if !remaining.IsPositive() {
+			break
+		}
+		lot := &lots[i]
+		take := decimal.Min(lot.Amount, remaining)
+		match := models.LotMatch{
+			LotTransactionID: lot.TransactionID,
+			AcquiredAt:       lot.AcquiredAt,
+			Amount:           take,
+			UnitCost:         lot.UnitCost,
+			CostBasis:        take.Mul(lot.UnitCost),
+			Proceeds:         proceeds.Mul(take).Div(amount),
+		}
+		match.Gain = match.Proceeds.Sub(match.CostBasis)
+		gain.Lots = append(gain.Lots, match)
+		gain.CostBasis = gain.CostBasis.Add(match.CostBasis)
+
+		lot.Amount = lot.Amount.Sub(take)
+		lot.CostBasis = lot.Amount.Mul(lot.UnitCost)
+		remaining = remaining.Sub(take)
+	}
+
+	if remaining.IsPositive() {
+		// Venta sin compras registradas que la respalden: costo base cero
+		unmatchedProceeds := proceeds.Mul(remaining).Div(amount)
+		gain.Unmatched = remaining
+		gain.Lots = append(gain.Lots, models.LotMatch{
+			AcquiredAt: transaction.Date,
+			Amount:     remaining,
+			Proceeds:   unmatchedProceeds,
+			Gain:       unmatchedProceeds,
+		})
+	}
+	gain.Gain = gain.Proceeds.Sub(gain.CostBasis)
+
+	// Descartar los lotes agotados conservando el orden de compra
+	kept := lots[:0]
+	for _, lot := range lots {
+		if lot.Amount.IsPositive() {
+			kept = append(kept, lot)
+		}
+	}
+	l.open[ticker] = kept
+
+	return gain
+}
+
+// consumptionOrder devuelve los índices de los lotes en el orden en que se venden
+func consumptionOrder(lots []models.OpenLot, method string) []int {
+	order := make([]int, len(lots))
+	for i := range lots {
+		order[i] = i
+	}
+	switch method {
+	case models.CostBasisLIFO:
+		for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+	case models.CostBasisHIFO:
+		sort.SliceStable(order, func(a, b int) bool { return lots[order[a]].UnitCost.GreaterThan(lots[order[b]].UnitCost) })
+	}
+	return order
+}
+
+// saleProceeds obtiene lo recibido por una venta
+func saleProceeds(transaction models.CryptoTransaction) decimal.Decimal {
+	if transaction.USDTReceived.IsPositive() {
+		return transaction.USDTReceived
+	}
+	if transaction.Total.IsPositive() {
+		return transaction.Total
+	}
+	return transaction.Amount.Mul(transaction.PurchasePrice)
+}
+
+// Tickers devuelve los tickers en el orden en que aparecieron por primera vez
+func (l *LotLedger) Tickers() []string {
+	return l.tickers
+}
+
+// CryptoName devuelve el nombre de la criptomoneda de un ticker
+func (l *LotLedger) CryptoName(ticker string) string {
+	return l.names[strings.ToUpper(ticker)]
+}
+
+// OpenLots devuelve los lotes abiertos de un ticker en orden de compra
+func (l *LotLedger) OpenLots(ticker string) []models.OpenLot {
+	return l.open[strings.ToUpper(ticker)]
+}
+
+// Position devuelve la cantidad y el costo base de lo que queda sin vender de un ticker
+func (l *LotLedger) Position(ticker string) (amount, costBasis decimal.Decimal) {
+	for _, lot := range l.OpenLots(ticker) {
+		amount = amount.Add(lot.Amount)
+		costBasis = costBasis.Add(lot.CostBasis)
+	}
+	return amount, costBasis
+}
+
+// Invested devuelve el costo base de lo que queda sin vender de un ticker sin contar los lotes
+// recibidos como ingreso, que no se pagaron
+func (l *LotLedger) Invested(ticker string) decimal.Decimal {
+	invested := decimal.Zero
+	for _, lot := range l.OpenLots(ticker) {
+		if !lot.Income {
+			invested = invested.Add(lot.CostBasis)
+		}
+	}
+	return invested
+}
+
+// IncomeValue devuelve el valor de mercado de los ingresos recibidos de un ticker
+func (l *LotLedger) IncomeValue(ticker string) decimal.Decimal {
+	ticker = strings.ToUpper(ticker)
+	total := decimal.Zero
+	for _, income := range l.Income {
+		if income.Ticker == ticker {
+			total = total.Add(income.Value)
+		}
+	}
+	return total
+}
+
+// TotalIncome devuelve el valor de mercado de todos los ingresos recibidos
+func (l *LotLedger) TotalIncome() decimal.Decimal {
+	total := decimal.Zero
+	for _, income := range l.Income {
+		total = total.Add(income.Value)
+	}
+	return total
+}
+
+// RealizedGain devuelve la ganancia realizada acumulada de un ticker
+func (l *LotLedger) RealizedGain(ticker string) decimal.Decimal {
+	ticker = strings.ToUpper(ticker)
+	total := decimal.Zero
+	for _, sale := range l.Realized {
+		if sale.Ticker == ticker {
+			total = total.Add(sale.Gain)
+		}
+	}
+	return total
+}
+
+// TotalRealizedGain devuelve la ganancia realizada de todas las ventas
+func (l *LotLedger) TotalRealizedGain() decimal.Decimal {
+	total := decimal.Zero
+	for _, sale := range l.Realized {
+		total = total.Add(sale.Gain)
+	}
+	return total
+}
+
+// FeesPaid devuelve las comisiones pagadas en dólares en las operaciones de un ticker
+func (l *LotLedger) FeesPaid(ticker string) decimal.Decimal {
+	return l.fees[strings.ToUpper(ticker)]
+}
+
+// TotalFees devuelve las comisiones pagadas en dólares en todas las operaciones
+func (l *LotLedger) TotalFees() decimal.Decimal {
+	total := decimal.Zero
+	for _, fee := range l.fees {
+		total = total.Add(fee)
+	}
+	return total
+}
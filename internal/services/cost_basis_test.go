@@ -0,0 +1,212 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// lotTransaction arma una transacción de prueba con total = cantidad × precio
+func lotTransaction(id, txType, ticker, amount, price string, day int) models.CryptoTransaction {
+	amountDec := decimal.RequireFromString(amount)
+	priceDec := decimal.RequireFromString(price)
+	return models.CryptoTransaction{
+		ID:            id,
+		Type:          txType,
+		Ticker:        ticker,
+		CryptoName:    ticker,
+		Amount:        amountDec,
+		PurchasePrice: priceDec,
+		Total:         amountDec.Mul(priceDec),
+		Date:          time.Date(2024, 1, day, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// withFee agrega una comisión a una transacción de prueba
+func withFee(transaction models.CryptoTransaction, fee, currency, feeUSD string) models.CryptoTransaction {
+	transaction.Fee = decimal.RequireFromString(fee)
+	transaction.FeeCurrency = currency
+	transaction.FeeUSD = decimal.RequireFromString(feeUSD)
+	return transaction
+}
+
+// wantSale es lo que se espera de una venta del ledger
+type wantSale struct {
+	ticker      string
+	amount      string
+	costBasis   string
+	proceeds    string
+	gain        string
+	unmatched   string
+	lots        []string // Lotes consumidos, en orden; "" para la parte sin respaldo
+	feeDisposal bool
+}
+
+func TestMatchLots(t *testing.T) {
+	buy, sell := models.TransactionTypeBuy, models.TransactionTypeSell
+
+	tests := []struct {
+		name         string
+		method       string
+		transactions []models.CryptoTransaction
+		wantSales    []wantSale
+		wantOpen     map[string]string // ticker → cantidad sin vender
+	}{
+		{
+			name:   "fifo vende la compra más antigua",
+			method: models.CostBasisFIFO,
+			transactions: []models.CryptoTransaction{
+				lotTransaction("a", buy, "BTC", "1", "100", 1),
+				lotTransaction("b", buy, "BTC", "1", "200", 2),
+				lotTransaction("s", sell, "BTC", "1", "300", 3),
+			},
+			wantSales: []wantSale{{ticker: "BTC", amount: "1", costBasis: "100", proceeds: "300", gain: "200", lots: []string{"a"}}},
+			wantOpen:  map[string]string{"BTC": "1"},
+		},
+		{
+			name:   "lifo vende la compra más reciente",
+			method: models.CostBasisLIFO,
+			transactions: []models.CryptoTransaction{
+				lotTransaction("a", buy, "BTC", "1", "100", 1),
+				lotTransaction("b", buy, "BTC", "1", "200", 2),
+				lotTransaction("s", sell, "BTC", "1", "300", 3),
+			},
+			wantSales: []wantSale{{ticker: "BTC", amount: "1", costBasis: "200", proceeds: "300", gain: "100", lots: []string{"b"}}},
+			wantOpen:  map[string]string{"BTC": "1"},
+		},
+		{
+			name:   "hifo con empate vende primero el lote más antiguo",
+			method: models.CostBasisHIFO,
+			transactions: []models.CryptoTransaction{
+				lotTransaction("a", buy, "BTC", "1", "200", 1),
+				lotTransaction("b", buy, "BTC", "1", "100", 2),
+				lotTransaction("c", buy, "BTC", "1", "200", 3),
+				lotTransaction("s", sell, "BTC", "1.5", "300", 4),
+			},
+			wantSales: []wantSale{{ticker: "BTC", amount: "1.5", costBasis: "300", proceeds: "450", gain: "150", lots: []string{"a", "c"}}},
+			wantOpen:  map[string]string{"BTC": "1.5"},
+		},
+		{
+			name:   "hifo con lotes de igual costo en distinto orden de compra",
+			method: models.CostBasisHIFO,
+			transactions: []models.CryptoTransaction{
+				lotTransaction("c", buy, "BTC", "1", "150", 3),
+				lotTransaction("a", buy, "BTC", "1", "150", 1),
+				lotTransaction("s", sell, "BTC", "1", "100", 4),
+			},
+			wantSales: []wantSale{{ticker: "BTC", amount: "1", costBasis: "150", proceeds: "100", gain: "-50", lots: []string{"a"}}},
+			wantOpen:  map[string]string{"BTC": "1"},
+		},
+		{
+			name:   "costo promedio",
+			method: models.CostBasisAverage,
+			transactions: []models.CryptoTransaction{
+				lotTransaction("a", buy, "BTC", "1", "100", 1),
+				lotTransaction("b", buy, "BTC", "1", "200", 2),
+				lotTransaction("s", sell, "BTC", "1", "300", 3),
+			},
+			wantSales: []wantSale{{ticker: "BTC", amount: "1", costBasis: "150", proceeds: "300", gain: "150", lots: []string{"a"}}},
+			wantOpen:  map[string]string{"BTC": "1"},
+		},
+		{
+			name:   "venta sin compras que la respalden",
+			method: models.CostBasisFIFO,
+			transactions: []models.CryptoTransaction{
+				lotTransaction("a", buy, "BTC", "1", "100", 1),
+				lotTransaction("s", sell, "BTC", "1.5", "200", 2),
+			},
+			wantSales: []wantSale{{ticker: "BTC", amount: "1.5", costBasis: "100", proceeds: "300", gain: "200", unmatched: "0.5", lots: []string{"a", ""}}},
+			wantOpen:  map[string]string{"BTC": "0"},
+		},
+		{
+			name:   "comisión en otra criptomoneda vende esa moneda",
+			method: models.CostBasisFIFO,
+			transactions: []models.CryptoTransaction{
+				lotTransaction("bnb", buy, "BNB", "1", "300", 1),
+				withFee(lotTransaction("eth", buy, "ETH", "1", "1000", 2), "0.01", "BNB", "5"),
+			},
+			wantSales: []wantSale{{ticker: "BNB", amount: "0.01", costBasis: "3", proceeds: "5", gain: "2", lots: []string{"bnb"}, feeDisposal: true}},
+			wantOpen:  map[string]string{"BNB": "0.99", "ETH": "1"},
+		},
+		{
+			name:   "comisión de una venta en la misma criptomoneda se vende con ella",
+			method: models.CostBasisFIFO,
+			transactions: []models.CryptoTransaction{
+				lotTransaction("a", buy, "BTC", "2", "100", 1),
+				withFee(lotTransaction("s", sell, "BTC", "1", "300", 2), "0.1", "BTC", "30"),
+			},
+			wantSales: []wantSale{{ticker: "BTC", amount: "1.1", costBasis: "110", proceeds: "300", gain: "190", lots: []string{"a"}}},
+			wantOpen:  map[string]string{"BTC": "0.9"},
+		},
+		{
+			name:   "comisión de una transferencia en la misma criptomoneda",
+			method: models.CostBasisFIFO,
+			transactions: []models.CryptoTransaction{
+				lotTransaction("a", buy, "BTC", "1", "100", 1),
+				withFee(lotTransaction("t", models.TransactionTypeTransfer, "BTC", "0.5", "0", 2), "0.1", "BTC", "20"),
+			},
+			wantSales: []wantSale{{ticker: "BTC", amount: "0.1", costBasis: "10", proceeds: "20", gain: "10", lots: []string{"a"}, feeDisposal: true}},
+			wantOpen:  map[string]string{"BTC": "0.9"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger, err := MatchLots(tt.transactions, tt.method)
+			if err != nil {
+				t.Fatalf("MatchLots: %v", err)
+			}
+
+			if len(ledger.Realized) != len(tt.wantSales) {
+				t.Fatalf("ventas = %d, se esperaban %d", len(ledger.Realized), len(tt.wantSales))
+			}
+			for i, want := range tt.wantSales {
+				got := ledger.Realized[i]
+				checkDecimal(t, "amount", got.Amount, want.amount)
+				checkDecimal(t, "cost_basis", got.CostBasis, want.costBasis)
+				checkDecimal(t, "proceeds", got.Proceeds, want.proceeds)
+				checkDecimal(t, "gain", got.Gain, want.gain)
+				if want.unmatched != "" {
+					checkDecimal(t, "unmatched", got.Unmatched, want.unmatched)
+				}
+				if got.Ticker != want.ticker || got.FeeDisposal != want.feeDisposal {
+					t.Errorf("venta %d: ticker %s fee_disposal %v, se esperaba %s %v", i, got.Ticker, got.FeeDisposal, want.ticker, want.feeDisposal)
+				}
+				var lots []string
+				for _, lot := range got.Lots {
+					lots = append(lots, lot.LotTransactionID)
+				}
+				if len(lots) != len(want.lots) {
+					t.Fatalf("venta %d: lotes %v, se esperaban %v", i, lots, want.lots)
+				}
+				for j := range lots {
+					if lots[j] != want.lots[j] {
+						t.Errorf("venta %d: lotes %v, se esperaban %v", i, lots, want.lots)
+						break
+					}
+				}
+			}
+
+			for ticker, want := range tt.wantOpen {
+				amount, _ := ledger.Position(ticker)
+				checkDecimal(t, "posición de "+ticker, amount, want)
+			}
+		})
+	}
+}
+
+func TestMatchLotsInvalidMethod(t *testing.T) {
+	if _, err := MatchLots(nil, "random"); err == nil {
+		t.Fatal("se esperaba un error con un método inválido")
+	}
+}
+
+// checkDecimal compara un decimal con el valor esperado
+func checkDecimal(t *testing.T, field string, got decimal.Decimal, want string) {
+	t.Helper()
+	if !got.Equal(decimal.RequireFromString(want)) {
+		t.Errorf("%s = %s, se esperaba %s", field, got, want)
+	}
+}
@@ -6,99 +6,196 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 )
 
-// CryptoData contiene la informaciu00f3n de precio de una criptomoneda
-type CryptoData struct {
-	Price       float64 `json:"price"`
-	MarketCap   float64 `json:"market_cap"`
-	Volume24h   float64 `json:"volume_24h"`
-	Change24h   float64 `json:"change_24h"`
-	LastUpdated string  `json:"last_updated"`
+// coinGeckoIDs relaciona los tickers más comunes con los ids que usa CoinGecko
+var coinGeckoIDs = map[string]string{
+	"BTC":   "bitcoin",
+	"ETH":   "ethereum",
+	"USDT":  "tether",
+	"USDC":  "usd-coin",
+	"BNB":   "binancecoin",
+	"SOL":   "solana",
+	"XRP":   "ripple",
+	"ADA":   "cardano",
+	"DOGE":  "dogecoin",
+	"DOT":   "polkadot",
+	"AVAX":  "avalanche-2",
+	"MATIC": "matic-network",
+	"LINK":  "chainlink",
+	"LTC":   "litecoin",
+	"TRX":   "tron",
+	"ATOM":  "cosmos",
+	"UNI":   "uniswap",
+	"XLM":   "stellar",
+	"NEAR":  "near",
+	"APT":   "aptos",
+	"ARB":   "arbitrum",
+	"OP":    "optimism",
+	"SHIB":  "shiba-inu",
+	"DAI":   "dai",
 }
 
-// Cachu00e9 para almacenar precios y reducir llamadas a la API
-var priceCache = make(map[string]cachedPrice)
+// coinGeckoMarket es un elemento de la respuesta de /coins/markets
+type coinGeckoMarket struct {
+	ID                       string  `json:"id"`
+	Symbol                   string  `json:"symbol"`
+	Image                    string  `json:"image"`
+	CurrentPrice             float64 `json:"current_price"`
+	PriceChange24h           float64 `json:"price_change_24h"`
+	PriceChangePercentage24h float64 `json:"price_change_percentage_24h"`
+}
 
-type cachedPrice struct {
-	Data      CryptoData
-	Timestamp time.Time
+// CoinGeckoProvider obtiene los precios desde la API de CoinGecko
+type CoinGeckoProvider struct {
+	client *http.Client
 }
 
-// GetCryptoPriceFromCoinGecko obtiene el precio actual de una criptomoneda desde CoinGecko
-func GetCryptoPriceFromCoinGecko(ticker string) (CryptoData, error) {
-	// Verificar si tenemos el precio en cachu00e9 y si es reciente (menos de 5 minutos)
-	if cached, exists := priceCache[ticker]; exists {
-		if time.Since(cached.Timestamp) < 5*time.Minute {
-			return cached.Data, nil
-		}
+// NewCoinGeckoProvider crea un nuevo proveedor de CoinGecko
+func NewCoinGeckoProvider() *CoinGeckoProvider {
+	return &CoinGeckoProvider{
+		client: http.DefaultClient,
+	}
+}
+
+// Name devuelve el identificador del proveedor
+func (p *CoinGeckoProvider) Name() string {
+	return PriceProviderCoinGecko
+}
+
+// GetQuote obtiene la cotización de un único ticker
+func (p *CoinGeckoProvider) GetQuote(ticker string) (PriceQuote, error) {
+	return quoteFromBatch(p, ticker)
+}
+
+// GetQuotes obtiene las cotizaciones de varios tickers en una sola llamada a /coins/markets
+func (p *CoinGeckoProvider) GetQuotes(tickers []string) (map[string]PriceQuote, error) {
+	tickers = normalizeTickers(tickers)
+	if len(tickers) == 0 {
+		return nil, fmt.Errorf("no se proporcionaron tickers")
+	}
+
+	// Traducir los tickers a ids de CoinGecko
+	idToTicker := make(map[string]string, len(tickers))
+	ids := make([]string, 0, len(tickers))
+	for _, ticker := range tickers {
+		id := coinGeckoID(ticker)
+		idToTicker[id] = ticker
+		ids = append(ids, id)
 	}
 
 	// Construir la URL de la API
-	url := fmt.Sprintf("https://api.coingecko.com/api/v3/simple/price?ids=%s&vs_currencies=usd&include_market_cap=true&include_24hr_vol=true&include_24hr_change=true&include_last_updated_at=true", ticker)
+	url := fmt.Sprintf("https://api.coingecko.com/api/v3/coins/markets?vs_currency=usd&ids=%s", strings.Join(ids, ","))
 
 	// Realizar la solicitud HTTP
-	resp, err := http.Get(url)
+	resp, err := p.client.Get(url)
 	if err != nil {
-		log.Printf("Error al obtener precio de %s: %v", ticker, err)
-		return CryptoData{}, err
+		log.Printf("Error al obtener precios de %v: %v", tickers, err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	// Leer el cuerpo de la respuesta
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error al leer respuesta para %s: %v", ticker, err)
-		return CryptoData{}, err
+		log.Printf("Error al leer respuesta para %v: %v", tickers, err)
+		return nil, err
 	}
 
 	// Parsear la respuesta JSON
-	var result map[string]map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("Error al parsear JSON para %s: %v", ticker, err)
-		return CryptoData{}, err
+	var markets []coinGeckoMarket
+	if err := json.Unmarshal(body, &markets); err != nil {
+		log.Printf("Error al parsear JSON para %v: %v", tickers, err)
+		return nil, err
+	}
+
+	quotes := make(map[string]PriceQuote, len(markets))
+	for _, market := range markets {
+		ticker, exists := idToTicker[market.ID]
+		if !exists {
+			ticker = strings.ToUpper(market.Symbol)
+		}
+		quotes[ticker] = PriceQuote{
+			Ticker:       ticker,
+			Price:        market.CurrentPrice,
+			Change24h:    market.PriceChange24h,
+			ChangePct24h: market.PriceChangePercentage24h,
+			ImageURL:     market.Image,
+		}
 	}
 
-	// Extraer los datos
-	tokenData, exists := result[ticker]
+	return quotes, nil
+}
+
+// coinGeckoFXCoin es la moneda que se usa para cotizar el dólar en CoinGecko,
+// que no ofrece cotizaciones entre monedas fiat
+const coinGeckoFXCoin = "tether"
+
+// GetFXRate obtiene cuántas unidades de la moneda equivalen a 1 USD a través de la cotización de USDT
+func (p *CoinGeckoProvider) GetFXRate(currency string) (float64, error) {
+	currency = strings.ToLower(currency)
+	url := fmt.Sprintf("https://api.coingecko.com/api/v3/simple/price?ids=%s&vs_currencies=%s", coinGeckoFXCoin, currency)
+
+	var result map[string]map[string]float64
+	if err := p.getJSON(url, &result); err != nil {
+		return 0, err
+	}
+	rate, exists := result[coinGeckoFXCoin][currency]
 	if !exists {
-		return CryptoData{}, fmt.Errorf("no se encontraron datos para %s", ticker)
+		return 0, fmt.Errorf("no se encontró la cotización de %s", strings.ToUpper(currency))
 	}
+	return rate, nil
+}
+
+// GetFXHistory obtiene la cotización diaria de la moneda entre dos fechas a través de la cotización de USDT
+func (p *CoinGeckoProvider) GetFXHistory(currency string, from, to time.Time) (map[string]float64, error) {
+	currency = strings.ToLower(currency)
+	url := fmt.Sprintf("https://api.coingecko.com/api/v3/coins/%s/market_chart/range?vs_currency=%s&from=%d&to=%d",
+		coinGeckoFXCoin, currency, from.Unix(), to.Unix())
 
-	// Crear el objeto CryptoData
-	data := CryptoData{
-		Price:       getFloat(tokenData, "usd"),
-		MarketCap:   getFloat(tokenData, "usd_market_cap"),
-		Volume24h:   getFloat(tokenData, "usd_24h_vol"),
-		Change24h:   getFloat(tokenData, "usd_24h_change"),
-		LastUpdated: time.Unix(int64(getFloat(tokenData, "last_updated_at")), 0).Format(time.RFC3339),
+	var result struct {
+		Prices [][2]float64 `json:"prices"`
+	}
+	if err := p.getJSON(url, &result); err != nil {
+		return nil, err
 	}
 
-	// Guardar en cachu00e9
-	priceCache[ticker] = cachedPrice{
-		Data:      data,
-		Timestamp: time.Now(),
+	// Con rangos largos CoinGecko devuelve un punto por día; con rangos cortos, varios.
+	// Nos quedamos con el último de cada día.
+	history := make(map[string]float64, len(result.Prices))
+	for _, point := range result.Prices {
+		if point[1] > 0 {
+			history[time.UnixMilli(int64(point[0])).UTC().Format(fxDayLayout)] = point[1]
+		}
 	}
+	return history, nil
+}
 
-	return data, nil
+// getJSON realiza una petición GET y decodifica la respuesta JSON
+func (p *CoinGeckoProvider) getJSON(url string, target interface{}) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("CoinGecko respondió %d: %s", resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, target)
 }
 
-// getFloat extrae un valor float64 de un mapa
-func getFloat(data map[string]interface{}, key string) float64 {
-	if val, exists := data[key]; exists {
-		switch v := val.(type) {
-		case float64:
-			return v
-		case int:
-			return float64(v)
-		case int64:
-			return float64(v)
-		case string:
-			var f float64
-			fmt.Sscanf(v, "%f", &f)
-			return f
-		}
+// coinGeckoID devuelve el id de CoinGecko para un ticker.
+// Si el ticker no está en la tabla se asume que ya es un id de CoinGecko.
+func coinGeckoID(ticker string) string {
+	if id, exists := coinGeckoIDs[strings.ToUpper(ticker)]; exists {
+		return id
 	}
-	return 0
+	return strings.ToLower(ticker)
 }
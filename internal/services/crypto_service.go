@@ -6,86 +6,230 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
 )
 
-func GetCryptoPrice(ticker string) (*models.Welcome, error) {
-	apiKey := os.Getenv("CRYPTO_API_KEY")
+// Cantidad máxima de tickers por petición a pricemultifull
+const cryptoCompareBatchSize = 50
+
+// CryptoCompareProvider obtiene los precios desde la API de CryptoCompare
+type CryptoCompareProvider struct {
+	apiKey string
+	client *http.Client
+}
+
+// NewCryptoCompareProvider crea un nuevo proveedor de CryptoCompare
+func NewCryptoCompareProvider(apiKey string) *CryptoCompareProvider {
+	return &CryptoCompareProvider{
+		apiKey: apiKey,
+		client: http.DefaultClient,
+	}
+}
+
+// Name devuelve el identificador del proveedor
+func (p *CryptoCompareProvider) Name() string {
+	return PriceProviderCryptoCompare
+}
+
+// GetQuote obtiene la cotización de un único ticker
+func (p *CryptoCompareProvider) GetQuote(ticker string) (PriceQuote, error) {
+	return quoteFromBatch(p, ticker)
+}
+
+// GetQuotes obtiene las cotizaciones de varios tickers usando pricemultifull con múltiples fsyms
+func (p *CryptoCompareProvider) GetQuotes(tickers []string) (map[string]PriceQuote, error) {
+	tickers = normalizeTickers(tickers)
+	if len(tickers) == 0 {
+		return nil, fmt.Errorf("no se proporcionaron tickers")
+	}
+
+	quotes := make(map[string]PriceQuote, len(tickers))
+	for start := 0; start < len(tickers); start += cryptoCompareBatchSize {
+		end := start + cryptoCompareBatchSize
+		if end > len(tickers) {
+			end = len(tickers)
+		}
+
+		result, err := p.fetchPriceMultiFull(tickers[start:end])
+		if err != nil {
+			return nil, err
+		}
+
+		for ticker, data := range result.Raw {
+			raw, exists := data["USD"]
+			if !exists {
+				continue
+			}
+			quotes[ticker] = PriceQuote{
+				Ticker:       ticker,
+				Price:        raw.PRICE,
+				Change24h:    raw.CHANGE24HOUR,
+				ChangePct24h: raw.CHANGEPCT24HOUR,
+				ImageURL:     cryptoCompareImageURL(ticker, raw.IMAGEURL),
+			}
+		}
+	}
+
+	return quotes, nil
+}
+
+// fetchPriceMultiFull realiza la petición a pricemultifull para un lote de tickers
+func (p *CryptoCompareProvider) fetchPriceMultiFull(tickers []string) (*models.Welcome, error) {
+	tickersStr := strings.Join(tickers, ",")
 	url := fmt.Sprintf("https://min-api.cryptocompare.com/data/pricemultifull?fsyms=%s&tsyms=USD&api_key=%s",
-		ticker, apiKey)
+		tickersStr, p.apiKey)
 
-	resp, err := http.Get(url)
+	resp, err := p.client.Get(url)
 	if err != nil {
-		log.Printf("Error haciendo la petición HTTP para %s: %v", ticker, err)
+		log.Printf("Error haciendo la petición HTTP para %s: %v", tickersStr, err)
 		return nil, fmt.Errorf("error en la petición HTTP: %v", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error leyendo el cuerpo de la respuesta para %s: %v", ticker, err)
+		log.Printf("Error leyendo el cuerpo de la respuesta para %s: %v", tickersStr, err)
 		return nil, fmt.Errorf("error leyendo respuesta: %v", err)
 	}
 
 	var result models.Welcome
 	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("Error decodificando JSON para %s: %v", ticker, err)
+		log.Printf("Error decodificando JSON para %s: %v", tickersStr, err)
 		return nil, fmt.Errorf("error decodificando JSON: %v", err)
 	}
 
-	if _, exists := result.Raw[ticker]; !exists {
-		log.Printf("No se encontraron datos para el ticker %s", ticker)
-		return nil, fmt.Errorf("no se encontraron datos para %s", ticker)
-	}
-
 	return &result, nil
 }
 
-// GetMultipleCryptoPrices obtiene los precios actuales de múltiples criptomonedas en una sola llamada a la API
-func GetMultipleCryptoPrices(tickers []string) (map[string]float64, error) {
-	if len(tickers) == 0 {
-		return nil, fmt.Errorf("no se proporcionaron tickers")
+// cryptoCompareHistoDay es la respuesta de /data/v2/histoday
+type cryptoCompareHistoDay struct {
+	Response string `json:"Response"`
+	Message  string `json:"Message"`
+	Data     struct {
+		Data []struct {
+			Time  int64   `json:"time"`
+			Close float64 `json:"close"`
+		} `json:"Data"`
+	} `json:"Data"`
+}
+
+// Cantidad máxima de días que devuelve histoday en una petición
+const cryptoCompareHistoDayLimit = 2000
+
+// GetFXRate obtiene cuántas unidades de la moneda equivalen a 1 USD
+func (p *CryptoCompareProvider) GetFXRate(currency string) (float64, error) {
+	currency = strings.ToUpper(currency)
+	url := fmt.Sprintf("https://min-api.cryptocompare.com/data/price?fsym=USD&tsyms=%s&api_key=%s", currency, p.apiKey)
+
+	var result map[string]float64
+	if err := p.getJSON(url, &result); err != nil {
+		return 0, err
 	}
+	rate, exists := result[currency]
+	if !exists {
+		return 0, fmt.Errorf("no se encontró la cotización de %s", currency)
+	}
+	return rate, nil
+}
 
-	// Unir los tickers en una cadena separada por comas
-	tickersStr := strings.Join(tickers, ",")
+// GetFXHistory obtiene el cierre diario de la moneda contra el dólar entre dos fechas.
+// histoday devuelve como máximo cryptoCompareHistoDayLimit días por petición, así que los rangos
+// más largos se piden en tramos hacia atrás hasta cubrir la fecha inicial.
+func (p *CryptoCompareProvider) GetFXHistory(currency string, from, to time.Time) (map[string]float64, error) {
+	currency = strings.ToUpper(currency)
+	history := make(map[string]float64)
+	for end := to; ; {
+		limit := int(end.Sub(from).Hours()/24) + 1
+		if limit > cryptoCompareHistoDayLimit {
+			limit = cryptoCompareHistoDayLimit
+		}
+		if limit < 1 {
+			limit = 1
+		}
+		url := fmt.Sprintf("https://min-api.cryptocompare.com/data/v2/histoday?fsym=USD&tsym=%s&limit=%d&toTs=%d&api_key=%s",
+			currency, limit, end.Unix(), p.apiKey)
 
-	// Construir la URL de la API
-	apiKey := os.Getenv("CRYPTO_API_KEY")
-	url := fmt.Sprintf("https://min-api.cryptocompare.com/data/pricemulti?fsyms=%s&tsyms=USD&api_key=%s",
-		tickersStr, apiKey)
+		var result cryptoCompareHistoDay
+		if err := p.getJSON(url, &result); err != nil {
+			return nil, err
+		}
+		if result.Response == "Error" {
+			return nil, fmt.Errorf("%s", result.Message)
+		}
+
+		earliest := end
+		found := false
+		for _, day := range result.Data.Data {
+			if day.Close > 0 {
+				date := time.Unix(day.Time, 0).UTC()
+				history[date.Format(fxDayLayout)] = day.Close
+				if date.Before(earliest) {
+					earliest = date
+				}
+				found = true
+			}
+		}
+		// Terminar al cubrir la fecha inicial o cuando el proveedor ya no tiene días más antiguos
+		if !found || !earliest.After(from) || !earliest.Before(end) {
+			break
+		}
+		end = earliest.AddDate(0, 0, -1)
+	}
+	return history, nil
+}
 
-	// Realizar la petición HTTP
-	resp, err := http.Get(url)
+// getJSON realiza una petición GET y decodifica la respuesta JSON
+func (p *CryptoCompareProvider) getJSON(url string, target interface{}) error {
+	resp, err := p.client.Get(url)
 	if err != nil {
-		log.Printf("Error haciendo la petición HTTP para múltiples tickers: %v", err)
-		return nil, fmt.Errorf("error en la petición HTTP: %v", err)
+		return fmt.Errorf("error en la petición HTTP: %v", err)
 	}
 	defer resp.Body.Close()
 
-	// Leer el cuerpo de la respuesta
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error leyendo el cuerpo de la respuesta para múltiples tickers: %v", err)
-		return nil, fmt.Errorf("error leyendo respuesta: %v", err)
+		return fmt.Errorf("error leyendo respuesta: %v", err)
 	}
+	if err := json.Unmarshal(body, target); err != nil {
+		return fmt.Errorf("error decodificando JSON: %v", err)
+	}
+	return nil
+}
 
-	// Decodificar la respuesta JSON
-	var result map[string]map[string]float64
-	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("Error decodificando JSON para múltiples tickers: %v", err)
-		return nil, fmt.Errorf("error decodificando JSON: %v", err)
+// cryptoCompareImageURL construye la URL completa de la imagen de una criptomoneda
+func cryptoCompareImageURL(ticker, imageURL string) string {
+	// Si la URL está vacía, construir una URL por defecto usando el servicio de CryptoCompare
+	if imageURL == "" {
+		return fmt.Sprintf("https://www.cryptocompare.com/media/37746251/%s.png", strings.ToLower(ticker))
+	}
+
+	// Asegurarse de que la URL sea completa
+	if !strings.HasPrefix(imageURL, "http") {
+		return "https://www.cryptocompare.com" + imageURL
+	}
+
+	return imageURL
+}
+
+// GetMultipleCryptoPrices obtiene los precios actuales de múltiples criptomonedas en una sola llamada al proveedor
+func GetMultipleCryptoPrices(tickers []string) (map[string]float64, error) {
+	if len(tickers) == 0 {
+		return nil, fmt.Errorf("no se proporcionaron tickers")
+	}
+
+	quotes, err := GetPriceProvider().GetQuotes(tickers)
+	if err != nil {
+		log.Printf("Error obteniendo precios para múltiples tickers: %v", err)
+		return nil, err
 	}
 
 	// Extraer los precios en USD
-	prices := make(map[string]float64)
-	for ticker, data := range result {
-		if usdPrice, exists := data["USD"]; exists {
-			prices[ticker] = usdPrice
-		}
+	prices := make(map[string]float64, len(quotes))
+	for ticker, quote := range quotes {
+		prices[ticker] = quote.Price
 	}
 
 	// Verificar que obtuvimos al menos un precio
@@ -93,39 +237,14 @@ func GetMultipleCryptoPrices(tickers []string) (map[string]float64, error) {
 		return nil, fmt.Errorf("no se encontraron precios para los tickers proporcionados")
 	}
 
-	// Registrar los precios obtenidos para depuración
-	log.Printf("Precios obtenidos para %d criptomonedas:", len(prices))
-	for ticker, price := range prices {
-		log.Printf("  - %s: %.2f USD", ticker, price)
-	}
-
 	return prices, nil
 }
 
+// GetCryptoImageURL obtiene la URL de la imagen de una criptomoneda desde el proveedor configurado
 func GetCryptoImageURL(ticker string) (string, error) {
-	// Intentar obtener todos los datos de la criptomoneda, que incluyen la URL de la imagen
-	cryptoData, err := GetCryptoPrice(ticker)
+	quote, err := GetPriceProvider().GetQuote(ticker)
 	if err != nil {
 		return "", err
 	}
-
-	// Verificar si existe la información del ticker
-	if _, exists := cryptoData.Raw[ticker]; !exists {
-		return "", fmt.Errorf("no se encontraron datos para %s", ticker)
-	}
-
-	// Obtener la URL de la imagen
-	imageURL := cryptoData.Raw[ticker]["USD"].IMAGEURL
-
-	// Si la URL está vacía, construir una URL por defecto usando el servicio de CryptoCompare
-	if imageURL == "" {
-		imageURL = fmt.Sprintf("https://www.cryptocompare.com/media/37746251/%s.png", strings.ToLower(ticker))
-	} else {
-		// Asegurarse de que la URL sea completa
-		if !strings.HasPrefix(imageURL, "http") {
-			imageURL = "https://www.cryptocompare.com" + imageURL
-		}
-	}
-
-	return imageURL, nil
+	return quote.ImageURL, nil
 }
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+)
+
+// cronSearchLimit evita búsquedas infinitas con expresiones que nunca se cumplen (por ejemplo 31 de febrero)
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// CronSchedule es una expresión cron estándar de 5 campos: minuto hora día-del-mes mes día-de-la-semana.
+// Cada campo admite "*", valores, rangos (1-5), listas (1,15) y pasos (*/15, 0-30/10).
+type CronSchedule struct {
+	minutes     [60]bool
+	hours       [24]bool
+	daysOfMonth [32]bool
+	months      [13]bool
+	daysOfWeek  [7]bool
+	anyDOM      bool
+	anyDOW      bool
+}
+
+// ParseCronExpression interpreta una expresión cron de 5 campos
+func ParseCronExpression(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("la expresión cron debe tener 5 campos, tiene %d", len(fields))
+	}
+
+	schedule := &CronSchedule{
+		anyDOM: fields[2] == "*",
+		anyDOW: fields[4] == "*",
+	}
+
+	if err := parseCronField(fields[0], 0, 59, schedule.minutes[:]); err != nil {
+		return nil, fmt.Errorf("minuto inválido: %v", err)
+	}
+	if err := parseCronField(fields[1], 0, 23, schedule.hours[:]); err != nil {
+		return nil, fmt.Errorf("hora inválida: %v", err)
+	}
+	if err := parseCronField(fields[2], 1, 31, schedule.daysOfMonth[:]); err != nil {
+		return nil, fmt.Errorf("día del mes inválido: %v", err)
+	}
+	if err := parseCronField(fields[3], 1, 12, schedule.months[:]); err != nil {
+		return nil, fmt.Errorf("mes inválido: %v", err)
+	}
+
+	// El día de la semana admite 0-7, donde tanto 0 como 7 son domingo
+	var daysOfWeek [8]bool
+	if err := parseCronField(fields[4], 0, 7, daysOfWeek[:]); err != nil {
+		return nil, fmt.Errorf("día de la semana inválido: %v", err)
+	}
+	copy(schedule.daysOfWeek[:], daysOfWeek[:7])
+	if daysOfWeek[7] {
+		schedule.daysOfWeek[0] = true
+	}
+
+	return schedule, nil
+}
+
+// parseCronField marca en values los valores permitidos por un campo cron
+func parseCronField(field string, min, max int, values []bool) error {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			parsed, err := strconv.Atoi(part[idx+1:])
+			if err != nil || parsed <= 0 {
+				return fmt.Errorf("paso inválido en %q", part)
+			}
+			step = parsed
+			part = part[:idx]
+		}
+
+		start, end := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return fmt.Errorf("rango inválido %q", part)
+			}
+			if end, err = strconv.Atoi(bounds[1]); err != nil {
+				return fmt.Errorf("rango inválido %q", part)
+			}
+		default:
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return fmt.Errorf("valor inválido %q", part)
+			}
+			start, end = value, value
+			if step > 1 {
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return fmt.Errorf("valor fuera de rango en %q (%d-%d)", part, min, max)
+		}
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+	return nil
+}
+
+// matchesDay indica si la fecha cumple las restricciones de día del mes y día de la semana.
+// Como en cron, si ambos campos están restringidos basta con que se cumpla uno de ellos.
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	dom := s.daysOfMonth[t.Day()]
+	dow := s.daysOfWeek[int(t.Weekday())]
+	switch {
+	case s.anyDOM && s.anyDOW:
+		return true
+	case s.anyDOM:
+		return dow
+	case s.anyDOW:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Next devuelve el primer minuto igual o posterior a from que cumple la expresión.
+// Con cambios de horario, las ejecuciones que caen en la hora que se saltea se hacen al terminar
+// el salto y las de la hora que se repite se hacen una sola vez, en su primera ocurrencia.
+func (s *CronSchedule) Next(from time.Time) (time.Time, bool) {
+	t := from.Truncate(time.Minute)
+	if t.Before(from) {
+		t = t.Add(time.Minute)
+	}
+	limit := from.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		var next time.Time
+		switch {
+		case !s.months[int(t.Month())]:
+			next = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			next = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hours[t.Hour()]:
+			next = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minutes[t.Minute()] || repeatedWallClock(t):
+			next = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+
+		// Una hora que no existe por el cambio de horario puede devolver un momento anterior
+		if !next.After(t) {
+			next = t.Add(time.Minute)
+		}
+		if s.matchesSkipped(t, next) {
+			return next, true
+		}
+		t = next
+	}
+	return time.Time{}, false
+}
+
+// wallClock devuelve la fecha y hora que marca el reloj local, en UTC para poder compararlas
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+// matchesSkipped indica si algún minuto que el reloj local saltea entre t y next cumple la expresión
+func (s *CronSchedule) matchesSkipped(t, next time.Time) bool {
+	gap := wallClock(next).Sub(wallClock(t)) - next.Sub(t)
+	for wall := wallClock(next).Add(-gap); wall.Before(wallClock(next)); wall = wall.Add(time.Minute) {
+		if s.months[int(wall.Month())] && s.matchesDay(wall) && s.hours[wall.Hour()] && s.minutes[wall.Minute()] {
+			return true
+		}
+	}
+	return false
+}
+
+// repeatedWallClock indica si la hora local de t ya ocurrió antes porque el reloj se atrasó
+func repeatedWallClock(t time.Time) bool {
+	_, offset := t.Zone()
+	_, earlierOffset := t.Add(-2 * time.Hour).Zone()
+	if earlierOffset <= offset {
+		return false
+	}
+	earlier := t.Add(-time.Duration(earlierOffset-offset) * time.Second)
+	_, offsetThen := earlier.Zone()
+	return offsetThen == earlierOffset && wallClock(earlier).Equal(wallClock(t))
+}
+
+// ValidateDCAPlan verifica que la configuración de un plan DCA sea válida
+func ValidateDCAPlan(plan models.DCAPlan) error {
+	if !plan.AmountUSD.IsPositive() {
+		return fmt.Errorf("el monto por periodo debe ser mayor a 0")
+	}
+
+	switch plan.Cadence {
+	case models.DCACadenceDaily, models.DCACadenceWeekly, models.DCACadenceMonthly:
+	case models.DCACadenceCron:
+		if _, err := ParseCronExpression(plan.CronExpr); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("frecuencia inválida %q (daily, weekly, monthly o cron)", plan.Cadence)
+	}
+
+	if plan.EndDate != nil && !plan.EndDate.After(plan.StartDate) {
+		return fmt.Errorf("la fecha de fin debe ser posterior a la fecha de inicio")
+	}
+	return nil
+}
+
+// NextDCARun devuelve la primera ejecución del plan igual o posterior a from.
+// Las frecuencias daily/weekly/monthly se cuentan desde la fecha de inicio del plan.
+// El segundo valor es false si el plan ya no tiene más ejecuciones (pasó su fecha de fin).
+func NextDCARun(plan models.DCAPlan, from time.Time) (time.Time, bool) {
+	if from.Before(plan.StartDate) {
+		from = plan.StartDate
+	}
+
+	var next time.Time
+	switch plan.Cadence {
+	case models.DCACadenceDaily:
+		next = nextPeriodicRun(plan.StartDate, from, 24*time.Hour)
+	case models.DCACadenceWeekly:
+		next = nextPeriodicRun(plan.StartDate, from, 7*24*time.Hour)
+	case models.DCACadenceMonthly:
+		next = nextMonthlyRun(plan.StartDate, from)
+	case models.DCACadenceCron:
+		schedule, err := ParseCronExpression(plan.CronExpr)
+		if err != nil {
+			return time.Time{}, false
+		}
+		var found bool
+		if next, found = schedule.Next(from); !found {
+			return time.Time{}, false
+		}
+	default:
+		return time.Time{}, false
+	}
+
+	if plan.EndDate != nil && next.After(*plan.EndDate) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// nextPeriodicRun calcula la primera ocurrencia start + k*period igual o posterior a from
+func nextPeriodicRun(start, from time.Time, period time.Duration) time.Time {
+	if !from.After(start) {
+		return start
+	}
+	periods := (from.Sub(start) + period - 1) / period
+	return start.Add(periods * period)
+}
+
+// nextMonthlyRun calcula la primera ocurrencia mensual desde start igual o posterior a from.
+// Si el día no existe en el mes (por ejemplo el 31), se usa el último día del mes.
+func nextMonthlyRun(start, from time.Time) time.Time {
+	months := (from.Year()-start.Year())*12 + int(from.Month()) - int(start.Month())
+	if months < 0 {
+		months = 0
+	}
+	for {
+		next := addMonthsClamped(start, months)
+		if !next.Before(from) {
+			return next
+		}
+		months++
+	}
+}
+
+// addMonthsClamped suma meses a una fecha sin desbordar al mes siguiente
+func addMonthsClamped(t time.Time, months int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return firstOfMonth.AddDate(0, 0, day-1)
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseCronExpression(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{name: "todos los campos", expr: "* * * * *"},
+		{name: "listas, rangos y pasos", expr: "0,30 9-17 1-31/2 */3 1-5"},
+		{name: "domingo como 7", expr: "0 10 * * 7"},
+		{name: "paso desde un valor", expr: "5/15 * * * *"},
+		{name: "faltan campos", expr: "0 10 * *", wantErr: true},
+		{name: "minuto fuera de rango", expr: "60 * * * *", wantErr: true},
+		{name: "hora fuera de rango", expr: "0 24 * * *", wantErr: true},
+		{name: "día del mes cero", expr: "0 0 0 * *", wantErr: true},
+		{name: "rango invertido", expr: "0 0 * 5-2 *", wantErr: true},
+		{name: "paso cero", expr: "*/0 * * * *", wantErr: true},
+		{name: "valor no numérico", expr: "a * * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCronExpression(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, se esperaba error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("no se pudo cargar la zona horaria: %v", err)
+	}
+	utc := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	local := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, newYork)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want []time.Time // Ejecuciones consecutivas esperadas
+	}{
+		{
+			name: "mismo minuto incluido",
+			expr: "30 9 * * *",
+			from: utc(time.May, 1, 9, 30),
+			want: []time.Time{utc(time.May, 1, 9, 30), utc(time.May, 2, 9, 30)},
+		},
+		{
+			name: "segundos redondean al minuto siguiente",
+			expr: "* * * * *",
+			from: utc(time.May, 1, 9, 30).Add(10 * time.Second),
+			want: []time.Time{utc(time.May, 1, 9, 31)},
+		},
+		{
+			name: "día del mes o de la semana",
+			expr: "0 0 15 * 1",
+			from: utc(time.May, 1, 0, 0),
+			want: []time.Time{utc(time.May, 6, 0, 0), utc(time.May, 13, 0, 0), utc(time.May, 15, 0, 0)},
+		},
+		{
+			name: "29 de febrero en año bisiesto",
+			expr: "0 12 29 2 *",
+			from: utc(time.January, 1, 0, 0),
+			want: []time.Time{utc(time.February, 29, 12, 0), time.Date(2028, time.February, 29, 12, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "hora salteada por el horario de verano se ejecuta al terminar el salto",
+			expr: "30 2 * * *",
+			from: local(time.March, 9, 12, 0),
+			want: []time.Time{local(time.March, 10, 3, 0), local(time.March, 11, 2, 30)},
+		},
+		{
+			name: "varias ejecuciones dentro del salto se hacen una sola vez",
+			expr: "*/20 * * * *",
+			from: local(time.March, 10, 1, 50),
+			want: []time.Time{local(time.March, 10, 3, 0), local(time.March, 10, 3, 20)},
+		},
+		{
+			name: "ejecución antes del salto no cambia",
+			expr: "0 1 * * *",
+			from: local(time.March, 10, 0, 0),
+			want: []time.Time{local(time.March, 10, 1, 0), local(time.March, 11, 1, 0)},
+		},
+		{
+			name: "hora repetida al volver al horario estándar se ejecuta una vez",
+			expr: "30 1 * * *",
+			from: local(time.November, 3, 0, 0),
+			want: []time.Time{
+				time.Date(2024, time.November, 3, 5, 30, 0, 0, time.UTC), // 01:30 EDT
+				local(time.November, 4, 1, 30),
+			},
+		},
+		{
+			name: "cada media hora durante la hora repetida",
+			expr: "*/30 * * * *",
+			from: local(time.November, 3, 0, 50),
+			want: []time.Time{
+				time.Date(2024, time.November, 3, 5, 0, 0, 0, time.UTC),  // 01:00 EDT
+				time.Date(2024, time.November, 3, 5, 30, 0, 0, time.UTC), // 01:30 EDT
+				time.Date(2024, time.November, 3, 7, 0, 0, 0, time.UTC),  // 02:00 EST
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCronExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCronExpression: %v", err)
+			}
+			from := tt.from
+			for i, want := range tt.want {
+				got, ok := schedule.Next(from)
+				if !ok {
+					t.Fatalf("ejecución %d: no se encontró, se esperaba %v", i, want)
+				}
+				if !got.Equal(want) {
+					t.Fatalf("ejecución %d = %v, se esperaba %v", i, got, want)
+				}
+				from = got.Add(time.Minute)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNextNeverMatches(t *testing.T) {
+	schedule, err := ParseCronExpression("0 0 31 2 *")
+	if err != nil {
+		t.Fatalf("ParseCronExpression: %v", err)
+	}
+	if next, ok := schedule.Next(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)); ok {
+		t.Errorf("no se esperaba ejecución para el 31 de febrero, se obtuvo %v", next)
+	}
+}
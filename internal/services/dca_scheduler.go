@@ -0,0 +1,157 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// DCAPlanStore define las operaciones que el programador necesita del repositorio de planes DCA
+type DCAPlanStore interface {
+	GetDueDCAPlans(now time.Time) ([]models.DCAPlan, error)
+	RecordDCAExecution(plan models.DCAPlan, execution models.DCAExecution, transaction *models.CryptoTransaction, nextRunAt *time.Time) error
+}
+
+// DCAScheduler ejecuta periódicamente los planes DCA pendientes, convirtiendo cada
+// ejecución en una transacción de compra al precio del momento
+type DCAScheduler struct {
+	store     DCAPlanStore
+	interval  time.Duration
+	isRunning bool
+	stopChan  chan struct{}
+	mutex     sync.Mutex
+}
+
+// NewDCAScheduler crea un nuevo programador de planes DCA que revisa los planes cada minuto
+func NewDCAScheduler(store DCAPlanStore) *DCAScheduler {
+	return &DCAScheduler{
+		store:    store,
+		interval: time.Minute,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start inicia el programador de planes DCA
+func (s *DCAScheduler) Start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isRunning {
+		log.Println("El programador de planes DCA ya está en ejecución")
+		return
+	}
+
+	s.isRunning = true
+	s.stopChan = make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		// Ejecutar inmediatamente los planes que quedaron pendientes mientras el servidor estaba detenido
+		s.RunDuePlans(time.Now())
+
+		for {
+			select {
+			case <-ticker.C:
+				s.RunDuePlans(time.Now())
+			case <-s.stopChan:
+				return
+			}
+		}
+	}()
+
+	log.Printf("Programador de planes DCA iniciado (revisando cada %v)", s.interval)
+}
+
+// Stop detiene el programador de planes DCA
+func (s *DCAScheduler) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.isRunning {
+		return
+	}
+
+	s.isRunning = false
+	close(s.stopChan)
+	log.Printf("Programador de planes DCA detenido")
+}
+
+// RunDuePlans ejecuta todos los planes cuya próxima ejecución es anterior o igual a now
+func (s *DCAScheduler) RunDuePlans(now time.Time) {
+	plans, err := s.store.GetDueDCAPlans(now)
+	if err != nil {
+		log.Printf("Error al obtener planes DCA pendientes: %v", err)
+		return
+	}
+
+	for _, plan := range plans {
+		execution, err := s.executePlan(plan, now)
+		if err != nil {
+			log.Printf("Error al registrar la ejecución del plan DCA %s: %v", plan.ID, err)
+			continue
+		}
+		log.Printf("Plan DCA %s ejecutado (%s): %s %s a %s", plan.ID, execution.Status, execution.Amount, plan.Ticker, execution.Price)
+	}
+}
+
+// executePlan realiza una ejecución de un plan y la registra junto con su resultado.
+// Si el servidor estuvo detenido varios periodos, solo se realiza una compra y el plan
+// continúa desde el próximo periodo futuro.
+func (s *DCAScheduler) executePlan(plan models.DCAPlan, now time.Time) (models.DCAExecution, error) {
+	scheduledAt := *plan.NextRunAt
+	execution := models.DCAExecution{
+		ID:          models.GenerateUUID(),
+		PlanID:      plan.ID,
+		UserID:      plan.UserID,
+		ScheduledAt: scheduledAt,
+		ExecutedAt:  now,
+	}
+
+	var transaction *models.CryptoTransaction
+	quote, err := GetAssetQuote(plan.AssetClass, plan.Ticker)
+	switch {
+	case err != nil:
+		execution.Status = models.DCAExecutionFailed
+		execution.Error = fmt.Sprintf("error al obtener precio de %s: %v", plan.Ticker, err)
+	case quote.Price <= 0:
+		execution.Status = models.DCAExecutionFailed
+		execution.Error = fmt.Sprintf("precio inválido para %s: %.8f", plan.Ticker, quote.Price)
+	default:
+		execution.Status = models.DCAExecutionSuccess
+		execution.Price = decimal.NewFromFloat(quote.Price)
+		execution.Amount = RoundAmount(plan.Ticker, plan.AmountUSD.Div(execution.Price))
+		execution.Total = plan.AmountUSD
+
+		transaction = &models.CryptoTransaction{
+			UserID:        plan.UserID,
+			CryptoName:    plan.CryptoName,
+			Ticker:        plan.Ticker,
+			Amount:        execution.Amount,
+			PurchasePrice: execution.Price,
+			Total:         execution.Total,
+			Date:          now,
+			Note:          fmt.Sprintf("Compra automática del plan DCA %s", plan.ID),
+			Type:          models.TransactionTypeBuy,
+			ImageURL:      quote.ImageURL,
+			AssetClass:    AssetClassOrDefault(plan.AssetClass),
+		}
+	}
+
+	// Calcular la próxima ejecución a partir de ahora, sin repetir periodos perdidos
+	from := now
+	if !from.After(scheduledAt) {
+		from = scheduledAt.Add(time.Nanosecond)
+	}
+	var nextRunAt *time.Time
+	if next, ok := NextDCARun(plan, from); ok {
+		nextRunAt = &next
+	}
+
+	return execution, s.store.RecordDCAExecution(plan, execution, transaction, nextRunAt)
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// Formatos de los valores decimales en las respuestas JSON (variable DECIMAL_JSON_FORMAT)
+const (
+	DecimalJSONNumber = "number" // 0.1 (por defecto, compatible con los clientes existentes)
+	DecimalJSONString = "string" // "0.1", sin pérdida de precisión en clientes que leen números como float64
+)
+
+// Decimales con los que se guardan los valores
+const (
+	DefaultAssetPrecision int32 = 8  // Cantidad de los activos sin regla propia
+	PricePrecision        int32 = 12 // Precio unitario en dólares (las monedas de baja capitalización valen fracciones de centavo)
+	USDPrecision          int32 = 8  // Totales, comisiones y demás valores en dólares
+)
+
+// assetPrecisions son los decimales con los que se registra la cantidad de cada activo,
+// según la unidad mínima de su red o del exchange donde se opera habitualmente
+var assetPrecisions = map[string]int32{
+	"BTC":   8,
+	"ETH":   18,
+	"BNB":   18,
+	"USDT":  6,
+	"USDC":  6,
+	"DAI":   18,
+	"SOL":   9,
+	"ADA":   6,
+	"XRP":   6,
+	"DOT":   10,
+	"DOGE":  8,
+	"LTC":   8,
+	"TRX":   6,
+	"AVAX":  18,
+	"MATIC": 18,
+	"SHIB":  18,
+	"ATOM":  6,
+}
+
+// ConfigureDecimalJSON aplica el formato JSON de los valores decimales indicado por la configuración
+func ConfigureDecimalJSON() {
+	format := strings.ToLower(strings.TrimSpace(os.Getenv("DECIMAL_JSON_FORMAT")))
+	switch format {
+	case "", DecimalJSONNumber:
+		decimal.MarshalJSONWithoutQuotes = true
+	case DecimalJSONString:
+		decimal.MarshalJSONWithoutQuotes = false
+	default:
+		log.Printf("Formato JSON de decimales desconocido %q, usando números", format)
+		decimal.MarshalJSONWithoutQuotes = true
+	}
+}
+
+// AssetPrecision devuelve los decimales con los que se registra la cantidad de un activo
+func AssetPrecision(ticker string) int32 {
+	if precision, exists := assetPrecisions[strings.ToUpper(strings.TrimSpace(ticker))]; exists {
+		return precision
+	}
+	return DefaultAssetPrecision
+}
+
+// RoundAmount redondea una cantidad a la precisión de su activo
+func RoundAmount(ticker string, amount decimal.Decimal) decimal.Decimal {
+	return amount.Round(AssetPrecision(ticker))
+}
+
+// RoundUSD redondea un valor en dólares
+func RoundUSD(value decimal.Decimal) decimal.Decimal {
+	return value.Round(USDPrecision)
+}
+
+// RoundTransaction redondea los valores de una transacción a la precisión con la que se guardan
+func RoundTransaction(transaction *models.CryptoTransaction) {
+	transaction.Amount = RoundAmount(transaction.Ticker, transaction.Amount)
+	transaction.PurchasePrice = transaction.PurchasePrice.Round(PricePrecision)
+	transaction.Total = RoundUSD(transaction.Total)
+	transaction.USDTReceived = RoundAmount("USDT", transaction.USDTReceived)
+	if IsUSDFee(*transaction) {
+		transaction.Fee = RoundUSD(transaction.Fee)
+	} else {
+		transaction.Fee = RoundAmount(transaction.FeeCurrency, transaction.Fee)
+	}
+	transaction.FeeUSD = RoundUSD(transaction.FeeUSD)
+}
+
+// RoundBolsaAsset redondea los valores de un activo de una bolsa a la precisión con la que se guardan
+func RoundBolsaAsset(asset *models.AssetInBolsa) {
+	asset.Amount = RoundAmount(asset.Ticker, asset.Amount)
+	asset.PurchasePrice = asset.PurchasePrice.Round(PricePrecision)
+	asset.Total = RoundUSD(asset.Total)
+}
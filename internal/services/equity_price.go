@@ -0,0 +1,209 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+)
+
+// Identificadores de los proveedores de precios de acciones y ETFs
+const (
+	EquityPriceProviderYahoo = "yahoo"
+	EquityPriceProviderFile  = "file"
+)
+
+// yahooChartResponse es la parte que usamos de la respuesta de /v8/finance/chart
+type yahooChartResponse struct {
+	Chart struct {
+		Result []struct {
+			Meta struct {
+				Symbol             string  `json:"symbol"`
+				Currency           string  `json:"currency"`
+				ExchangeName       string  `json:"exchangeName"`
+				InstrumentType     string  `json:"instrumentType"`
+				RegularMarketPrice float64 `json:"regularMarketPrice"`
+				ChartPreviousClose float64 `json:"chartPreviousClose"`
+				PreviousClose      float64 `json:"previousClose"`
+			} `json:"meta"`
+		} `json:"result"`
+		Error *struct {
+			Code        string `json:"code"`
+			Description string `json:"description"`
+		} `json:"error"`
+	} `json:"chart"`
+}
+
+// YahooFinanceProvider obtiene las cotizaciones de acciones y ETFs desde Yahoo Finance.
+// Con el mercado cerrado el precio no cambia, así que la cotización obtenida después
+// del último cierre se reutiliza hasta la próxima sesión sin volver a consultar la API.
+type YahooFinanceProvider struct {
+	client *http.Client
+
+	mutex  sync.Mutex
+	closes map[string]cachedQuote
+}
+
+// NewYahooFinanceProvider crea un nuevo proveedor de Yahoo Finance
+func NewYahooFinanceProvider() *YahooFinanceProvider {
+	return &YahooFinanceProvider{
+		client: http.DefaultClient,
+		closes: make(map[string]cachedQuote),
+	}
+}
+
+// Name devuelve el identificador del proveedor
+func (p *YahooFinanceProvider) Name() string {
+	return EquityPriceProviderYahoo
+}
+
+// GetQuote obtiene la cotización de un único ticker
+func (p *YahooFinanceProvider) GetQuote(ticker string) (PriceQuote, error) {
+	return quoteFromBatch(p, ticker)
+}
+
+// GetQuotes obtiene las cotizaciones de varios tickers. Yahoo no ofrece una consulta por lotes
+// sin autenticación, así que se hace una petición por ticker que no se conozca ya.
+func (p *YahooFinanceProvider) GetQuotes(tickers []string) (map[string]PriceQuote, error) {
+	tickers = normalizeTickers(tickers)
+	if len(tickers) == 0 {
+		return nil, fmt.Errorf("no se proporcionaron tickers")
+	}
+
+	now := time.Now()
+	marketOpen := MarketOpen(models.AssetClassStock, now)
+	lastClose := lastEquityMarketClose(now)
+
+	quotes := make(map[string]PriceQuote, len(tickers))
+	var firstErr error
+	for _, ticker := range tickers {
+		if !marketOpen {
+			p.mutex.Lock()
+			entry, cached := p.closes[ticker]
+			p.mutex.Unlock()
+			if cached && entry.fetchedAt.After(lastClose) {
+				quotes[ticker] = entry.quote
+				continue
+			}
+		}
+
+		instrument, err := p.fetchInstrument(ticker)
+		if err != nil {
+			log.Printf("Error al obtener la cotización de %s desde Yahoo Finance: %v", ticker, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		quote := instrumentQuote(ticker, instrument)
+		quotes[ticker] = quote
+		if !marketOpen {
+			p.mutex.Lock()
+			p.closes[ticker] = cachedQuote{quote: quote, fetchedAt: now}
+			p.mutex.Unlock()
+		}
+	}
+
+	if len(quotes) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return quotes, nil
+}
+
+// fetchInstrument consulta la cotización de un ticker. Solo se aceptan cotizaciones en dólares.
+func (p *YahooFinanceProvider) fetchInstrument(ticker string) (models.Instrument, error) {
+	endpoint := fmt.Sprintf("https://query1.finance.yahoo.com/v8/finance/chart/%s?range=1d&interval=1d", url.PathEscape(ticker))
+	request, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return models.Instrument{}, err
+	}
+	// Yahoo rechaza las peticiones sin User-Agent
+	request.Header.Set("User-Agent", "Mozilla/5.0 (compatible; DCA-API)")
+
+	resp, err := p.client.Do(request)
+	if err != nil {
+		return models.Instrument{}, fmt.Errorf("error en la petición HTTP: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return models.Instrument{}, fmt.Errorf("error leyendo respuesta: %v", err)
+	}
+
+	var result yahooChartResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return models.Instrument{}, fmt.Errorf("error decodificando JSON: %v", err)
+	}
+	if result.Chart.Error != nil {
+		return models.Instrument{}, fmt.Errorf("%s", result.Chart.Error.Description)
+	}
+	if len(result.Chart.Result) == 0 {
+		return models.Instrument{}, fmt.Errorf("no se encontraron datos para %s", ticker)
+	}
+
+	meta := result.Chart.Result[0].Meta
+	if meta.Currency != "" && !strings.EqualFold(meta.Currency, CurrencyUSD) {
+		return models.Instrument{}, fmt.Errorf("%s cotiza en %s, solo se admiten cotizaciones en USD", ticker, meta.Currency)
+	}
+	if meta.RegularMarketPrice <= 0 {
+		return models.Instrument{}, fmt.Errorf("no se encontró el precio de %s", ticker)
+	}
+
+	previousClose := meta.ChartPreviousClose
+	if previousClose <= 0 {
+		previousClose = meta.PreviousClose
+	}
+	instrument := models.Instrument{
+		Type:   meta.InstrumentType,
+		Market: meta.ExchangeName,
+		Value:  meta.RegularMarketPrice,
+	}
+	if previousClose > 0 {
+		instrument.CurrentDayChange = meta.RegularMarketPrice - previousClose
+		instrument.CurrentDayChangePct = instrument.CurrentDayChange / previousClose * 100
+	}
+	return instrument, nil
+}
+
+// instrumentQuote convierte la cotización de un instrumento del mercado en una PriceQuote
+func instrumentQuote(ticker string, instrument models.Instrument) PriceQuote {
+	return PriceQuote{
+		Ticker:       ticker,
+		Price:        instrument.Value,
+		Change24h:    instrument.CurrentDayChange,
+		ChangePct24h: instrument.CurrentDayChangePct,
+	}
+}
+
+// newEquityPriceProviderFromEnv construye el proveedor de acciones indicado por EQUITY_PRICE_PROVIDER
+// (yahoo o file, con el archivo en EQUITY_PRICE_FIXTURES_PATH)
+func newEquityPriceProviderFromEnv() PriceProvider {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("EQUITY_PRICE_PROVIDER")))
+
+	switch name {
+	case EquityPriceProviderFile:
+		path := os.Getenv("EQUITY_PRICE_FIXTURES_PATH")
+		provider, err := NewFilePriceProvider(path)
+		if err != nil {
+			log.Printf("Error al cargar precios de acciones desde %q: %v", path, err)
+			return NewStaticPriceProvider(nil)
+		}
+		log.Printf("Usando precios de archivo para acciones y ETFs (%s)", path)
+		return provider
+	case "", EquityPriceProviderYahoo:
+		return NewYahooFinanceProvider()
+	default:
+		log.Printf("Proveedor de precios de acciones desconocido %q, usando Yahoo Finance", name)
+		return NewYahooFinanceProvider()
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// FilePriceProvider sirve precios fijos cargados desde un archivo JSON.
+// Permite ejecutar la API sin acceso a internet, por ejemplo en pruebas.
+//
+// Formato del archivo:
+//
+//	{
+//	  "BTC": {"price": 65000, "change_24h": 1200, "change_pct_24h": 1.8, "image_url": "https://..."},
+//	  "ETH": {"price": 3200},
+//	  "USDARS": {"price": 1050}
+//	}
+//
+// Las entradas USD<moneda> indican cuántas unidades de la moneda equivalen a 1 USD;
+// la misma cotización se usa para todas las fechas.
+type FilePriceProvider struct {
+	quotes map[string]PriceQuote
+}
+
+// NewFilePriceProvider carga las cotizaciones desde el archivo indicado
+func NewFilePriceProvider(path string) (*FilePriceProvider, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no se indicó el archivo de precios")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]PriceQuote
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("error decodificando JSON: %v", err)
+	}
+
+	return NewStaticPriceProvider(raw), nil
+}
+
+// NewStaticPriceProvider crea un proveedor a partir de cotizaciones en memoria
+func NewStaticPriceProvider(quotes map[string]PriceQuote) *FilePriceProvider {
+	normalized := make(map[string]PriceQuote, len(quotes))
+	for ticker, quote := range quotes {
+		ticker = strings.ToUpper(ticker)
+		quote.Ticker = ticker
+		normalized[ticker] = quote
+	}
+	return &FilePriceProvider{quotes: normalized}
+}
+
+// Name devuelve el identificador del proveedor
+func (p *FilePriceProvider) Name() string {
+	return PriceProviderFile
+}
+
+// GetQuote obtiene la cotización de un único ticker
+func (p *FilePriceProvider) GetQuote(ticker string) (PriceQuote, error) {
+	return quoteFromBatch(p, ticker)
+}
+
+// GetQuotes obtiene las cotizaciones de varios tickers
+func (p *FilePriceProvider) GetQuotes(tickers []string) (map[string]PriceQuote, error) {
+	tickers = normalizeTickers(tickers)
+	if len(tickers) == 0 {
+		return nil, fmt.Errorf("no se proporcionaron tickers")
+	}
+
+	quotes := make(map[string]PriceQuote, len(tickers))
+	for _, ticker := range tickers {
+		if quote, exists := p.quotes[ticker]; exists {
+			quotes[ticker] = quote
+		}
+	}
+	return quotes, nil
+}
+
+// GetFXRate obtiene la cotización de la moneda desde la entrada USD<moneda> del archivo
+func (p *FilePriceProvider) GetFXRate(currency string) (float64, error) {
+	quote, exists := p.quotes[CurrencyUSD+strings.ToUpper(currency)]
+	if !exists || quote.Price <= 0 {
+		return 0, fmt.Errorf("no se encontró la cotización de %s", strings.ToUpper(currency))
+	}
+	return quote.Price, nil
+}
+
+// GetFXHistory devuelve la cotización fija de la moneda para cada día del rango
+func (p *FilePriceProvider) GetFXHistory(currency string, from, to time.Time) (map[string]float64, error) {
+	rate, err := p.GetFXRate(currency)
+	if err != nil {
+		return nil, err
+	}
+	history := make(map[string]float64)
+	for _, day := range fxDayRange(from, to) {
+		history[day] = rate
+	}
+	return history, nil
+}
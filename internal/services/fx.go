@@ -0,0 +1,241 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CurrencyUSD es la moneda en la que se guardan todos los precios y totales
+const CurrencyUSD = "USD"
+
+// fxDayLayout es el formato de las fechas de las cotizaciones históricas de monedas
+const fxDayLayout = "2006-01-02"
+
+// fxHistoryGrace es cuánto antes del primer día del historial se acepta usar su cotización
+const fxHistoryGrace = 7 * 24 * time.Hour
+
+// supportedCurrencies son las monedas fiat en las que se pueden expresar las valoraciones
+var supportedCurrencies = map[string]bool{
+	"USD": true,
+	"EUR": true,
+	"GBP": true,
+	"CHF": true,
+	"JPY": true,
+	"CAD": true,
+	"AUD": true,
+	"ARS": true,
+	"BRL": true,
+	"MXN": true,
+	"CLP": true,
+	"COP": true,
+	"PEN": true,
+	"UYU": true,
+}
+
+// NormalizeCurrency valida una moneda fiat. Si está vacía devuelve el dólar.
+func NormalizeCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return CurrencyUSD, nil
+	}
+	if !supportedCurrencies[currency] {
+		return "", fmt.Errorf("moneda no soportada: %s", currency)
+	}
+	return currency, nil
+}
+
+// FXRateProvider lo implementan los proveedores de precios que también cotizan monedas fiat.
+// Las cotizaciones indican cuántas unidades de la moneda equivalen a 1 USD.
+type FXRateProvider interface {
+	// GetFXRate obtiene la cotización actual de una moneda
+	GetFXRate(currency string) (float64, error)
+	// GetFXHistory obtiene la cotización diaria de una moneda entre dos fechas,
+	// indexada por día con el formato YYYY-MM-DD
+	GetFXHistory(currency string, from, to time.Time) (map[string]float64, error)
+}
+
+// fxRatesFrom devuelve el proveedor de cotizaciones de monedas de un proveedor de precios
+func fxRatesFrom(provider PriceProvider) (FXRateProvider, error) {
+	rates, ok := provider.(FXRateProvider)
+	if !ok {
+		return nil, fmt.Errorf("el proveedor de precios %s no ofrece cotizaciones de monedas", provider.Name())
+	}
+	return rates, nil
+}
+
+// CurrencyPriceProvider expresa las cotizaciones de otro proveedor en una moneda distinta del dólar.
+// Se crea por petición: memoriza la cotización actual y la histórica de la moneda.
+type CurrencyPriceProvider struct {
+	provider PriceProvider
+	currency string
+
+	mutex       sync.Mutex
+	rate        float64
+	rateLoaded  bool
+	history     map[string]float64
+	historyDays []string
+	historyFrom time.Time
+}
+
+// NewCurrencyPriceProvider crea un proveedor que convierte las cotizaciones a la moneda indicada
+func NewCurrencyPriceProvider(provider PriceProvider, currency string) *CurrencyPriceProvider {
+	return &CurrencyPriceProvider{
+		provider: provider,
+		currency: strings.ToUpper(currency),
+	}
+}
+
+// Name devuelve el identificador del proveedor subyacente
+func (p *CurrencyPriceProvider) Name() string {
+	return p.provider.Name()
+}
+
+// Currency devuelve la moneda en la que se expresan las cotizaciones
+func (p *CurrencyPriceProvider) Currency() string {
+	return p.currency
+}
+
+// GetQuote obtiene la cotización de un único ticker
+func (p *CurrencyPriceProvider) GetQuote(ticker string) (PriceQuote, error) {
+	return quoteFromBatch(p, ticker)
+}
+
+// GetQuotes obtiene las cotizaciones del proveedor subyacente convertidas a la moneda
+func (p *CurrencyPriceProvider) GetQuotes(tickers []string) (map[string]PriceQuote, error) {
+	quotes, err := p.provider.GetQuotes(tickers)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := p.Rate()
+	if err != nil {
+		return nil, err
+	}
+
+	converted := make(map[string]PriceQuote, len(quotes))
+	for ticker, quote := range quotes {
+		quote.Price *= rate
+		quote.Change24h *= rate
+		converted[ticker] = quote
+	}
+	return converted, nil
+}
+
+// Rate devuelve la cotización actual de la moneda (unidades por dólar)
+func (p *CurrencyPriceProvider) Rate() (float64, error) {
+	if p.currency == CurrencyUSD {
+		return 1, nil
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.rateLoaded {
+		return p.rate, nil
+	}
+
+	rates, err := fxRatesFrom(p.provider)
+	if err != nil {
+		return 0, err
+	}
+	rate, err := rates.GetFXRate(p.currency)
+	if err != nil {
+		return 0, fmt.Errorf("error al obtener la cotización de %s: %v", p.currency, err)
+	}
+	if rate <= 0 {
+		return 0, fmt.Errorf("cotización inválida para %s", p.currency)
+	}
+	p.rate, p.rateLoaded = rate, true
+	return rate, nil
+}
+
+// LoadHistory obtiene de una sola vez las cotizaciones diarias desde la fecha indicada hasta hoy
+func (p *CurrencyPriceProvider) LoadHistory(from time.Time) error {
+	if p.currency == CurrencyUSD {
+		return nil
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.history != nil && !from.Before(p.historyFrom) {
+		return nil
+	}
+
+	rates, err := fxRatesFrom(p.provider)
+	if err != nil {
+		return err
+	}
+	history, err := rates.GetFXHistory(p.currency, from, time.Now())
+	if err != nil {
+		return fmt.Errorf("error al obtener el historial de %s: %v", p.currency, err)
+	}
+
+	days := make([]string, 0, len(history))
+	for day, rate := range history {
+		if rate > 0 {
+			days = append(days, day)
+		}
+	}
+	sort.Strings(days)
+	p.history, p.historyDays, p.historyFrom = history, days, from
+	return nil
+}
+
+// RateOn devuelve la cotización de la moneda en una fecha: la del último día conocido anterior o
+// igual a la fecha. Devuelve un error si el historial no está cargado o no llega hasta la fecha,
+// porque convertir un costo pasado con otra cotización lo distorsiona (mucho en monedas como el ARS).
+func (p *CurrencyPriceProvider) RateOn(date time.Time) (float64, error) {
+	if p.currency == CurrencyUSD {
+		return 1, nil
+	}
+
+	p.mutex.Lock()
+	days := p.historyDays
+	history := p.history
+	p.mutex.Unlock()
+
+	if len(days) == 0 {
+		return 0, fmt.Errorf("no hay historial de cotizaciones de %s cargado", p.currency)
+	}
+	day := date.UTC().Format(fxDayLayout)
+	index := sort.SearchStrings(days, day)
+	if index < len(days) && days[index] == day {
+		return history[day], nil
+	}
+	if index > 0 {
+		return history[days[index-1]], nil
+	}
+	// Se admiten unos pocos días antes del primero por feriados o días sin cierre
+	if first, err := time.Parse(fxDayLayout, days[0]); err == nil && first.Sub(date.UTC()) <= fxHistoryGrace {
+		return history[days[0]], nil
+	}
+	return 0, fmt.Errorf("no hay cotización de %s para el %s, el historial disponible empieza el %s", p.currency, day, days[0])
+}
+
+// ConvertHistory prepara la conversión de valores con fecha: carga el historial de la moneda
+// desde la fecha indicada. Sin historial devuelve un error en lugar de convertir con la cotización actual.
+func (p *CurrencyPriceProvider) ConvertHistory(from time.Time) error {
+	if _, err := p.Rate(); err != nil {
+		return err
+	}
+	return p.LoadHistory(from)
+}
+
+// CurrencyOf devuelve la moneda en la que un proveedor expresa sus cotizaciones
+func CurrencyOf(provider PriceProvider) string {
+	if converter, ok := provider.(*CurrencyPriceProvider); ok {
+		return converter.Currency()
+	}
+	return CurrencyUSD
+}
+
+// fxDayRange devuelve los días entre dos fechas, ambos incluidos
+func fxDayRange(from, to time.Time) []string {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	days := make([]string, 0)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		days = append(days, day.Format(fxDayLayout))
+	}
+	return days
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+)
+
+// Formatos de fecha aceptados en los CSV de precios históricos
+var historicalDateLayouts = []string{
+	"2006-01-02",
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"02/01/2006",
+}
+
+// ParseHistoricalPricesCSV lee precios de cierre diarios desde un CSV con encabezado.
+// Columnas reconocidas: date (o time/timestamp), ticker (o symbol) y close (o price).
+// Si el archivo no tiene columna de ticker se usa defaultTicker.
+//
+//	date,ticker,close
+//	2024-01-01,BTC,42280.23
+func ParseHistoricalPricesCSV(reader io.Reader, defaultTicker string) ([]models.HistoricalPrice, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error al leer el encabezado del CSV: %v", err)
+	}
+
+	dateCol, tickerCol, closeCol := -1, -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) {
+		case "date", "time", "timestamp":
+			dateCol = i
+		case "ticker", "symbol":
+			tickerCol = i
+		case "close", "price":
+			closeCol = i
+		}
+	}
+	if dateCol < 0 || closeCol < 0 {
+		return nil, fmt.Errorf("el CSV debe tener columnas date y close")
+	}
+	defaultTicker = strings.ToUpper(strings.TrimSpace(defaultTicker))
+	if tickerCol < 0 && defaultTicker == "" {
+		return nil, fmt.Errorf("el CSV no tiene columna ticker y no se indicó uno")
+	}
+
+	var prices []models.HistoricalPrice
+	line := 1
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("línea %d: %v", line, err)
+		}
+
+		date, err := parseHistoricalDate(record[dateCol])
+		if err != nil {
+			return nil, fmt.Errorf("línea %d: %v", line, err)
+		}
+
+		closePrice, err := strconv.ParseFloat(strings.TrimSpace(record[closeCol]), 64)
+		if err != nil || closePrice <= 0 {
+			return nil, fmt.Errorf("línea %d: precio inválido %q", line, record[closeCol])
+		}
+
+		ticker := defaultTicker
+		if tickerCol >= 0 && strings.TrimSpace(record[tickerCol]) != "" {
+			ticker = strings.ToUpper(strings.TrimSpace(record[tickerCol]))
+		}
+
+		prices = append(prices, models.HistoricalPrice{
+			Ticker: ticker,
+			Date:   date,
+			Close:  closePrice,
+		})
+	}
+
+	return prices, nil
+}
+
+// parseHistoricalDate interpreta una fecha en cualquiera de los formatos aceptados
+// (o un timestamp Unix en segundos) y la trunca al día en UTC
+func parseHistoricalDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range historicalDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return truncateToDay(date), nil
+		}
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return truncateToDay(time.Unix(seconds, 0)), nil
+	}
+	return time.Time{}, fmt.Errorf("fecha inválida %q", value)
+}
+
+// truncateToDay devuelve la fecha a las 00:00 UTC
+func truncateToDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// PriceBatch memoriza las cotizaciones obtenidas durante una petición para que
+// dashboard, tenencias, rendimiento y balance compartan un único resultado.
+// Implementa PriceProvider, por lo que puede usarse en lugar del proveedor global.
+type PriceBatch struct {
+	provider PriceProvider
+	mutex    sync.Mutex
+	quotes   map[string]PriceQuote
+	missing  map[string]bool
+}
+
+// NewPriceBatch crea un lote de precios sobre el proveedor indicado
+func NewPriceBatch(provider PriceProvider) *PriceBatch {
+	return &PriceBatch{
+		provider: provider,
+		quotes:   make(map[string]PriceQuote),
+		missing:  make(map[string]bool),
+	}
+}
+
+// Name devuelve el identificador del proveedor subyacente
+func (b *PriceBatch) Name() string {
+	return b.provider.Name()
+}
+
+// GetQuote obtiene la cotización de un único ticker
+func (b *PriceBatch) GetQuote(ticker string) (PriceQuote, error) {
+	return quoteFromBatch(b, ticker)
+}
+
+// GetQuotes devuelve las cotizaciones pedidas, consultando al proveedor en una
+// sola llamada únicamente por los tickers que todavía no se conocen
+func (b *PriceBatch) GetQuotes(tickers []string) (map[string]PriceQuote, error) {
+	tickers = normalizeTickers(tickers)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// Determinar qué tickers faltan por consultar
+	pending := make([]string, 0, len(tickers))
+	for _, ticker := range tickers {
+		if _, exists := b.quotes[ticker]; exists {
+			continue
+		}
+		if b.missing[ticker] {
+			continue
+		}
+		pending = append(pending, ticker)
+	}
+
+	if len(pending) > 0 {
+		fetched, err := b.provider.GetQuotes(pending)
+		if err != nil {
+			// No memorizamos los errores para poder reintentar más adelante
+			return nil, err
+		}
+		for _, ticker := range pending {
+			if quote, exists := fetched[ticker]; exists {
+				b.quotes[ticker] = quote
+			} else {
+				b.missing[ticker] = true
+			}
+		}
+	}
+
+	result := make(map[string]PriceQuote, len(tickers))
+	for _, ticker := range tickers {
+		if quote, exists := b.quotes[ticker]; exists {
+			result[ticker] = quote
+		}
+	}
+	return result, nil
+}
+
+// GetFXRate obtiene la cotización de una moneda desde el proveedor subyacente
+func (b *PriceBatch) GetFXRate(currency string) (float64, error) {
+	rates, err := fxRatesFrom(b.provider)
+	if err != nil {
+		return 0, err
+	}
+	return rates.GetFXRate(currency)
+}
+
+// GetFXHistory obtiene el historial de una moneda desde el proveedor subyacente
+func (b *PriceBatch) GetFXHistory(currency string, from, to time.Time) (map[string]float64, error) {
+	rates, err := fxRatesFrom(b.provider)
+	if err != nil {
+		return nil, err
+	}
+	return rates.GetFXHistory(currency, from, to)
+}
@@ -0,0 +1,355 @@
+package services
+
+import (
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Valores por defecto de la caché de precios
+const (
+	defaultPriceCacheTTL      = time.Minute
+	defaultPriceCacheStaleTTL = 5 * time.Minute
+)
+
+// cachedQuote es una cotización guardada en la caché junto con el momento en que se obtuvo
+type cachedQuote struct {
+	quote     PriceQuote
+	fetchedAt time.Time
+}
+
+// cachedFXRate es una cotización de moneda guardada en la caché
+type cachedFXRate struct {
+	rate      float64
+	fetchedAt time.Time
+}
+
+// cachedFXHistory es el historial de una moneda guardado en la caché junto con el rango que cubre
+type cachedFXHistory struct {
+	rates     map[string]float64
+	from, to  time.Time
+	fetchedAt time.Time
+}
+
+// priceCall representa una consulta al proveedor en curso que comparten varias peticiones
+type priceCall struct {
+	done   chan struct{}
+	quotes map[string]PriceQuote
+	err    error
+}
+
+// PriceCacheStats contiene las estadísticas de uso de la caché de precios
+type PriceCacheStats struct {
+	Provider        string  `json:"provider"`
+	Entries         int     `json:"entries"`
+	Hits            uint64  `json:"hits"`
+	Misses          uint64  `json:"misses"`
+	StaleHits       uint64  `json:"stale_hits"`
+	Coalesced       uint64  `json:"coalesced"`
+	UpstreamCalls   uint64  `json:"upstream_calls"`
+	UpstreamErrors  uint64  `json:"upstream_errors"`
+	HitRatio        float64 `json:"hit_ratio"`
+	TTLSeconds      float64 `json:"ttl_seconds"`
+	StaleTTLSeconds float64 `json:"stale_ttl_seconds"`
+}
+
+// CachedPriceProvider envuelve otro proveedor con una caché compartida por todo el proceso.
+//
+//   - Dentro del TTL la cotización se sirve directamente desde la caché.
+//   - Entre el TTL y el TTL de obsolescencia se sirve la cotización guardada y se
+//     refresca en segundo plano (stale-while-revalidate).
+//   - Si la consulta al proveedor falla se sirve la última cotización conocida, sin importar su antigüedad.
+//   - Las peticiones concurrentes por el mismo ticker comparten una única consulta al proveedor.
+type CachedPriceProvider struct {
+	provider PriceProvider
+	ttl      time.Duration
+	staleTTL time.Duration
+
+	mutex    sync.Mutex
+	entries  map[string]cachedQuote
+	inflight map[string]*priceCall
+
+	fxMutex   sync.Mutex
+	fxRates   map[string]cachedFXRate
+	fxHistory map[string]cachedFXHistory
+
+	hits           atomic.Uint64
+	misses         atomic.Uint64
+	staleHits      atomic.Uint64
+	coalesced      atomic.Uint64
+	upstreamCalls  atomic.Uint64
+	upstreamErrors atomic.Uint64
+}
+
+// NewCachedPriceProvider crea una caché sobre el proveedor indicado.
+// staleTTL es el tiempo adicional tras el TTL durante el que se sirve la cotización mientras se refresca.
+func NewCachedPriceProvider(provider PriceProvider, ttl, staleTTL time.Duration) *CachedPriceProvider {
+	return &CachedPriceProvider{
+		provider: provider,
+		ttl:      ttl,
+		staleTTL: staleTTL,
+		entries:  make(map[string]cachedQuote),
+		inflight: make(map[string]*priceCall),
+
+		fxRates:   make(map[string]cachedFXRate),
+		fxHistory: make(map[string]cachedFXHistory),
+	}
+}
+
+// newPriceCacheFromEnv envuelve el proveedor con la caché configurada por
+// PRICE_CACHE_TTL y PRICE_CACHE_STALE_TTL. Un TTL de 0 desactiva la caché.
+func newPriceCacheFromEnv(provider PriceProvider) PriceProvider {
+	ttl := durationFromEnv("PRICE_CACHE_TTL", defaultPriceCacheTTL)
+	if ttl <= 0 {
+		log.Printf("Caché de precios desactivada")
+		return provider
+	}
+	staleTTL := durationFromEnv("PRICE_CACHE_STALE_TTL", defaultPriceCacheStaleTTL)
+	return NewCachedPriceProvider(provider, ttl, staleTTL)
+}
+
+// durationFromEnv lee una duración (por ejemplo "30s" o "5m") de una variable de entorno
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Valor inválido para %s (%q), usando %v", key, value, fallback)
+		return fallback
+	}
+	return duration
+}
+
+// Name devuelve el identificador del proveedor subyacente
+func (c *CachedPriceProvider) Name() string {
+	return c.provider.Name()
+}
+
+// GetQuote obtiene la cotización de un único ticker
+func (c *CachedPriceProvider) GetQuote(ticker string) (PriceQuote, error) {
+	return quoteFromBatch(c, ticker)
+}
+
+// GetQuotes devuelve las cotizaciones pedidas usando la caché siempre que sea posible
+func (c *CachedPriceProvider) GetQuotes(tickers []string) (map[string]PriceQuote, error) {
+	tickers = normalizeTickers(tickers)
+	result := make(map[string]PriceQuote, len(tickers))
+	now := time.Now()
+
+	var fetch []string
+	var revalidate []string
+	waits := make(map[*priceCall]bool)
+
+	c.mutex.Lock()
+	for _, ticker := range tickers {
+		entry, cached := c.entries[ticker]
+		age := now.Sub(entry.fetchedAt)
+
+		switch {
+		case cached && age < c.ttl:
+			c.hits.Add(1)
+			result[ticker] = entry.quote
+		case cached && age < c.ttl+c.staleTTL:
+			c.staleHits.Add(1)
+			result[ticker] = entry.quote
+			if _, running := c.inflight[ticker]; !running {
+				revalidate = append(revalidate, ticker)
+			}
+		default:
+			c.misses.Add(1)
+			if call, running := c.inflight[ticker]; running {
+				c.coalesced.Add(1)
+				waits[call] = true
+			} else {
+				fetch = append(fetch, ticker)
+			}
+		}
+	}
+
+	var call, refresh *priceCall
+	if len(fetch) > 0 {
+		call = c.startCall(fetch)
+	}
+	if len(revalidate) > 0 {
+		refresh = c.startCall(revalidate)
+	}
+	c.mutex.Unlock()
+
+	if refresh != nil {
+		go c.runCall(refresh, revalidate)
+	}
+	if call != nil {
+		c.runCall(call, fetch)
+		waits[call] = true
+	}
+
+	// Esperar las consultas propias y las compartidas con otras peticiones
+	var firstErr error
+	for pending := range waits {
+		<-pending.done
+		if pending.err != nil && firstErr == nil {
+			firstErr = pending.err
+		}
+		for ticker, quote := range pending.quotes {
+			result[ticker] = quote
+		}
+	}
+
+	if firstErr != nil {
+		// Servir la última cotización conocida de los tickers que no pudimos refrescar
+		c.mutex.Lock()
+		for _, ticker := range tickers {
+			if _, exists := result[ticker]; exists {
+				continue
+			}
+			if entry, cached := c.entries[ticker]; cached {
+				c.staleHits.Add(1)
+				result[ticker] = entry.quote
+			}
+		}
+		c.mutex.Unlock()
+
+		if len(result) == 0 {
+			return nil, firstErr
+		}
+		log.Printf("Sirviendo precios en caché tras un error del proveedor: %v", firstErr)
+	}
+
+	return result, nil
+}
+
+// startCall registra una consulta en curso para los tickers indicados. Debe llamarse con el mutex tomado.
+func (c *CachedPriceProvider) startCall(tickers []string) *priceCall {
+	call := &priceCall{done: make(chan struct{})}
+	for _, ticker := range tickers {
+		c.inflight[ticker] = call
+	}
+	return call
+}
+
+// runCall consulta al proveedor, guarda el resultado en la caché y libera a quienes esperan
+func (c *CachedPriceProvider) runCall(call *priceCall, tickers []string) {
+	c.upstreamCalls.Add(1)
+	quotes, err := c.provider.GetQuotes(tickers)
+	if err != nil {
+		c.upstreamErrors.Add(1)
+	}
+
+	fetchedAt := time.Now()
+	c.mutex.Lock()
+	for ticker, quote := range quotes {
+		c.entries[ticker] = cachedQuote{quote: quote, fetchedAt: fetchedAt}
+	}
+	for _, ticker := range tickers {
+		if c.inflight[ticker] == call {
+			delete(c.inflight, ticker)
+		}
+	}
+	c.mutex.Unlock()
+
+	call.quotes = quotes
+	call.err = err
+	close(call.done)
+}
+
+// GetFXRate obtiene la cotización de una moneda, guardándola en la caché durante el TTL.
+// Si la consulta al proveedor falla se sirve la última cotización conocida.
+func (c *CachedPriceProvider) GetFXRate(currency string) (float64, error) {
+	c.fxMutex.Lock()
+	entry, cached := c.fxRates[currency]
+	c.fxMutex.Unlock()
+	if cached && time.Since(entry.fetchedAt) < c.ttl {
+		return entry.rate, nil
+	}
+
+	rates, err := fxRatesFrom(c.provider)
+	if err != nil {
+		return 0, err
+	}
+	c.upstreamCalls.Add(1)
+	rate, err := rates.GetFXRate(currency)
+	if err != nil {
+		c.upstreamErrors.Add(1)
+		if cached {
+			log.Printf("Sirviendo la cotización de %s en caché tras un error del proveedor: %v", currency, err)
+			return entry.rate, nil
+		}
+		return 0, err
+	}
+
+	c.fxMutex.Lock()
+	c.fxRates[currency] = cachedFXRate{rate: rate, fetchedAt: time.Now()}
+	c.fxMutex.Unlock()
+	return rate, nil
+}
+
+// GetFXHistory obtiene el historial de una moneda. Se reutiliza el historial guardado si cubre
+// el rango pedido o si se obtuvo dentro del TTL y comienza antes que el rango.
+func (c *CachedPriceProvider) GetFXHistory(currency string, from, to time.Time) (map[string]float64, error) {
+	c.fxMutex.Lock()
+	entry, cached := c.fxHistory[currency]
+	c.fxMutex.Unlock()
+	if cached && !from.Before(entry.from) && (!to.After(entry.to) || time.Since(entry.fetchedAt) < c.ttl) {
+		return entry.rates, nil
+	}
+
+	rates, err := fxRatesFrom(c.provider)
+	if err != nil {
+		return nil, err
+	}
+	c.upstreamCalls.Add(1)
+	history, err := rates.GetFXHistory(currency, from, to)
+	if err != nil {
+		c.upstreamErrors.Add(1)
+		if cached {
+			log.Printf("Sirviendo el historial de %s en caché tras un error del proveedor: %v", currency, err)
+			return entry.rates, nil
+		}
+		return nil, err
+	}
+
+	c.fxMutex.Lock()
+	c.fxHistory[currency] = cachedFXHistory{rates: history, from: from, to: to, fetchedAt: time.Now()}
+	c.fxMutex.Unlock()
+	return history, nil
+}
+
+// Stats devuelve las estadísticas actuales de la caché
+func (c *CachedPriceProvider) Stats() PriceCacheStats {
+	c.mutex.Lock()
+	entries := len(c.entries)
+	c.mutex.Unlock()
+
+	stats := PriceCacheStats{
+		Provider:        c.provider.Name(),
+		Entries:         entries,
+		Hits:            c.hits.Load(),
+		Misses:          c.misses.Load(),
+		StaleHits:       c.staleHits.Load(),
+		Coalesced:       c.coalesced.Load(),
+		UpstreamCalls:   c.upstreamCalls.Load(),
+		UpstreamErrors:  c.upstreamErrors.Load(),
+		TTLSeconds:      c.ttl.Seconds(),
+		StaleTTLSeconds: c.staleTTL.Seconds(),
+	}
+
+	served := stats.Hits + stats.StaleHits + stats.Misses
+	if served > 0 {
+		stats.HitRatio = float64(stats.Hits+stats.StaleHits) / float64(served)
+	}
+	return stats
+}
+
+// GetPriceCacheStats devuelve las estadísticas de la caché global de precios.
+// El segundo valor es false si el proveedor configurado no tiene caché.
+func GetPriceCacheStats() (PriceCacheStats, bool) {
+	cache, ok := GetPriceProvider().(*CachedPriceProvider)
+	if !ok {
+		return PriceCacheStats{}, false
+	}
+	return cache.Stats(), true
+}
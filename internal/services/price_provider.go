@@ -0,0 +1,135 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Identificadores de los proveedores de precios disponibles
+const (
+	PriceProviderCryptoCompare = "cryptocompare"
+	PriceProviderCoinGecko     = "coingecko"
+	PriceProviderFile          = "file"
+)
+
+// PriceQuote contiene la cotización actual de una criptomoneda
+type PriceQuote struct {
+	Ticker       string  `json:"ticker"`
+	Price        float64 `json:"price"`
+	Change24h    float64 `json:"change_24h"`
+	ChangePct24h float64 `json:"change_pct_24h"`
+	ImageURL     string  `json:"image_url"`
+}
+
+// PriceProvider define las operaciones que necesitamos de una fuente de precios
+type PriceProvider interface {
+	// Name devuelve el identificador del proveedor
+	Name() string
+	// GetQuote obtiene la cotización de un único ticker
+	GetQuote(ticker string) (PriceQuote, error)
+	// GetQuotes obtiene las cotizaciones de varios tickers en una sola consulta.
+	// Los tickers sin datos simplemente no aparecen en el mapa resultante.
+	GetQuotes(tickers []string) (map[string]PriceQuote, error)
+}
+
+// Singleton para el proveedor de precios
+var (
+	priceProvider     PriceProvider
+	priceProviderOnce sync.Once
+	priceProviderMu   sync.RWMutex
+)
+
+// GetPriceProvider devuelve el proveedor de precios configurado, envuelto en la caché compartida.
+// Las criptomonedas se cotizan con PRICE_PROVIDER (cryptocompare, coingecko o file) y las
+// acciones y ETFs con EQUITY_PRICE_PROVIDER (yahoo o file); ver AssetPriceProvider.
+func GetPriceProvider() PriceProvider {
+	priceProviderOnce.Do(func() {
+		provider := newPriceCacheFromEnv(NewAssetPriceProvider(newPriceProviderFromEnv(), newEquityPriceProviderFromEnv()))
+		priceProviderMu.Lock()
+		if priceProvider == nil {
+			priceProvider = provider
+		}
+		priceProviderMu.Unlock()
+	})
+
+	priceProviderMu.RLock()
+	defer priceProviderMu.RUnlock()
+	return priceProvider
+}
+
+// SetPriceProvider reemplaza el proveedor de precios global (útil para pruebas y ejecución offline)
+func SetPriceProvider(provider PriceProvider) {
+	priceProviderOnce.Do(func() {})
+
+	priceProviderMu.Lock()
+	priceProvider = provider
+	priceProviderMu.Unlock()
+}
+
+// newPriceProviderFromEnv construye el proveedor indicado por la configuración
+func newPriceProviderFromEnv() PriceProvider {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("PRICE_PROVIDER")))
+
+	switch name {
+	case PriceProviderCoinGecko:
+		log.Printf("Usando CoinGecko como proveedor de precios")
+		return NewCoinGeckoProvider()
+	case PriceProviderFile:
+		path := os.Getenv("PRICE_FIXTURES_PATH")
+		provider, err := NewFilePriceProvider(path)
+		if err != nil {
+			// No caemos a un proveedor remoto para que la ejecución offline siga siendo offline
+			log.Printf("Error al cargar precios desde %q: %v", path, err)
+			return NewStaticPriceProvider(nil)
+		}
+		log.Printf("Usando precios de archivo como proveedor de precios (%s)", path)
+		return provider
+	case "", PriceProviderCryptoCompare:
+		return NewCryptoCompareProvider(os.Getenv("CRYPTO_API_KEY"))
+	default:
+		log.Printf("Proveedor de precios desconocido %q, usando CryptoCompare", name)
+		return NewCryptoCompareProvider(os.Getenv("CRYPTO_API_KEY"))
+	}
+}
+
+// GetPrice obtiene el precio actual de un ticker usando el proveedor configurado
+func GetPrice(ticker string) (float64, error) {
+	quote, err := GetPriceProvider().GetQuote(ticker)
+	if err != nil {
+		return 0, err
+	}
+	return quote.Price, nil
+}
+
+// normalizeTickers pasa los tickers a mayúsculas y elimina vacíos y duplicados
+func normalizeTickers(tickers []string) []string {
+	seen := make(map[string]bool, len(tickers))
+	result := make([]string, 0, len(tickers))
+	for _, ticker := range tickers {
+		ticker = strings.ToUpper(strings.TrimSpace(ticker))
+		if ticker == "" || seen[ticker] {
+			continue
+		}
+		seen[ticker] = true
+		result = append(result, ticker)
+	}
+	return result
+}
+
+// quoteFromBatch obtiene un único ticker a través de la consulta por lotes del proveedor
+func quoteFromBatch(provider PriceProvider, ticker string) (PriceQuote, error) {
+	ticker = strings.ToUpper(strings.TrimSpace(ticker))
+	quotes, err := provider.GetQuotes([]string{ticker})
+	if err != nil {
+		return PriceQuote{}, err
+	}
+
+	quote, exists := quotes[ticker]
+	if !exists {
+		return PriceQuote{}, fmt.Errorf("no se encontraron datos para %s", ticker)
+	}
+	return quote, nil
+}
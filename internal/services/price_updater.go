@@ -2,7 +2,6 @@ package services
 
 import (
 	"database/sql"
-	"fmt"
 	"log"
 	"sort"
 	"sync"
@@ -10,6 +9,7 @@ import (
 
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/database"
 	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/shopspring/decimal"
 )
 
 // RepositoryInterface define las operaciones que necesitamos del repositorio
@@ -42,6 +42,7 @@ type PriceUpdater struct {
 	lastUpdated   time.Time
 	cachedResults map[string]interface{}
 	userBalances  sync.Map // Almacena userBalance por userID
+	ruleEvaluator *RuleEvaluator
 }
 
 // NewPriceUpdater crea un nuevo servicio de actualización de precios
@@ -82,7 +83,7 @@ func (a *cryptoRepositoryAdapter) SaveInvestmentSnapshot(userID string, totalVal
 	}
 
 	// Generar un ID único para el snapshot
-	snapshotID := fmt.Sprintf("snapshot_%d", time.Now().UnixNano())
+	snapshotID := models.GenerateUUID()
 
 	// Obtener la fecha actual y truncarla al intervalo de 5 minutos
 	currentTime := time.Now()
@@ -346,30 +347,33 @@ func (a *holdingsRepositoryAdapter) GetHoldings(userID string) (*models.Holdings
 			continue
 		}
 
+		// Los valores del snapshot son una valoración aproximada a precios de mercado
+		amount, total := tx.Amount.InexactFloat64(), tx.Total.InexactFloat64()
+
 		// Actualizar los holdings según el tipo de transacción
 		if tx.Type == "buy" {
 			// Si es una compra, agregar al holding
 			if holding, exists := holdingsMap[tx.Ticker]; exists {
 				// Si ya existe el holding, actualizar
-				holding.Amount += tx.Amount
-				holding.Invested += tx.Total
+				holding.Amount += amount
+				holding.Invested += total
 			} else {
 				// Si no existe, crear nuevo holding
 				holdingsMap[tx.Ticker] = &tempHolding{
 					Ticker:   tx.Ticker,
-					Amount:   tx.Amount,
-					Invested: tx.Total,
+					Amount:   amount,
+					Invested: total,
 				}
 			}
-			totalInvested += tx.Total
+			totalInvested += total
 		} else if tx.Type == "sell" {
 			// Si es una venta, reducir el holding
 			if holding, exists := holdingsMap[tx.Ticker]; exists {
 				// Calcular la proporción vendida y reducir la inversión proporcionalmente
 				if holding.Amount > 0 {
-					proportion := tx.Amount / holding.Amount
+					proportion := amount / holding.Amount
 					reducedInvestment := holding.Invested * proportion
-					holding.Amount -= tx.Amount
+					holding.Amount -= amount
 					holding.Invested -= reducedInvestment
 					totalInvested -= reducedInvestment
 				}
@@ -388,12 +392,12 @@ func (a *holdingsRepositoryAdapter) GetHoldings(userID string) (*models.Holdings
 		}
 
 		// Obtener el precio actual
-		cryptoData, err := GetCryptoPriceFromCoinGecko(ticker)
+		price, err := GetPrice(ticker)
 		if err != nil {
 			// Si hay error, usar el último precio conocido o un valor por defecto
 			holding.CurrentPrice = holding.Invested / holding.Amount // Precio promedio de compra
 		} else {
-			holding.CurrentPrice = cryptoData.Price
+			holding.CurrentPrice = price
 		}
 
 		// Calcular el valor actual
@@ -457,6 +461,30 @@ func (a *holdingsRepositoryAdapter) GetHoldings(userID string) (*models.Holdings
 	return result, nil
 }
 
+// SetRuleEvaluator configura el evaluador de reglas de bolsas que se ejecuta cada minuto
+func (p *PriceUpdater) SetRuleEvaluator(evaluator *RuleEvaluator) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.ruleEvaluator = evaluator
+}
+
+// runRuleEvaluation evalúa las reglas de las bolsas cada minuto hasta que se detenga el servicio.
+// Corre aparte del ciclo de snapshots, que espera hasta medianoche para comenzar.
+func (p *PriceUpdater) runRuleEvaluation(evaluator *RuleEvaluator, stopChan chan struct{}) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	evaluator.EvaluateAll(time.Now())
+	for {
+		select {
+		case <-ticker.C:
+			evaluator.EvaluateAll(time.Now())
+		case <-stopChan:
+			return
+		}
+	}
+}
+
 // Start inicia el servicio de actualización de precios
 // Guarda un snapshot exactamente al inicio de cada día
 func (p *PriceUpdater) Start() {
@@ -471,6 +499,10 @@ func (p *PriceUpdater) Start() {
 	p.isRunning = true
 	p.stopChan = make(chan struct{})
 
+	if p.ruleEvaluator != nil {
+		go p.runRuleEvaluation(p.ruleEvaluator, p.stopChan)
+	}
+
 	go func() {
 		// Configurar el logger para incluir la hora exacta
 		log.SetFlags(log.LstdFlags | log.Lmicroseconds)
@@ -769,7 +801,7 @@ func (p *PriceUpdater) GetFormattedInvestmentHistory(userID string, limit int) (
 
 	// Preparar datos para el gráfico
 	var labels []string
-	var values []float64
+	var values []decimal.Decimal
 
 	for _, snapshot := range snapshots {
 		// Formatear la fecha para el gráfico (solo hora:minuto)
@@ -819,7 +851,7 @@ func (p *PriceUpdater) GetFormattedInvestmentHistorySince(userID string, since t
 		return map[string]interface{}{
 			"snapshots": []models.InvestmentSnapshot{},
 			"labels":    []string{},
-			"values":    []float64{},
+			"values":    []decimal.Decimal{},
 		}, nil
 	}
 
@@ -844,7 +876,7 @@ func (p *PriceUpdater) GetFormattedInvestmentHistorySince(userID string, since t
 		// Si ya existe un snapshot para este día, solo actualizamos si el valor total es mayor
 		// o si es el día actual (siempre queremos el más reciente para el día actual)
 		if existing, exists := dayMap[dayKey]; exists {
-			if snapshot.TotalValue > existing.TotalValue || dayKey == currentDayKey {
+			if snapshot.TotalValue.GreaterThan(existing.TotalValue) || dayKey == currentDayKey {
 				// Mantener la fecha pero truncar a día completo (00:00:00)
 				snapshot.Date = time.Date(
 					snapshot.Date.Year(), snapshot.Date.Month(), snapshot.Date.Day(),
@@ -852,7 +884,7 @@ func (p *PriceUpdater) GetFormattedInvestmentHistorySince(userID string, since t
 					time.UTC,
 				)
 				dayMap[dayKey] = snapshot
-				log.Printf("Actualizado snapshot para día %s: valor %.2f", dayKey, snapshot.TotalValue)
+				log.Printf("Actualizado snapshot para día %s: valor %s", dayKey, snapshot.TotalValue.StringFixed(2))
 			}
 		} else {
 			// Asegurarse de que la fecha tenga hora, minutos, segundos y milisegundos en 0 para agrupar por día
@@ -862,7 +894,7 @@ func (p *PriceUpdater) GetFormattedInvestmentHistorySince(userID string, since t
 				time.UTC,
 			)
 			dayMap[dayKey] = snapshot
-			log.Printf("Nuevo snapshot para día %s: valor %.2f", dayKey, snapshot.TotalValue)
+			log.Printf("Nuevo snapshot para día %s: valor %s", dayKey, snapshot.TotalValue.StringFixed(2))
 		}
 	}
 
@@ -888,7 +920,7 @@ func (p *PriceUpdater) GetFormattedInvestmentHistorySince(userID string, since t
 	// Crear las listas ordenadas
 	var orderedSnapshots []models.InvestmentSnapshot
 	var labels []string
-	var values []float64
+	var values []decimal.Decimal
 
 	for _, item := range snapshotsList {
 		snapshot := item.snapshot
@@ -899,13 +931,13 @@ func (p *PriceUpdater) GetFormattedInvestmentHistorySince(userID string, since t
 	}
 
 	// Crear arrays para valores máximos y mínimos
-	var maxValues []float64
-	var minValues []float64
+	var maxValues []decimal.Decimal
+	var minValues []decimal.Decimal
 
 	// Mapa para agrupar snapshots por intervalos de 5 minutos y calcular max/min
 	intervalMaxMin := make(map[string]struct {
-		max float64
-		min float64
+		max decimal.Decimal
+		min decimal.Decimal
 	})
 
 	// Primero, calcular los valores máximo y mínimo para cada intervalo
@@ -920,19 +952,19 @@ func (p *PriceUpdater) GetFormattedInvestmentHistorySince(userID string, since t
 		if !exists {
 			// Primera vez que vemos este intervalo
 			intervalMaxMin[intervalKey] = struct {
-				max float64
-				min float64
+				max decimal.Decimal
+				min decimal.Decimal
 			}{
 				max: snapshot.TotalValue,
 				min: snapshot.TotalValue,
 			}
 		} else {
 			// Actualizar máximo y mínimo para este intervalo
-			if snapshot.TotalValue > values.max {
+			if snapshot.TotalValue.GreaterThan(values.max) {
 				values.max = snapshot.TotalValue
 				intervalMaxMin[intervalKey] = values
 			}
-			if snapshot.TotalValue < values.min {
+			if snapshot.TotalValue.LessThan(values.min) {
 				values.min = snapshot.TotalValue
 				intervalMaxMin[intervalKey] = values
 			}
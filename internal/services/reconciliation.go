@@ -0,0 +1,158 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// ParseReportedBalancesCSV lee los saldos que informa un exchange desde un CSV con encabezado.
+// Columnas reconocidas: ticker (o asset, coin, currency, symbol), amount (o balance, total) y,
+// opcionalmente, date (o reported_at) y note. Las filas sin fecha usan defaultDate.
+//
+//	ticker,amount,date
+//	BTC,0.5,2024-06-30
+func ParseReportedBalancesCSV(reader io.Reader, defaultDate time.Time) ([]models.ReportedBalance, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	csvReader.FieldsPerRecord = -1
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error al leer el encabezado del CSV: %v", err)
+	}
+
+	tickerCol, amountCol, dateCol, noteCol := -1, -1, -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) {
+		case "ticker", "asset", "coin", "currency", "symbol":
+			tickerCol = i
+		case "amount", "balance", "total":
+			amountCol = i
+		case "date", "reported_at":
+			dateCol = i
+		case "note":
+			noteCol = i
+		}
+	}
+	if tickerCol < 0 || amountCol < 0 {
+		return nil, fmt.Errorf("el CSV debe tener columnas ticker y amount")
+	}
+
+	field := func(record []string, col int) string {
+		if col < 0 || col >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[col])
+	}
+
+	var balances []models.ReportedBalance
+	line := 1
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("línea %d: %v", line, err)
+		}
+		if isBlankRow(record) {
+			continue
+		}
+
+		ticker := strings.ToUpper(field(record, tickerCol))
+		if ticker == "" {
+			return nil, fmt.Errorf("línea %d: falta el ticker", line)
+		}
+		amount, err := parseImportNumber(field(record, amountCol))
+		if err != nil {
+			return nil, fmt.Errorf("línea %d: %v", line, err)
+		}
+		if amount.IsNegative() {
+			return nil, fmt.Errorf("línea %d: el saldo no puede ser negativo", line)
+		}
+
+		reportedAt := defaultDate
+		if value := field(record, dateCol); value != "" {
+			if reportedAt, err = parseImportDate(value); err != nil {
+				return nil, fmt.Errorf("línea %d: %v", line, err)
+			}
+		}
+
+		balances = append(balances, models.ReportedBalance{
+			Ticker:     ticker,
+			Amount:     amount,
+			ReportedAt: reportedAt,
+			Source:     models.ReportedBalanceSourceCSV,
+			Note:       field(record, noteCol),
+		})
+	}
+
+	if len(balances) == 0 {
+		return nil, fmt.Errorf("el CSV no contiene saldos")
+	}
+	return balances, nil
+}
+
+// AccountBalanceAt calcula la cantidad de un ticker guardada en una cuenta con las transacciones
+// registradas hasta el momento indicado
+func AccountBalanceAt(transactions []models.CryptoTransaction, accountID, ticker string, at time.Time) decimal.Decimal {
+	var until []models.CryptoTransaction
+	for _, transaction := range transactions {
+		if !transaction.Date.After(at) {
+			until = append(until, transaction)
+		}
+	}
+	return AccountBalances(until)[accountID][strings.ToUpper(ticker)]
+}
+
+// ReconcileBalance compara un saldo informado con el calculado. Las diferencias menores que la
+// precisión con la que se guarda el ticker no se consideran una discrepancia.
+func ReconcileBalance(reported models.ReportedBalance, ledger decimal.Decimal) models.ReconciliationItem {
+	difference := RoundAmount(reported.Ticker, reported.Amount.Sub(ledger))
+	return models.ReconciliationItem{
+		Ticker:            reported.Ticker,
+		ReportedBalanceID: reported.ID,
+		ReportedAt:        reported.ReportedAt,
+		Reported:          reported.Amount,
+		Ledger:            ledger,
+		Difference:        difference,
+		Discrepancy:       !difference.IsZero(),
+		AdjustmentID:      reported.AdjustmentID,
+	}
+}
+
+// ReconciliationAdjustment arma la transacción que lleva el saldo calculado de una cuenta al informado,
+// fechada en el momento del saldo informado y con una nota que deja constancia del ajuste.
+// Si faltan monedas y hay suficientes registradas sin cuenta (unassigned), el ajuste es una transferencia
+// desde ellas, que no cambia el capital invertido; si no, es una compra, que suma al capital invertido
+// como cualquier otra. Si sobran monedas el ajuste es una venta.
+func ReconciliationAdjustment(item models.ReconciliationItem, accountID, note string, unassigned decimal.Decimal) models.CryptoTransaction {
+	transaction := models.CryptoTransaction{
+		Ticker:    item.Ticker,
+		Type:      models.TransactionTypeBuy,
+		Amount:    item.Difference.Abs(),
+		Date:      item.ReportedAt,
+		AccountID: accountID,
+	}
+	switch {
+	case item.Difference.IsNegative():
+		transaction.Type = models.TransactionTypeSell
+	case unassigned.GreaterThanOrEqual(transaction.Amount):
+		transaction.Type = models.TransactionTypeTransfer
+		transaction.AccountID, transaction.ToAccountID = "", accountID
+	}
+
+	transaction.Note = fmt.Sprintf("Ajuste de conciliación: saldo informado %s, calculado %s (diferencia %s) al %s",
+		item.Reported.String(), item.Ledger.String(), item.Difference.String(), item.ReportedAt.UTC().Format(time.RFC3339))
+	if note = strings.TrimSpace(note); note != "" {
+		transaction.Note += ". " + note
+	}
+	return transaction
+}
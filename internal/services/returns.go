@@ -0,0 +1,193 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+)
+
+// daysPerYear es la duración del año usada para anualizar rendimientos
+const daysPerYear = 365.0
+
+// NormalizeReturnPeriod valida un período de rendimientos. Si está vacío devuelve all.
+func NormalizeReturnPeriod(period string) (string, error) {
+	switch strings.ToUpper(strings.TrimSpace(period)) {
+	case "", "ALL":
+		return models.ReturnPeriodAll, nil
+	case models.ReturnPeriod1M:
+		return models.ReturnPeriod1M, nil
+	case models.ReturnPeriod3M:
+		return models.ReturnPeriod3M, nil
+	case models.ReturnPeriodYTD:
+		return models.ReturnPeriodYTD, nil
+	case models.ReturnPeriod1Y:
+		return models.ReturnPeriod1Y, nil
+	default:
+		return "", fmt.Errorf("período inválido: %s (usa 1M, 3M, YTD, 1Y o all)", period)
+	}
+}
+
+// ReturnPeriodStart devuelve el día (en UTC) en que empieza un período. Para all devuelve la fecha cero.
+func ReturnPeriodStart(period string, now time.Time) time.Time {
+	today := dayUTC(now)
+	switch period {
+	case models.ReturnPeriod1M:
+		return today.AddDate(0, -1, 0)
+	case models.ReturnPeriod3M:
+		return today.AddDate(0, -3, 0)
+	case models.ReturnPeriodYTD:
+		return time.Date(today.Year(), 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	case models.ReturnPeriod1Y:
+		return today.AddDate(-1, 0, 0)
+	default:
+		return time.Time{}
+	}
+}
+
+// dayUTC trunca una fecha al día en UTC, igual que los precios históricos
+func dayUTC(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// TransactionCashFlow devuelve el dinero que entra (positivo) o sale (negativo) de un activo con una
+// transacción. Los ingresos y las transferencias no son aportes: su valor forma parte del rendimiento.
+// Las comisiones en dólares se suman al aporte; las pagadas con una criptomoneda ya reducen las tenencias.
+func TransactionCashFlow(transaction models.CryptoTransaction) float64 {
+	var fee float64
+	if transaction.Fee.IsPositive() && transaction.FeeCurrency == models.FeeCurrencyUSD {
+		fee = transaction.FeeUSD.InexactFloat64()
+	}
+
+	switch transaction.Type {
+	case models.TransactionTypeBuy:
+		return transaction.Total.InexactFloat64() + fee
+	case models.TransactionTypeSell:
+		proceeds := transaction.Total
+		if transaction.USDTReceived.IsPositive() {
+			proceeds = transaction.USDTReceived
+		}
+		return fee - proceeds.InexactFloat64()
+	default:
+		return 0
+	}
+}
+
+// ReturnPoint es el valor de una posición al final de un día y el aporte neto recibido ese día
+type ReturnPoint struct {
+	Date  time.Time
+	Value float64
+	Flow  float64
+}
+
+// cashFlow es un movimiento de dinero desde el punto de vista del inversor (negativo si invierte)
+type cashFlow struct {
+	date   time.Time
+	amount float64
+}
+
+// ComputeReturns calcula los rendimientos de un período a partir del valor inicial y del valor al
+// final de cada día con aportes, con el último punto como valor final.
+//
+// El rendimiento ponderado por tiempo encadena los subperíodos entre aportes, de modo que el monto
+// aportado no influye. El ponderado por dinero es la tasa interna de retorno (XIRR) de los aportes.
+func ComputeReturns(start ReturnPoint, points []ReturnPoint) models.ReturnMetrics {
+	metrics := models.ReturnMetrics{StartValue: start.Value}
+	if len(points) == 0 {
+		metrics.EndValue = start.Value
+		return metrics
+	}
+	end := points[len(points)-1]
+	metrics.EndValue = end.Value
+
+	growth, chained := 1.0, false
+	previous := start.Value
+	flows := []cashFlow{}
+	if start.Value > 0 {
+		flows = append(flows, cashFlow{start.Date, -start.Value})
+	}
+	for _, point := range points {
+		metrics.NetFlows += point.Flow
+		if previous > 0 {
+			growth *= (point.Value - point.Flow) / previous
+			chained = true
+		}
+		previous = point.Value
+		if point.Flow != 0 {
+			flows = append(flows, cashFlow{point.Date, -point.Flow})
+		}
+	}
+	flows = append(flows, cashFlow{end.Date, end.Value})
+	metrics.Gain = metrics.EndValue - metrics.StartValue - metrics.NetFlows
+
+	days := end.Date.Sub(start.Date).Hours() / 24
+	if chained {
+		twr := (growth - 1) * 100
+		metrics.TWR = &twr
+		if days >= daysPerYear && growth > 0 {
+			annualized := (math.Pow(growth, daysPerYear/days) - 1) * 100
+			metrics.TWRAnnualized = &annualized
+		}
+	}
+
+	if rate, ok := xirr(flows); ok && days > 0 {
+		mwr := rate * 100
+		period := (math.Pow(1+rate, days/daysPerYear) - 1) * 100
+		metrics.MWR = &mwr
+		metrics.MWRPeriod = &period
+	}
+	return metrics
+}
+
+// xirr busca por bisección la tasa anual que hace cero el valor presente de los movimientos.
+// Necesita al menos un movimiento positivo y uno negativo.
+func xirr(flows []cashFlow) (float64, bool) {
+	var hasPositive, hasNegative bool
+	for _, flow := range flows {
+		hasPositive = hasPositive || flow.amount > 0
+		hasNegative = hasNegative || flow.amount < 0
+	}
+	if !hasPositive || !hasNegative {
+		return 0, false
+	}
+
+	first := flows[0].date
+	for _, flow := range flows {
+		if flow.date.Before(first) {
+			first = flow.date
+		}
+	}
+	presentValue := func(rate float64) float64 {
+		var total float64
+		for _, flow := range flows {
+			years := flow.date.Sub(first).Hours() / 24 / daysPerYear
+			total += flow.amount / math.Pow(1+rate, years)
+		}
+		return total
+	}
+
+	low, high := -0.999999, 1.0
+	lowValue := presentValue(low)
+	for presentValue(high)*lowValue > 0 {
+		high *= 10
+		if high > 1e12 {
+			return 0, false
+		}
+	}
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+		midValue := presentValue(mid)
+		if math.Abs(midValue) < 1e-9 || high-low < 1e-12 {
+			return mid, true
+		}
+		if midValue*lowValue > 0 {
+			low, lowValue = mid, midValue
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2, true
+}
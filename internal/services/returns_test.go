@@ -0,0 +1,180 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// returnDay devuelve el día indicado contado desde el 1 de enero de 2023
+func returnDay(day int) time.Time {
+	return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, day)
+}
+
+func TestXIRR(t *testing.T) {
+	tests := []struct {
+		name   string
+		flows  []cashFlow
+		want   float64
+		wantOK bool
+	}{
+		{
+			name:   "un año al 10%",
+			flows:  []cashFlow{{returnDay(0), -1000}, {returnDay(365), 1100}},
+			want:   0.10,
+			wantOK: true,
+		},
+		{
+			name:   "tasa mayor al 100% amplía el intervalo de bisección",
+			flows:  []cashFlow{{returnDay(0), -100}, {returnDay(365), 300}},
+			want:   2.0,
+			wantOK: true,
+		},
+		{
+			name:   "pérdida",
+			flows:  []cashFlow{{returnDay(0), -1000}, {returnDay(365), 500}},
+			want:   -0.5,
+			wantOK: true,
+		},
+		{
+			name:   "pérdida casi total queda dentro del límite inferior",
+			flows:  []cashFlow{{returnDay(0), -1000}, {returnDay(365), 1}},
+			want:   -0.999,
+			wantOK: true,
+		},
+		{
+			name:   "varios movimientos el primer día",
+			flows:  []cashFlow{{returnDay(0), -500}, {returnDay(0), -500}, {returnDay(365), 1100}},
+			want:   0.10,
+			wantOK: true,
+		},
+		{
+			name:   "movimientos desordenados",
+			flows:  []cashFlow{{returnDay(365), 1100}, {returnDay(0), -1000}},
+			want:   0.10,
+			wantOK: true,
+		},
+		{
+			name:   "sin movimientos positivos",
+			flows:  []cashFlow{{returnDay(0), -1000}, {returnDay(365), -100}},
+			wantOK: false,
+		},
+		{
+			name:   "sin movimientos",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := xirr(tt.flows)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, se esperaba %v", ok, tt.wantOK)
+			}
+			if ok && math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("xirr = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeReturns(t *testing.T) {
+	tests := []struct {
+		name          string
+		start         ReturnPoint
+		points        []ReturnPoint
+		wantTWR       *float64
+		wantMWR       *float64
+		wantAnnual    *float64
+		wantNetFlows  float64
+		wantGain      float64
+		wantEndValue  float64
+		wantNoReturns bool
+	}{
+		{
+			name:         "sin aportes",
+			start:        ReturnPoint{Date: returnDay(0), Value: 1000},
+			points:       []ReturnPoint{{Date: returnDay(365), Value: 1100}},
+			wantTWR:      floatPtr(10),
+			wantMWR:      floatPtr(10),
+			wantAnnual:   floatPtr(10),
+			wantGain:     100,
+			wantEndValue: 1100,
+		},
+		{
+			name:  "aporte el primer día sin valor inicial",
+			start: ReturnPoint{Date: returnDay(0)},
+			points: []ReturnPoint{
+				{Date: returnDay(0), Value: 1000, Flow: 1000},
+				{Date: returnDay(365), Value: 1100},
+			},
+			wantTWR:      floatPtr(10),
+			wantMWR:      floatPtr(10),
+			wantNetFlows: 1000,
+			wantGain:     100,
+			wantEndValue: 1100,
+		},
+		{
+			name:  "el aporte no cambia el rendimiento ponderado por tiempo",
+			start: ReturnPoint{Date: returnDay(0), Value: 1000},
+			points: []ReturnPoint{
+				{Date: returnDay(100), Value: 2200, Flow: 1000},
+				{Date: returnDay(200), Value: 2200},
+			},
+			wantTWR:      floatPtr(20),
+			wantNetFlows: 1000,
+			wantGain:     200,
+			wantEndValue: 2200,
+		},
+		{
+			name:          "sin puntos",
+			start:         ReturnPoint{Date: returnDay(0), Value: 1000},
+			wantEndValue:  1000,
+			wantNoReturns: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := ComputeReturns(tt.start, tt.points)
+			checkFloat(t, "end_value", metrics.EndValue, tt.wantEndValue)
+			checkFloat(t, "net_flows", metrics.NetFlows, tt.wantNetFlows)
+			checkFloat(t, "gain", metrics.Gain, tt.wantGain)
+
+			if tt.wantNoReturns {
+				if metrics.TWR != nil || metrics.MWR != nil {
+					t.Errorf("no se esperaban rendimientos: twr %v mwr %v", metrics.TWR, metrics.MWR)
+				}
+				return
+			}
+			checkOptionalFloat(t, "twr", metrics.TWR, tt.wantTWR)
+			checkOptionalFloat(t, "mwr", metrics.MWR, tt.wantMWR)
+			checkOptionalFloat(t, "twr_annualized", metrics.TWRAnnualized, tt.wantAnnual)
+		})
+	}
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+// checkFloat compara un float con el valor esperado con tolerancia
+func checkFloat(t *testing.T, field string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-6 {
+		t.Errorf("%s = %v, se esperaba %v", field, got, want)
+	}
+}
+
+// checkOptionalFloat compara un valor opcional solo si se espera uno
+func checkOptionalFloat(t *testing.T, field string, got, want *float64) {
+	t.Helper()
+	if want == nil {
+		return
+	}
+	if got == nil {
+		t.Errorf("%s = nil, se esperaba %v", field, *want)
+		return
+	}
+	checkFloat(t, field, *got, *want)
+}
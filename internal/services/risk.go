@@ -0,0 +1,102 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+)
+
+// FlowNeutralReturns calcula el rendimiento de cada punto respecto del anterior descontando el aporte
+// recibido entre ambos, de modo que comprar o vender no cuente como ganancia o pérdida.
+// Los puntos cuyo valor anterior es 0 se omiten.
+func FlowNeutralReturns(points []ReturnPoint) []models.RiskDay {
+	returns := []models.RiskDay{}
+	for i := 1; i < len(points); i++ {
+		previous := points[i-1].Value
+		if previous <= 0 {
+			continue
+		}
+		returns = append(returns, models.RiskDay{
+			Date:   points[i].Date,
+			Return: ((points[i].Value-points[i].Flow)/previous - 1) * 100,
+		})
+	}
+	return returns
+}
+
+// ComputeRiskMetrics calcula la volatilidad, la máxima caída, los ratios de Sharpe y Sortino y los
+// mejores y peores días a partir de rendimientos diarios. riskFreeRate es la tasa anual en porcentaje.
+// Las criptomonedas cotizan todos los días, así que se anualiza con 365 días.
+func ComputeRiskMetrics(start time.Time, returns []models.RiskDay, riskFreeRate float64) models.RiskMetrics {
+	metrics := models.RiskMetrics{
+		Observations: len(returns),
+		RiskFreeRate: riskFreeRate,
+	}
+	if len(returns) == 0 {
+		return metrics
+	}
+
+	// Máxima caída sobre el índice que encadena los rendimientos
+	index, peak := 1.0, 1.0
+	peakDate := start
+	for i, day := range returns {
+		index *= 1 + day.Return/100
+		if index > peak {
+			peak = index
+			peakDate = day.Date
+		}
+		drawdown := (1 - index/peak) * 100
+		if drawdown > metrics.MaxDrawdown {
+			metrics.MaxDrawdown = drawdown
+			peakAt, troughAt := peakDate, day.Date
+			metrics.MaxDrawdownPeak = &peakAt
+			metrics.MaxDrawdownTrough = &troughAt
+		}
+		if i == len(returns)-1 {
+			metrics.CurrentDrawdown = drawdown
+		}
+
+		if metrics.BestDay == nil || day.Return > metrics.BestDay.Return {
+			best := day
+			metrics.BestDay = &best
+		}
+		if metrics.WorstDay == nil || day.Return < metrics.WorstDay.Return {
+			worst := day
+			metrics.WorstDay = &worst
+		}
+	}
+
+	if len(returns) < 2 {
+		return metrics
+	}
+
+	dailyRiskFree := math.Pow(1+riskFreeRate/100, 1/daysPerYear) - 1
+	var mean, downside float64
+	for _, day := range returns {
+		mean += day.Return / 100
+		if excess := day.Return/100 - dailyRiskFree; excess < 0 {
+			downside += excess * excess
+		}
+	}
+	mean /= float64(len(returns))
+	downside = math.Sqrt(downside / float64(len(returns)))
+
+	var variance float64
+	for _, day := range returns {
+		variance += (day.Return/100 - mean) * (day.Return/100 - mean)
+	}
+	deviation := math.Sqrt(variance / float64(len(returns)-1))
+
+	volatility := deviation * math.Sqrt(daysPerYear) * 100
+	metrics.Volatility = &volatility
+	if deviation > 0 {
+		sharpe := (mean - dailyRiskFree) / deviation * math.Sqrt(daysPerYear)
+		metrics.Sharpe = &sharpe
+	}
+	if downside > 0 {
+		sortino := (mean - dailyRiskFree) / downside * math.Sqrt(daysPerYear)
+		metrics.Sortino = &sortino
+	}
+	return metrics
+}
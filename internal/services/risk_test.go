@@ -0,0 +1,156 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+)
+
+// riskDays arma rendimientos diarios consecutivos desde el día 1
+func riskDays(returns ...float64) []models.RiskDay {
+	days := make([]models.RiskDay, 0, len(returns))
+	for i, value := range returns {
+		days = append(days, models.RiskDay{Date: returnDay(i + 1), Return: value})
+	}
+	return days
+}
+
+func TestComputeRiskMetrics(t *testing.T) {
+	tests := []struct {
+		name            string
+		returns         []models.RiskDay
+		riskFreeRate    float64
+		wantMaxDrawdown float64
+		wantCurrent     float64
+		wantPeak        *time.Time
+		wantTrough      *time.Time
+		wantBest        float64
+		wantWorst       float64
+		wantVolatility  *float64
+		wantSharpe      *float64
+		wantSortino     *float64
+		wantNoSharpe    bool
+		wantNoSortino   bool
+	}{
+		{
+			name:            "caída y recuperación parcial",
+			returns:         riskDays(10, -50, 20),
+			wantMaxDrawdown: 50,
+			wantCurrent:     40,
+			wantPeak:        timePtr(returnDay(1)),
+			wantTrough:      timePtr(returnDay(2)),
+			wantBest:        20,
+			wantWorst:       -50,
+		},
+		{
+			name:            "caída desde el primer día toma el inicio como pico",
+			returns:         riskDays(-10, 5),
+			wantMaxDrawdown: 10,
+			wantCurrent:     5.5,
+			wantPeak:        timePtr(returnDay(0)),
+			wantTrough:      timePtr(returnDay(1)),
+			wantBest:        5,
+			wantWorst:       -10,
+		},
+		{
+			name:            "rendimientos simétricos",
+			returns:         riskDays(1, -1),
+			wantMaxDrawdown: 1,
+			wantCurrent:     1,
+			wantBest:        1,
+			wantWorst:       -1,
+			wantVolatility:  floatPtr(math.Sqrt(0.0002) * math.Sqrt(daysPerYear) * 100),
+			wantSharpe:      floatPtr(0),
+			wantSortino:     floatPtr(0),
+		},
+		{
+			name:           "rendimientos constantes sin desvío",
+			returns:        riskDays(1, 1, 1),
+			wantBest:       1,
+			wantWorst:      1,
+			wantVolatility: floatPtr(0),
+			wantNoSharpe:   true,
+			wantNoSortino:  true,
+		},
+		{
+			name:           "tasa libre de riesgo mayor que los rendimientos",
+			returns:        riskDays(0, 0),
+			riskFreeRate:   10,
+			wantVolatility: floatPtr(0),
+			wantNoSharpe:   true,
+			wantSortino:    floatPtr(-math.Sqrt(daysPerYear)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := ComputeRiskMetrics(returnDay(0), tt.returns, tt.riskFreeRate)
+			if metrics.Observations != len(tt.returns) {
+				t.Errorf("observations = %d, se esperaba %d", metrics.Observations, len(tt.returns))
+			}
+			checkFloat(t, "max_drawdown", metrics.MaxDrawdown, tt.wantMaxDrawdown)
+			checkFloat(t, "current_drawdown", metrics.CurrentDrawdown, tt.wantCurrent)
+			checkOptionalTime(t, "max_drawdown_peak", metrics.MaxDrawdownPeak, tt.wantPeak)
+			checkOptionalTime(t, "max_drawdown_trough", metrics.MaxDrawdownTrough, tt.wantTrough)
+
+			if metrics.BestDay == nil || metrics.WorstDay == nil {
+				t.Fatal("faltan el mejor y el peor día")
+			}
+			checkFloat(t, "best_day", metrics.BestDay.Return, tt.wantBest)
+			checkFloat(t, "worst_day", metrics.WorstDay.Return, tt.wantWorst)
+
+			checkOptionalFloat(t, "volatility", metrics.Volatility, tt.wantVolatility)
+			checkOptionalFloat(t, "sharpe", metrics.Sharpe, tt.wantSharpe)
+			checkOptionalFloat(t, "sortino", metrics.Sortino, tt.wantSortino)
+			if tt.wantNoSharpe && metrics.Sharpe != nil {
+				t.Errorf("sharpe = %v, se esperaba nil", *metrics.Sharpe)
+			}
+			if tt.wantNoSortino && metrics.Sortino != nil {
+				t.Errorf("sortino = %v, se esperaba nil", *metrics.Sortino)
+			}
+		})
+	}
+}
+
+func TestComputeRiskMetricsFewObservations(t *testing.T) {
+	empty := ComputeRiskMetrics(returnDay(0), nil, 0)
+	if empty.Observations != 0 || empty.BestDay != nil || empty.Volatility != nil {
+		t.Errorf("sin rendimientos no se esperaban métricas: %+v", empty)
+	}
+
+	single := ComputeRiskMetrics(returnDay(0), riskDays(-5), 0)
+	if single.Volatility != nil || single.Sharpe != nil {
+		t.Errorf("con un solo rendimiento no se esperaba volatilidad: %+v", single)
+	}
+	checkFloat(t, "max_drawdown", single.MaxDrawdown, 5)
+}
+
+func TestFlowNeutralReturns(t *testing.T) {
+	points := []ReturnPoint{
+		{Date: returnDay(0), Value: 0},
+		{Date: returnDay(1), Value: 1000, Flow: 1000},
+		{Date: returnDay(2), Value: 2100, Flow: 1000},
+	}
+	returns := FlowNeutralReturns(points)
+	if len(returns) != 1 {
+		t.Fatalf("rendimientos = %d, se esperaba 1 (el punto con valor anterior 0 se omite)", len(returns))
+	}
+	checkFloat(t, "return", returns[0].Return, 10)
+}
+
+func timePtr(value time.Time) *time.Time {
+	return &value
+}
+
+// checkOptionalTime compara una fecha opcional solo si se espera una
+func checkOptionalTime(t *testing.T, field string, got, want *time.Time) {
+	t.Helper()
+	if want == nil {
+		return
+	}
+	if got == nil || !got.Equal(*want) {
+		t.Errorf("%s = %v, se esperaba %v", field, got, *want)
+	}
+}
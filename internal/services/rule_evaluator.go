@@ -0,0 +1,266 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// TriggerRuleStore define las operaciones que el evaluador necesita del repositorio de bolsas
+type TriggerRuleStore interface {
+	GetActiveRules() ([]models.TriggerRule, error)
+	GetBolsaByID(id string) (*models.Bolsa, error)
+	MarkRuleTriggered(event models.TriggerEvent) (bool, error)
+	RearmRule(ruleID string) error
+	UpdateRulePeak(ruleID string, peak decimal.Decimal) error
+}
+
+// RuleEvaluator evalúa las reglas de las bolsas contra los precios y valores actuales
+type RuleEvaluator struct {
+	store TriggerRuleStore
+}
+
+// NewRuleEvaluator crea un nuevo evaluador de reglas
+func NewRuleEvaluator(store TriggerRuleStore) *RuleEvaluator {
+	return &RuleEvaluator{
+		store: store,
+	}
+}
+
+// EvaluateAll evalúa todas las reglas activas y devuelve los eventos de los disparos producidos
+func (e *RuleEvaluator) EvaluateAll(now time.Time) []models.TriggerEvent {
+	rules, err := e.store.GetActiveRules()
+	if err != nil {
+		log.Printf("Error al obtener reglas activas: %v", err)
+		return nil
+	}
+
+	// Agrupar las reglas por bolsa para valorar cada bolsa una sola vez
+	rulesByBolsa := make(map[string][]models.TriggerRule)
+	order := make([]string, 0)
+	for _, rule := range rules {
+		if _, exists := rulesByBolsa[rule.BolsaID]; !exists {
+			order = append(order, rule.BolsaID)
+		}
+		rulesByBolsa[rule.BolsaID] = append(rulesByBolsa[rule.BolsaID], rule)
+	}
+
+	var events []models.TriggerEvent
+	for _, bolsaID := range order {
+		bolsa, err := e.store.GetBolsaByID(bolsaID)
+		if err != nil {
+			log.Printf("Error al obtener la bolsa %s para evaluar reglas: %v", bolsaID, err)
+			continue
+		}
+		bolsa.Rules = rulesByBolsa[bolsaID]
+		events = append(events, e.EvaluateBolsa(bolsa, now)...)
+	}
+
+	if len(events) > 0 {
+		log.Printf("Reglas disparadas en esta evaluación: %d", len(events))
+	}
+	return events
+}
+
+// EvaluateBolsa actualiza los precios de la bolsa y evalúa sus reglas activas.
+// Devuelve los eventos de las reglas que se dispararon en esta evaluación.
+// Las reglas solo se evalúan con cotizaciones actuales: si falta la del ticker de la regla (o la de
+// algún activo, para las reglas sobre el valor de la bolsa) la regla se omite hasta la próxima evaluación.
+func (e *RuleEvaluator) EvaluateBolsa(bolsa *models.Bolsa, now time.Time) []models.TriggerEvent {
+	bolsa, missing := GetBolsaPriceService().QuoteBolsa(bolsa)
+
+	var events []models.TriggerEvent
+	for i := range bolsa.Rules {
+		rule := &bolsa.Rules[i]
+		if !rule.Active {
+			continue
+		}
+
+		if unquoted := ruleMissingQuotes(*rule, missing); len(unquoted) > 0 {
+			log.Printf("Regla %s omitida: sin cotización actual para %s", rule.ID, strings.Join(unquoted, ", "))
+			continue
+		}
+
+		currentValue := RoundUSD(decimal.NewFromFloat(bolsa.CurrentValue))
+		if rule.Type == models.TriggerTypeDrawdownExceeded && currentValue.GreaterThan(rule.PeakValue) {
+			// Registrar el nuevo máximo desde el que se mide la caída
+			if err := e.store.UpdateRulePeak(rule.ID, currentValue); err != nil {
+				log.Printf("Error al actualizar el máximo de la regla %s: %v", rule.ID, err)
+			}
+			rule.PeakValue = currentValue
+		}
+
+		observed, err := observedRuleValue(*rule, bolsa)
+		if err != nil {
+			log.Printf("No se pudo evaluar la regla %s: %v", rule.ID, err)
+			continue
+		}
+		met := ruleConditionMet(*rule, observed)
+
+		switch {
+		case !rule.Triggered && met && ruleCooledDown(*rule, now):
+			event := models.TriggerEvent{
+				ID:            models.GenerateUUID(),
+				RuleID:        rule.ID,
+				BolsaID:       bolsa.ID,
+				Type:          rule.Type,
+				Ticker:        rule.Ticker,
+				TargetValue:   rule.TargetValue,
+				ObservedValue: observed,
+				TriggeredAt:   now,
+			}
+			triggered, err := e.store.MarkRuleTriggered(event)
+			if err != nil {
+				log.Printf("Error al marcar la regla %s como disparada: %v", rule.ID, err)
+				continue
+			}
+			if !triggered {
+				// Otra evaluación concurrente ya registró este disparo
+				continue
+			}
+			rule.Triggered = true
+			rule.LastTriggeredAt = &now
+			events = append(events, event)
+			log.Printf("Regla %s (%s) disparada en la bolsa %s: valor observado %s, objetivo %s",
+				rule.ID, rule.Type, bolsa.ID, observed.StringFixed(2), rule.TargetValue.StringFixed(2))
+
+		case rule.Triggered && rule.Rearm && !met && ruleCooledDown(*rule, now):
+			// La condición dejó de cumplirse: rearmar para detectar el próximo cruce
+			if err := e.store.RearmRule(rule.ID); err != nil {
+				log.Printf("Error al rearmar la regla %s: %v", rule.ID, err)
+				continue
+			}
+			rule.Triggered = false
+		}
+	}
+
+	return events
+}
+
+// ruleMissingQuotes devuelve los tickers sin cotización actual de los que depende la regla: el de la
+// regla en price_reached y todos los activos de la bolsa en las reglas sobre su valor
+func ruleMissingQuotes(rule models.TriggerRule, missing map[string]bool) []string {
+	if rule.Type == models.TriggerTypePriceReached {
+		if missing[strings.ToUpper(rule.Ticker)] {
+			return []string{strings.ToUpper(rule.Ticker)}
+		}
+		return nil
+	}
+
+	unquoted := make([]string, 0, len(missing))
+	for ticker := range missing {
+		unquoted = append(unquoted, ticker)
+	}
+	sort.Strings(unquoted)
+	return unquoted
+}
+
+// observedRuleValue obtiene el valor actual que se compara con el objetivo de la regla
+func observedRuleValue(rule models.TriggerRule, bolsa *models.Bolsa) (decimal.Decimal, error) {
+	hundred := decimal.NewFromInt(100)
+	currentValue := RoundUSD(decimal.NewFromFloat(bolsa.CurrentValue))
+
+	switch rule.Type {
+	case models.TriggerTypePriceReached:
+		if rule.Ticker == "" {
+			return decimal.Zero, fmt.Errorf("la regla no tiene ticker")
+		}
+		for _, asset := range bolsa.Assets {
+			if strings.EqualFold(asset.Ticker, rule.Ticker) && asset.CurrentPrice > 0 {
+				return decimal.NewFromFloat(asset.CurrentPrice), nil
+			}
+		}
+		price, err := GetAssetPrice(RuleAssetClass(rule, bolsa), rule.Ticker)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		return decimal.NewFromFloat(price), nil
+	case models.TriggerTypeValueReached:
+		return currentValue, nil
+	case models.TriggerTypeGoalPercentReached:
+		if !bolsa.Goal.IsPositive() {
+			return decimal.Zero, fmt.Errorf("la bolsa no tiene un objetivo definido")
+		}
+		return currentValue.Div(bolsa.Goal).Mul(hundred).Round(USDPrecision), nil
+	case models.TriggerTypeDrawdownExceeded:
+		// Caída porcentual del valor de la bolsa desde el máximo observado
+		if !rule.PeakValue.IsPositive() {
+			return decimal.Zero, nil
+		}
+		return rule.PeakValue.Sub(currentValue).Div(rule.PeakValue).Mul(hundred).Round(USDPrecision), nil
+	default:
+		return decimal.Zero, fmt.Errorf("tipo de regla desconocido %q", rule.Type)
+	}
+}
+
+// RuleAssetClass devuelve la clase de activo del ticker de una regla price_reached: la del activo
+// de la bolsa con ese ticker o, si la bolsa no lo tiene, cripto
+func RuleAssetClass(rule models.TriggerRule, bolsa *models.Bolsa) string {
+	for _, asset := range bolsa.Assets {
+		if strings.EqualFold(asset.Ticker, rule.Ticker) {
+			return AssetClassOrDefault(asset.AssetClass)
+		}
+	}
+	return models.AssetClassCrypto
+}
+
+// ruleConditionMet indica si el valor observado alcanzó el objetivo de la regla en su dirección
+func ruleConditionMet(rule models.TriggerRule, observed decimal.Decimal) bool {
+	if rule.Direction == models.TriggerDirectionBelow {
+		return observed.LessThanOrEqual(rule.TargetValue)
+	}
+	return observed.GreaterThanOrEqual(rule.TargetValue)
+}
+
+// ValidateTriggerRule normaliza una regla (ticker en mayúsculas, dirección por defecto)
+// y verifica que sea coherente con su tipo
+func ValidateTriggerRule(rule *models.TriggerRule) error {
+	rule.Ticker = strings.ToUpper(strings.TrimSpace(rule.Ticker))
+	if rule.Direction == "" {
+		rule.Direction = models.TriggerDirectionAbove
+	}
+
+	switch rule.Type {
+	case models.TriggerTypePriceReached:
+		if rule.Ticker == "" {
+			return fmt.Errorf("las reglas price_reached requieren un ticker")
+		}
+	case models.TriggerTypeValueReached, models.TriggerTypeGoalPercentReached:
+	case models.TriggerTypeDrawdownExceeded:
+		if rule.Direction != models.TriggerDirectionAbove {
+			return fmt.Errorf("las reglas drawdown_exceeded solo admiten la dirección above")
+		}
+		if rule.TargetValue.GreaterThan(decimal.NewFromInt(100)) {
+			return fmt.Errorf("la caída objetivo no puede superar el 100%%")
+		}
+	default:
+		return fmt.Errorf("tipo de regla inválido %q", rule.Type)
+	}
+
+	if rule.Type != models.TriggerTypePriceReached {
+		rule.Ticker = ""
+	}
+	if !rule.TargetValue.IsPositive() {
+		return fmt.Errorf("el valor objetivo debe ser mayor a 0")
+	}
+	if rule.Direction != models.TriggerDirectionAbove && rule.Direction != models.TriggerDirectionBelow {
+		return fmt.Errorf("dirección inválida %q, debe ser above o below", rule.Direction)
+	}
+	if rule.CooldownMinutes < 0 {
+		return fmt.Errorf("cooldown_minutes no puede ser negativo")
+	}
+	return nil
+}
+
+// ruleCooledDown indica si ya pasó el tiempo de enfriamiento desde el último disparo
+func ruleCooledDown(rule models.TriggerRule, now time.Time) bool {
+	if rule.CooldownMinutes <= 0 || rule.LastTriggeredAt == nil {
+		return true
+	}
+	return now.Sub(*rule.LastTriggeredAt) >= time.Duration(rule.CooldownMinutes)*time.Minute
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// BuildSwapLegs valida un intercambio y arma sus dos patas: una venta de la moneda origen y una
+// compra de la moneda destino por el mismo valor en dólares, que pasa a ser el costo de lo recibido
+func BuildSwapLegs(userID, swapID string, request models.SwapRequest) (models.CryptoTransaction, models.CryptoTransaction, error) {
+	var sell, buy models.CryptoTransaction
+
+	fromTicker := strings.ToUpper(strings.TrimSpace(request.FromTicker))
+	toTicker := strings.ToUpper(strings.TrimSpace(request.ToTicker))
+	if fromTicker == "" || toTicker == "" {
+		return sell, buy, fmt.Errorf("se deben indicar las criptomonedas de origen y destino")
+	}
+	if fromTicker == toTicker {
+		return sell, buy, fmt.Errorf("no se puede intercambiar una criptomoneda por sí misma")
+	}
+	if !request.FromAmount.IsPositive() || !request.ToAmount.IsPositive() {
+		return sell, buy, fmt.Errorf("las cantidades del intercambio deben ser mayores a 0")
+	}
+
+	date := request.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	// Valorar el intercambio con lo recibido o, si no hay precio, con lo entregado. Los precios actuales
+	// solo valen para un intercambio de hoy: uno anterior necesita value_usd o el cierre guardado de ese día.
+	valueUSD := request.ValueUSD
+	if !valueUSD.IsPositive() {
+		if IsPastDay(date, time.Now()) {
+			return sell, buy, fmt.Errorf("no hay precio histórico de %s ni de %s para el %s, indica value_usd",
+				toTicker, fromTicker, date.Format("2006-01-02"))
+		}
+		if price, err := GetPrice(toTicker); err == nil && price > 0 {
+			valueUSD = request.ToAmount.Mul(decimal.NewFromFloat(price))
+		} else if price, err := GetPrice(fromTicker); err == nil && price > 0 {
+			valueUSD = request.FromAmount.Mul(decimal.NewFromFloat(price))
+		} else {
+			return sell, buy, fmt.Errorf("no se pudo valorar el intercambio, indica value_usd")
+		}
+	}
+
+	fromName, toName := request.FromCryptoName, request.ToCryptoName
+	if fromName == "" {
+		fromName = fromTicker
+	}
+	if toName == "" {
+		toName = toTicker
+	}
+
+	now := time.Now()
+	sell = models.CryptoTransaction{
+		UserID:        userID,
+		CryptoName:    fromName,
+		Ticker:        fromTicker,
+		Amount:        request.FromAmount,
+		PurchasePrice: valueUSD.Div(request.FromAmount),
+		Total:         valueUSD,
+		Date:          date,
+		Note:          request.Note,
+		CreatedAt:     now,
+		Type:          models.TransactionTypeSell,
+		SwapID:        swapID,
+		AccountID:     request.AccountID,
+	}
+	buy = models.CryptoTransaction{
+		UserID:        userID,
+		CryptoName:    toName,
+		Ticker:        toTicker,
+		Amount:        request.ToAmount,
+		PurchasePrice: valueUSD.Div(request.ToAmount),
+		Total:         valueUSD,
+		Date:          date,
+		Note:          request.Note,
+		CreatedAt:     now,
+		Type:          models.TransactionTypeBuy,
+		SwapID:        swapID,
+		AccountID:     request.AccountID,
+	}
+
+	// La comisión pagada con la moneda recibida reduce lo comprado; cualquier otra se carga a la venta
+	feeLeg := &sell
+	if strings.EqualFold(strings.TrimSpace(request.FeeCurrency), toTicker) {
+		feeLeg = &buy
+	}
+	feeLeg.Fee = request.Fee
+	feeLeg.FeeCurrency = request.FeeCurrency
+	feeLeg.FeeUSD = request.FeeUSD
+	if err := ResolveTransactionFee(feeLeg); err != nil {
+		return sell, buy, err
+	}
+
+	return sell, buy, nil
+}
+
+// IsPastDay indica si la fecha es de un día anterior al actual, en el que los precios actuales ya no sirven
+func IsPastDay(date, now time.Time) bool {
+	return dayUTC(date).Before(dayUTC(now))
+}
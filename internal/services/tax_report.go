@@ -0,0 +1,156 @@
+package services
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+)
+
+// BuildTaxReport arma el informe fiscal de un año con cada lote vendido en ese año
+// y, por separado, los ingresos recibidos en ese año
+func BuildTaxReport(ledger *LotLedger, year int) models.TaxReport {
+	report := models.TaxReport{
+		Year:      year,
+		Method:    ledger.Method,
+		Disposals: []models.TaxDisposal{},
+		Income:    []models.IncomeEvent{},
+	}
+
+	for _, income := range ledger.Income {
+		if income.ReceivedAt.UTC().Year() != year {
+			continue
+		}
+		report.Income = append(report.Income, income)
+		report.Totals.Income = report.Totals.Income.Add(income.Value)
+	}
+
+	for _, sale := range ledger.Realized {
+		if sale.SoldAt.UTC().Year() != year {
+			continue
+		}
+		for _, lot := range sale.Lots {
+			disposal := models.TaxDisposal{
+				SaleTransactionID: sale.TransactionID,
+				LotTransactionID:  lot.LotTransactionID,
+				Ticker:            sale.Ticker,
+				CryptoName:        sale.CryptoName,
+				Amount:            lot.Amount,
+				AcquiredAt:        lot.AcquiredAt,
+				DisposedAt:        sale.SoldAt,
+				Proceeds:          lot.Proceeds,
+				CostBasis:         lot.CostBasis,
+				Gain:              lot.Gain,
+				HoldingDays:       int(sale.SoldAt.Sub(lot.AcquiredAt).Hours() / 24),
+				HoldingPeriod:     holdingPeriod(lot.AcquiredAt, sale.SoldAt),
+			}
+			report.Disposals = append(report.Disposals, disposal)
+
+			report.Totals.Disposals++
+			report.Totals.Proceeds = report.Totals.Proceeds.Add(disposal.Proceeds)
+			report.Totals.CostBasis = report.Totals.CostBasis.Add(disposal.CostBasis)
+			report.Totals.Gain = report.Totals.Gain.Add(disposal.Gain)
+			if disposal.HoldingPeriod == models.HoldingPeriodLong {
+				report.Totals.LongTermGain = report.Totals.LongTermGain.Add(disposal.Gain)
+			} else {
+				report.Totals.ShortTermGain = report.Totals.ShortTermGain.Add(disposal.Gain)
+			}
+		}
+	}
+
+	return report
+}
+
+// holdingPeriod es largo plazo si el lote se vendió más de un año después de comprarlo
+func holdingPeriod(acquiredAt, disposedAt time.Time) string {
+	if disposedAt.After(acquiredAt.AddDate(1, 0, 0)) {
+		return models.HoldingPeriodLong
+	}
+	return models.HoldingPeriodShort
+}
+
+// WriteTaxReportCSV escribe el informe fiscal como CSV con una fila por lote vendido
+// y una fila con los totales; si hubo ingresos les sigue una sección con una fila por ingreso
+// y su total
+func WriteTaxReportCSV(writer io.Writer, report models.TaxReport) error {
+	csvWriter := csv.NewWriter(writer)
+
+	header := []string{
+		"sale_transaction_id", "lot_transaction_id", "ticker", "crypto_name", "amount",
+		"acquired_at", "disposed_at", "proceeds", "cost_basis", "gain", "holding_days", "holding_period",
+	}
+	if err := csvWriter.Write(header); err != nil {
+		return err
+	}
+
+	for _, disposal := range report.Disposals {
+		record := []string{
+			disposal.SaleTransactionID,
+			disposal.LotTransactionID,
+			disposal.Ticker,
+			disposal.CryptoName,
+			disposal.Amount.String(),
+			disposal.AcquiredAt.UTC().Format("2006-01-02"),
+			disposal.DisposedAt.UTC().Format("2006-01-02"),
+			disposal.Proceeds.String(),
+			disposal.CostBasis.String(),
+			disposal.Gain.String(),
+			strconv.Itoa(disposal.HoldingDays),
+			disposal.HoldingPeriod,
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+
+	totals := []string{
+		"TOTAL", "", "", "", "", "", "",
+		report.Totals.Proceeds.String(),
+		report.Totals.CostBasis.String(),
+		report.Totals.Gain.String(),
+		"", "",
+	}
+	if err := csvWriter.Write(totals); err != nil {
+		return err
+	}
+
+	if len(report.Income) > 0 {
+		if err := writeTaxIncomeCSV(csvWriter, report); err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// writeTaxIncomeCSV escribe la sección de ingresos del informe fiscal, separada de las ventas
+// por una fila vacía y con su propio encabezado
+func writeTaxIncomeCSV(csvWriter *csv.Writer, report models.TaxReport) error {
+	if err := csvWriter.Write([]string{""}); err != nil {
+		return err
+	}
+	header := []string{"income_transaction_id", "ticker", "crypto_name", "type", "amount", "received_at", "value"}
+	if err := csvWriter.Write(header); err != nil {
+		return err
+	}
+
+	for _, income := range report.Income {
+		record := []string{
+			income.TransactionID,
+			income.Ticker,
+			income.CryptoName,
+			income.Type,
+			income.Amount.String(),
+			income.ReceivedAt.UTC().Format("2006-01-02"),
+			income.Value.String(),
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+
+	return csvWriter.Write([]string{"TOTAL", "", "", "", "", "", report.Totals.Income.String()})
+}
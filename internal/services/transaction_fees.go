@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// ResolveTransactionFee normaliza la comisión de una transacción y calcula su valor en dólares.
+// Si la comisión se pagó en otra moneda y no se indicó fee_usd, se valora con su precio actual.
+func ResolveTransactionFee(transaction *models.CryptoTransaction) error {
+	if transaction.Fee.IsNegative() {
+		return fmt.Errorf("la comisión no puede ser negativa")
+	}
+	if transaction.Fee.IsZero() {
+		transaction.FeeCurrency = ""
+		transaction.FeeUSD = decimal.Zero
+		return nil
+	}
+
+	transaction.FeeCurrency = strings.ToUpper(strings.TrimSpace(transaction.FeeCurrency))
+	if transaction.FeeCurrency == "" {
+		transaction.FeeCurrency = models.FeeCurrencyUSD
+	}
+
+	switch {
+	case IsUSDFee(*transaction):
+		transaction.FeeUSD = transaction.Fee
+	case IsTradedCoinFee(*transaction):
+		if transaction.Type == models.TransactionTypeBuy && transaction.Fee.GreaterThanOrEqual(transaction.Amount) {
+			return fmt.Errorf("la comisión no puede ser mayor o igual a la cantidad comprada")
+		}
+		transaction.FeeUSD = transaction.Fee.Mul(transaction.PurchasePrice)
+	case !transaction.FeeUSD.IsPositive():
+		price, err := GetPrice(transaction.FeeCurrency)
+		if err != nil {
+			return fmt.Errorf("error al obtener precio de %s para valorar la comisión: %v", transaction.FeeCurrency, err)
+		}
+		transaction.FeeUSD = transaction.Fee.Mul(decimal.NewFromFloat(price))
+	}
+
+	return nil
+}
+
+// IsUSDFee indica si la comisión se pagó en dólares (o USDT, que se trata como dólares)
+func IsUSDFee(transaction models.CryptoTransaction) bool {
+	return transaction.FeeCurrency == models.FeeCurrencyUSD || transaction.FeeCurrency == "USDT"
+}
+
+// IsTradedCoinFee indica si la comisión se pagó en la misma criptomoneda operada
+func IsTradedCoinFee(transaction models.CryptoTransaction) bool {
+	return transaction.Fee.IsPositive() && strings.EqualFold(transaction.FeeCurrency, transaction.Ticker)
+}
+
+// IsOtherCoinFee indica si la comisión se pagó en una criptomoneda distinta a la operada (ej. BNB)
+func IsOtherCoinFee(transaction models.CryptoTransaction) bool {
+	return transaction.Fee.IsPositive() && transaction.FeeCurrency != "" && !IsUSDFee(transaction) && !IsTradedCoinFee(transaction)
+}
+
+// FeeCoin devuelve la moneda con la que se pagó la comisión cuando sale de las tenencias del usuario
+// (la criptomoneda operada, otra como BNB o USDT), o vacío si no hay comisión o se pagó en dólares
+func FeeCoin(transaction models.CryptoTransaction) string {
+	if !transaction.Fee.IsPositive() || transaction.FeeCurrency == "" || transaction.FeeCurrency == models.FeeCurrencyUSD {
+		return ""
+	}
+	return strings.ToUpper(transaction.FeeCurrency)
+}
@@ -0,0 +1,777 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// ParsedImport es el contenido de un CSV de transacciones ya interpretado
+type ParsedImport struct {
+	Format  string
+	Rows    []models.ImportRow
+	Skipped []models.ImportRowIssue
+	Errors  []models.ImportRowIssue
+}
+
+// Formatos de fecha aceptados en los CSV de los exchanges
+var importDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05 -0700 MST",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Monedas que se toman como dólares al importar
+var usdCurrencies = map[string]bool{
+	"USD": true, "USDT": true, "USDC": true, "BUSD": true, "FDUSD": true, "TUSD": true, "DAI": true,
+}
+
+// Monedas fiat que todavía no se pueden valorar en dólares
+var otherFiatCurrencies = map[string]bool{
+	"EUR": true, "GBP": true, "JPY": true, "CAD": true, "AUD": true, "CHF": true,
+	"ARS": true, "BRL": true, "MXN": true, "TRY": true,
+}
+
+// Monedas de cotización de Binance, de la más larga a la más corta para separar pares como BTCUSDT
+var binanceQuoteAssets = []string{
+	"FDUSD", "USDT", "USDC", "BUSD", "TUSD", "BTC", "ETH", "BNB", "EUR", "TRY", "BRL", "GBP", "DAI", "USD",
+}
+
+// Activos de Kraken con nombre propio
+var krakenAssetAliases = map[string]string{
+	"XXBT": "BTC", "XBT": "BTC", "XXDG": "DOGE", "XDG": "DOGE",
+	"XETH": "ETH", "XLTC": "LTC", "XXRP": "XRP", "XXLM": "XLM", "XETC": "ETC",
+	"XXMR": "XMR", "XZEC": "ZEC", "XREP": "REP", "XMLN": "MLN",
+	"ZUSD": "USD", "ZEUR": "EUR", "ZGBP": "GBP", "ZCAD": "CAD", "ZJPY": "JPY", "ZAUD": "AUD",
+}
+
+// Cantidad seguida del activo, como las columnas "0.5BTC" del historial de Binance
+var amountWithAssetPattern = regexp.MustCompile(`^([0-9.,]+)\s*([A-Za-z0-9]+)$`)
+
+// Nota de las conversiones de Coinbase: "Converted 0.01 BTC to 0.15 ETH"
+var coinbaseConvertPattern = regexp.MustCompile(`(?i)converted\s+([0-9.,]+)\s+(\S+)\s+to\s+([0-9.,]+)\s+(\S+)`)
+
+// ParseTransactionsCSV interpreta un CSV exportado de un exchange. Las filas inválidas se
+// informan en Errors y las de tipos que no son operaciones (depósitos, retiros...) en Skipped.
+// Solo se devuelve error si el archivo no se puede leer o no tiene el encabezado esperado.
+//
+// El formato generic usa las columnas date, type, ticker, amount, price, total, quote, fee,
+// fee_currency, note, crypto_name, to_ticker y to_amount; mapping permite indicar con qué
+// encabezado del archivo se corresponde cada una (ej. {"date": "Fecha"}).
+func ParseTransactionsCSV(reader io.Reader, format string, mapping map[string]string) (*ParsedImport, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	var records csvRecords
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error al leer el CSV: %v", err)
+		}
+		line, _ := csvReader.FieldPos(0)
+		records.rows = append(records.rows, record)
+		records.lines = append(records.lines, line)
+	}
+
+	var err error
+
+	parsed := &ParsedImport{Format: format}
+	switch format {
+	case models.ImportFormatBinance:
+		err = parseBinanceTrades(records, parsed)
+	case models.ImportFormatCoinbase:
+		err = parseCoinbaseReport(records, parsed)
+	case models.ImportFormatKraken:
+		err = parseKrakenLedger(records, parsed)
+	case models.ImportFormatGeneric:
+		err = parseGenericCSV(records, mapping, parsed)
+	default:
+		return nil, fmt.Errorf("formato de importación inválido %q, debe ser binance, coinbase, kraken o generic", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+// csvRecords son las filas de un CSV con el número de línea en que empieza cada una
+type csvRecords struct {
+	rows  [][]string
+	lines []int
+}
+
+// csvTable son las filas de datos de un CSV con sus columnas indexadas por nombre
+type csvTable struct {
+	columns map[string]int
+	rows    [][]string
+	lines   []int
+}
+
+// findCSVHeader busca la primera fila que contiene todas las columnas requeridas, saltando
+// los textos que algunos exchanges agregan antes del encabezado
+func findCSVHeader(records csvRecords, required ...string) (*csvTable, error) {
+	for i, record := range records.rows {
+		columns := make(map[string]int)
+		for j, name := range record {
+			columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = j
+		}
+		found := true
+		for _, name := range required {
+			if _, ok := columns[name]; !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			return &csvTable{columns: columns, rows: records.rows[i+1:], lines: records.lines[i+1:]}, nil
+		}
+	}
+	return nil, fmt.Errorf("no se encontró el encabezado esperado, el CSV debe tener las columnas %s", strings.Join(required, ", "))
+}
+
+// has indica si la tabla tiene una columna
+func (t *csvTable) has(column string) bool {
+	_, ok := t.columns[column]
+	return ok
+}
+
+// value devuelve el valor de una columna de una fila o vacío si no existe
+func (t *csvTable) value(row []string, column string) string {
+	i, ok := t.columns[column]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// isBlankRow indica si una fila no tiene ningún valor
+func isBlankRow(row []string) bool {
+	for _, value := range row {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// importTrade es una operación de un exchange expresada como par base/cotización
+type importTrade struct {
+	line        int
+	date        time.Time
+	buy         bool // Compra de base pagando con la moneda de cotización
+	base        string
+	baseAmount  decimal.Decimal
+	quote       string
+	quoteAmount decimal.Decimal
+	fee         decimal.Decimal
+	feeCurrency string
+	feeUSD      decimal.Decimal // Valor en dólares de la comisión, si el archivo lo indica
+	cryptoName  string
+	note        string
+}
+
+// row convierte la operación en una compra o venta si se cotiza en dólares, o en un
+// intercambio si ambas monedas son criptomonedas
+func (t importTrade) row() (models.ImportRow, error) {
+	base := strings.ToUpper(strings.TrimSpace(t.base))
+	quote := strings.ToUpper(strings.TrimSpace(t.quote))
+	feeCurrency := strings.ToUpper(strings.TrimSpace(t.feeCurrency))
+	if usdCurrencies[feeCurrency] {
+		feeCurrency = models.FeeCurrencyUSD
+	}
+
+	if base == "" || quote == "" {
+		return models.ImportRow{}, fmt.Errorf("no se pudo determinar el par operado")
+	}
+	if !t.baseAmount.IsPositive() || !t.quoteAmount.IsPositive() {
+		return models.ImportRow{}, fmt.Errorf("las cantidades de la operación deben ser mayores a 0")
+	}
+	if t.fee.IsNegative() {
+		return models.ImportRow{}, fmt.Errorf("la comisión no puede ser negativa")
+	}
+	if usdCurrencies[base] {
+		return models.ImportRow{}, fmt.Errorf("operación sin criptomoneda: %s/%s", base, quote)
+	}
+	if otherFiatCurrencies[quote] || otherFiatCurrencies[feeCurrency] {
+		return models.ImportRow{}, fmt.Errorf("moneda fiat no soportada: solo se aceptan operaciones en dólares")
+	}
+
+	if usdCurrencies[quote] {
+		transaction := &models.CryptoTransaction{
+			CryptoName:    t.cryptoName,
+			Ticker:        base,
+			Amount:        t.baseAmount,
+			PurchasePrice: t.quoteAmount.Div(t.baseAmount),
+			Total:         t.quoteAmount,
+			Date:          t.date,
+			Note:          t.note,
+			Type:          models.TransactionTypeBuy,
+			Fee:           t.fee,
+			FeeCurrency:   feeCurrency,
+			FeeUSD:        t.feeUSD,
+		}
+		if !t.buy {
+			transaction.Type = models.TransactionTypeSell
+			if quote == "USDT" {
+				transaction.USDTReceived = t.quoteAmount
+			}
+		}
+		return models.ImportRow{Line: t.line, Transaction: transaction}, nil
+	}
+
+	swap := &models.SwapRequest{
+		FromTicker:  base,
+		FromAmount:  t.baseAmount,
+		ToTicker:    quote,
+		ToAmount:    t.quoteAmount,
+		Date:        t.date,
+		Note:        t.note,
+		Fee:         t.fee,
+		FeeCurrency: feeCurrency,
+		FeeUSD:      t.feeUSD,
+	}
+	if t.buy {
+		swap.FromTicker, swap.FromAmount = quote, t.quoteAmount
+		swap.ToTicker, swap.ToAmount = base, t.baseAmount
+		swap.ToCryptoName = t.cryptoName
+	} else {
+		swap.FromCryptoName = t.cryptoName
+	}
+	return models.ImportRow{Line: t.line, Swap: swap}, nil
+}
+
+// addTrade agrega una operación al resultado o registra el error de su fila
+func (p *ParsedImport) addTrade(trade importTrade) {
+	row, err := trade.row()
+	if err != nil {
+		p.addError(trade.line, err)
+		return
+	}
+	p.Rows = append(p.Rows, row)
+}
+
+// addError registra una fila inválida
+func (p *ParsedImport) addError(line int, err error) {
+	p.Errors = append(p.Errors, models.ImportRowIssue{Line: line, Reason: err.Error()})
+}
+
+// addSkipped registra una fila que no es una operación
+func (p *ParsedImport) addSkipped(line int, reason string) {
+	p.Skipped = append(p.Skipped, models.ImportRowIssue{Line: line, Reason: reason})
+}
+
+// importNote es la nota con la que se marcan las transacciones importadas
+func importNote(exchange, reference string) string {
+	if reference == "" {
+		return "Importado de " + exchange
+	}
+	return fmt.Sprintf("Importado de %s (%s)", exchange, reference)
+}
+
+// parseBinanceTrades interpreta el historial de operaciones de Binance, tanto el formato actual
+// (Date(UTC), Pair, Side, Price, Executed, Amount, Fee) como el anterior (Market, Type, Amount,
+// Total, Fee, Fee Coin)
+func parseBinanceTrades(records csvRecords, parsed *ParsedImport) error {
+	table, err := findCSVHeader(records, "date(utc)")
+	if err != nil {
+		return err
+	}
+	current := table.has("pair") && table.has("executed")
+	if !current && !(table.has("market") && table.has("total")) {
+		return fmt.Errorf("el CSV de Binance debe tener las columnas Pair y Executed o Market y Total")
+	}
+
+	for i, record := range table.rows {
+		line := table.lines[i]
+		if isBlankRow(record) {
+			continue
+		}
+
+		date, err := parseImportDate(table.value(record, "date(utc)"))
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+		side := strings.ToUpper(table.value(record, "side"))
+		if !current {
+			side = strings.ToUpper(table.value(record, "type"))
+		}
+		if side != "BUY" && side != "SELL" {
+			parsed.addError(line, fmt.Errorf("lado de la operación inválido %q", side))
+			continue
+		}
+
+		trade := importTrade{line: line, date: date, buy: side == "BUY", note: importNote("Binance", "")}
+		if current {
+			trade.baseAmount, trade.base, err = parseAmountWithAsset(table.value(record, "executed"))
+			if err == nil {
+				trade.quoteAmount, trade.quote, err = parseAmountWithAsset(table.value(record, "amount"))
+			}
+			if err == nil && table.value(record, "fee") != "" {
+				trade.fee, trade.feeCurrency, err = parseAmountWithAsset(table.value(record, "fee"))
+			}
+		} else {
+			trade.base, trade.quote, err = splitBinancePair(table.value(record, "market"))
+			if err == nil {
+				trade.baseAmount, err = parseImportNumber(table.value(record, "amount"))
+			}
+			if err == nil {
+				trade.quoteAmount, err = parseImportNumber(table.value(record, "total"))
+			}
+			if err == nil {
+				trade.fee, err = parseImportNumber(table.value(record, "fee"))
+				trade.feeCurrency = table.value(record, "fee coin")
+			}
+		}
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+
+		parsed.addTrade(trade)
+	}
+	return nil
+}
+
+// parseCoinbaseReport interpreta el reporte de transacciones de Coinbase. Las compras y ventas
+// se importan como tales y las conversiones como intercambios; el resto de los tipos se omiten.
+func parseCoinbaseReport(records csvRecords, parsed *ParsedImport) error {
+	table, err := findCSVHeader(records, "timestamp", "transaction type", "asset", "quantity transacted")
+	if err != nil {
+		return err
+	}
+	currencyColumn, priceColumn := "spot price currency", "spot price at transaction"
+	if !table.has(currencyColumn) {
+		currencyColumn, priceColumn = "price currency", "price at transaction"
+	}
+
+	for i, record := range table.rows {
+		line := table.lines[i]
+		if isBlankRow(record) {
+			continue
+		}
+
+		kind := strings.ToLower(table.value(record, "transaction type"))
+		var buy bool
+		switch kind {
+		case "buy", "advanced trade buy":
+			buy = true
+		case "sell", "advanced trade sell", "convert":
+		default:
+			parsed.addSkipped(line, fmt.Sprintf("tipo de transacción no soportado %q", table.value(record, "transaction type")))
+			continue
+		}
+
+		date, err := parseImportDate(table.value(record, "timestamp"))
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+		if currency := strings.ToUpper(table.value(record, currencyColumn)); currency != "" && currency != "USD" {
+			parsed.addError(line, fmt.Errorf("moneda fiat no soportada %q: solo se aceptan reportes en dólares", currency))
+			continue
+		}
+
+		quantity, err := parseImportNumber(table.value(record, "quantity transacted"))
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+		price, err := parseImportNumber(table.value(record, priceColumn))
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+		subtotal, err := parseImportNumber(table.value(record, "subtotal"))
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+		fee, err := parseImportNumber(table.value(record, "fees and/or spread"))
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+		quantity, subtotal, fee = quantity.Abs(), subtotal.Abs(), fee.Abs()
+		if subtotal.IsZero() {
+			subtotal = quantity.Mul(price)
+		}
+
+		asset := table.value(record, "asset")
+		note := importNote("Coinbase", table.value(record, "id"))
+
+		if kind == "convert" {
+			match := coinbaseConvertPattern.FindStringSubmatch(table.value(record, "notes"))
+			if match == nil {
+				parsed.addError(line, fmt.Errorf("no se pudo leer la conversión de la nota %q", table.value(record, "notes")))
+				continue
+			}
+			fromAmount, errFrom := parseImportNumber(match[1])
+			toAmount, errTo := parseImportNumber(match[3])
+			if errFrom != nil || errTo != nil || !fromAmount.IsPositive() || !toAmount.IsPositive() {
+				parsed.addError(line, fmt.Errorf("cantidades de la conversión inválidas"))
+				continue
+			}
+			from, to := strings.ToUpper(match[2]), strings.ToUpper(match[4])
+			if usdCurrencies[from] || usdCurrencies[to] {
+				// Convertir desde o hacia un dólar digital es una compra o una venta
+				trade := importTrade{line: line, date: date, fee: fee, feeCurrency: models.FeeCurrencyUSD, note: note}
+				if usdCurrencies[from] {
+					trade.buy, trade.base, trade.baseAmount, trade.quote, trade.quoteAmount = true, to, toAmount, from, fromAmount
+				} else {
+					trade.base, trade.baseAmount, trade.quote, trade.quoteAmount = from, fromAmount, to, toAmount
+				}
+				parsed.addTrade(trade)
+				continue
+			}
+			if !subtotal.IsPositive() {
+				parsed.addError(line, fmt.Errorf("la conversión no tiene valor en dólares"))
+				continue
+			}
+			parsed.Rows = append(parsed.Rows, models.ImportRow{Line: line, Swap: &models.SwapRequest{
+				FromTicker:  from,
+				FromAmount:  fromAmount,
+				ToTicker:    to,
+				ToAmount:    toAmount,
+				ValueUSD:    subtotal,
+				Date:        date,
+				Note:        note,
+				Fee:         fee,
+				FeeCurrency: models.FeeCurrencyUSD,
+			}})
+			continue
+		}
+
+		parsed.addTrade(importTrade{
+			line:        line,
+			date:        date,
+			buy:         buy,
+			base:        asset,
+			baseAmount:  quantity,
+			quote:       "USD",
+			quoteAmount: subtotal,
+			fee:         fee,
+			feeCurrency: models.FeeCurrencyUSD,
+			note:        note,
+		})
+	}
+	return nil
+}
+
+// krakenEntry es una fila del ledger de Kraken
+type krakenEntry struct {
+	line   int
+	date   time.Time
+	asset  string
+	amount decimal.Decimal
+	fee    decimal.Decimal
+}
+
+// parseKrakenLedger interpreta el ledger de Kraken. Cada operación son dos filas con el mismo
+// refid: la moneda entregada (cantidad negativa) y la recibida (cantidad positiva).
+func parseKrakenLedger(records csvRecords, parsed *ParsedImport) error {
+	table, err := findCSVHeader(records, "txid", "refid", "time", "type", "asset", "amount", "fee")
+	if err != nil {
+		return err
+	}
+
+	groups := make(map[string][]krakenEntry)
+	var refs []string
+	for i, record := range table.rows {
+		line := table.lines[i]
+		if isBlankRow(record) {
+			continue
+		}
+		if table.value(record, "txid") == "" {
+			// Kraken repite sin txid los movimientos pendientes de confirmación
+			continue
+		}
+
+		kind := strings.ToLower(table.value(record, "type"))
+		if kind != "trade" && kind != "spend" && kind != "receive" {
+			parsed.addSkipped(line, fmt.Sprintf("tipo de movimiento no soportado %q", table.value(record, "type")))
+			continue
+		}
+
+		date, err := parseImportDate(table.value(record, "time"))
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+		amount, err := parseImportNumber(table.value(record, "amount"))
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+		fee, err := parseImportNumber(table.value(record, "fee"))
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+
+		refid := table.value(record, "refid")
+		if _, seen := groups[refid]; !seen {
+			refs = append(refs, refid)
+		}
+		groups[refid] = append(groups[refid], krakenEntry{
+			line:   line,
+			date:   date,
+			asset:  normalizeKrakenAsset(table.value(record, "asset")),
+			amount: amount,
+			fee:    fee,
+		})
+	}
+
+	for _, refid := range refs {
+		entries := groups[refid]
+		line := entries[0].line
+		if len(entries) != 2 || entries[0].amount.IsNegative() == entries[1].amount.IsNegative() {
+			parsed.addError(line, fmt.Errorf("la operación %s debe tener una fila entregada y una recibida", refid))
+			continue
+		}
+		spent, received := entries[0], entries[1]
+		if received.amount.IsNegative() {
+			spent, received = received, spent
+		}
+		if spent.fee.IsPositive() && received.fee.IsPositive() {
+			parsed.addError(line, fmt.Errorf("la operación %s tiene comisión en ambas monedas", refid))
+			continue
+		}
+		feeEntry := spent
+		if received.fee.IsPositive() {
+			feeEntry = received
+		}
+
+		trade := importTrade{
+			line:        line,
+			date:        spent.date,
+			fee:         feeEntry.fee,
+			feeCurrency: feeEntry.asset,
+			note:        importNote("Kraken", refid),
+		}
+		if usdCurrencies[received.asset] {
+			// Se recibieron dólares: es una venta de lo entregado
+			trade.base, trade.baseAmount = spent.asset, spent.amount.Abs()
+			trade.quote, trade.quoteAmount = received.asset, received.amount
+		} else {
+			trade.buy = true
+			trade.base, trade.baseAmount = received.asset, received.amount
+			trade.quote, trade.quoteAmount = spent.asset, spent.amount.Abs()
+		}
+		parsed.addTrade(trade)
+	}
+
+	sort.SliceStable(parsed.Errors, func(i, j int) bool { return parsed.Errors[i].Line < parsed.Errors[j].Line })
+	return nil
+}
+
+// Columnas del formato generic
+var genericImportColumns = []string{
+	"date", "type", "ticker", "amount", "price", "total", "quote", "fee", "fee_currency", "fee_usd",
+	"note", "crypto_name", "to_ticker", "to_amount",
+}
+
+// parseGenericCSV interpreta un CSV con columnas propias. Los tipos aceptados son compra/buy,
+// venta/sell e intercambio/swap; los intercambios usan to_ticker y to_amount y total como valor en dólares.
+func parseGenericCSV(records csvRecords, mapping map[string]string, parsed *ParsedImport) error {
+	headers := make(map[string]string, len(genericImportColumns))
+	for _, column := range genericImportColumns {
+		headers[column] = column
+	}
+	for column, header := range mapping {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if _, known := headers[column]; !known {
+			return fmt.Errorf("columna desconocida en el mapeo: %q", column)
+		}
+		headers[column] = strings.ToLower(strings.TrimSpace(header))
+	}
+
+	table, err := findCSVHeader(records, headers["date"], headers["type"], headers["ticker"], headers["amount"])
+	if err != nil {
+		return err
+	}
+	value := func(record []string, column string) string {
+		return table.value(record, headers[column])
+	}
+	number := func(record []string, column string) (decimal.Decimal, error) {
+		return parseImportNumber(value(record, column))
+	}
+
+	for i, record := range table.rows {
+		line := table.lines[i]
+		if isBlankRow(record) {
+			continue
+		}
+
+		date, err := parseImportDate(value(record, "date"))
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+		amount, err := number(record, "amount")
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+		price, err := number(record, "price")
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+		total, err := number(record, "total")
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+		fee, err := number(record, "fee")
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+		feeUSD, err := number(record, "fee_usd")
+		if err != nil {
+			parsed.addError(line, err)
+			continue
+		}
+		if total.IsZero() {
+			total = amount.Mul(price)
+		}
+
+		note := value(record, "note")
+		if note == "" {
+			note = importNote("CSV", "")
+		}
+
+		switch strings.ToLower(value(record, "type")) {
+		case "compra", "buy", "venta", "sell":
+			if !total.IsPositive() {
+				parsed.addError(line, fmt.Errorf("se debe indicar price o total"))
+				continue
+			}
+			quote := value(record, "quote")
+			if quote == "" {
+				quote = "USD"
+			}
+			kind := strings.ToLower(value(record, "type"))
+			parsed.addTrade(importTrade{
+				line:        line,
+				date:        date,
+				buy:         kind == "compra" || kind == "buy",
+				base:        value(record, "ticker"),
+				baseAmount:  amount,
+				quote:       quote,
+				quoteAmount: total,
+				fee:         fee,
+				feeCurrency: value(record, "fee_currency"),
+				feeUSD:      feeUSD,
+				cryptoName:  value(record, "crypto_name"),
+				note:        note,
+			})
+		case "intercambio", "swap":
+			toAmount, err := number(record, "to_amount")
+			if err != nil {
+				parsed.addError(line, err)
+				continue
+			}
+			parsed.Rows = append(parsed.Rows, models.ImportRow{Line: line, Swap: &models.SwapRequest{
+				FromTicker:     strings.ToUpper(value(record, "ticker")),
+				FromCryptoName: value(record, "crypto_name"),
+				FromAmount:     amount,
+				ToTicker:       strings.ToUpper(value(record, "to_ticker")),
+				ToAmount:       toAmount,
+				ValueUSD:       total,
+				Date:           date,
+				Note:           note,
+				Fee:            fee,
+				FeeCurrency:    value(record, "fee_currency"),
+				FeeUSD:         feeUSD,
+			}})
+		default:
+			parsed.addError(line, fmt.Errorf("tipo de transacción inválido %q", value(record, "type")))
+		}
+	}
+	return nil
+}
+
+// parseImportDate interpreta una fecha en cualquiera de los formatos aceptados o un timestamp Unix en segundos
+func parseImportDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range importDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date.UTC(), nil
+		}
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("fecha inválida %q", value)
+}
+
+// parseImportNumber interpreta un número quitando símbolos de moneda y separadores de miles.
+// Un valor vacío es 0.
+func parseImportNumber(value string) (decimal.Decimal, error) {
+	cleaned := strings.NewReplacer("$", "", ",", "", " ", "").Replace(strings.TrimSpace(value))
+	if cleaned == "" {
+		return decimal.Zero, nil
+	}
+	number, err := decimal.NewFromString(cleaned)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("número inválido %q", value)
+	}
+	return number, nil
+}
+
+// parseAmountWithAsset separa una cantidad de su activo, como "0.5BTC" o "12.3 USDT"
+func parseAmountWithAsset(value string) (decimal.Decimal, string, error) {
+	match := amountWithAssetPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return decimal.Zero, "", fmt.Errorf("cantidad inválida %q", value)
+	}
+	amount, err := parseImportNumber(match[1])
+	if err != nil {
+		return decimal.Zero, "", err
+	}
+	return amount, strings.ToUpper(match[2]), nil
+}
+
+// splitBinancePair separa un par de Binance como BTCUSDT en base y cotización
+func splitBinancePair(pair string) (string, string, error) {
+	pair = strings.ToUpper(strings.TrimSpace(pair))
+	for _, quote := range binanceQuoteAssets {
+		if strings.HasSuffix(pair, quote) && len(pair) > len(quote) {
+			return strings.TrimSuffix(pair, quote), quote, nil
+		}
+	}
+	return "", "", fmt.Errorf("par desconocido %q", pair)
+}
+
+// normalizeKrakenAsset traduce los nombres de activos de Kraken a tickers (XXBT -> BTC, ETH2.S -> ETH2)
+func normalizeKrakenAsset(asset string) string {
+	asset = strings.ToUpper(strings.TrimSpace(asset))
+	if i := strings.Index(asset, "."); i > 0 {
+		asset = asset[:i]
+	}
+	if alias, ok := krakenAssetAliases[asset]; ok {
+		return alias
+	}
+	return asset
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+)
+
+func TestParseImportDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "binance", value: "2024-01-15 10:30:45", want: time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)},
+		{name: "kraken con fracción de segundo", value: "2024-01-15 10:30:45.1234", want: time.Date(2024, 1, 15, 10, 30, 45, 123400000, time.UTC)},
+		{name: "rfc 3339 con zona se pasa a utc", value: "2024-01-15T10:30:45-03:00", want: time.Date(2024, 1, 15, 13, 30, 45, 0, time.UTC)},
+		{name: "coinbase con zona nombrada", value: "2024-01-15 10:30:45 UTC", want: time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)},
+		{name: "sin segundos", value: "2024-01-15 10:30", want: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)},
+		{name: "solo fecha", value: " 2024-01-15 ", want: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "timestamp unix", value: "1705314645", want: time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)},
+		{name: "día primero", value: "15/01/2024", wantErr: true},
+		{name: "vacía", value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseImportDate(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("fecha = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTransactionsCSV(t *testing.T) {
+	type wantRow struct {
+		txType      string // Tipo de la transacción, o models.TransactionTypeSwap
+		ticker      string // En los intercambios, la moneda entregada
+		amount      string
+		total       string // En los intercambios, la cantidad recibida
+		toTicker    string
+		fee         string
+		feeCurrency string
+		date        time.Time
+	}
+
+	tests := []struct {
+		name        string
+		format      string
+		mapping     map[string]string
+		csv         string
+		wantRows    []wantRow
+		wantErrors  int
+		wantSkipped int
+	}{
+		{
+			name:   "binance formato actual",
+			format: models.ImportFormatBinance,
+			csv: "Date(UTC),Pair,Side,Price,Executed,Amount,Fee\n" +
+				"2024-01-15 10:30:45,BTCUSDT,BUY,42000,0.5BTC,\"21,000USDT\",0.0005BTC\n" +
+				"2024-01-16 08:00:00,BTCUSDT,HOLD,42000,0.5BTC,21000USDT,0.0005BTC\n",
+			wantRows: []wantRow{{
+				txType: models.TransactionTypeBuy, ticker: "BTC", amount: "0.5", total: "21000",
+				fee: "0.0005", feeCurrency: "BTC", date: time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC),
+			}},
+			wantErrors: 1,
+		},
+		{
+			name:   "binance formato anterior con par entre criptomonedas",
+			format: models.ImportFormatBinance,
+			csv: "Date(UTC),Market,Type,Price,Amount,Total,Fee,Fee Coin\n" +
+				"2024-01-15 10:30:45,ETHBTC,SELL,0.05,2,0.1,0.0001,BNB\n",
+			wantRows: []wantRow{{
+				txType: models.TransactionTypeSwap, ticker: "ETH", amount: "2", total: "0.1", toTicker: "BTC",
+				fee: "0.0001", feeCurrency: "BNB", date: time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC),
+			}},
+		},
+		{
+			name:   "binance con fecha inválida",
+			format: models.ImportFormatBinance,
+			csv: "Date(UTC),Pair,Side,Price,Executed,Amount,Fee\n" +
+				"15/01/2024 10:30,BTCUSDT,BUY,42000,0.5BTC,21000USDT,0.0005BTC\n",
+			wantErrors: 1,
+		},
+		{
+			name:   "kraken compra y venta con fracción de segundo",
+			format: models.ImportFormatKraken,
+			csv: `"txid","refid","time","type","subtype","aclass","asset","amount","fee","balance"` + "\n" +
+				`"L1","T1","2024-01-15 10:30:45.1234","trade","","currency","ZUSD","-21000.0000","0.0000","0"` + "\n" +
+				`"L2","T1","2024-01-15 10:30:45.1234","trade","","currency","XXBT","0.5000000000","0.0000000000","0.5"` + "\n" +
+				`"L3","T2","2024-02-01 12:00:00","trade","","currency","XXBT","-0.1000000000","0.0000000000","0.4"` + "\n" +
+				`"L4","T2","2024-02-01 12:00:00","trade","","currency","ZUSD","4500.0000","10.0000","4490"` + "\n" +
+				`"L5","D1","2024-01-14 09:00:00","deposit","","currency","ZUSD","21000.0000","0.0000","21000"` + "\n" +
+				`"","T3","2024-02-02 12:00:00","trade","","currency","XXBT","-0.1","0","0.3"` + "\n",
+			wantRows: []wantRow{
+				{
+					txType: models.TransactionTypeBuy, ticker: "BTC", amount: "0.5", total: "21000",
+					fee: "0", feeCurrency: models.FeeCurrencyUSD, date: time.Date(2024, 1, 15, 10, 30, 45, 123400000, time.UTC),
+				},
+				{
+					txType: models.TransactionTypeSell, ticker: "BTC", amount: "0.1", total: "4500",
+					fee: "10", feeCurrency: models.FeeCurrencyUSD, date: time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC),
+				},
+			},
+			wantSkipped: 1,
+		},
+		{
+			name:   "kraken operación incompleta",
+			format: models.ImportFormatKraken,
+			csv: "txid,refid,time,type,subtype,aclass,asset,amount,fee,balance\n" +
+				"L1,T1,2024-01-15 10:30:45,trade,,currency,ZUSD,-21000,0,0\n",
+			wantErrors: 1,
+		},
+		{
+			name:    "generic con encabezados propios",
+			format:  models.ImportFormatGeneric,
+			mapping: map[string]string{"date": "Fecha", "type": "Tipo", "ticker": "Moneda", "amount": "Cantidad", "price": "Precio"},
+			csv: "Fecha,Tipo,Moneda,Cantidad,Precio\n" +
+				"2024-01-15,compra,btc,0.5,\"$42,000\"\n" +
+				"2024-01-16,regalo,btc,0.5,42000\n",
+			wantRows: []wantRow{{
+				txType: models.TransactionTypeBuy, ticker: "BTC", amount: "0.5", total: "21000",
+				fee: "0", date: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+			}},
+			wantErrors: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseTransactionsCSV(strings.NewReader(tt.csv), tt.format, tt.mapping)
+			if err != nil {
+				t.Fatalf("ParseTransactionsCSV: %v", err)
+			}
+			if len(parsed.Errors) != tt.wantErrors {
+				t.Errorf("errores = %v, se esperaban %d", parsed.Errors, tt.wantErrors)
+			}
+			if len(parsed.Skipped) != tt.wantSkipped {
+				t.Errorf("omitidas = %v, se esperaban %d", parsed.Skipped, tt.wantSkipped)
+			}
+			if len(parsed.Rows) != len(tt.wantRows) {
+				t.Fatalf("filas = %d, se esperaban %d", len(parsed.Rows), len(tt.wantRows))
+			}
+
+			for i, want := range tt.wantRows {
+				row := parsed.Rows[i]
+				if want.txType == models.TransactionTypeSwap {
+					if row.Swap == nil {
+						t.Fatalf("fila %d: se esperaba un intercambio", i)
+					}
+					if row.Swap.FromTicker != want.ticker || row.Swap.ToTicker != want.toTicker {
+						t.Errorf("fila %d: intercambio %s → %s, se esperaba %s → %s", i, row.Swap.FromTicker, row.Swap.ToTicker, want.ticker, want.toTicker)
+					}
+					checkDecimal(t, "from_amount", row.Swap.FromAmount, want.amount)
+					checkDecimal(t, "to_amount", row.Swap.ToAmount, want.total)
+					checkDecimal(t, "fee", row.Swap.Fee, want.fee)
+					if row.Swap.FeeCurrency != want.feeCurrency || !row.Swap.Date.Equal(want.date) {
+						t.Errorf("fila %d: comisión en %q del %v, se esperaba %q del %v", i, row.Swap.FeeCurrency, row.Swap.Date, want.feeCurrency, want.date)
+					}
+					continue
+				}
+
+				transaction := row.Transaction
+				if transaction == nil {
+					t.Fatalf("fila %d: se esperaba una transacción", i)
+				}
+				if transaction.Type != want.txType || transaction.Ticker != want.ticker {
+					t.Errorf("fila %d: %s de %s, se esperaba %s de %s", i, transaction.Type, transaction.Ticker, want.txType, want.ticker)
+				}
+				checkDecimal(t, "amount", transaction.Amount, want.amount)
+				checkDecimal(t, "total", transaction.Total, want.total)
+				checkDecimal(t, "fee", transaction.Fee, want.fee)
+				if transaction.FeeCurrency != want.feeCurrency || !transaction.Date.Equal(want.date) {
+					t.Errorf("fila %d: comisión en %q del %v, se esperaba %q del %v", i, transaction.FeeCurrency, transaction.Date, want.feeCurrency, want.date)
+				}
+			}
+		})
+	}
+}
+
+func TestParseTransactionsCSVInvalidFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		csv    string
+	}{
+		{name: "formato desconocido", format: "ftx", csv: "a,b\n1,2\n"},
+		{name: "binance sin encabezado", format: models.ImportFormatBinance, csv: "foo,bar\n1,2\n"},
+		{name: "kraken sin columnas", format: models.ImportFormatKraken, csv: "txid,refid,time\nL1,T1,2024-01-15\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseTransactionsCSV(strings.NewReader(tt.csv), tt.format, nil); err == nil {
+				t.Error("se esperaba un error")
+			}
+		})
+	}
+}
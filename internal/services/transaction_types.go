@@ -0,0 +1,47 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AgusMolinaCode/DCA_Api.git/internal/models"
+)
+
+// IncomeTransactionTypes son los tipos de transacción que registran un ingreso
+var IncomeTransactionTypes = []string{
+	models.TransactionTypeStakingReward,
+	models.TransactionTypeAirdrop,
+	models.TransactionTypeInterest,
+	models.TransactionTypeMining,
+	models.TransactionTypeGift,
+}
+
+// IsIncomeType indica si el tipo de transacción es un ingreso (staking, airdrop, interés, minería o regalo)
+func IsIncomeType(transactionType string) bool {
+	for _, incomeType := range IncomeTransactionTypes {
+		if transactionType == incomeType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAcquisitionType indica si el tipo de transacción aumenta las tenencias: una compra o un ingreso
+func IsAcquisitionType(transactionType string) bool {
+	return transactionType == models.TransactionTypeBuy || IsIncomeType(transactionType)
+}
+
+// NormalizeTransactionType valida el tipo de una transacción. Si está vacío devuelve compra.
+func NormalizeTransactionType(transactionType string) (string, error) {
+	transactionType = strings.ToLower(strings.TrimSpace(transactionType))
+	switch {
+	case transactionType == "":
+		return models.TransactionTypeBuy, nil
+	case transactionType == models.TransactionTypeBuy, transactionType == models.TransactionTypeSell,
+		transactionType == models.TransactionTypeTransfer, IsIncomeType(transactionType):
+		return transactionType, nil
+	default:
+		return "", fmt.Errorf("tipo de transacción inválido: %s (usa compra, venta, transferencia, %s)",
+			transactionType, strings.Join(IncomeTransactionTypes, ", "))
+	}
+}